@@ -0,0 +1,352 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	eks "github.com/aws/aws-sdk-go-v2/service/eks"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	iamtypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
+)
+
+// catalogueAddon is an optional addon that can be picked when creating a cluster
+type catalogueAddon struct {
+	Name        string
+	Description string
+	// Prepare creates the AWS resources the addon needs and returns the role its service account
+	// uses, "" when it needs none
+	Prepare func(ctx context.Context, region, clusterName string) (string, error)
+	// Install installs addons that are not EKS addons, with kubectl, given the role from Prepare.
+	// EKS addons leave it nil.
+	Install func(ctx context.Context, region, clusterName, roleArn string) error
+	// Cleanup removes what Prepare created once the cluster is deleted
+	Cleanup func(ctx context.Context, region, clusterName string) error
+	// Plugin marks an installer plugin, which is only cleaned up after the clusters it was
+	// installed on
+	Plugin bool
+}
+
+// addonCatalogue lists the optional addons, next to the defaultAddons every cluster can get
+var addonCatalogue = []catalogueAddon{
+	{
+		Name:        "aws-mountpoint-s3-csi-driver",
+		Description: "mount a sandbox S3 bucket as a volume",
+		Prepare:     prepareMountpointS3,
+		Cleanup:     cleanupMountpointS3,
+	},
+	{
+		Name:        "secrets-store-csi-driver",
+		Description: "mount a demo Secrets Manager secret into pods",
+		Prepare:     prepareSecretsStore,
+		Install:     installSecretsStore,
+		Cleanup:     cleanupSecretsStore,
+	},
+	{
+		Name:        "external-secrets",
+		Description: "sync Secrets Manager secrets and SSM parameters into Kubernetes Secrets",
+		Prepare:     prepareExternalSecrets,
+		Install:     installExternalSecrets,
+		Cleanup:     cleanupExternalSecrets,
+	},
+	{
+		Name:        "istio",
+		Description: "Istio service mesh with an ingress gateway and a demo app",
+		Install:     installIstio,
+	},
+	{
+		Name:        "kubecost",
+		Description: "cost of each namespace of the sandbox",
+		Install:     installKubecost,
+	},
+	{
+		Name:        "velero",
+		Description: "back up and restore the sandbox to a bucket removed with it",
+		Prepare:     prepareVelero,
+		Install:     installVelero,
+		Cleanup:     cleanupVelero,
+	},
+}
+
+// noOptionalAddons is the choice that installs none of the optional addons
+const noOptionalAddons = "none"
+
+func askOptionalAddons(ctx context.Context, a *createAnswers) error {
+	options := []string{noOptionalAddons}
+	descriptions := map[string]string{noOptionalAddons: "only the default addons"}
+	for _, addon := range addonCatalogue {
+		options = append(options, addon.Name)
+		descriptions[addon.Name] = addon.Description
+	}
+	selected := a.OptionalAddons
+	if len(selected) == 0 {
+		selected = []string{noOptionalAddons}
+	}
+	optionalPrompt := &survey.MultiSelect{
+		Message:     "Select optional addons to install:",
+		Options:     options,
+		Default:     selected,
+		Description: func(value string, index int) string { return descriptions[value] },
+	}
+	if err := askOne("EST_OPTIONAL_ADDONS", optionalPrompt, &selected); err != nil {
+		return wrapErr("reading optional addons", err)
+	}
+	a.OptionalAddons = nil
+	for _, name := range selected {
+		if name != noOptionalAddons {
+			a.OptionalAddons = append(a.OptionalAddons, name)
+		}
+	}
+	return nil
+}
+
+// catalogueEntry returns the catalogue entry of an addon
+func catalogueEntry(name string) (catalogueAddon, bool) {
+	for _, addon := range addonCatalogue {
+		if addon.Name == name {
+			return addon, true
+		}
+	}
+	return catalogueAddon{}, false
+}
+
+// eksCatalogueAddons returns the names that are EKS addons, whose versions AWS manages
+func eksCatalogueAddons(names []string) []string {
+	var eksNames []string
+	for _, name := range names {
+		if addon, ok := catalogueEntry(name); ok && addon.Install == nil {
+			eksNames = append(eksNames, name)
+		}
+	}
+	return eksNames
+}
+
+// kubectlCatalogueAddons returns the names that are installed with kubectl
+func kubectlCatalogueAddons(names []string) []string {
+	var kubectlNames []string
+	for _, name := range names {
+		if addon, ok := catalogueEntry(name); ok && addon.Install != nil {
+			kubectlNames = append(kubectlNames, name)
+		}
+	}
+	return kubectlNames
+}
+
+// InstallCatalogueAddons prepares and installs optional addons from the catalogue
+func InstallCatalogueAddons(ctx context.Context, region, clusterName, k8sVersion string, names []string) error {
+	cfg, err := loadAWSConfig(ctx, region)
+	if err != nil {
+		return fmt.Errorf("unable to load AWS configuration: %w", err)
+	}
+	client := eks.NewFromConfig(cfg)
+
+	eksNames := eksCatalogueAddons(names)
+	versions, err := ResolveAddonVersions(ctx, region, k8sVersion, eksNames)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		addon, ok := catalogueEntry(name)
+		if !ok {
+			return validationErr("%s is not in the addon catalogue", name)
+		}
+		var roleArn string
+		if addon.Prepare != nil {
+			if roleArn, err = addon.Prepare(ctx, region, clusterName); err != nil {
+				return fmt.Errorf("unable to prepare addon %s: %w", name, err)
+			}
+		}
+		if addon.Install != nil {
+			if err := addon.Install(ctx, region, clusterName, roleArn); err != nil {
+				return fmt.Errorf("failed to install %s: %w", name, err)
+			}
+			successf("Installed %s", name)
+			continue
+		}
+		input := &eks.CreateAddonInput{
+			ClusterName:  aws.String(clusterName),
+			AddonName:    aws.String(name),
+			AddonVersion: aws.String(versions[name]),
+		}
+		if roleArn != "" {
+			input.ServiceAccountRoleArn = aws.String(roleArn)
+		}
+		if _, err := client.CreateAddon(ctx, input); err != nil {
+			return fmt.Errorf("failed to install addon %s: %w", name, err)
+		}
+		infof("Requested addon %s %s", name, versions[name])
+	}
+
+	var failed []string
+	for _, name := range eksNames {
+		if !reportAddonStatus(ctx, region, clusterName, name) {
+			failed = append(failed, name)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("addons failed to install: %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// CleanupCatalogueAddons removes what optional addons needed outside the cluster. It runs after the
+// cluster is deleted and only warns, as leftovers do not block anything. plugins are the installer
+// plugins the cluster had, read from its tags before it was deleted; other plugins are not run.
+func CleanupCatalogueAddons(ctx context.Context, region, clusterName string, plugins []string) {
+	for _, addon := range addonCatalogue {
+		if addon.Cleanup == nil || (addon.Plugin && !slices.Contains(plugins, addon.Name)) {
+			continue
+		}
+		if err := addon.Cleanup(ctx, region, clusterName); err != nil {
+			warnf("Unable to clean up after addon %s: %v", addon.Name, err)
+		}
+	}
+}
+
+// Mountpoint for S3 runs as this service account, which gets the bucket role through IRSA
+const (
+	mountpointNamespace      = "kube-system"
+	mountpointServiceAccount = "s3-csi-driver-sa"
+	mountpointPolicyName     = "est-mountpoint-s3-bucket"
+)
+
+var invalidBucketChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// mountpointBucketName returns the sandbox bucket of a cluster
+func mountpointBucketName(clusterName, accountID, region string) string {
+	return sandboxBucketName("est-", clusterName, accountID, region)
+}
+
+// sandboxBucketName returns the name of a bucket created for a cluster. Bucket names are global, so
+// the account and region are part of it.
+func sandboxBucketName(prefix, clusterName, accountID, region string) string {
+	cluster := invalidBucketChars.ReplaceAllString(strings.ToLower(strings.TrimPrefix(clusterName, "Sandbox-")), "-")
+	suffix := "-" + accountID + "-" + region
+	if max := 63 - len(prefix) - len(suffix); len(cluster) > max {
+		cluster = cluster[:max]
+	}
+	return prefix + strings.Trim(cluster, "-") + suffix
+}
+
+// prepareMountpointS3 creates the sandbox bucket and a role that can read and write it
+func prepareMountpointS3(ctx context.Context, region, clusterName string) (string, error) {
+	accountID, _, err := GetAWSAccountDetails(ctx, region)
+	if err != nil {
+		return "", err
+	}
+	bucket := mountpointBucketName(clusterName, accountID, region)
+	if err := createBucket(ctx, region, bucket); err != nil {
+		return "", err
+	}
+
+	req := irsaRequest{
+		Region:         region,
+		Cluster:        clusterName,
+		Namespace:      mountpointNamespace,
+		ServiceAccount: mountpointServiceAccount,
+		RoleName:       irsaRoleName(clusterName, mountpointNamespace, mountpointServiceAccount),
+	}
+	roleArn, err := CreateIRSARole(ctx, req)
+	if err != nil {
+		return "", err
+	}
+	err = putRolePolicy(ctx, region, req.RoleName, mountpointPolicyName, []map[string]interface{}{
+		{
+			"Effect":   "Allow",
+			"Action":   []string{"s3:ListBucket"},
+			"Resource": "arn:aws:s3:::" + bucket,
+		},
+		{
+			"Effect":   "Allow",
+			"Action":   []string{"s3:GetObject", "s3:PutObject", "s3:AbortMultipartUpload", "s3:DeleteObject"},
+			"Resource": "arn:aws:s3:::" + bucket + "/*",
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	infof("Mount bucket %s with a PersistentVolume using the s3.csi.aws.com driver and volumeAttributes bucketName: %s", bucket, bucket)
+	return roleArn, nil
+}
+
+// cleanupMountpointS3 deletes the driver role and the sandbox bucket. A bucket that still holds
+// objects is kept, so experiments are not lost by deleting the cluster.
+func cleanupMountpointS3(ctx context.Context, region, clusterName string) error {
+	roleName := irsaRoleName(clusterName, mountpointNamespace, mountpointServiceAccount)
+	found, err := deleteAddonRole(ctx, region, roleName, mountpointPolicyName)
+	if err != nil || !found {
+		// Without the role the addon was never installed on this cluster
+		return err
+	}
+
+	accountID, _, err := GetAWSAccountDetails(ctx, region)
+	if err != nil {
+		return err
+	}
+	bucket := mountpointBucketName(clusterName, accountID, region)
+	if _, err := runAWSCLI(ctx, region, "s3", "rb", "s3://"+bucket); err != nil {
+		if strings.Contains(err.Error(), "BucketNotEmpty") {
+			warnf("Bucket %s still holds objects and was kept; delete it with: aws s3 rb s3://%s --force", bucket, bucket)
+			return nil
+		}
+		return fmt.Errorf("unable to delete bucket %s: %w", bucket, err)
+	}
+	successf("Deleted bucket %s", bucket)
+	return nil
+}
+
+// putRolePolicy puts an inline policy with the given statements on an addon role
+func putRolePolicy(ctx context.Context, region, roleName, policyName string, statements []map[string]interface{}) error {
+	policy, err := json.Marshal(map[string]interface{}{
+		"Version":   "2012-10-17",
+		"Statement": statements,
+	})
+	if err != nil {
+		return err
+	}
+	cfg, err := loadAWSConfig(ctx, region)
+	if err != nil {
+		return fmt.Errorf("unable to load AWS configuration: %w", err)
+	}
+	_, err = iam.NewFromConfig(cfg).PutRolePolicy(ctx, &iam.PutRolePolicyInput{
+		RoleName:       aws.String(roleName),
+		PolicyName:     aws.String(policyName),
+		PolicyDocument: aws.String(string(policy)),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to put policy %s on role %s: %w", policyName, roleName, err)
+	}
+	return nil
+}
+
+// deleteAddonRole deletes an addon role and its inline policy. It reports false when the role
+// does not exist.
+func deleteAddonRole(ctx context.Context, region, roleName, policyName string) (bool, error) {
+	cfg, err := loadAWSConfig(ctx, region)
+	if err != nil {
+		return false, fmt.Errorf("unable to load AWS configuration: %w", err)
+	}
+	iamClient := iam.NewFromConfig(cfg)
+	_, err = iamClient.DeleteRolePolicy(ctx, &iam.DeleteRolePolicyInput{
+		RoleName:   aws.String(roleName),
+		PolicyName: aws.String(policyName),
+	})
+	var noSuchEntity *iamtypes.NoSuchEntityException
+	if errors.As(err, &noSuchEntity) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("unable to remove policy %s from role %s: %w", policyName, roleName, err)
+	}
+	if _, err := iamClient.DeleteRole(ctx, &iam.DeleteRoleInput{RoleName: aws.String(roleName)}); err != nil {
+		return false, fmt.Errorf("unable to delete role %s: %w", roleName, err)
+	}
+	successf("Deleted role %s", roleName)
+	return true, nil
+}
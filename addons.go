@@ -0,0 +1,282 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	eks "github.com/aws/aws-sdk-go-v2/service/eks"
+)
+
+// addonInfo describes an addon installed on a cluster
+type addonInfo struct {
+	Name    string
+	Version string
+	Status  string
+}
+
+// runManageAddons lists the addons of a cluster and removes the ones the user selects
+func runManageAddons(ctx context.Context, timer *stepTimer) error {
+	if err := requireWritable("Manage Addons"); err != nil {
+		return err
+	}
+	var region string
+	promptRegion := &survey.Input{
+		Message: "Enter the AWS region:",
+		Default: regionDefault("eu-west-2"),
+	}
+	if err := askOne("EST_REGION", promptRegion, &region, survey.WithValidator(validRegion)); err != nil {
+		return wrapErr("reading region", err)
+	}
+	notifier.Region = region
+
+	clusters, err := ListEKSClusters(ctx, region)
+	if err != nil {
+		return wrapErr("fetching clusters", err)
+	}
+	if len(clusters) == 0 {
+		infof("No clusters found in the specified region.")
+		return nil
+	}
+	clusterName, err := askCluster(clusters, "Select the cluster whose addons to manage:")
+	if err != nil {
+		return err
+	}
+	notifier.Cluster = clusterName
+
+	addons, err := ListClusterAddons(ctx, region, clusterName)
+	if err != nil {
+		return wrapErr("listing addons", err)
+	}
+	if len(addons) == 0 {
+		infof("Cluster %s has no addons.", clusterName)
+		return nil
+	}
+
+	var options []string
+	for _, addon := range addons {
+		options = append(options, fmt.Sprintf("%s %s %s", addon.Name, addon.Version, addon.Status))
+	}
+	var selected []string
+	removePrompt := &survey.MultiSelect{
+		Message:  "Select the addons to remove:",
+		Options:  options,
+		PageSize: 15,
+	}
+	if err := askOne("EST_REMOVE_ADDONS", removePrompt, &selected); err != nil {
+		return wrapErr("reading addons to remove", err)
+	}
+	if err := requireInputs(); err != nil {
+		return err
+	}
+	if len(selected) == 0 {
+		infof("No addons selected, nothing was changed.")
+		return nil
+	}
+
+	var preserve bool
+	preservePrompt := &survey.Confirm{
+		Message: "Keep the addons' Kubernetes resources running on the cluster (only stop managing them with EKS)?",
+		Default: confirmDefault("EST_PRESERVE_ADDONS"),
+	}
+	if err := askOne("EST_PRESERVE_ADDONS", preservePrompt, &preserve); err != nil {
+		return wrapErr("reading preserve choice", err)
+	}
+
+	stopAddons := timer.Start("addons")
+	for _, choice := range selected {
+		name := strings.Fields(choice)[0]
+		if err := DeleteAddon(ctx, region, clusterName, name, preserve); err != nil {
+			return wrapErr("removing addon "+name, err)
+		}
+		if preserve {
+			successf("Removed addon %s from EKS management, its resources keep running", name)
+		} else {
+			successf("Removed addon %s", name)
+		}
+	}
+	stopAddons()
+	return nil
+}
+
+// addonVersions are the versions of an addon that support a Kubernetes version
+type addonVersions struct {
+	Name string
+	// Default is the version EKS installs when none is given
+	Default string
+	// Versions are all compatible versions, newest first
+	Versions []string
+}
+
+// runAddons implements `est addons versions`
+func runAddons(ctx context.Context, args []string) error {
+	usage := validationErr("usage: est addons versions [--cluster-version <version>] [--addon <name>...] [--region <region>]")
+	if len(args) == 0 || args[0] != "versions" {
+		return usage
+	}
+
+	var region, k8sVersion string
+	var names stringList
+	fs := flag.NewFlagSet("addons versions", flag.ContinueOnError)
+	fs.StringVar(&k8sVersion, "cluster-version", "", "Kubernetes version to list compatible addon versions for (default the latest EKS version)")
+	fs.Var(&names, "addon", "Addon to list (repeatable, default every addon)")
+	fs.StringVar(&region, "region", firstNonEmpty(os.Getenv("EST_REGION"), os.Getenv("AWS_REGION")), "Region to query")
+	if err := fs.Parse(args[1:]); err != nil {
+		return usage
+	}
+	if region == "" {
+		return validationErr("no region given: pass --region or set EST_REGION")
+	}
+	if k8sVersion == "" {
+		latest, err := GetLatestEKSVersion(ctx, region)
+		if err != nil {
+			return wrapErr("fetching latest EKS version", err)
+		}
+		k8sVersion = latest
+	}
+
+	addons, err := ListAddonVersions(ctx, region, k8sVersion, names)
+	if err != nil {
+		return wrapErr("listing addon versions", err)
+	}
+	if len(addons) == 0 {
+		infof("No addons support Kubernetes %s in %s.", k8sVersion, region)
+		return nil
+	}
+	infof("Addon versions compatible with Kubernetes %s in %s:", k8sVersion, region)
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ADDON\tDEFAULT\tVERSIONS")
+	for _, addon := range addons {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", addon.Name, firstNonEmpty(addon.Default, "-"), strings.Join(addon.Versions, ", "))
+	}
+	return tw.Flush()
+}
+
+// ListAddonVersions returns the addons that support a Kubernetes version with their compatible
+// versions, sorted by name. Without names every addon EKS offers is listed.
+func ListAddonVersions(ctx context.Context, region, k8sVersion string, names []string) ([]addonVersions, error) {
+	cfg, err := loadAWSConfig(ctx, region)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load AWS configuration: %w", err)
+	}
+	client := eks.NewFromConfig(cfg)
+
+	byName := map[string]*addonVersions{}
+	list := func(input *eks.DescribeAddonVersionsInput) error {
+		paginator := eks.NewDescribeAddonVersionsPaginator(client, input)
+		for paginator.HasMorePages() {
+			page, err := paginator.NextPage(ctx)
+			if err != nil {
+				return fmt.Errorf("unable to describe addon versions: %w", err)
+			}
+			for _, info := range page.Addons {
+				name := aws.ToString(info.AddonName)
+				// Versions are listed newest first
+				for _, version := range info.AddonVersions {
+					for _, compat := range version.Compatibilities {
+						if aws.ToString(compat.ClusterVersion) != k8sVersion {
+							continue
+						}
+						addon, ok := byName[name]
+						if !ok {
+							addon = &addonVersions{Name: name}
+							byName[name] = addon
+						}
+						addon.Versions = append(addon.Versions, aws.ToString(version.AddonVersion))
+						if compat.DefaultVersion {
+							addon.Default = aws.ToString(version.AddonVersion)
+						}
+					}
+				}
+			}
+		}
+		return nil
+	}
+	if len(names) == 0 {
+		if err := list(&eks.DescribeAddonVersionsInput{KubernetesVersion: aws.String(k8sVersion)}); err != nil {
+			return nil, err
+		}
+	}
+	for _, name := range names {
+		err := list(&eks.DescribeAddonVersionsInput{AddonName: aws.String(name), KubernetesVersion: aws.String(k8sVersion)})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var addons []addonVersions
+	for _, addon := range byName {
+		addons = append(addons, *addon)
+	}
+	sort.Slice(addons, func(i, j int) bool { return addons[i].Name < addons[j].Name })
+	return addons, nil
+}
+
+// ListClusterAddons returns the addons installed on a cluster with their version and status
+func ListClusterAddons(ctx context.Context, region, clusterName string) ([]addonInfo, error) {
+	cfg, err := loadAWSConfig(ctx, region)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load AWS configuration: %w", err)
+	}
+	client := eks.NewFromConfig(cfg)
+
+	var addons []addonInfo
+	paginator := eks.NewListAddonsPaginator(client, &eks.ListAddonsInput{ClusterName: aws.String(clusterName)})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("unable to list addons of %s: %w", clusterName, err)
+		}
+		for _, name := range page.Addons {
+			output, err := client.DescribeAddon(ctx, &eks.DescribeAddonInput{
+				ClusterName: aws.String(clusterName),
+				AddonName:   aws.String(name),
+			})
+			if err != nil {
+				return nil, fmt.Errorf("unable to describe addon %s: %w", name, err)
+			}
+			addons = append(addons, addonInfo{
+				Name:    name,
+				Version: aws.ToString(output.Addon.AddonVersion),
+				Status:  string(output.Addon.Status),
+			})
+		}
+	}
+	return addons, nil
+}
+
+// DeleteAddon removes an addon and waits until it is gone. With preserve the addon's Kubernetes
+// resources stay on the cluster and only EKS stops managing them.
+func DeleteAddon(ctx context.Context, region, clusterName, addonName string, preserve bool) error {
+	cfg, err := loadAWSConfig(ctx, region)
+	if err != nil {
+		return fmt.Errorf("unable to load AWS configuration: %w", err)
+	}
+	client := eks.NewFromConfig(cfg)
+
+	_, err = client.DeleteAddon(ctx, &eks.DeleteAddonInput{
+		ClusterName: aws.String(clusterName),
+		AddonName:   aws.String(addonName),
+		Preserve:    preserve,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete addon %s: %w", addonName, err)
+	}
+
+	waiter := eks.NewAddonDeletedWaiter(client)
+	err = waiter.Wait(ctx, &eks.DescribeAddonInput{
+		ClusterName: aws.String(clusterName),
+		AddonName:   aws.String(addonName),
+	}, 15*time.Minute)
+	if err != nil {
+		return fmt.Errorf("addon %s was not deleted: %w", addonName, err)
+	}
+	return nil
+}
@@ -1,13 +1,20 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"math/rand"
+	"net/url"
+	"slices"
 	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
 	ec2 "github.com/aws/aws-sdk-go-v2/service/ec2"
 	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
 	eks "github.com/aws/aws-sdk-go-v2/service/eks"
@@ -15,89 +22,337 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/iam"
 	iamtypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
 	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/smithy-go"
 )
 
 // GetAWSAccountDetails retrieves the AWS Account ID and the caller's identity (ARN) using the STS GetCallerIdentity API.
+// The result is cached for the process, and a lookup started by PrefetchAccountDetails is reused.
 func GetAWSAccountDetails(ctx context.Context, region string) (string, string, error) {
-	// Load default configuration with specified region
-	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(region))
-	if err != nil {
-		return "", "", fmt.Errorf("unable to load AWS configuration: %v", err)
+	lookup := lookupAccount(accountRole(ctx), region)
+	select {
+	case <-lookup.done:
+	case <-ctx.Done():
+		return "", "", ctx.Err()
 	}
+	return lookup.account, lookup.arn, lookup.err
+}
 
-	// Create STS client
-	stsClient := sts.NewFromConfig(cfg)
+// PrefetchAccountDetails starts looking up the account in the background, e.g. while the user is
+// still answering prompts
+func PrefetchAccountDetails(region string) {
+	lookupAccount("", region)
+}
 
-	// Call GetCallerIdentity to retrieve account information
-	output, err := stsClient.GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
-	if err != nil {
-		return "", "", fmt.Errorf("failed to get caller identity: %v", err)
+// accountLookup is a caller identity lookup that is in flight or done
+type accountLookup struct {
+	done         chan struct{}
+	account, arn string
+	err          error
+}
+
+var (
+	accountMu      sync.Mutex
+	accountLookups = map[string]*accountLookup{}
+)
+
+// lookupAccount returns the lookup for a region and account role, starting one unless a lookup is
+// in flight or succeeded
+func lookupAccount(role, region string) *accountLookup {
+	key := region + "|" + role
+	accountMu.Lock()
+	defer accountMu.Unlock()
+	if lookup, ok := accountLookups[key]; ok {
+		select {
+		case <-lookup.done:
+			if lookup.err == nil {
+				return lookup
+			}
+		default:
+			return lookup
+		}
 	}
 
-	// Return the Account ID and Caller Identity (ARN)
-	return aws.ToString(output.Account), aws.ToString(output.Arn), nil
+	lookup := &accountLookup{done: make(chan struct{})}
+	accountLookups[key] = lookup
+	go func() {
+		defer close(lookup.done)
+		ctx, cancel := context.WithTimeout(withAccountRole(context.Background(), role), time.Minute)
+		defer cancel()
+
+		// Load default configuration with specified region
+		cfg, err := loadAWSConfig(ctx, region)
+		if err != nil {
+			lookup.err = fmt.Errorf("unable to load AWS configuration: %w", err)
+			return
+		}
+
+		// Call GetCallerIdentity to retrieve account information
+		output, err := sts.NewFromConfig(cfg).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+		if err != nil {
+			lookup.err = fmt.Errorf("failed to get caller identity: %w", err)
+			return
+		}
+		lookup.account, lookup.arn = aws.ToString(output.Account), aws.ToString(output.Arn)
+	}()
+	return lookup
 }
 
-func IamOperations(ctx context.Context, region, roleName string) error {
+// IamOperations makes sure the cluster role exists and returns its ARN. role is either the name of a
+// role to create or reuse, which gets the EKS cluster policies attached, or the ARN of an existing
+// role, which is used as is. Existing roles must trust EKS. New roles trust EKS and the principals of
+// iam.extra_trust_principals.
+func IamOperations(ctx context.Context, region, role string) (string, error) {
 	// Load default AWS configuration
-	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(region))
+	cfg, err := loadAWSConfig(ctx, region)
 	if err != nil {
-		return fmt.Errorf("unable to load AWS configuration: %v", err)
+		return "", fmt.Errorf("unable to load AWS configuration: %w", err)
 	}
 
 	iamClient := iam.NewFromConfig(cfg)
+	partition := regionPartition(region)
 
-	// Define the assume role policy document
-	assumeRolePolicy := `{
-		"Version": "2012-10-17",
-		"Statement": [
-			{
-				"Effect": "Allow",
-				"Principal": {
-					"Service": "eks.amazonaws.com"
-				},
-				"Action": "sts:AssumeRole"
-			}
-		]
-	}`
+	if strings.HasPrefix(role, "arn:") {
+		roleName := role[strings.LastIndex(role, "/")+1:]
+		existing, err := iamClient.GetRole(ctx, &iam.GetRoleInput{RoleName: aws.String(roleName)})
+		if err != nil {
+			return "", fmt.Errorf("unable to read role %s: %w", role, err)
+		}
+		if aws.ToString(existing.Role.Arn) != role {
+			return "", validationErr("role %s does not exist in this account", role)
+		}
+		if err := checkClusterTrustPolicy(aws.ToString(existing.Role.AssumeRolePolicyDocument), region); err != nil {
+			return "", validationErr("role %s cannot be used as an EKS cluster role: %v", role, err)
+		}
+		infof("Using existing role %s", role)
+		return role, nil
+	}
+	roleName := role
+
+	principals := append([]string{servicePrincipal(partition, "eks")}, userConfig.IAM.ExtraTrustPrincipals...)
+	trustPolicy, err := assumeRolePolicy(principals...)
+	if err != nil {
+		return "", err
+	}
 
 	// Try to create the IAM role
-	_, err = iamClient.CreateRole(ctx, &iam.CreateRoleInput{
+	var roleArn string
+	createInput := &iam.CreateRoleInput{
 		RoleName:                 aws.String(roleName),
-		AssumeRolePolicyDocument: aws.String(assumeRolePolicy),
-	})
+		AssumeRolePolicyDocument: aws.String(trustPolicy),
+	}
+	if boundary := userConfig.IAM.PermissionsBoundary; boundary != "" {
+		createInput.PermissionsBoundary = aws.String(boundary)
+	}
+	created, err := iamClient.CreateRole(ctx, createInput)
 	if err != nil {
 		var alreadyExists *iamtypes.EntityAlreadyExistsException
 		if !errors.As(err, &alreadyExists) {
-			return fmt.Errorf("failed to create role %s: %v", roleName, err)
+			return "", fmt.Errorf("failed to create role %s: %w%s", roleName, err, boundaryHint(err))
+		}
+		existing, err := iamClient.GetRole(ctx, &iam.GetRoleInput{RoleName: aws.String(roleName)})
+		if err != nil {
+			return "", fmt.Errorf("unable to read role %s: %w", roleName, err)
+		}
+		document := aws.ToString(existing.Role.AssumeRolePolicyDocument)
+		if err := checkClusterTrustPolicy(document, region); err != nil {
+			if err := fixClusterTrustPolicy(ctx, iamClient, roleName, document, principals, err); err != nil {
+				return "", err
+			}
 		}
-		fmt.Printf("Role %s already exists. Proceeding...\n", roleName)
+		roleArn = aws.ToString(existing.Role.Arn)
+		infof("Role %s already exists. Proceeding...", roleName)
 	} else {
-		fmt.Printf("Successfully created role: %s\n", roleName)
+		roleArn = aws.ToString(created.Role.Arn)
+		successf("Successfully created role: %s", roleName)
 	}
 
 	// Attach the required policies
 	policies := []string{
-		"arn:aws:iam::aws:policy/AmazonEKSClusterPolicy",
-		"arn:aws:iam::aws:policy/AmazonEKSVPCResourceController",
+		managedPolicyArn(partition, "AmazonEKSClusterPolicy"),
+		managedPolicyArn(partition, "AmazonEKSVPCResourceController"),
+	}
+	policies = append(policies, userConfig.IAM.ExtraPolicyArns...)
+	if err := syncRolePolicies(ctx, iamClient, roleName, policies, true); err != nil {
+		return "", err
+	}
+
+	return roleArn, nil
+}
+
+// syncRolePolicies makes sure a role est manages has the given managed policies attached and, with
+// inline, the inline policy of the iam section. Policies already in place are left as they are, so
+// repeated runs change nothing. Policies est did not ask for are reported as drift but kept.
+func syncRolePolicies(ctx context.Context, iamClient *iam.Client, roleName string, policies []string, inline bool) error {
+	var attached []string
+	paginator := iam.NewListAttachedRolePoliciesPaginator(iamClient, &iam.ListAttachedRolePoliciesInput{RoleName: aws.String(roleName)})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return fmt.Errorf("unable to list policies of role %s: %w", roleName, err)
+		}
+		for _, policy := range page.AttachedPolicies {
+			attached = append(attached, aws.ToString(policy.PolicyArn))
+		}
 	}
 	for _, policyArn := range policies {
+		if slices.Contains(attached, policyArn) {
+			debugf("policy %s is already attached to role %s", policyArn, roleName)
+			continue
+		}
 		_, err := iamClient.AttachRolePolicy(ctx, &iam.AttachRolePolicyInput{
 			RoleName:  aws.String(roleName),
 			PolicyArn: aws.String(policyArn),
 		})
 		if err != nil {
-			return fmt.Errorf("failed to attach policy %s to role %s: %v", policyArn, roleName, err)
+			return fmt.Errorf("failed to attach policy %s to role %s: %w", policyArn, roleName, err)
+		}
+		successf("Attached policy %s to role %s", policyArn, roleName)
+	}
+	for _, policyArn := range attached {
+		if !slices.Contains(policies, policyArn) {
+			warnf("Role %s has policy %s attached, which est does not manage", roleName, policyArn)
 		}
-		fmt.Printf("Attached policy %s to role %s\n", policyArn, roleName)
 	}
 
+	var inlineNames []string
+	inlinePages := iam.NewListRolePoliciesPaginator(iamClient, &iam.ListRolePoliciesInput{RoleName: aws.String(roleName)})
+	for inlinePages.HasMorePages() {
+		page, err := inlinePages.NextPage(ctx)
+		if err != nil {
+			return fmt.Errorf("unable to list inline policies of role %s: %w", roleName, err)
+		}
+		inlineNames = append(inlineNames, page.PolicyNames...)
+	}
+	wantInline := inline && userConfig.IAM.InlinePolicyFile != ""
+	if wantInline {
+		document, err := userConfig.IAM.inlinePolicy()
+		if err != nil {
+			return err
+		}
+		if !slices.Contains(inlineNames, inlinePolicyName) || !inlinePolicyMatches(ctx, iamClient, roleName, document) {
+			_, err = iamClient.PutRolePolicy(ctx, &iam.PutRolePolicyInput{
+				RoleName:       aws.String(roleName),
+				PolicyName:     aws.String(inlinePolicyName),
+				PolicyDocument: aws.String(document),
+			})
+			if err != nil {
+				return fmt.Errorf("failed to put inline policy on role %s: %w", roleName, err)
+			}
+			successf("Put inline policy %s on role %s", inlinePolicyName, roleName)
+		}
+	}
+	for _, name := range inlineNames {
+		if !wantInline || name != inlinePolicyName {
+			warnf("Role %s has inline policy %s, which est does not manage", roleName, name)
+		}
+	}
 	return nil
 }
 
+// inlinePolicyMatches reports whether the inline policy est put on a role is document, ignoring
+// formatting
+func inlinePolicyMatches(ctx context.Context, iamClient *iam.Client, roleName, document string) bool {
+	current, err := iamClient.GetRolePolicy(ctx, &iam.GetRolePolicyInput{
+		RoleName:   aws.String(roleName),
+		PolicyName: aws.String(inlinePolicyName),
+	})
+	if err != nil {
+		return false
+	}
+	decoded, err := url.QueryUnescape(aws.ToString(current.PolicyDocument))
+	if err != nil {
+		return false
+	}
+	var have, want bytes.Buffer
+	if json.Compact(&have, []byte(decoded)) != nil || json.Compact(&want, []byte(document)) != nil {
+		return false
+	}
+	return have.String() == want.String()
+}
+
+// inlinePolicyName is the name of the inline policy est puts on the cluster and node roles
+const inlinePolicyName = "est-inline-policy"
+
+// boundaryHint explains a denied role creation that is most likely caused by a mandatory permissions boundary
+func boundaryHint(err error) string {
+	if userConfig.IAM.PermissionsBoundary == "" && strings.Contains(err.Error(), "AccessDenied") {
+		return " (if this account requires a permissions boundary on new roles, set iam.permissions_boundary in the config file)"
+	}
+	return ""
+}
+
+// trustStatement is the part of an IAM trust policy statement needed to check who may assume a role
+type trustStatement struct {
+	Effect    string
+	Principal json.RawMessage
+	Action    json.RawMessage
+}
+
+// checkClusterTrustPolicy verifies that a role's trust policy, as returned URL-encoded by IAM,
+// lets the EKS service assume the role in region, through its global or regional principal
+func checkClusterTrustPolicy(document, region string) error {
+	decoded, err := url.QueryUnescape(document)
+	if err != nil {
+		return fmt.Errorf("unable to decode trust policy: %w", err)
+	}
+	var policy struct {
+		Statement json.RawMessage
+	}
+	if err := json.Unmarshal([]byte(decoded), &policy); err != nil {
+		return fmt.Errorf("unable to parse trust policy: %w", err)
+	}
+	var statements []trustStatement
+	if err := json.Unmarshal(policy.Statement, &statements); err != nil {
+		// A policy with a single statement may use an object instead of a list
+		var single trustStatement
+		if err := json.Unmarshal(policy.Statement, &single); err != nil {
+			return fmt.Errorf("unable to parse trust policy statements: %w", err)
+		}
+		statements = []trustStatement{single}
+	}
+
+	for _, st := range statements {
+		if st.Effect != "Allow" {
+			continue
+		}
+		var principal struct {
+			Service json.RawMessage
+		}
+		if err := json.Unmarshal(st.Principal, &principal); err != nil {
+			continue
+		}
+		services := stringOrList(principal.Service)
+		if slices.ContainsFunc(eksPrincipals(region), func(p string) bool { return contains(services, p) }) &&
+			(contains(stringOrList(st.Action), "sts:AssumeRole") || contains(stringOrList(st.Action), "sts:*")) {
+			return nil
+		}
+	}
+	return fmt.Errorf("trust policy does not allow %s to call sts:AssumeRole", strings.Join(eksPrincipals(region), " or "))
+}
+
+// stringOrList decodes an IAM policy element that may be a single string or a list of strings
+func stringOrList(raw json.RawMessage) []string {
+	var one string
+	if err := json.Unmarshal(raw, &one); err == nil {
+		return []string{one}
+	}
+	var many []string
+	json.Unmarshal(raw, &many)
+	return many
+}
+
+func contains(values []string, want string) bool {
+	for _, v := range values {
+		if v == want {
+			return true
+		}
+	}
+	return false
+}
+
 // CreateVPC creates a new VPC with the provided CIDR and name
 func CreateVPC(ctx context.Context, region, cidr, name string) (string, error) {
-	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(region))
+	cfg, err := loadAWSConfig(ctx, region)
 	if err != nil {
 		return "", err
 	}
@@ -108,23 +363,25 @@ func CreateVPC(ctx context.Context, region, cidr, name string) (string, error) {
 		TagSpecifications: []ec2types.TagSpecification{
 			{
 				ResourceType: ec2types.ResourceTypeVpc,
-				Tags: []ec2types.Tag{
-					{Key: aws.String("Name"), Value: aws.String(name)},
-					{Key: aws.String("CreatedBy"), Value: aws.String("EKS-Sandbox-Tool")},
-				},
+				Tags:         ec2Tags(name),
 			},
 		},
 	})
 	if err != nil {
 		return "", err
 	}
+	vpcID := aws.ToString(output.Vpc.VpcId)
 
-	return aws.ToString(output.Vpc.VpcId), nil
+	waiter := ec2.NewVpcAvailableWaiter(client)
+	if err := waiter.Wait(ctx, &ec2.DescribeVpcsInput{VpcIds: []string{vpcID}}, 5*time.Minute); err != nil {
+		return "", fmt.Errorf("VPC %s did not become available: %w", vpcID, err)
+	}
+	return vpcID, nil
 }
 
 // CreateSubnet creates a subnet with the provided parameters
-func CreateSubnet(ctx context.Context, region, vpcID, cidr, name, azSuffix string) (string, error) {
-	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(region))
+func CreateSubnet(ctx context.Context, region, vpcID, cidr, name, az string) (string, error) {
+	cfg, err := loadAWSConfig(ctx, region)
 	if err != nil {
 		return "", err
 	}
@@ -133,27 +390,105 @@ func CreateSubnet(ctx context.Context, region, vpcID, cidr, name, azSuffix strin
 	output, err := client.CreateSubnet(ctx, &ec2.CreateSubnetInput{
 		VpcId:            aws.String(vpcID),
 		CidrBlock:        aws.String(cidr),
-		AvailabilityZone: aws.String(region + azSuffix),
+		AvailabilityZone: aws.String(az),
 		TagSpecifications: []ec2types.TagSpecification{
 			{
 				ResourceType: ec2types.ResourceTypeSubnet,
-				Tags: []ec2types.Tag{
-					{Key: aws.String("Name"), Value: aws.String(name)},
-					{Key: aws.String("CreatedBy"), Value: aws.String("EKS-Sandbox-Tool")},
-				},
+				Tags:         ec2Tags(name),
 			},
 		},
 	})
 	if err != nil {
 		return "", err
 	}
+	subnetID := aws.ToString(output.Subnet.SubnetId)
+
+	waiter := ec2.NewSubnetAvailableWaiter(client)
+	if err := waiter.Wait(ctx, &ec2.DescribeSubnetsInput{SubnetIds: []string{subnetID}}, 5*time.Minute); err != nil {
+		return "", fmt.Errorf("subnet %s did not become available: %w", subnetID, err)
+	}
+	return subnetID, nil
+}
+
+// AssociateVpcCidrBlock adds a secondary IPv4 CIDR block to the VPC and waits until it can be used
+func AssociateVpcCidrBlock(ctx context.Context, region, vpcID, cidr string) (string, error) {
+	cfg, err := loadAWSConfig(ctx, region)
+	if err != nil {
+		return "", err
+	}
+	client := ec2.NewFromConfig(cfg)
+
+	output, err := client.AssociateVpcCidrBlock(ctx, &ec2.AssociateVpcCidrBlockInput{
+		VpcId:     aws.String(vpcID),
+		CidrBlock: aws.String(cidr),
+	})
+	if err != nil {
+		return "", fmt.Errorf("unable to associate CIDR %s with VPC %s: %w", cidr, vpcID, err)
+	}
+	associationID := aws.ToString(output.CidrBlockAssociation.AssociationId)
+
+	err = pollUntil(ctx, "CIDR "+cidr, pollOptions{Interval: 2 * time.Second, Timeout: time.Minute}, func(ctx context.Context) (bool, string, error) {
+		vpcs, err := client.DescribeVpcs(ctx, &ec2.DescribeVpcsInput{VpcIds: []string{vpcID}})
+		if err != nil {
+			return false, "", fmt.Errorf("unable to describe VPC %s: %w", vpcID, err)
+		}
+		for _, assoc := range vpcs.Vpcs[0].CidrBlockAssociationSet {
+			if aws.ToString(assoc.AssociationId) != associationID || assoc.CidrBlockState == nil {
+				continue
+			}
+			switch state := assoc.CidrBlockState.State; state {
+			case ec2types.VpcCidrBlockStateCodeAssociated:
+				return true, string(state), nil
+			case ec2types.VpcCidrBlockStateCodeFailed:
+				return false, string(state), fmt.Errorf("association failed: %s", aws.ToString(assoc.CidrBlockState.StatusMessage))
+			default:
+				return false, string(state), nil
+			}
+		}
+		return false, "unknown", nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return associationID, nil
+}
+
+// DisassociateSecondaryCidrBlocks removes every IPv4 CIDR block of the VPC except its primary one
+func DisassociateSecondaryCidrBlocks(ctx context.Context, region, vpcID string) error {
+	cfg, err := loadAWSConfig(ctx, region)
+	if err != nil {
+		return fmt.Errorf("unable to load AWS configuration: %w", err)
+	}
+	client := ec2.NewFromConfig(cfg)
 
-	return aws.ToString(output.Subnet.SubnetId), nil
+	vpcs, err := client.DescribeVpcs(ctx, &ec2.DescribeVpcsInput{VpcIds: []string{vpcID}})
+	if err != nil {
+		return fmt.Errorf("unable to describe VPC %s: %w", vpcID, err)
+	}
+	if len(vpcs.Vpcs) == 0 {
+		return nil
+	}
+	vpc := vpcs.Vpcs[0]
+	for _, assoc := range vpc.CidrBlockAssociationSet {
+		cidr := aws.ToString(assoc.CidrBlock)
+		if cidr == aws.ToString(vpc.CidrBlock) || assoc.CidrBlockState == nil ||
+			assoc.CidrBlockState.State != ec2types.VpcCidrBlockStateCodeAssociated {
+			continue
+		}
+		_, err := client.DisassociateVpcCidrBlock(ctx, &ec2.DisassociateVpcCidrBlockInput{
+			AssociationId: assoc.AssociationId,
+		})
+		if err != nil {
+			return fmt.Errorf("unable to disassociate CIDR %s from VPC %s: %w", cidr, vpcID, err)
+		}
+		successf("Disassociated secondary CIDR %s", cidr)
+	}
+	return nil
 }
 
 // CreateInternetGateway creates and attaches an Internet Gateway to the VPC
 func CreateInternetGateway(ctx context.Context, region, name, vpcID string) (string, error) {
-	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(region))
+	cfg, err := loadAWSConfig(ctx, region)
 	if err != nil {
 		return "", err
 	}
@@ -164,10 +499,7 @@ func CreateInternetGateway(ctx context.Context, region, name, vpcID string) (str
 		TagSpecifications: []ec2types.TagSpecification{
 			{
 				ResourceType: ec2types.ResourceTypeInternetGateway,
-				Tags: []ec2types.Tag{
-					{Key: aws.String("Name"), Value: aws.String(name)},
-					{Key: aws.String("CreatedBy"), Value: aws.String("EKS-Sandbox-Tool")},
-				},
+				Tags:         ec2Tags(name),
 			},
 		},
 	})
@@ -191,7 +523,7 @@ func CreateInternetGateway(ctx context.Context, region, name, vpcID string) (str
 
 // CreateRouteTable creates a route table and associates it with the given VPC
 func CreateRouteTable(ctx context.Context, region, vpcID, name string) (string, error) {
-	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(region))
+	cfg, err := loadAWSConfig(ctx, region)
 	if err != nil {
 		return "", err
 	}
@@ -202,10 +534,7 @@ func CreateRouteTable(ctx context.Context, region, vpcID, name string) (string,
 		TagSpecifications: []ec2types.TagSpecification{
 			{
 				ResourceType: ec2types.ResourceTypeRouteTable,
-				Tags: []ec2types.Tag{
-					{Key: aws.String("Name"), Value: aws.String(name)},
-					{Key: aws.String("CreatedBy"), Value: aws.String("EKS-Sandbox-Tool")},
-				},
+				Tags:         ec2Tags(name),
 			},
 		},
 	})
@@ -218,7 +547,7 @@ func CreateRouteTable(ctx context.Context, region, vpcID, name string) (string,
 
 // CreateRoute creates a route to the Internet Gateway
 func CreateRoute(ctx context.Context, region, routeTableID, cidr, igwID string) error {
-	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(region))
+	cfg, err := loadAWSConfig(ctx, region)
 	if err != nil {
 		return err
 	}
@@ -232,9 +561,120 @@ func CreateRoute(ctx context.Context, region, routeTableID, cidr, igwID string)
 	return err
 }
 
+// CreateNatRoute adds a route through a NAT gateway to a route table
+func CreateNatRoute(ctx context.Context, region, routeTableID, cidr, natGatewayID string) error {
+	cfg, err := loadAWSConfig(ctx, region)
+	if err != nil {
+		return err
+	}
+	client := ec2.NewFromConfig(cfg)
+
+	_, err = client.CreateRoute(ctx, &ec2.CreateRouteInput{
+		RouteTableId:         aws.String(routeTableID),
+		DestinationCidrBlock: aws.String(cidr),
+		NatGatewayId:         aws.String(natGatewayID),
+	})
+	return err
+}
+
+// CreateNatGateway creates a NAT gateway with a new Elastic IP in a public subnet and waits until it is available.
+// The Elastic IP is tagged with the VPC so DeleteVPC releases it.
+func CreateNatGateway(ctx context.Context, region, vpcID, subnetID, name string) (string, error) {
+	cfg, err := loadAWSConfig(ctx, region)
+	if err != nil {
+		return "", err
+	}
+	client := ec2.NewFromConfig(cfg)
+
+	address, err := client.AllocateAddress(ctx, &ec2.AllocateAddressInput{
+		Domain: ec2types.DomainTypeVpc,
+		TagSpecifications: []ec2types.TagSpecification{
+			{
+				ResourceType: ec2types.ResourceTypeElasticIp,
+				Tags:         append(ec2Tags(name), ec2types.Tag{Key: aws.String("VpcId"), Value: aws.String(vpcID)}),
+			},
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("unable to allocate Elastic IP: %w", err)
+	}
+
+	output, err := client.CreateNatGateway(ctx, &ec2.CreateNatGatewayInput{
+		SubnetId:     aws.String(subnetID),
+		AllocationId: address.AllocationId,
+		TagSpecifications: []ec2types.TagSpecification{
+			{
+				ResourceType: ec2types.ResourceTypeNatgateway,
+				Tags:         ec2Tags(name),
+			},
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("unable to create NAT gateway: %w", err)
+	}
+	natID := aws.ToString(output.NatGateway.NatGatewayId)
+
+	waiter := ec2.NewNatGatewayAvailableWaiter(client)
+	if err := waiter.Wait(ctx, &ec2.DescribeNatGatewaysInput{NatGatewayIds: []string{natID}}, 10*time.Minute); err != nil {
+		return "", fmt.Errorf("NAT gateway %s did not become available: %w", natID, err)
+	}
+	return natID, nil
+}
+
+// ListAvailabilityZones returns the names of the availability zones of the region that are available, sorted
+func ListAvailabilityZones(ctx context.Context, region string) ([]string, error) {
+	cfg, err := loadAWSConfig(ctx, region)
+	if err != nil {
+		return nil, err
+	}
+	client := ec2.NewFromConfig(cfg)
+
+	output, err := client.DescribeAvailabilityZones(ctx, &ec2.DescribeAvailabilityZonesInput{
+		Filters: []ec2types.Filter{
+			{Name: aws.String("state"), Values: []string{"available"}},
+			{Name: aws.String("zone-type"), Values: []string{"availability-zone"}},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list availability zones: %w", err)
+	}
+	var zones []string
+	for _, zone := range output.AvailabilityZones {
+		zones = append(zones, aws.ToString(zone.ZoneName))
+	}
+	sort.Strings(zones)
+	return zones, nil
+}
+
+// ListLocalZones returns the Local Zones of a region the account has opted in to
+func ListLocalZones(ctx context.Context, region string) ([]string, error) {
+	cfg, err := loadAWSConfig(ctx, region)
+	if err != nil {
+		return nil, err
+	}
+	client := ec2.NewFromConfig(cfg)
+
+	output, err := client.DescribeAvailabilityZones(ctx, &ec2.DescribeAvailabilityZonesInput{
+		AllAvailabilityZones: aws.Bool(true),
+		Filters: []ec2types.Filter{
+			{Name: aws.String("zone-type"), Values: []string{"local-zone"}},
+			{Name: aws.String("opt-in-status"), Values: []string{"opted-in"}},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list local zones: %w", err)
+	}
+	var zones []string
+	for _, zone := range output.AvailabilityZones {
+		zones = append(zones, aws.ToString(zone.ZoneName))
+	}
+	sort.Strings(zones)
+	return zones, nil
+}
+
 // AssociateRouteTable associates a route table with a subnet
 func AssociateRouteTable(ctx context.Context, region, routeTableID, subnetID string) error {
-	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(region))
+	cfg, err := loadAWSConfig(ctx, region)
 	if err != nil {
 		return err
 	}
@@ -249,7 +689,7 @@ func AssociateRouteTable(ctx context.Context, region, routeTableID, subnetID str
 
 // ModifySubnetForPublicIP enables auto-assign public IP for a subnet
 func ModifySubnetForPublicIP(ctx context.Context, region, subnetID string) error {
-	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(region))
+	cfg, err := loadAWSConfig(ctx, region)
 	if err != nil {
 		return err
 	}
@@ -263,13 +703,47 @@ func ModifySubnetForPublicIP(ctx context.Context, region, subnetID string) error
 }
 
 // CreateSecurityGroup creates a security group in the given VPC
+//
+// Group names are unique per VPC. When a group with the name exists already, a group created by
+// this tool is reused and any other group is left alone by creating the new one with a suffix.
 func CreateSecurityGroup(ctx context.Context, region, vpcID, name, description string) (string, error) {
-	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(region))
+	cfg, err := loadAWSConfig(ctx, region)
 	if err != nil {
 		return "", err
 	}
 	client := ec2.NewFromConfig(cfg)
 
+	sgID, err := createSecurityGroup(ctx, client, vpcID, name, description)
+	var apiErr smithy.APIError
+	if err == nil || !errors.As(err, &apiErr) || apiErr.ErrorCode() != "InvalidGroup.Duplicate" {
+		return sgID, err
+	}
+
+	existing, err := client.DescribeSecurityGroups(ctx, &ec2.DescribeSecurityGroupsInput{
+		Filters: []ec2types.Filter{
+			{Name: aws.String("vpc-id"), Values: []string{vpcID}},
+			{Name: aws.String("group-name"), Values: []string{name}},
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("unable to look up existing security group %s: %w", name, err)
+	}
+	if len(existing.SecurityGroups) > 0 {
+		group := existing.SecurityGroups[0]
+		for _, tag := range group.Tags {
+			if aws.ToString(tag.Key) == "CreatedBy" && aws.ToString(tag.Value) == "EKS-Sandbox-Tool" {
+				infof("Reusing security group %s (%s) created by an earlier run", name, aws.ToString(group.GroupId))
+				return aws.ToString(group.GroupId), nil
+			}
+		}
+	}
+
+	unique := fmt.Sprintf("%s-%04x", name, rand.Intn(0x10000))
+	warnf("Security group %s already exists in %s and was not created by this tool, creating %s instead", name, vpcID, unique)
+	return createSecurityGroup(ctx, client, vpcID, unique, description)
+}
+
+func createSecurityGroup(ctx context.Context, client *ec2.Client, vpcID, name, description string) (string, error) {
 	output, err := client.CreateSecurityGroup(ctx, &ec2.CreateSecurityGroupInput{
 		GroupName:   aws.String(name),
 		Description: aws.String(description),
@@ -277,10 +751,7 @@ func CreateSecurityGroup(ctx context.Context, region, vpcID, name, description s
 		TagSpecifications: []ec2types.TagSpecification{
 			{
 				ResourceType: ec2types.ResourceTypeSecurityGroup,
-				Tags: []ec2types.Tag{
-					{Key: aws.String("Name"), Value: aws.String(name)},
-					{Key: aws.String("CreatedBy"), Value: aws.String("EKS-Sandbox-Tool")},
-				},
+				Tags:         ec2Tags(name),
 			},
 		},
 	})
@@ -291,9 +762,36 @@ func CreateSecurityGroup(ctx context.Context, region, vpcID, name, description s
 	return aws.ToString(output.GroupId), nil
 }
 
-// AuthorizeAllTraffic allows all inbound traffic for a security group
-func AuthorizeAllTraffic(ctx context.Context, region, sgID string) error {
-	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(region))
+// AuthorizeHTTPSWithinGroup allows HTTPS between members of a security group, e.g. from an SSM
+// instance to the private API server endpoints
+func AuthorizeHTTPSWithinGroup(ctx context.Context, region, sgID string) error {
+	cfg, err := loadAWSConfig(ctx, region)
+	if err != nil {
+		return err
+	}
+	client := ec2.NewFromConfig(cfg)
+
+	_, err = client.AuthorizeSecurityGroupIngress(ctx, &ec2.AuthorizeSecurityGroupIngressInput{
+		GroupId: aws.String(sgID),
+		IpPermissions: []ec2types.IpPermission{
+			{
+				IpProtocol:       aws.String("tcp"),
+				FromPort:         aws.Int32(443),
+				ToPort:           aws.Int32(443),
+				UserIdGroupPairs: []ec2types.UserIdGroupPair{{GroupId: aws.String(sgID)}},
+			},
+		},
+	})
+	return err
+}
+
+// AuthorizeAllTraffic allows all inbound traffic from the internet for a security group and tags it
+// with the OpenIngress marker. consent has to come from confirmOpenIngress for the same group.
+func AuthorizeAllTraffic(ctx context.Context, region, sgID string, consent openIngressConsent) error {
+	if consent.sgID != sgID {
+		return fmt.Errorf("opening security group %s to the internet was not confirmed", sgID)
+	}
+	cfg, err := loadAWSConfig(ctx, region)
 	if err != nil {
 		return err
 	}
@@ -310,24 +808,57 @@ func AuthorizeAllTraffic(ctx context.Context, region, sgID string) error {
 			},
 		},
 	})
-	return err
+	if err != nil {
+		return err
+	}
+
+	_, err = client.CreateTags(ctx, &ec2.CreateTagsInput{
+		Resources: []string{sgID},
+		Tags: []ec2types.Tag{
+			{Key: aws.String(openIngressTag), Value: aws.String("0.0.0.0/0")},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("unable to tag security group %s as open to the internet: %w", sgID, err)
+	}
+	warnf("Security group %s now allows all inbound traffic from the internet", sgID)
+	return nil
 }
 
 // CreateEKSCluster creates an EKS cluster with the provided parameters
-func CreateEKSCluster(ctx context.Context, region, clusterName, accountID string, subnetIDs, securityGroupIDs []string, k8sVersion string, vpcId string, autoMode bool) error {
-	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(region))
+// hostingVPC is "isolated" for a VPC created for the cluster, "shared" for the shared sandbox VPC and
+// "existing" for a reused one.
+// privateEndpoint makes the API server reachable from inside the VPC only.
+// zonalShift lets Application Recovery Controller shift traffic away from an impaired availability zone.
+// outpost, when not nil, creates a local cluster whose control plane runs on the Outpost.
+// encryptionKeyArn, when set, is the KMS key Kubernetes secrets are encrypted with.
+func CreateEKSCluster(ctx context.Context, region, clusterName, roleArn string, subnetIDs, securityGroupIDs []string, k8sVersion string, vpcId, hostingVPC string, autoMode, privateEndpoint, zonalShift, selfManagedAddons bool, outpost *types.OutpostConfigRequest, encryptionKeyArn string) error {
+	cfg, err := loadAWSConfig(ctx, region)
 	if err != nil {
 		return err
 	}
 	client := eks.NewFromConfig(cfg)
 
-	roleArn := fmt.Sprintf("arn:aws:iam::%s:role/EKSClusterRole", accountID)
-
 	tags := map[string]string{
 		"CreatedBy":  "EKS-Sandbox-Tool",
-		"HostingVPC": "isolated",
+		"HostingVPC": hostingVPC,
 		"VpcId":      vpcId,
 	}
+	if costCenter != "" {
+		tags[costCenterTag] = costCenter
+	}
+	if expiresAt := activeGuardrails.expiresAt(); expiresAt != "" {
+		tags["ExpiresAt"] = expiresAt
+	}
+	if clusterOwner != "" {
+		tags[ownerTag] = clusterOwner
+	}
+	if workshopEvent != "" {
+		tags[workshopEventTag] = workshopEvent
+	}
+	if poolName != "" {
+		tags[poolTag] = poolName
+	}
 
 	// Configure the cluster input
 	clusterInput := &eks.CreateClusterInput{
@@ -335,8 +866,10 @@ func CreateEKSCluster(ctx context.Context, region, clusterName, accountID string
 		Version: &k8sVersion,
 		RoleArn: aws.String(roleArn),
 		ResourcesVpcConfig: &types.VpcConfigRequest{
-			SubnetIds:        subnetIDs,
-			SecurityGroupIds: securityGroupIDs,
+			SubnetIds:             subnetIDs,
+			SecurityGroupIds:      securityGroupIDs,
+			EndpointPublicAccess:  aws.Bool(!privateEndpoint),
+			EndpointPrivateAccess: aws.Bool(privateEndpoint),
 		},
 		AccessConfig: &types.CreateAccessConfigRequest{
 			AuthenticationMode:                      "API_AND_CONFIG_MAP",
@@ -345,8 +878,26 @@ func CreateEKSCluster(ctx context.Context, region, clusterName, accountID string
 
 		Tags: tags,
 	}
+	if zonalShift {
+		clusterInput.ZonalShiftConfig = &types.ZonalShiftConfigRequest{Enabled: aws.Bool(true)}
+	}
+	if outpost != nil {
+		clusterInput.OutpostConfig = outpost
+	}
+	if encryptionKeyArn != "" {
+		clusterInput.EncryptionConfig = []types.EncryptionConfig{{
+			Resources: []string{"secrets"},
+			Provider:  &types.Provider{KeyArn: aws.String(encryptionKeyArn)},
+		}}
+	}
 
+	if !selfManagedAddons {
+		// Another CNI is installed in place of the vpc-cni EKS would otherwise bootstrap
+		clusterInput.BootstrapSelfManagedAddons = aws.Bool(false)
+	}
 	if autoMode {
+		// Auto mode replaces the self-managed vpc-cni, kube-proxy and coredns EKS would otherwise bootstrap
+		clusterInput.BootstrapSelfManagedAddons = aws.Bool(false)
 		clusterInput.ComputeConfig = &types.ComputeConfigRequest{
 			Enabled: aws.Bool(true), // Ensure Auto Mode is explicitly enabled
 		}
@@ -364,137 +915,300 @@ func CreateEKSCluster(ctx context.Context, region, clusterName, accountID string
 	// Create the EKS cluster
 	_, err = client.CreateCluster(ctx, clusterInput)
 	if err != nil {
-		return fmt.Errorf("failed to create EKS cluster: %v", err)
+		return fmt.Errorf("failed to create EKS cluster: %w", err)
 	}
 
-	fmt.Printf("EKS Cluster '%s' creation initiated with Kubernetes version %s \n", clusterName, k8sVersion)
+	successf("EKS Cluster '%s' creation initiated with Kubernetes version %s", clusterName, k8sVersion)
+
+	// Addons and everything after them need an active control plane
+	stepf("Waiting for the cluster to become active, this usually takes 10-15 minutes...")
+	waiter := eks.NewClusterActiveWaiter(client)
+	if err := waiter.Wait(ctx, &eks.DescribeClusterInput{Name: aws.String(clusterName)}, 30*time.Minute); err != nil {
+		return fmt.Errorf("cluster %s did not become active: %w", clusterName, err)
+	}
+	successf("EKS Cluster '%s' is active", clusterName)
 	return nil
 }
 
-// ListVPCs returns a list of VPC IDs
-func ListVPCs(ctx context.Context, region string) ([]string, error) {
-	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(region))
+// vpcInfo describes a VPC for selection prompts
+type vpcInfo struct {
+	ID   string
+	Name string
+	CIDR string
+	// OpenIngress lists security groups of the VPC that allow all inbound traffic from the internet
+	OpenIngress []string
+}
+
+// DescribeVPCs returns the VPCs of the region with their Name tag, primary CIDR and open security groups
+func DescribeVPCs(ctx context.Context, region string) ([]vpcInfo, error) {
+	cfg, err := loadAWSConfig(ctx, region)
 	if err != nil {
 		return nil, err
 	}
 	ec2Client := ec2.NewFromConfig(cfg)
 
-	output, err := ec2Client.DescribeVpcs(ctx, &ec2.DescribeVpcsInput{})
+	openGroups, err := ListOpenIngressGroups(ctx, region)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("unable to describe security groups: %w", err)
 	}
 
-	var vpcs []string
-	for _, vpc := range output.Vpcs {
-		vpcs = append(vpcs, aws.ToString(vpc.VpcId))
+	var vpcs []vpcInfo
+	paginator := ec2.NewDescribeVpcsPaginator(ec2Client, &ec2.DescribeVpcsInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("unable to describe VPCs: %w", err)
+		}
+		for _, vpc := range page.Vpcs {
+			vpcs = append(vpcs, vpcInfo{
+				ID:          aws.ToString(vpc.VpcId),
+				Name:        nameTag(vpc.Tags),
+				CIDR:        aws.ToString(vpc.CidrBlock),
+				OpenIngress: openGroups[aws.ToString(vpc.VpcId)],
+			})
+		}
 	}
 	return vpcs, nil
 }
 
-// ListSubnets returns a list of Subnet IDs for a given VPC
-func ListSubnets(ctx context.Context, region, vpcID string) ([]string, error) {
-	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(region))
+// subnetInfo describes a subnet for selection prompts. A subnet is public when its route table
+// sends 0.0.0.0/0 to an Internet Gateway.
+type subnetInfo struct {
+	ID     string
+	Name   string
+	AZ     string
+	CIDR   string
+	Public bool
+	// LocalZone is set for subnets in a Local Zone, which can host nodes but not the control plane
+	LocalZone bool
+	// OutpostArn is the Outpost of an Outpost subnet
+	OutpostArn string
+}
+
+// DescribeVPCSubnets returns the subnets of a VPC with their availability zone, CIDR and whether they are public
+func DescribeVPCSubnets(ctx context.Context, region, vpcID string) ([]subnetInfo, error) {
+	cfg, err := loadAWSConfig(ctx, region)
+	if err != nil {
+		return nil, err
+	}
+	ec2Client := ec2.NewFromConfig(cfg)
+	vpcFilter := []ec2types.Filter{{Name: aws.String("vpc-id"), Values: []string{vpcID}}}
+
+	routeTables, err := ec2Client.DescribeRouteTables(ctx, &ec2.DescribeRouteTablesInput{Filters: vpcFilter})
+	if err != nil {
+		return nil, fmt.Errorf("unable to describe route tables: %w", err)
+	}
+	// Subnets without an explicit association use the main route table
+	publicBySubnet := map[string]bool{}
+	mainIsPublic := false
+	for _, rtb := range routeTables.RouteTables {
+		public := false
+		for _, route := range rtb.Routes {
+			if aws.ToString(route.DestinationCidrBlock) == "0.0.0.0/0" && strings.HasPrefix(aws.ToString(route.GatewayId), "igw-") {
+				public = true
+			}
+		}
+		for _, assoc := range rtb.Associations {
+			if aws.ToBool(assoc.Main) {
+				mainIsPublic = public
+			} else if assoc.SubnetId != nil {
+				publicBySubnet[aws.ToString(assoc.SubnetId)] = public
+			}
+		}
+	}
+
+	localZones, err := ListLocalZones(ctx, region)
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := ec2Client.DescribeSubnets(ctx, &ec2.DescribeSubnetsInput{Filters: vpcFilter})
+	if err != nil {
+		return nil, fmt.Errorf("unable to describe subnets: %w", err)
+	}
+	var subnets []subnetInfo
+	for _, subnet := range output.Subnets {
+		id := aws.ToString(subnet.SubnetId)
+		public, ok := publicBySubnet[id]
+		if !ok {
+			public = mainIsPublic
+		}
+		subnets = append(subnets, subnetInfo{
+			ID:     id,
+			Name:   nameTag(subnet.Tags),
+			AZ:     aws.ToString(subnet.AvailabilityZone),
+			CIDR:   aws.ToString(subnet.CidrBlock),
+			Public: public,
+
+			LocalZone:  slices.Contains(localZones, aws.ToString(subnet.AvailabilityZone)),
+			OutpostArn: aws.ToString(subnet.OutpostArn),
+		})
+	}
+	sort.Slice(subnets, func(i, j int) bool {
+		if subnets[i].AZ != subnets[j].AZ {
+			return subnets[i].AZ < subnets[j].AZ
+		}
+		return subnets[i].CIDR < subnets[j].CIDR
+	})
+	return subnets, nil
+}
+
+// nameTag returns the value of the Name tag, if any
+func nameTag(tags []ec2types.Tag) string {
+	for _, tag := range tags {
+		if aws.ToString(tag.Key) == "Name" {
+			return aws.ToString(tag.Value)
+		}
+	}
+	return ""
+}
+
+// ec2Resource describes a network resource of a VPC as the List* functions return it. CIDR is only
+// set for subnets and State only for resources that have one.
+type ec2Resource struct {
+	ID    string            `json:"id"`
+	Name  string            `json:"name,omitempty"`
+	CIDR  string            `json:"cidr,omitempty"`
+	State string            `json:"state,omitempty"`
+	Tags  map[string]string `json:"tags,omitempty"`
+}
+
+// newEC2Resource returns the resource with an ID and tags, taking its name from the Name tag
+func newEC2Resource(id string, tags []ec2types.Tag) ec2Resource {
+	r := ec2Resource{ID: id, Name: nameTag(tags)}
+	if len(tags) > 0 {
+		r.Tags = make(map[string]string, len(tags))
+		for _, tag := range tags {
+			r.Tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+		}
+	}
+	return r
+}
+
+// resourceIDs returns the IDs of resources
+func resourceIDs(resources []ec2Resource) []string {
+	ids := make([]string, 0, len(resources))
+	for _, r := range resources {
+		ids = append(ids, r.ID)
+	}
+	return ids
+}
+
+// ListSubnets returns the subnets of a given VPC, narrowed by the scope filters
+func ListSubnets(ctx context.Context, region, vpcID string, scope ...ec2types.Filter) ([]ec2Resource, error) {
+	cfg, err := loadAWSConfig(ctx, region)
 	if err != nil {
 		return nil, err
 	}
 	ec2Client := ec2.NewFromConfig(cfg)
 
 	output, err := ec2Client.DescribeSubnets(ctx, &ec2.DescribeSubnetsInput{
-		Filters: []ec2types.Filter{
+		Filters: append([]ec2types.Filter{
 			{
 				Name:   aws.String("vpc-id"),
 				Values: []string{vpcID},
 			},
-		},
+		}, scope...),
 	})
 	if err != nil {
 		return nil, err
 	}
 
-	var subnets []string
+	var subnets []ec2Resource
 	for _, subnet := range output.Subnets {
-		subnets = append(subnets, aws.ToString(subnet.SubnetId))
+		r := newEC2Resource(aws.ToString(subnet.SubnetId), subnet.Tags)
+		r.CIDR, r.State = aws.ToString(subnet.CidrBlock), string(subnet.State)
+		subnets = append(subnets, r)
 	}
 	return subnets, nil
 }
 
-// ListInternetGateways returns a list of Internet Gateway IDs for a given VPC
-func ListInternetGateways(ctx context.Context, region, vpcID string) ([]string, error) {
-	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(region))
+// ListInternetGateways returns the Internet Gateways attached to a given VPC, narrowed by the scope filters
+func ListInternetGateways(ctx context.Context, region, vpcID string, scope ...ec2types.Filter) ([]ec2Resource, error) {
+	cfg, err := loadAWSConfig(ctx, region)
 	if err != nil {
 		return nil, err
 	}
 	ec2Client := ec2.NewFromConfig(cfg)
 
 	output, err := ec2Client.DescribeInternetGateways(ctx, &ec2.DescribeInternetGatewaysInput{
-		Filters: []ec2types.Filter{
+		Filters: append([]ec2types.Filter{
 			{
 				Name:   aws.String("attachment.vpc-id"),
 				Values: []string{vpcID},
 			},
-		},
+		}, scope...),
 	})
 	if err != nil {
 		return nil, err
 	}
 
-	var gateways []string
+	var gateways []ec2Resource
 	for _, igw := range output.InternetGateways {
-		gateways = append(gateways, aws.ToString(igw.InternetGatewayId))
+		r := newEC2Resource(aws.ToString(igw.InternetGatewayId), igw.Tags)
+		for _, attachment := range igw.Attachments {
+			if aws.ToString(attachment.VpcId) == vpcID {
+				r.State = string(attachment.State)
+			}
+		}
+		gateways = append(gateways, r)
 	}
 	return gateways, nil
 }
 
-// ListRouteTables returns a list of Route Table IDs for a given VPC
-func ListRouteTables(ctx context.Context, region, vpcID string) ([]string, error) {
-	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(region))
+// ListRouteTables returns the route tables of a given VPC, narrowed by the scope filters
+func ListRouteTables(ctx context.Context, region, vpcID string, scope ...ec2types.Filter) ([]ec2Resource, error) {
+	cfg, err := loadAWSConfig(ctx, region)
 	if err != nil {
 		return nil, err
 	}
 	ec2Client := ec2.NewFromConfig(cfg)
 
 	output, err := ec2Client.DescribeRouteTables(ctx, &ec2.DescribeRouteTablesInput{
-		Filters: []ec2types.Filter{
+		Filters: append([]ec2types.Filter{
 			{
 				Name:   aws.String("vpc-id"),
 				Values: []string{vpcID},
 			},
-		},
+		}, scope...),
 	})
 	if err != nil {
 		return nil, err
 	}
 
-	var routeTables []string
+	var routeTables []ec2Resource
 	for _, rtb := range output.RouteTables {
-		routeTables = append(routeTables, aws.ToString(rtb.RouteTableId))
+		routeTables = append(routeTables, newEC2Resource(aws.ToString(rtb.RouteTableId), rtb.Tags))
 	}
 	return routeTables, nil
 }
 
-// ListSecurityGroups returns a list of Security Group IDs for a given VPC
-func ListSecurityGroups(ctx context.Context, region, vpcID string) ([]string, error) {
-	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(region))
+// ListSecurityGroups returns the security groups of a given VPC, narrowed by the scope filters
+func ListSecurityGroups(ctx context.Context, region, vpcID string, scope ...ec2types.Filter) ([]ec2Resource, error) {
+	cfg, err := loadAWSConfig(ctx, region)
 	if err != nil {
 		return nil, err
 	}
 	ec2Client := ec2.NewFromConfig(cfg)
 
 	output, err := ec2Client.DescribeSecurityGroups(ctx, &ec2.DescribeSecurityGroupsInput{
-		Filters: []ec2types.Filter{
+		Filters: append([]ec2types.Filter{
 			{
 				Name:   aws.String("vpc-id"),
 				Values: []string{vpcID},
 			},
-		},
+		}, scope...),
 	})
 	if err != nil {
 		return nil, err
 	}
 
-	var securityGroups []string
+	var securityGroups []ec2Resource
 	for _, sg := range output.SecurityGroups {
-		securityGroups = append(securityGroups, aws.ToString(sg.GroupId))
+		r := newEC2Resource(aws.ToString(sg.GroupId), sg.Tags)
+		// Security groups have a name of their own, which the Name tag is often left unset beside
+		r.Name = firstNonEmpty(r.Name, aws.ToString(sg.GroupName))
+		securityGroups = append(securityGroups, r)
 	}
 	return securityGroups, nil
 }
@@ -502,9 +1216,9 @@ func ListSecurityGroups(ctx context.Context, region, vpcID string) ([]string, er
 // GetLatestEKSVersion fetches all available EKS versions and returns the latest one.
 func GetLatestEKSVersion(ctx context.Context, region string) (string, error) {
 	// Load AWS configuration
-	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(region))
+	cfg, err := loadAWSConfig(ctx, region)
 	if err != nil {
-		return "", fmt.Errorf("unable to load AWS configuration: %v", err)
+		return "", fmt.Errorf("unable to load AWS configuration: %w", err)
 	}
 	client := eks.NewFromConfig(cfg)
 
@@ -516,7 +1230,7 @@ func GetLatestEKSVersion(ctx context.Context, region string) (string, error) {
 	// Call DescribeClusterVersions
 	output, err := client.DescribeClusterVersions(ctx, input)
 	if err != nil {
-		return "", fmt.Errorf("failed to fetch EKS cluster versions: %v", err)
+		return "", fmt.Errorf("failed to fetch EKS cluster versions: %w", err)
 	}
 
 	if len(output.ClusterVersions) == 0 {
@@ -553,23 +1267,23 @@ func getLatestVersion(versions []string) string {
 }
 
 func ListEKSClusters(ctx context.Context, region string) ([]string, error) {
-	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(region))
+	cfg, err := loadAWSConfig(ctx, region)
 	if err != nil {
-		return nil, fmt.Errorf("unable to load AWS configuration: %v", err)
+		return nil, fmt.Errorf("unable to load AWS configuration: %w", err)
 	}
 	client := eks.NewFromConfig(cfg)
 
 	output, err := client.ListClusters(ctx, &eks.ListClustersInput{})
 	if err != nil {
-		return nil, fmt.Errorf("failed to list EKS clusters: %v", err)
+		return nil, fmt.Errorf("failed to list EKS clusters: %w", err)
 	}
 
 	return output.Clusters, nil
 }
 func CheckClusterTag(ctx context.Context, region, clusterName, tagName, tagValue string) (bool, error) {
-	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(region))
+	cfg, err := loadAWSConfig(ctx, region)
 	if err != nil {
-		return false, fmt.Errorf("unable to load AWS configuration: %v", err)
+		return false, fmt.Errorf("unable to load AWS configuration: %w", err)
 	}
 	client := eks.NewFromConfig(cfg)
 
@@ -577,7 +1291,7 @@ func CheckClusterTag(ctx context.Context, region, clusterName, tagName, tagValue
 		Name: aws.String(clusterName),
 	})
 	if err != nil {
-		return false, fmt.Errorf("failed to describe EKS cluster: %v", err)
+		return false, fmt.Errorf("failed to describe EKS cluster: %w", err)
 	}
 
 	// Check if the tag exists and matches the expected value
@@ -593,201 +1307,572 @@ func CheckClusterTag(ctx context.Context, region, clusterName, tagName, tagValue
 }
 
 func DeleteEKSCluster(ctx context.Context, region, clusterName string) error {
-	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(region))
+	cfg, err := loadAWSConfig(ctx, region)
 	if err != nil {
-		return fmt.Errorf("unable to load AWS configuration: %v", err)
+		return fmt.Errorf("unable to load AWS configuration: %w", err)
 	}
 	client := eks.NewFromConfig(cfg)
 
-	_, err = client.DeleteCluster(ctx, &eks.DeleteClusterInput{
-		Name: aws.String(clusterName),
+	status, err := settleClusterForDeletion(ctx, client, clusterName)
+	if err != nil {
+		return err
+	}
+	switch status {
+	case "":
+		infof("Cluster %s no longer exists", clusterName)
+		return nil
+	case types.ClusterStatusDeleting:
+		infof("Cluster %s is already being deleted", clusterName)
+		return waitForClusterDeleted(ctx, client, clusterName)
+	}
+
+	// Karpenter's controller runs on a node group, so its nodes go first
+	if err := RemoveKarpenterNodes(ctx, region, clusterName); err != nil {
+		return err
+	}
+	// EKS refuses to delete a cluster that still has managed node groups or Fargate profiles
+	if err := DeleteNodegroups(ctx, region, clusterName); err != nil {
+		return err
+	}
+	if err := DeleteFargateProfiles(ctx, region, clusterName); err != nil {
+		return err
+	}
+
+	// EKS reports the cluster in use while an update or an addon operation is still finishing
+	err = pollUntil(ctx, "deletion of cluster "+clusterName, pollOptions{Timeout: 15 * time.Minute}, func(ctx context.Context) (bool, string, error) {
+		_, err := client.DeleteCluster(ctx, &eks.DeleteClusterInput{
+			Name: aws.String(clusterName),
+		})
+		var inUse *types.ResourceInUseException
+		if errors.As(err, &inUse) {
+			return false, "in use: " + aws.ToString(inUse.Message), nil
+		}
+		if err != nil {
+			return false, "", fmt.Errorf("failed to delete EKS cluster: %w", err)
+		}
+		return true, "deleting", nil
 	})
 	if err != nil {
-		return fmt.Errorf("failed to delete EKS cluster: %v", err)
+		return err
 	}
+	return waitForClusterDeleted(ctx, client, clusterName)
+}
 
+// settleClusterForDeletion waits for a cluster that is being created or updated to finish, since EKS
+// refuses to delete it until then, and reports why a cluster failed. It returns the status the
+// cluster settled in, or "" when the cluster does not exist.
+func settleClusterForDeletion(ctx context.Context, client *eks.Client, clusterName string) (types.ClusterStatus, error) {
+	var status types.ClusterStatus
+	var issues []types.ClusterIssue
+	err := pollUntil(ctx, "cluster "+clusterName+" to settle before deletion", pollOptions{Timeout: 30 * time.Minute}, func(ctx context.Context) (bool, string, error) {
+		output, err := client.DescribeCluster(ctx, &eks.DescribeClusterInput{Name: aws.String(clusterName)})
+		var notFound *types.ResourceNotFoundException
+		if errors.As(err, &notFound) {
+			status = ""
+			return true, "not found", nil
+		}
+		if err != nil {
+			return false, "", fmt.Errorf("failed to describe EKS cluster %s: %w", clusterName, err)
+		}
+		status = output.Cluster.Status
+		if output.Cluster.Health != nil {
+			issues = output.Cluster.Health.Issues
+		}
+		switch status {
+		case types.ClusterStatusCreating, types.ClusterStatusUpdating:
+			return false, string(status), nil
+		}
+		return true, string(status), nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("cluster %s cannot be deleted until it leaves %s: %w", clusterName, status, err)
+	}
+	if status == types.ClusterStatusFailed {
+		warnf("Cluster %s failed and is deleted anyway", clusterName)
+	}
+	for _, issue := range issues {
+		warnf("Cluster health issue %s: %s", issue.Code, aws.ToString(issue.Message))
+	}
+	return status, nil
+}
+
+// waitForClusterDeleted waits until the control plane is gone, as it holds network interfaces in
+// the VPC until then
+func waitForClusterDeleted(ctx context.Context, client *eks.Client, clusterName string) error {
+	stepf("Waiting for the cluster to be deleted...")
+	waiter := eks.NewClusterDeletedWaiter(client)
+	if err := waiter.Wait(ctx, &eks.DescribeClusterInput{Name: aws.String(clusterName)}, 30*time.Minute); err != nil {
+		return fmt.Errorf("cluster %s was not deleted: %w", clusterName, err)
+	}
 	return nil
 }
 
-// DeleteVPC deletes a VPC by its VPC ID.
-func DeleteVPC(ctx context.Context, region, vpcID string) error {
+// DeleteVPC deletes the resources the tool created in a VPC for clusterName, found by their
+// CreatedBy and ClusterName tags, and then the VPC itself when it was created for the cluster.
+// Resources others added to the VPC are left alone.
+func DeleteVPC(ctx context.Context, region, vpcID, clusterName string) error {
 	// Load AWS configuration
-	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(region))
+	cfg, err := loadAWSConfig(ctx, region)
 	if err != nil {
-		return fmt.Errorf("unable to load AWS configuration: %v", err)
+		return fmt.Errorf("unable to load AWS configuration: %w", err)
 	}
 	ec2Client := ec2.NewFromConfig(cfg)
-	//Describe network interfaces, for each network interface, detach and delete
-	eniOutput, err := ec2Client.DescribeNetworkInterfaces(ctx, &ec2.DescribeNetworkInterfacesInput{
-		// list enis in the vpc
-		Filters: []ec2types.Filter{
-			{
-				Name:   aws.String("vpc-id"),
-				Values: []string{vpcID},
-			},
-		},
-	})
+
+	scope, ownsVPC, err := vpcTeardownScope(ctx, ec2Client, vpcID, clusterName)
+	if err != nil {
+		return err
+	}
+
+	if ownsVPC {
+		// Remove flow logs and their destinations, which outlive the VPC otherwise
+		if err := DeleteFlowLogs(ctx, region, vpcID); err != nil {
+			return err
+		}
+
+		// Terminate SSM instances, whose network interfaces cannot be deleted while they run
+		if err := DeleteSSMBastions(ctx, region, vpcID); err != nil {
+			return err
+		}
+	}
+
+	// Delete NAT gateways first; their Elastic IPs only become releasable once they are gone
+	natAllocationIDs, err := DeleteNatGateways(ctx, region, vpcID, scope...)
 	if err != nil {
-		return fmt.Errorf("unable to describe network interfaces: %v", err)
+		return fmt.Errorf("unable to delete NAT gateways: %w", err)
 	}
-	if len(eniOutput.NetworkInterfaces) == 0 {
-		fmt.Println("No network interfaces found")
+
+	subnetResources, err := ListSubnets(ctx, region, vpcID, scope...)
+	if err != nil {
+		return fmt.Errorf("unable to list subnets: %w", err)
 	}
-	for _, eni := range eniOutput.NetworkInterfaces {
-		_, err = ec2Client.DetachNetworkInterface(ctx, &ec2.DetachNetworkInterfaceInput{
-			AttachmentId: eni.Attachment.AttachmentId,
-			Force:        aws.Bool(true),
+	subnets := resourceIDs(subnetResources)
+
+	// Collect Elastic IPs before the network interfaces they are attached to disappear
+	addresses, err := ListVPCElasticIPs(ctx, region, vpcID, subnets, scope...)
+	if err != nil {
+		return fmt.Errorf("unable to list Elastic IPs: %w", err)
+	}
+	allocationIDs := natAllocationIDs
+	for _, addr := range addresses {
+		if addr.AssociationId != nil {
+			_, err = ec2Client.DisassociateAddress(ctx, &ec2.DisassociateAddressInput{
+				AssociationId: addr.AssociationId,
+			})
+			if err != nil {
+				warnf("unable to disassociate Elastic IP %s: %v", aws.ToString(addr.PublicIp), err)
+			}
+		}
+		allocationIDs = append(allocationIDs, aws.ToString(addr.AllocationId))
+	}
+
+	// Teardown runs in phases that respect dependencies; within a phase independent resources are
+	// deleted concurrently. Network interfaces go first, as they keep subnets and security groups in use.
+	// Interfaces in the cluster's subnets are removed whoever created them, since the subnets cannot
+	// be deleted otherwise.
+	attachments := map[string]*string{}
+	var eniIDs []string
+	if len(subnets) > 0 {
+		eniOutput, err := ec2Client.DescribeNetworkInterfaces(ctx, &ec2.DescribeNetworkInterfacesInput{
+			Filters: []ec2types.Filter{
+				{
+					Name:   aws.String("subnet-id"),
+					Values: subnets,
+				},
+			},
 		})
 		if err != nil {
-			fmt.Printf("unable to detach network interface %s: %v", *eni.NetworkInterfaceId, err)
-			continue
+			return fmt.Errorf("unable to describe network interfaces: %w", err)
+		}
+		for _, eni := range eniOutput.NetworkInterfaces {
+			eniID := aws.ToString(eni.NetworkInterfaceId)
+			eniIDs = append(eniIDs, eniID)
+			if eni.Attachment != nil {
+				attachments[eniID] = eni.Attachment.AttachmentId
+			}
+		}
+	}
+	if len(eniIDs) == 0 {
+		infof("No network interfaces found")
+	}
+	// Interfaces that cannot be removed only warn; deleting what depends on them reports the real problem
+	_ = forEachParallel(eniIDs, teardownWorkers, func(eniID string) error {
+		if attachmentID := attachments[eniID]; attachmentID != nil {
+			_, err := ec2Client.DetachNetworkInterface(ctx, &ec2.DetachNetworkInterfaceInput{
+				AttachmentId: attachmentID,
+				Force:        aws.Bool(true),
+			})
+			if err != nil {
+				warnf("unable to detach network interface %s: %v", eniID, err)
+				return err
+			}
 		}
-		_, err = ec2Client.DeleteNetworkInterface(ctx, &ec2.DeleteNetworkInterfaceInput{
-			NetworkInterfaceId: eni.NetworkInterfaceId,
+		_, err := ec2Client.DeleteNetworkInterface(ctx, &ec2.DeleteNetworkInterfaceInput{
+			NetworkInterfaceId: aws.String(eniID),
 		})
 		if err != nil {
-			fmt.Printf("unable to delete network interface %s: %v", *eni.NetworkInterfaceId, err)
-			continue
+			warnf("unable to delete network interface %s: %v", eniID, err)
+		}
+		return err
+	})
+
+	// Release Elastic IPs, otherwise the Internet Gateway cannot be detached and they keep accruing charges
+	if err := ReleaseElasticIPs(ctx, region, allocationIDs); err != nil {
+		return err
+	}
+
+	igwResources, err := ListInternetGateways(ctx, region, vpcID, scope...)
+	if err != nil {
+		return fmt.Errorf("unable to list Internet Gateways: %w", err)
+	}
+	routeTableResources, err := ListRouteTables(ctx, region, vpcID, scope...)
+	if err != nil {
+		return fmt.Errorf("unable to list route tables: %w", err)
+	}
+	groupResources, err := ListSecurityGroups(ctx, region, vpcID, scope...)
+	if err != nil {
+		return fmt.Errorf("unable to list security groups: %w", err)
+	}
+	igws, routeTables, securityGroups := resourceIDs(igwResources), resourceIDs(routeTableResources), resourceIDs(groupResources)
+	// The AWS Load Balancer Controller tags the security groups it creates with the cluster
+	controllerGroups, err := ListSecurityGroups(ctx, region, vpcID, ec2types.Filter{
+		Name:   aws.String("tag:elbv2.k8s.aws/cluster"),
+		Values: []string{clusterName},
+	})
+	if err != nil {
+		return fmt.Errorf("unable to list security groups: %w", err)
+	}
+	for _, sgID := range resourceIDs(controllerGroups) {
+		if !slices.Contains(securityGroups, sgID) {
+			securityGroups = append(securityGroups, sgID)
 		}
 	}
 
-	// Describe the VPC to ensure it exists
-	_, err = ec2Client.DescribeVpcs(ctx, &ec2.DescribeVpcsInput{
+	// Internet Gateways and subnets do not depend on each other
+	err = runConcurrently(
+		func() error {
+			return forEachParallel(igws, teardownWorkers, func(igwID string) error {
+				_, err := ec2Client.DetachInternetGateway(ctx, &ec2.DetachInternetGatewayInput{
+					InternetGatewayId: aws.String(igwID),
+					VpcId:             aws.String(vpcID),
+				})
+				if err != nil {
+					return fmt.Errorf("unable to detach Internet Gateway %s: %w", igwID, err)
+				}
+
+				_, err = ec2Client.DeleteInternetGateway(ctx, &ec2.DeleteInternetGatewayInput{
+					InternetGatewayId: aws.String(igwID),
+				})
+				if err != nil {
+					return fmt.Errorf("unable to delete Internet Gateway %s: %w", igwID, err)
+				}
+				return nil
+			})
+		},
+		func() error {
+			return forEachParallel(subnets, teardownWorkers, func(subnetID string) error {
+				_, err := ec2Client.DeleteSubnet(ctx, &ec2.DeleteSubnetInput{
+					SubnetId: aws.String(subnetID),
+				})
+				if err != nil {
+					return fmt.Errorf("unable to delete subnet %s: %w", subnetID, err)
+				}
+				return nil
+			})
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	// Route tables are free once their subnets are gone, security groups once the interfaces are
+	err = runConcurrently(
+		func() error {
+			return forEachParallel(routeTables, teardownWorkers, func(rtbID string) error {
+				return deleteRouteTable(ctx, ec2Client, rtbID)
+			})
+		},
+		func() error {
+			return forEachParallel(securityGroups, teardownWorkers, func(sgID string) error {
+				return deleteSecurityGroup(ctx, ec2Client, sgID)
+			})
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	if !ownsVPC {
+		infof("VPC %s was not created for %s and is kept", vpcID, clusterName)
+		return nil
+	}
+
+	// Disassociate secondary CIDR blocks now that no subnet uses them
+	if err := DisassociateSecondaryCidrBlocks(ctx, region, vpcID); err != nil {
+		return err
+	}
+
+	// Finally, delete the VPC
+	_, err = ec2Client.DeleteVpc(ctx, &ec2.DeleteVpcInput{
+		VpcId: aws.String(vpcID),
+	})
+	if err != nil {
+		return fmt.Errorf("unable to delete VPC %s, it may hold resources that were not created for %s: %w", vpcID, clusterName, err)
+	}
+
+	return nil
+
+}
+
+// vpcTeardownScope returns the tag filters that select the resources created in a VPC for
+// clusterName, and whether the VPC itself was created for it. VPCs created before resources were
+// tagged with their cluster are scoped by the CreatedBy tag alone.
+func vpcTeardownScope(ctx context.Context, ec2Client *ec2.Client, vpcID, clusterName string) ([]ec2types.Filter, bool, error) {
+	output, err := ec2Client.DescribeVpcs(ctx, &ec2.DescribeVpcsInput{
 		VpcIds: []string{vpcID},
 	})
 	if err != nil {
-		return fmt.Errorf("unable to describe VPC: %v", err)
+		return nil, false, fmt.Errorf("unable to describe VPC: %w", err)
+	}
+	if len(output.Vpcs) == 0 {
+		return nil, false, fmt.Errorf("VPC %s not found", vpcID)
 	}
+	tags := map[string]string{}
+	for _, tag := range output.Vpcs[0].Tags {
+		tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+	}
+	createdByTool := tags["CreatedBy"] == "EKS-Sandbox-Tool"
+	owner, tagged := tags[clusterNameTag]
 
-	// Detach and delete Internet Gateways
-	igws, err := ListInternetGateways(ctx, region, vpcID)
+	scope := []ec2types.Filter{
+		{Name: aws.String("tag:CreatedBy"), Values: []string{"EKS-Sandbox-Tool"}},
+	}
+	if tagged || !createdByTool {
+		scope = append(scope, ec2types.Filter{Name: aws.String("tag:" + clusterNameTag), Values: []string{clusterName}})
+	}
+	return scope, createdByTool && (!tagged || owner == clusterName), nil
+}
+
+// deleteRouteTable deletes a route table unless it is the main route table of its VPC
+func deleteRouteTable(ctx context.Context, ec2Client *ec2.Client, rtbID string) error {
+	// Check if the route table is the main route table
+	rtbOutput, err := ec2Client.DescribeRouteTables(ctx, &ec2.DescribeRouteTablesInput{
+		RouteTableIds: []string{rtbID},
+	})
 	if err != nil {
-		return fmt.Errorf("unable to list Internet Gateways: %v", err)
+		return fmt.Errorf("unable to describe route table %s: %w", rtbID, err)
 	}
-	for _, igwID := range igws {
-		_, err = ec2Client.DetachInternetGateway(ctx, &ec2.DetachInternetGatewayInput{
-			InternetGatewayId: aws.String(igwID),
-			VpcId:             aws.String(vpcID),
-		})
-		if err != nil {
-			return fmt.Errorf("unable to detach Internet Gateway %s: %v", igwID, err)
-		}
 
-		_, err = ec2Client.DeleteInternetGateway(ctx, &ec2.DeleteInternetGatewayInput{
-			InternetGatewayId: aws.String(igwID),
-		})
-		if err != nil {
-			return fmt.Errorf("unable to delete Internet Gateway %s: %v", igwID, err)
+	for _, association := range rtbOutput.RouteTables[0].Associations {
+		if association.Main != nil && *association.Main {
+			infof("Skipping deletion of main route table %s", rtbID)
+			return nil // Do not delete the main route table
 		}
 	}
 
-	// Delete subnets
-	subnets, err := ListSubnets(ctx, region, vpcID)
+	_, err = ec2Client.DeleteRouteTable(ctx, &ec2.DeleteRouteTableInput{
+		RouteTableId: aws.String(rtbID),
+	})
 	if err != nil {
-		return fmt.Errorf("unable to list subnets: %v", err)
+		return fmt.Errorf("unable to delete route table %s: %w", rtbID, err)
 	}
-	for _, subnetID := range subnets {
-		_, err = ec2Client.DeleteSubnet(ctx, &ec2.DeleteSubnetInput{
-			SubnetId: aws.String(subnetID),
-		})
-		if err != nil {
-			return fmt.Errorf("unable to delete subnet %s: %v", subnetID, err)
-		}
+
+	successf("Successfully deleted route table %s", rtbID)
+	return nil
+}
+
+// deleteSecurityGroup deletes a security group unless it is the default one, which cannot be deleted
+func deleteSecurityGroup(ctx context.Context, ec2Client *ec2.Client, sgID string) error {
+	// Describe the security group to check its name
+	sgOutput, err := ec2Client.DescribeSecurityGroups(ctx, &ec2.DescribeSecurityGroupsInput{
+		GroupIds: []string{sgID},
+	})
+	if err != nil {
+		return fmt.Errorf("unable to describe security group %s: %w", sgID, err)
+	}
+	if len(sgOutput.SecurityGroups) == 0 {
+		return nil
 	}
 
-	// Delete route tables
-	routeTables, err := ListRouteTables(ctx, region, vpcID)
+	if aws.ToString(sgOutput.SecurityGroups[0].GroupName) == "default" {
+		infof("Skipping deletion of default security group %s", sgID)
+		return nil // Do not delete the default security group
+	}
+	if hasOpenIngressTag(sgOutput.SecurityGroups[0].Tags) {
+		warnf("Security group %s allowed all inbound traffic from the internet", sgID)
+	}
+
+	_, err = ec2Client.DeleteSecurityGroup(ctx, &ec2.DeleteSecurityGroupInput{
+		GroupId: aws.String(sgID),
+	})
 	if err != nil {
-		return fmt.Errorf("unable to list route tables: %v", err)
+		return fmt.Errorf("unable to delete security group %s: %w", sgID, err)
 	}
-	for _, rtbID := range routeTables {
-		// Check if the route table is the main route table
-		rtbOutput, err := ec2Client.DescribeRouteTables(ctx, &ec2.DescribeRouteTablesInput{
-			RouteTableIds: []string{rtbID},
-		})
-		if err != nil {
-			return fmt.Errorf("unable to describe route table %s: %v", rtbID, err)
-		}
 
-		isMainRouteTable := false
-		for _, association := range rtbOutput.RouteTables[0].Associations {
-			if association.Main != nil && *association.Main {
-				isMainRouteTable = true
-				break
+	successf("Successfully deleted security group %s", sgID)
+	return nil
+}
+
+// DeleteNatGateways deletes the NAT gateways in a VPC that match the scope filters, waits until
+// they are gone and returns the allocation IDs of the Elastic IPs they were using.
+func DeleteNatGateways(ctx context.Context, region, vpcID string, scope ...ec2types.Filter) ([]string, error) {
+	cfg, err := loadAWSConfig(ctx, region)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load AWS configuration: %w", err)
+	}
+	ec2Client := ec2.NewFromConfig(cfg)
+
+	output, err := ec2Client.DescribeNatGateways(ctx, &ec2.DescribeNatGatewaysInput{
+		Filter: append([]ec2types.Filter{
+			{
+				Name:   aws.String("vpc-id"),
+				Values: []string{vpcID},
+			},
+			{
+				Name:   aws.String("state"),
+				Values: []string{"pending", "available", "deleting"},
+			},
+		}, scope...),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to describe NAT gateways: %w", err)
+	}
+
+	var allocationIDs, natIDs []string
+	for _, nat := range output.NatGateways {
+		for _, addr := range nat.NatGatewayAddresses {
+			if addr.AllocationId != nil {
+				allocationIDs = append(allocationIDs, aws.ToString(addr.AllocationId))
 			}
 		}
-
-		if isMainRouteTable {
-			fmt.Printf("Skipping deletion of main route table %s\n", rtbID)
-			continue // Do not delete the main route table
+		natIDs = append(natIDs, aws.ToString(nat.NatGatewayId))
+		if nat.State == ec2types.NatGatewayStateDeleting {
+			continue
 		}
 
-		// Attempt to delete the route table
-		_, err = ec2Client.DeleteRouteTable(ctx, &ec2.DeleteRouteTableInput{
-			RouteTableId: aws.String(rtbID),
+		_, err = ec2Client.DeleteNatGateway(ctx, &ec2.DeleteNatGatewayInput{
+			NatGatewayId: nat.NatGatewayId,
 		})
 		if err != nil {
-			return fmt.Errorf("unable to delete route table %s: %v", rtbID, err)
+			return nil, fmt.Errorf("unable to delete NAT gateway %s: %w", aws.ToString(nat.NatGatewayId), err)
 		}
+		infof("Deleting NAT gateway %s", aws.ToString(nat.NatGatewayId))
+	}
 
-		fmt.Printf("Successfully deleted route table %s\n", rtbID)
+	if len(natIDs) > 0 {
+		waiter := ec2.NewNatGatewayDeletedWaiter(ec2Client)
+		err = waiter.Wait(ctx, &ec2.DescribeNatGatewaysInput{NatGatewayIds: natIDs}, 15*time.Minute)
+		if err != nil {
+			return nil, fmt.Errorf("NAT gateways did not finish deleting: %w", err)
+		}
+		successf("Deleted %d NAT gateway(s)", len(natIDs))
 	}
 
-	// Delete security groups (except the default one, as it cannot be deleted)
-	securityGroups, err := ListSecurityGroups(ctx, region, vpcID)
+	return allocationIDs, nil
+}
+
+// ListVPCElasticIPs returns the Elastic IPs attached to network interfaces in the given subnets
+// of a VPC, plus any unattached addresses the tool allocated, tagged with the VPC ID and matching
+// the scope filters.
+func ListVPCElasticIPs(ctx context.Context, region, vpcID string, subnetIDs []string, scope ...ec2types.Filter) ([]ec2types.Address, error) {
+	cfg, err := loadAWSConfig(ctx, region)
 	if err != nil {
-		return fmt.Errorf("unable to list security groups: %v", err)
+		return nil, fmt.Errorf("unable to load AWS configuration: %w", err)
 	}
+	ec2Client := ec2.NewFromConfig(cfg)
 
-	for _, sgID := range securityGroups {
-		// Describe the security group to check its name
-		sgOutput, err := ec2Client.DescribeSecurityGroups(ctx, &ec2.DescribeSecurityGroupsInput{
-			GroupIds: []string{sgID},
+	var eniIDs []string
+	if len(subnetIDs) > 0 {
+		eniOutput, err := ec2Client.DescribeNetworkInterfaces(ctx, &ec2.DescribeNetworkInterfacesInput{
+			Filters: []ec2types.Filter{
+				{
+					Name:   aws.String("subnet-id"),
+					Values: subnetIDs,
+				},
+			},
 		})
 		if err != nil {
-			return fmt.Errorf("unable to describe security group %s: %v", sgID, err)
+			return nil, fmt.Errorf("unable to describe network interfaces: %w", err)
+		}
+		for _, eni := range eniOutput.NetworkInterfaces {
+			eniIDs = append(eniIDs, aws.ToString(eni.NetworkInterfaceId))
 		}
+	}
+
+	filterSets := [][]ec2types.Filter{
+		append([]ec2types.Filter{
+			{Name: aws.String("tag:CreatedBy"), Values: []string{"EKS-Sandbox-Tool"}},
+			{Name: aws.String("tag:VpcId"), Values: []string{vpcID}},
+		}, scope...),
+	}
+	if len(eniIDs) > 0 {
+		filterSets = append(filterSets, []ec2types.Filter{
+			{Name: aws.String("network-interface-id"), Values: eniIDs},
+		})
+	}
 
-		// Check if the security group is the default one
-		isDefault := false
-		if len(sgOutput.SecurityGroups) > 0 && *sgOutput.SecurityGroups[0].GroupName == "default" {
-			isDefault = true
+	seen := map[string]bool{}
+	var addresses []ec2types.Address
+	for _, filters := range filterSets {
+		output, err := ec2Client.DescribeAddresses(ctx, &ec2.DescribeAddressesInput{Filters: filters})
+		if err != nil {
+			return nil, fmt.Errorf("unable to describe Elastic IPs: %w", err)
+		}
+		for _, addr := range output.Addresses {
+			id := aws.ToString(addr.AllocationId)
+			if id == "" || seen[id] {
+				continue
+			}
+			seen[id] = true
+			addresses = append(addresses, addr)
 		}
+	}
+	return addresses, nil
+}
+
+// ReleaseElasticIPs releases the given Elastic IP allocations. Every address is attempted
+// before an error is returned so a single failure does not leave the rest allocated.
+func ReleaseElasticIPs(ctx context.Context, region string, allocationIDs []string) error {
+	if len(allocationIDs) == 0 {
+		return nil
+	}
+	cfg, err := loadAWSConfig(ctx, region)
+	if err != nil {
+		return fmt.Errorf("unable to load AWS configuration: %w", err)
+	}
+	ec2Client := ec2.NewFromConfig(cfg)
 
-		if isDefault {
-			fmt.Printf("Skipping deletion of default security group %s\n", sgID)
-			continue // Do not delete the default security group
+	seen := map[string]bool{}
+	var failed []string
+	for _, allocationID := range allocationIDs {
+		if seen[allocationID] {
+			continue
 		}
+		seen[allocationID] = true
 
-		// Attempt to delete the security group
-		_, err = ec2Client.DeleteSecurityGroup(ctx, &ec2.DeleteSecurityGroupInput{
-			GroupId: aws.String(sgID),
+		_, err := ec2Client.ReleaseAddress(ctx, &ec2.ReleaseAddressInput{
+			AllocationId: aws.String(allocationID),
 		})
 		if err != nil {
-			return fmt.Errorf("unable to delete security group %s: %v", sgID, err)
+			warnf("unable to release Elastic IP %s: %v", allocationID, err)
+			failed = append(failed, allocationID)
+			continue
 		}
-
-		fmt.Printf("Successfully deleted security group %s\n", sgID)
+		successf("Released Elastic IP %s", allocationID)
 	}
 
-	// Finally, delete the VPC
-	_, err = ec2Client.DeleteVpc(ctx, &ec2.DeleteVpcInput{
-		VpcId: aws.String(vpcID),
-	})
-	if err != nil {
-		return fmt.Errorf("unable to delete VPC %s: %v", vpcID, err)
+	if len(failed) > 0 {
+		return fmt.Errorf("unable to release Elastic IPs %s; release them manually to stop charges", strings.Join(failed, ", "))
 	}
-
 	return nil
-
 }
 
-// GetVPCIDFromCluster fetches the VPC ID by reading the "vpc-id" tag from an EKS cluster.
+// GetVPCIDFromCluster returns the VPC of an EKS cluster from its VpcId tag. Clusters without the tag,
+// such as those not created by this tool, fall back to the VPC in the cluster's network configuration.
 func GetVPCIDFromCluster(ctx context.Context, region, clusterName string) (string, error) {
 	// Load AWS configuration
-	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(region))
+	cfg, err := loadAWSConfig(ctx, region)
 	if err != nil {
-		return "", fmt.Errorf("unable to load AWS configuration: %v", err)
+		return "", fmt.Errorf("unable to load AWS configuration: %w", err)
 	}
 	eksClient := eks.NewFromConfig(cfg)
 
@@ -796,29 +1881,68 @@ func GetVPCIDFromCluster(ctx context.Context, region, clusterName string) (strin
 		Name: aws.String(clusterName),
 	})
 	if err != nil {
-		return "", fmt.Errorf("failed to describe EKS cluster %s: %v", clusterName, err)
+		return "", fmt.Errorf("failed to describe EKS cluster %s: %w", clusterName, err)
+	}
+	if clusterOutput.Cluster == nil {
+		return "", fmt.Errorf("cluster %s is malformed", clusterName)
 	}
 
-	// Extract tags from the cluster
-	if clusterOutput.Cluster == nil || clusterOutput.Cluster.Tags == nil {
-		return "", fmt.Errorf("cluster %s does not have tags or is malformed", clusterName)
+	if vpcID, exists := clusterOutput.Cluster.Tags["VpcId"]; exists {
+		return vpcID, nil
 	}
+	if vpc := clusterOutput.Cluster.ResourcesVpcConfig; vpc != nil && aws.ToString(vpc.VpcId) != "" {
+		debugf("cluster %s has no VpcId tag, using the VPC of its network configuration", clusterName)
+		return aws.ToString(vpc.VpcId), nil
+	}
+	return "", fmt.Errorf("cluster %s has no VpcId tag and no VPC in its network configuration", clusterName)
+}
 
-	// Look for the "vpc-id" tag
-	vpcID, exists := clusterOutput.Cluster.Tags["VpcId"]
-	if !exists {
-		return "", fmt.Errorf("vpc-id tag not found on cluster %s", clusterName)
+// CheckClusterVPCTag makes sure the VPC named by a cluster's VpcId tag is the VPC the cluster actually
+// runs in, so a stale or edited tag cannot point VPC deletion at someone else's VPC. It needs the
+// cluster to still exist.
+func CheckClusterVPCTag(ctx context.Context, region, clusterName, taggedVPC string) error {
+	cfg, err := loadAWSConfig(ctx, region)
+	if err != nil {
+		return fmt.Errorf("unable to load AWS configuration: %w", err)
+	}
+	output, err := eks.NewFromConfig(cfg).DescribeCluster(ctx, &eks.DescribeClusterInput{Name: aws.String(clusterName)})
+	if err != nil {
+		return fmt.Errorf("failed to describe EKS cluster %s: %w", clusterName, err)
 	}
+	if vpc := output.Cluster.ResourcesVpcConfig; vpc != nil && aws.ToString(vpc.VpcId) != "" && aws.ToString(vpc.VpcId) != taggedVPC {
+		return fmt.Errorf("the VpcId tag of cluster %s names VPC %s, but the cluster runs in VPC %s; refusing to delete either",
+			clusterName, taggedVPC, aws.ToString(vpc.VpcId))
+	}
+	return nil
+}
 
-	// Return the VPC ID
-	return vpcID, nil
+// CheckVPCOwner makes sure a VPC about to be torn down was created for clusterName. VPCs created
+// before network resources were tagged with their cluster have no ClusterName tag and pass.
+func CheckVPCOwner(ctx context.Context, region, vpcID, clusterName string) error {
+	cfg, err := loadAWSConfig(ctx, region)
+	if err != nil {
+		return fmt.Errorf("unable to load AWS configuration: %w", err)
+	}
+	output, err := ec2.NewFromConfig(cfg).DescribeVpcs(ctx, &ec2.DescribeVpcsInput{VpcIds: []string{vpcID}})
+	if err != nil {
+		return fmt.Errorf("unable to describe VPC %s: %w", vpcID, err)
+	}
+	if len(output.Vpcs) == 0 {
+		return fmt.Errorf("VPC %s not found", vpcID)
+	}
+	for _, tag := range output.Vpcs[0].Tags {
+		if aws.ToString(tag.Key) == clusterNameTag && aws.ToString(tag.Value) != clusterName {
+			return fmt.Errorf("VPC %s was created for cluster %s, not %s", vpcID, aws.ToString(tag.Value), clusterName)
+		}
+	}
+	return nil
 }
 
 func EnableAutoAssignPublicIP(ctx context.Context, region string, subnets []string) error {
 	// Load AWS configuration
-	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(region))
+	cfg, err := loadAWSConfig(ctx, region)
 	if err != nil {
-		return fmt.Errorf("unable to load AWS configuration: %v", err)
+		return fmt.Errorf("unable to load AWS configuration: %w", err)
 	}
 	ec2Client := ec2.NewFromConfig(cfg)
 
@@ -831,37 +1955,193 @@ func EnableAutoAssignPublicIP(ctx context.Context, region string, subnets []stri
 			},
 		})
 		if err != nil {
-			return fmt.Errorf("unable to enable auto-assign public IPv4 for subnet %s: %v", subnetID, err)
+			return fmt.Errorf("unable to enable auto-assign public IPv4 for subnet %s: %w", subnetID, err)
 		}
 
-		fmt.Printf("Enabled auto-assign public IPv4 for subnet %s\n", subnetID)
+		successf("Enabled auto-assign public IPv4 for subnet %s", subnetID)
 	}
 
 	return nil
 }
 
-// function to install addons coredns, kube-proxy, vpc-cni
-func InstallAddons(ctx context.Context, region, clusterName string) error {
-	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(region))
+// defaultAddons are the addons installed on new clusters
+var defaultAddons = []string{"coredns", "kube-proxy", "vpc-cni"}
+
+// autoModeAddons are the addons whose work auto mode does itself, so auto mode clusters do not need them
+var autoModeAddons = []string{"coredns", "kube-proxy", "vpc-cni", "aws-ebs-csi-driver", "eks-pod-identity-agent"}
+
+// clusterAutoMode reports whether auto mode manages the compute of a cluster
+func clusterAutoMode(ctx context.Context, client *eks.Client, clusterName string) (bool, error) {
+	output, err := client.DescribeCluster(ctx, &eks.DescribeClusterInput{Name: aws.String(clusterName)})
+	if err != nil {
+		return false, fmt.Errorf("failed to describe EKS cluster %s: %w", clusterName, err)
+	}
+	compute := output.Cluster.ComputeConfig
+	return compute != nil && aws.ToBool(compute.Enabled), nil
+}
+
+// ResolveAddonVersions returns the version of each addon to install for a Kubernetes version,
+// failing when AWS offers no compatible version of one of them
+func ResolveAddonVersions(ctx context.Context, region, k8sVersion string, addons []string) (map[string]string, error) {
+	cfg, err := loadAWSConfig(ctx, region)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load AWS configuration: %w", err)
+	}
+	client := eks.NewFromConfig(cfg)
+
+	versions := map[string]string{}
+	for _, addon := range addons {
+		version, err := compatibleAddonVersion(ctx, client, addon, k8sVersion)
+		if err != nil {
+			return nil, err
+		}
+		versions[addon] = version
+	}
+	return versions, nil
+}
+
+// function to install the default addons given: coredns, kube-proxy and vpc-cni, unless another CNI replaces it
+// Each addon gets the version AWS marks as default for k8sVersion. Addons auto mode replaces are
+// skipped with a warning on auto mode clusters.
+func InstallAddons(ctx context.Context, region, clusterName, k8sVersion string, defaults []string, configurations map[string]string) error {
+	cfg, err := loadAWSConfig(ctx, region)
 	if err != nil {
-		return fmt.Errorf("unable to load AWS configuration: %v", err)
+		return fmt.Errorf("unable to load AWS configuration: %w", err)
 	}
 	client := eks.NewFromConfig(cfg)
 
-	// List of addons to install
-	addons := []string{"coredns", "kube-proxy", "vpc-cni"}
+	autoMode, err := clusterAutoMode(ctx, client, clusterName)
+	if err != nil {
+		return err
+	}
+	var addons []string
+	for _, addon := range defaults {
+		if autoMode && slices.Contains(autoModeAddons, addon) {
+			warnf("Not installing addon %s: auto mode runs it as part of the cluster", addon)
+			continue
+		}
+		addons = append(addons, addon)
+	}
+	if len(addons) == 0 {
+		return nil
+	}
+
+	versions, err := ResolveAddonVersions(ctx, region, k8sVersion, addons)
+	if err != nil {
+		return err
+	}
 
 	for _, addon := range addons {
-		_, err = client.CreateAddon(ctx, &eks.CreateAddonInput{
-			ClusterName: aws.String(clusterName),
-			AddonName:   aws.String(addon),
-		})
+		input := &eks.CreateAddonInput{
+			ClusterName:  aws.String(clusterName),
+			AddonName:    aws.String(addon),
+			AddonVersion: aws.String(versions[addon]),
+		}
+		if values, ok := configurations[addon]; ok {
+			input.ConfigurationValues = aws.String(values)
+		}
+		_, err = client.CreateAddon(ctx, input)
 		if err != nil {
-			return fmt.Errorf("failed to install addon %s: %v", addon, err)
+			return fmt.Errorf("failed to install addon %s: %w", addon, err)
 		}
 
-		fmt.Printf("Successfully installed addon %s\n", addon)
+		infof("Requested addon %s %s", addon, versions[addon])
 	}
 
+	// CreateAddon returns before the addon runs, so check how each one ends up. Addons such as
+	// coredns only become active once nodes join, so an addon that is still creating is not an error.
+	var failed []string
+	for _, addon := range addons {
+		if !reportAddonStatus(ctx, region, clusterName, addon) {
+			failed = append(failed, addon)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("addons failed to install: %s", strings.Join(failed, ", "))
+	}
 	return nil
 }
+
+// reportAddonStatus waits for a newly requested addon to settle and prints how it ended up. It
+// returns false only when the addon failed.
+func reportAddonStatus(ctx context.Context, region, clusterName, addon string) bool {
+	status, err := WaitForAddon(ctx, region, clusterName, addon, 10*time.Minute)
+	if err != nil {
+		warnf("Addon %s is not active yet: %v", addon, err)
+		return true
+	}
+	switch status.Status {
+	case types.AddonStatusActive:
+		successf("Addon %s is active", addon)
+	case types.AddonStatusDegraded:
+		warnf("Addon %s is degraded%s", addon, addonIssues(status.Health))
+	default:
+		errorf("Addon %s failed%s", addon, addonIssues(status.Health))
+		return false
+	}
+	return true
+}
+
+// compatibleAddonVersion returns the version of an addon AWS marks as default for a Kubernetes
+// version, or the newest compatible one when none is marked. It fails when no version supports it.
+func compatibleAddonVersion(ctx context.Context, client *eks.Client, addon, k8sVersion string) (string, error) {
+	var newest string
+	paginator := eks.NewDescribeAddonVersionsPaginator(client, &eks.DescribeAddonVersionsInput{
+		AddonName:         aws.String(addon),
+		KubernetesVersion: aws.String(k8sVersion),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return "", fmt.Errorf("unable to describe versions of addon %s: %w", addon, err)
+		}
+		for _, info := range page.Addons {
+			// Versions are listed newest first
+			for _, version := range info.AddonVersions {
+				for _, compat := range version.Compatibilities {
+					if aws.ToString(compat.ClusterVersion) != k8sVersion {
+						continue
+					}
+					if compat.DefaultVersion {
+						return aws.ToString(version.AddonVersion), nil
+					}
+					if newest == "" {
+						newest = aws.ToString(version.AddonVersion)
+					}
+				}
+			}
+		}
+	}
+	if newest == "" {
+		return "", validationErr("addon %s has no version that supports Kubernetes %s", addon, k8sVersion)
+	}
+	return newest, nil
+}
+
+// waitForEKSUpdate waits until an update of a cluster, or of one of its node groups when nodegroup
+// is set, succeeds and fails when it does not
+func waitForEKSUpdate(ctx context.Context, client *eks.Client, clusterName, nodegroup, updateID, what string, timeout time.Duration) error {
+	input := &eks.DescribeUpdateInput{
+		Name:     aws.String(clusterName),
+		UpdateId: aws.String(updateID),
+	}
+	if nodegroup != "" {
+		input.NodegroupName = aws.String(nodegroup)
+	}
+	return pollUntil(ctx, what, pollOptions{Timeout: timeout}, func(ctx context.Context) (bool, string, error) {
+		update, err := client.DescribeUpdate(ctx, input)
+		if err != nil {
+			return false, "", fmt.Errorf("unable to describe update %s: %w", updateID, err)
+		}
+		switch update.Update.Status {
+		case types.UpdateStatusSuccessful:
+			return true, string(update.Update.Status), nil
+		case types.UpdateStatusFailed, types.UpdateStatusCancelled:
+			if errs := update.Update.Errors; len(errs) > 0 {
+				return false, "", fmt.Errorf("update %s %s: %s", updateID, update.Update.Status, aws.ToString(errs[0].ErrorMessage))
+			}
+			return false, "", fmt.Errorf("update %s %s", updateID, update.Update.Status)
+		}
+		return false, string(update.Update.Status), nil
+	})
+}
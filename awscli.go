@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// runAWSCLI runs the aws CLI for services the tool has no SDK client for. The region, FIPS and
+// network settings of the run are passed on so the CLI reaches AWS the same way the SDK does.
+// Calls are recorded and replayed with the cassette like SDK calls.
+func runAWSCLI(ctx context.Context, region string, args ...string) (string, error) {
+	if err := checkReadOnlyCLI(args); err != nil {
+		return "", err
+	}
+	command := strings.Join(args[:min(len(args), 2)], " ")
+	if *replayFile == "" {
+		if _, err := exec.LookPath("aws"); err != nil {
+			return "", fmt.Errorf("the aws CLI is not installed: %w", err)
+		}
+	}
+	cmd := exec.CommandContext(ctx, "aws", append(args, "--region", region, "--output", "json")...)
+	cmd.Env = os.Environ()
+	if *useFIPS {
+		cmd.Env = append(cmd.Env, "AWS_USE_FIPS_ENDPOINT=true")
+	}
+	if n := userConfig.Network; n.HTTPSProxy != "" {
+		cmd.Env = append(cmd.Env, "HTTPS_PROXY="+n.HTTPSProxy, "NO_PROXY="+n.NoProxy)
+	}
+	if n := userConfig.Network; n.CABundle != "" {
+		cmd.Env = append(cmd.Env, "AWS_CA_BUNDLE="+n.CABundle)
+	}
+	credentials, err := credentialsEnv(ctx, region)
+	if err != nil {
+		return "", err
+	}
+	cmd.Env = append(cmd.Env, credentials...)
+	debugf("running aws %s", strings.Join(args, " "))
+	stdout, stderr, err := runCommand("aws "+command, cmd)
+	if err != nil {
+		return stdout, fmt.Errorf("aws %s: %w: %s", command, err, strings.TrimSpace(stderr))
+	}
+	return stdout, nil
+}
+
+// credentialsEnv returns the environment that gives a child process the credentials of the run:
+// the profile switched to in the session or, when ctx carries an account role, the credentials of
+// that role, which the SDK calls of ctx assume
+func credentialsEnv(ctx context.Context, region string) ([]string, error) {
+	role := accountRole(ctx)
+	if role == "" {
+		if sessionProfile == "" {
+			return nil, nil
+		}
+		return []string{"AWS_PROFILE=" + sessionProfile}, nil
+	}
+	cfg, err := loadAWSConfig(ctx, region)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load AWS configuration: %w", err)
+	}
+	creds, err := cfg.Credentials.Retrieve(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to assume %s: %w", role, err)
+	}
+	return []string{"AWS_ACCESS_KEY_ID=" + creds.AccessKeyID, "AWS_SECRET_ACCESS_KEY=" + creds.SecretAccessKey,
+		"AWS_SESSION_TOKEN=" + creds.SessionToken}, nil
+}
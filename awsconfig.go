@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/aws-sdk-go-v2/aws/ratelimit"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/smithy-go/middleware"
+)
+
+var (
+	debugAWS = flag.Bool("debug-aws", false, "Trace every AWS API call with its request IDs, retries and throttling")
+	useFIPS  = flag.Bool("fips", false, "Use FIPS 140 validated endpoints for all AWS services")
+)
+
+// loadAWSConfig loads the AWS configuration used by every client, applying the options selected on
+// the command line. When ctx carries an account role, calls are made with that role's credentials.
+func loadAWSConfig(ctx context.Context, region string) (aws.Config, error) {
+	role := accountRole(ctx)
+	key := region + "|" + role
+
+	awsConfigMu.Lock()
+	defer awsConfigMu.Unlock()
+	if cfg, ok := awsConfigs[key]; ok {
+		return cfg, nil
+	}
+	cfg, err := newAWSConfig(ctx, region)
+	if err != nil {
+		return aws.Config{}, err
+	}
+	if role != "" {
+		cfg.Credentials = aws.NewCredentialsCache(stscreds.NewAssumeRoleProvider(sts.NewFromConfig(cfg), role, func(o *stscreds.AssumeRoleOptions) {
+			o.RoleSessionName = "est"
+		}))
+	}
+	awsConfigs[key] = cfg
+	return cfg, nil
+}
+
+// awsConfigs caches the configuration of each region and account role for the lifetime of the
+// process. Reading the shared config files and resolving credentials is then done once per region
+// instead of per call.
+var (
+	awsConfigMu sync.Mutex
+	awsConfigs  = map[string]aws.Config{}
+)
+
+type accountRoleKey struct{}
+
+// withAccountRole returns a context whose AWS calls assume roleARN, e.g. to work in a member
+// account of an organization with the functions written for the caller's own account
+func withAccountRole(ctx context.Context, roleARN string) context.Context {
+	return context.WithValue(ctx, accountRoleKey{}, roleARN)
+}
+
+// accountRole returns the role the AWS calls of ctx assume, "" for the caller's own credentials
+func accountRole(ctx context.Context) string {
+	role, _ := ctx.Value(accountRoleKey{}).(string)
+	return role
+}
+
+// newAWSConfig loads the configuration for a region with the settings of this run
+func newAWSConfig(ctx context.Context, region string) (aws.Config, error) {
+	opts := []func(*config.LoadOptions) error{
+		config.WithRegion(region),
+		config.WithRetryer(newRetryer),
+	}
+	networkOpts, err := networkLoadOptions(userConfig.Network)
+	if err != nil {
+		return aws.Config{}, err
+	}
+	opts = append(opts, networkOpts...)
+	if sessionProfile != "" {
+		opts = append(opts, config.WithSharedConfigProfile(sessionProfile))
+	}
+	if *useFIPS {
+		opts = append(opts, config.WithUseFIPSEndpoint(aws.FIPSEndpointStateEnabled))
+	}
+	var apiOptions []func(*middleware.Stack) error
+	if rps := userConfig.RateLimit.BatchRequestsPerSecond; rps > 0 {
+		batchLimiter.SetRate(rps)
+		apiOptions = append(apiOptions, addBatchRateLimit)
+	}
+	if *debugAWS {
+		apiOptions = append(apiOptions, addAPITrace)
+	}
+	if *readOnly {
+		apiOptions = append(apiOptions, addReadOnlyGuard)
+	}
+	apiOptions = append(apiOptions, addManifestRecorder)
+	faultOption, err := faultInjectionOption()
+	if err != nil {
+		return aws.Config{}, err
+	}
+	if faultOption != nil {
+		apiOptions = append(apiOptions, faultOption)
+	}
+	if cassetteOption, credentials := cassetteOptions(); cassetteOption != nil {
+		apiOptions = append(apiOptions, cassetteOption)
+		if credentials != nil {
+			opts = append(opts, config.WithCredentialsProvider(credentials))
+		}
+	}
+	if len(apiOptions) > 0 {
+		opts = append(opts, config.WithAPIOptions(apiOptions))
+	}
+	return config.LoadDefaultConfig(ctx, opts...)
+}
+
+// newRetryer builds the retryer configured under "retry" in the config file. The client-side retry
+// quota is disabled so a burst of RequestLimitExceeded errors is retried with backoff instead of
+// failing the call once the quota runs out.
+func newRetryer() aws.Retryer {
+	rc := userConfig.Retry
+	standard := func(o *retry.StandardOptions) {
+		o.MaxAttempts = rc.MaxAttempts
+		o.MaxBackoff = time.Duration(rc.MaxBackoffSeconds) * time.Second
+		o.RateLimiter = ratelimit.None
+	}
+	if rc.Mode == "adaptive" {
+		return retry.NewAdaptiveMode(func(o *retry.AdaptiveModeOptions) {
+			o.StandardOptions = append(o.StandardOptions, standard)
+		})
+	}
+	return retry.NewStandard(standard)
+}
+
+// addAPITrace wraps each operation so the outcome of the call and all of its attempts is logged
+func addAPITrace(stack *middleware.Stack) error {
+	return stack.Initialize.Add(middleware.InitializeMiddlewareFunc("EstAPITrace", traceAPICall), middleware.After)
+}
+
+func traceAPICall(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (middleware.InitializeOutput, middleware.Metadata, error) {
+	start := time.Now()
+	out, metadata, err := next.HandleInitialize(ctx, in)
+
+	op := awsmiddleware.GetServiceID(ctx) + "." + awsmiddleware.GetOperationName(ctx)
+	var requestIDs []string
+	attempts, throttled := 1, 0
+	if results, ok := retry.GetAttemptResults(metadata); ok && len(results.Results) > 0 {
+		attempts = len(results.Results)
+		for i, result := range results.Results {
+			if id, ok := awsmiddleware.GetRequestIDMetadata(result.ResponseMetadata); ok {
+				requestIDs = append(requestIDs, id)
+			}
+			if result.Err == nil {
+				continue
+			}
+			if retry.IsErrorThrottles(retry.DefaultThrottles).IsErrorThrottle(result.Err) == aws.TrueTernary {
+				throttled++
+			}
+			traceAWSf("%s attempt %d failed (retryable=%t): %v", op, i+1, result.Retryable, result.Err)
+		}
+	}
+	if len(requestIDs) == 0 {
+		if id, ok := awsmiddleware.GetRequestIDMetadata(metadata); ok {
+			requestIDs = append(requestIDs, id)
+		}
+	}
+
+	outcome := "ok"
+	if err != nil {
+		outcome = "error: " + err.Error()
+	}
+	traceAWSf("%s %s attempts=%d throttled=%d request-ids=%s %s",
+		op, time.Since(start).Round(time.Millisecond), attempts, throttled, strings.Join(requestIDs, ","), outcome)
+	return out, metadata, err
+}
+
+// traceAWSf prints an API trace line to stderr and records it in the run log
+func traceAWSf(format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	logLine("AWS", msg)
+	fmt.Fprintf(os.Stderr, "[aws] %s\n", msg)
+}
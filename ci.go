@@ -0,0 +1,73 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ciMode switches to GitHub Actions friendly output and answers every prompt with its default.
+// It is enabled automatically when running inside a GitHub Actions workflow.
+var ciMode = flag.Bool("ci", os.Getenv("GITHUB_ACTIONS") == "true", "Non-interactive GitHub Actions mode: log groups, step outputs and masked values")
+
+// ciGroup opens a collapsible log section in the workflow log
+func ciGroup(name string) {
+	if *ciMode {
+		fmt.Printf("::group::%s\n", name)
+	}
+}
+
+// ciEndGroup closes the section opened by ciGroup
+func ciEndGroup() {
+	if *ciMode {
+		fmt.Println("::endgroup::")
+	}
+}
+
+// ciMask asks the runner to redact a value from all subsequent log output
+func ciMask(value string) {
+	if *ciMode && value != "" {
+		fmt.Printf("::add-mask::%s\n", value)
+	}
+}
+
+// ciSetOutput publishes a step output that later workflow steps can read as steps.<id>.outputs.<name>
+func ciSetOutput(name, value string) error {
+	if !*ciMode {
+		return nil
+	}
+	path := os.Getenv("GITHUB_OUTPUT")
+	if path == "" {
+		fmt.Printf("%s=%s\n", name, value)
+		return nil
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("unable to open GITHUB_OUTPUT: %w", err)
+	}
+	defer f.Close()
+	_, err = fmt.Fprintf(f, "%s=%s\n", name, value)
+	return err
+}
+
+// ciClusterName derives a unique default cluster name from the workflow run
+func ciClusterName() string {
+	name := "ci"
+	for _, key := range []string{"GITHUB_RUN_ID", "GITHUB_RUN_ATTEMPT"} {
+		if v := os.Getenv(key); v != "" {
+			name += "-" + v
+		}
+	}
+	return name
+}
+
+// ciKubeconfigPath returns where the kubeconfig for a CI-created cluster is written
+func ciKubeconfigPath(clusterName string) string {
+	dir := os.Getenv("RUNNER_TEMP")
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "kubeconfig-"+strings.ToLower(clusterName))
+}
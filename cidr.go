@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// defaultVPCCIDR is the CIDR block of the VPCs the tool creates unless another one is chosen
+const defaultVPCCIDR = "10.0.0.0/16"
+
+// parseVPCCIDR checks a VPC CIDR block against the sizes AWS allows
+func parseVPCCIDR(value string) (*net.IPNet, error) {
+	ip, network, err := net.ParseCIDR(strings.TrimSpace(value))
+	if err != nil || ip.To4() == nil {
+		return nil, fmt.Errorf("%q is not an IPv4 CIDR block", value)
+	}
+	if !ip.Equal(network.IP) {
+		return nil, fmt.Errorf("%q is not a network address, did you mean %s?", value, network)
+	}
+	if ones, _ := network.Mask.Size(); ones < 16 || ones > 28 {
+		return nil, fmt.Errorf("%s must have a prefix between /16 and /28", value)
+	}
+	return network, nil
+}
+
+// parseSecondaryCIDRs parses a comma separated list of extra CIDR blocks for a VPC whose primary
+// block is vpcCIDR. "none" or an empty string means no extra blocks.
+func parseSecondaryCIDRs(value, vpcCIDR string) ([]string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" || strings.EqualFold(value, "none") {
+		return nil, nil
+	}
+	var cidrs []string
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if _, err := parseVPCCIDR(entry); err != nil {
+			return nil, err
+		}
+		if cidrsOverlap(entry, vpcCIDR) {
+			return nil, fmt.Errorf("%s overlaps the VPC CIDR %s", entry, vpcCIDR)
+		}
+		for _, other := range cidrs {
+			if cidrsOverlap(entry, other) {
+				return nil, fmt.Errorf("%s overlaps %s", entry, other)
+			}
+		}
+		cidrs = append(cidrs, entry)
+	}
+	return cidrs, nil
+}
+
+// splitSubnets divides a VPC CIDR block into count equally sized, non-overlapping subnets, as large
+// as possible. AWS subnets can be no smaller than /28.
+func splitSubnets(vpcCIDR string, count int) ([]string, error) {
+	network, err := parseVPCCIDR(vpcCIDR)
+	if err != nil {
+		return nil, err
+	}
+	if count < 1 {
+		return nil, fmt.Errorf("at least one subnet is required")
+	}
+	ones, bits := network.Mask.Size()
+	extra := 0
+	for 1<<extra < count {
+		extra++
+	}
+	prefix := ones + extra
+	if prefix > 28 {
+		return nil, fmt.Errorf("%s is too small for %d subnets of at least /28", vpcCIDR, count)
+	}
+
+	base := binary.BigEndian.Uint32(network.IP.To4())
+	size := uint32(1) << (bits - prefix)
+	subnets := make([]string, count)
+	for i := range subnets {
+		ip := make(net.IP, 4)
+		binary.BigEndian.PutUint32(ip, base+uint32(i)*size)
+		subnets[i] = fmt.Sprintf("%s/%d", ip, prefix)
+	}
+	return subnets, nil
+}
+
+// cidrList formats CIDR blocks the way parseSecondaryCIDRs reads them
+func cidrList(cidrs []string) string {
+	if len(cidrs) == 0 {
+		return "none"
+	}
+	return strings.Join(cidrs, ",")
+}
+
+// cidrsOverlap reports whether two valid CIDR blocks share any address
+func cidrsOverlap(a, b string) bool {
+	_, na, errA := net.ParseCIDR(a)
+	_, nb, errB := net.ParseCIDR(b)
+	if errA != nil || errB != nil {
+		return false
+	}
+	return na.Contains(nb.IP) || nb.Contains(na.IP)
+}
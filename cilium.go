@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os/exec"
+	"time"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/eks/types"
+)
+
+// CNIs that give pods their network on clusters without auto mode
+const (
+	cniVPC           = "vpc-cni"
+	cniCiliumENI     = "cilium-eni"
+	cniCiliumOverlay = "cilium-overlay"
+)
+
+// ciliumVersion is the Cilium chart installed in place of the VPC CNI
+const (
+	ciliumVersion   = "1.16.5"
+	ciliumChartRepo = "https://helm.cilium.io/"
+)
+
+// ciliumOverlayCIDR is the pod CIDR of Cilium in overlay mode. It must not overlap the VPC.
+const ciliumOverlayCIDR = "100.96.0.0/16"
+
+// ciliumStartupTaint keeps pods off a new node until the Cilium agent runs on it, which then
+// removes the taint. Without it pods scheduled first would not get their network from Cilium.
+var ciliumStartupTaint = types.Taint{
+	Key:    aws.String("node.cilium.io/agent-not-ready"),
+	Value:  aws.String("true"),
+	Effect: types.TaintEffectNoExecute,
+}
+
+// cilium reports whether Cilium replaces the VPC CNI
+func (a *createAnswers) cilium() bool {
+	return !a.AutoMode && (a.CNI == cniCiliumENI || a.CNI == cniCiliumOverlay)
+}
+
+// nodeTaints returns the taints new nodes start with
+func (a *createAnswers) nodeTaints() []types.Taint {
+	if a.cilium() {
+		return []types.Taint{ciliumStartupTaint}
+	}
+	return nil
+}
+
+func askCNI(ctx context.Context, a *createAnswers) error {
+	descriptions := map[string]string{
+		cniVPC:           "the EKS default, pods get VPC addresses",
+		cniCiliumENI:     "advanced: Cilium hands out VPC addresses from ENIs itself",
+		cniCiliumOverlay: "advanced: Cilium with a VXLAN overlay, pods get addresses from " + ciliumOverlayCIDR,
+	}
+	cniPrompt := &survey.Select{
+		Message:     "Which CNI should give pods their network?",
+		Options:     []string{cniVPC, cniCiliumENI, cniCiliumOverlay},
+		Default:     firstNonEmpty(a.CNI, cniVPC),
+		Description: func(value string, index int) string { return descriptions[value] },
+	}
+	if err := askOne("EST_CNI", cniPrompt, &a.CNI); err != nil {
+		return wrapErr("reading CNI", err)
+	}
+	return nil
+}
+
+// validateCilium rejects combinations Cilium cannot be installed with
+func (a *createAnswers) validateCilium() error {
+	switch {
+	case !a.cilium():
+		return nil
+	case a.outpostArn != "":
+		return validationErr("local clusters on Outposts only support the VPC CNI; choose %s", cniVPC)
+	case !a.InstallAddons:
+		return validationErr("a cluster with Cilium still needs the coredns and kube-proxy addons; enable the addons or choose %s", cniVPC)
+	case a.compute() == computeFargate:
+		return validationErr("Fargate pods always use the VPC CNI; choose other compute or %s", cniVPC)
+	case a.CustomNetworking:
+		return validationErr("custom networking is a VPC CNI feature; disable it or choose %s", cniVPC)
+	case a.PrivateEndpoint:
+		return validationErr("Cilium is installed with helm, which cannot reach a private endpoint; keep the endpoint public or choose %s", cniVPC)
+	}
+	if a.CNI == cniCiliumOverlay && a.ExistingVPC == "" && a.sharedVPCID == "" {
+		for _, cidr := range append([]string{a.VPCCIDR}, a.SecondaryCIDRs...) {
+			if cidrsOverlap(cidr, ciliumOverlayCIDR) {
+				return validationErr("VPC CIDR %s overlaps the Cilium pod CIDR %s", cidr, ciliumOverlayCIDR)
+			}
+		}
+	}
+	return nil
+}
+
+// InstallCilium installs Cilium with helm on a cluster created without the VPC CNI. It runs before
+// the cluster has nodes, so the agent talks to the API server endpoint directly rather than
+// through the kubernetes service, which needs kube-proxy to run first. Nodes start with
+// ciliumStartupTaint until the agent runs on them. In ENI mode the operator allocates ENIs and
+// addresses with the node role, which has the AmazonEKS_CNI_Policy.
+func InstallCilium(ctx context.Context, region, clusterName, mode string) error {
+	if _, err := exec.LookPath("helm"); err != nil {
+		return fmt.Errorf("helm is not installed: %w", err)
+	}
+	conn, err := WaitForClusterConnection(ctx, region, clusterName, 30*time.Minute)
+	if err != nil {
+		return err
+	}
+	endpoint, err := url.Parse(conn.Endpoint)
+	if err != nil {
+		return fmt.Errorf("unable to parse cluster endpoint %s: %w", conn.Endpoint, err)
+	}
+	kube, err := newKubectlSession(ctx, region, clusterName)
+	if err != nil {
+		return err
+	}
+	defer kube.Close()
+
+	values := []string{"k8sServiceHost=" + endpoint.Hostname(), "k8sServicePort=443"}
+	if mode == cniCiliumENI {
+		values = append(values, "eni.enabled=true", "ipam.mode=eni", "egressMasqueradeInterfaces=eth+", "routingMode=native")
+	} else {
+		values = append(values, "routingMode=tunnel", "tunnelProtocol=vxlan", "ipam.mode=cluster-pool",
+			"ipam.operator.clusterPoolIPv4PodCIDRList={"+ciliumOverlayCIDR+"}")
+	}
+	args := []string{"upgrade", "--install", "cilium", "cilium", "--repo", ciliumChartRepo, "--version", ciliumVersion,
+		"--namespace", "kube-system"}
+	for _, value := range values {
+		args = append(args, "--set", value)
+	}
+	debugf("installing Cilium %s with helm", ciliumVersion)
+	if err := kube.Helm(ctx, args...); err != nil {
+		return err
+	}
+	successf("Installed Cilium %s in %s mode", ciliumVersion, mode)
+	return nil
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	eks "github.com/aws/aws-sdk-go-v2/service/eks"
+	"github.com/aws/aws-sdk-go-v2/service/eks/types"
+)
+
+// clusterSummary is what `est clusters` shows about a cluster
+type clusterSummary struct {
+	Name     string
+	Arn      string
+	Version  string
+	Status   string
+	Endpoint string
+	VPCID    string
+	// ClusterSecurityGroup is the security group EKS created for the control plane and nodes
+	ClusterSecurityGroup string
+	// SecurityGroups are the additional security groups given at creation
+	SecurityGroups []string
+	CreatedByTool  bool
+	// Owner is the IAM identity that created the cluster, from its Owner tag
+	Owner string
+	// HealthIssues are the problems EKS reports with the cluster, which explain why it is stuck in
+	// CREATING or has FAILED
+	HealthIssues []string
+}
+
+// clusterInsight is an upgrade or configuration insight EKS found for a cluster
+type clusterInsight struct {
+	Name     string
+	Category string
+	Status   string
+	Reason   string
+}
+
+// runClusters implements `est clusters list` and `est clusters describe`
+func runClusters(ctx context.Context, args []string) error {
+	usage := validationErr("usage: est clusters list [--mine] [--registry] [--region <region>...] | est clusters describe --cluster <name> [--region <region>]")
+	if len(args) == 0 || (args[0] != "list" && args[0] != "describe") {
+		return usage
+	}
+
+	var region, clusterName string
+	var regions stringList
+	var mine, registry bool
+	fs := flag.NewFlagSet("clusters "+args[0], flag.ContinueOnError)
+	if args[0] == "describe" {
+		fs.StringVar(&clusterName, "cluster", "", "Name of the EKS cluster")
+		fs.StringVar(&region, "region", firstNonEmpty(os.Getenv("EST_REGION"), os.Getenv("AWS_REGION")), "Region of the clusters")
+	} else {
+		fs.Var(&regions, "region", "Region to list (repeatable, default EST_REGION or AWS_REGION)")
+		fs.BoolVar(&mine, "mine", false, "Only list the clusters whose Owner tag matches the caller identity")
+		fs.BoolVar(&registry, "registry", false, "List the clusters of the registry table instead of asking EKS, in every region unless --region is given")
+	}
+	if err := fs.Parse(args[1:]); err != nil {
+		return usage
+	}
+
+	if args[0] == "list" && registry {
+		if registryTable() == "" {
+			return validationErr("no cluster registry configured: set registry.table in the config file or EST_REGISTRY_TABLE")
+		}
+		return listRegistry(ctx, regions, mine)
+	}
+	if args[0] == "list" {
+		if len(regions) == 0 {
+			if region := firstNonEmpty(os.Getenv("EST_REGION"), os.Getenv("AWS_REGION")); region != "" {
+				regions = stringList{region}
+			}
+		}
+		if len(regions) == 0 {
+			return validationErr("no region given: pass --region or set EST_REGION")
+		}
+		return listClusters(ctx, regions, mine)
+	}
+
+	if region == "" {
+		return validationErr("no region given: pass --region or set EST_REGION")
+	}
+	if clusterName == "" {
+		return usage
+	}
+	cluster, err := DescribeClusterSummary(ctx, region, clusterName)
+	if err != nil {
+		return wrapErr("describing cluster "+clusterName, err)
+	}
+	partition := partitionOf(cluster.Arn)
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Cluster:\t%s\t%s\n", cluster.Name, clusterConsoleURL(partition, region, cluster.Name))
+	fmt.Fprintf(tw, "ARN:\t%s\n", cluster.Arn)
+	fmt.Fprintf(tw, "Version:\t%s\n", cluster.Version)
+	fmt.Fprintf(tw, "Status:\t%s\n", cluster.Status)
+	if cluster.Owner != "" {
+		fmt.Fprintf(tw, "Owner:\t%s\n", cluster.Owner)
+	}
+	fmt.Fprintf(tw, "Endpoint:\t%s\n", firstNonEmpty(cluster.Endpoint, "-"))
+	if cluster.VPCID != "" {
+		fmt.Fprintf(tw, "VPC:\t%s\t%s\n", cluster.VPCID, vpcConsoleURL(partition, region, cluster.VPCID))
+	}
+	if cluster.ClusterSecurityGroup != "" {
+		fmt.Fprintf(tw, "Cluster security group:\t%s\t%s\n", cluster.ClusterSecurityGroup, securityGroupConsoleURL(partition, region, cluster.ClusterSecurityGroup))
+	}
+	for _, sg := range cluster.SecurityGroups {
+		fmt.Fprintf(tw, "Security group:\t%s\t%s\n", sg, securityGroupConsoleURL(partition, region, sg))
+	}
+	if len(cluster.HealthIssues) == 0 {
+		fmt.Fprintf(tw, "Health:\tno issues\n")
+	}
+	for _, issue := range cluster.HealthIssues {
+		fmt.Fprintf(tw, "Health issue:\t%s\n", issue)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	// Insights only exist once the cluster is active and need eks:ListInsights, so they are optional
+	insights, err := ListClusterInsights(ctx, region, clusterName)
+	if err != nil {
+		warnf("Unable to list insights: %v", err)
+		return nil
+	}
+	tw = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	passing := 0
+	for _, insight := range insights {
+		if insight.Status == string(types.InsightStatusValuePassing) {
+			passing++
+			continue
+		}
+		fmt.Fprintf(tw, "Insight:\t%s\t%s\t%s\t%s\n", insight.Status, insight.Category, insight.Name, insight.Reason)
+	}
+	if len(insights) > 0 {
+		fmt.Fprintf(tw, "Insights:\t%d of %d passing\n", passing, len(insights))
+	}
+	return tw.Flush()
+}
+
+// runListClustersAction is the List Clusters action of the menu, offered in read-only mode
+func runListClustersAction(ctx context.Context) error {
+	var region string
+	promptRegion := &survey.Input{
+		Message: "Enter the AWS region:",
+		Default: regionDefault("eu-west-2"),
+	}
+	if err := askOne("EST_REGION", promptRegion, &region, survey.WithValidator(validRegion)); err != nil {
+		return wrapErr("reading region", err)
+	}
+	notifier.Region = region
+	return listClusters(ctx, []string{region}, false)
+}
+
+// listClusters prints the clusters of regions, only those owned by the caller with mine
+func listClusters(ctx context.Context, regions []string, mine bool) error {
+	owner := ""
+	if mine {
+		_, callerID, err := GetAWSAccountDetails(ctx, regions[0])
+		if err != nil {
+			return wrapErr("fetching caller identity", err)
+		}
+		if owner = ownerFromARN(callerID); owner == "" {
+			return validationErr("no owner can be derived from the caller identity %s", callerID)
+		}
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tREGION\tVERSION\tSTATUS\tHEALTH ISSUES\tEST\tOWNER\tCONSOLE")
+	found := 0
+	for _, region := range regions {
+		names, err := ListEKSClusters(ctx, region)
+		if err != nil {
+			return wrapErr("fetching clusters in "+region, err)
+		}
+		for _, name := range names {
+			cluster, err := DescribeClusterSummary(ctx, region, name)
+			if err != nil {
+				return wrapErr("describing cluster "+name, err)
+			}
+			if mine && cluster.Owner != owner {
+				continue
+			}
+			createdBy := "no"
+			if cluster.CreatedByTool {
+				createdBy = "yes"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\t%s\t%s\t%s\n", cluster.Name, region, cluster.Version, cluster.Status, len(cluster.HealthIssues),
+				createdBy, firstNonEmpty(cluster.Owner, "-"), clusterConsoleURL(partitionOf(cluster.Arn), region, cluster.Name))
+			found++
+		}
+	}
+	if found == 0 {
+		if mine {
+			infof("No clusters owned by %s found in %s.", owner, strings.Join(regions, ", "))
+		} else {
+			infof("No clusters found in %s.", strings.Join(regions, ", "))
+		}
+		return nil
+	}
+	return tw.Flush()
+}
+
+// DescribeClusterSummary returns the version, state and network of a cluster
+func DescribeClusterSummary(ctx context.Context, region, clusterName string) (clusterSummary, error) {
+	cfg, err := loadAWSConfig(ctx, region)
+	if err != nil {
+		return clusterSummary{}, fmt.Errorf("unable to load AWS configuration: %w", err)
+	}
+	output, err := eks.NewFromConfig(cfg).DescribeCluster(ctx, &eks.DescribeClusterInput{Name: aws.String(clusterName)})
+	if err != nil {
+		return clusterSummary{}, fmt.Errorf("failed to describe EKS cluster %s: %w", clusterName, err)
+	}
+	cluster := output.Cluster
+	summary := clusterSummary{
+		Name:          clusterName,
+		Arn:           aws.ToString(cluster.Arn),
+		Version:       aws.ToString(cluster.Version),
+		Status:        string(cluster.Status),
+		Endpoint:      aws.ToString(cluster.Endpoint),
+		CreatedByTool: cluster.Tags["CreatedBy"] == "EKS-Sandbox-Tool",
+		Owner:         cluster.Tags[ownerTag],
+	}
+	if vpc := cluster.ResourcesVpcConfig; vpc != nil {
+		summary.VPCID = aws.ToString(vpc.VpcId)
+		summary.ClusterSecurityGroup = aws.ToString(vpc.ClusterSecurityGroupId)
+		summary.SecurityGroups = vpc.SecurityGroupIds
+	}
+	if cluster.Health != nil {
+		for _, issue := range cluster.Health.Issues {
+			text := fmt.Sprintf("%s: %s", issue.Code, aws.ToString(issue.Message))
+			if len(issue.ResourceIds) > 0 {
+				text += fmt.Sprintf(" (%s)", strings.Join(issue.ResourceIds, ", "))
+			}
+			summary.HealthIssues = append(summary.HealthIssues, text)
+		}
+	}
+	return summary, nil
+}
+
+// ListClusterInsights returns the insights EKS found for a cluster, such as deprecated APIs in use
+// that block the next upgrade
+func ListClusterInsights(ctx context.Context, region, clusterName string) ([]clusterInsight, error) {
+	cfg, err := loadAWSConfig(ctx, region)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load AWS configuration: %w", err)
+	}
+	var insights []clusterInsight
+	paginator := eks.NewListInsightsPaginator(eks.NewFromConfig(cfg), &eks.ListInsightsInput{ClusterName: aws.String(clusterName)})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list insights of cluster %s: %w", clusterName, err)
+		}
+		for _, insight := range page.Insights {
+			finding := clusterInsight{Name: aws.ToString(insight.Name), Category: string(insight.Category)}
+			if insight.InsightStatus != nil {
+				finding.Status = string(insight.InsightStatus.Status)
+				finding.Reason = aws.ToString(insight.InsightStatus.Reason)
+			}
+			insights = append(insights, finding)
+		}
+	}
+	return insights, nil
+}
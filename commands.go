@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	eks "github.com/aws/aws-sdk-go-v2/service/eks"
+)
+
+// version is the release of est, set at build time with -ldflags "-X main.version=<version>"
+var version = "dev"
+
+// command is a subcommand of est, which skips the main menu and goes straight to an action
+type command struct {
+	Name    string
+	Summary string
+	Run     func(ctx context.Context, timer *stepTimer, args []string) error
+	// Offline commands run before the configuration file and guardrails are loaded
+	Offline bool
+}
+
+// commands lists the subcommands of est in the order `est help` shows them
+var commands []command
+
+func init() {
+	commands = []command{
+		{Name: "create", Summary: "Create a sandbox cluster, asking only the create questions", Run: runCreateCommand},
+		{Name: "delete", Summary: "Delete a sandbox cluster and, when est created it, its VPC", Run: runDeleteCommand},
+		{Name: "list", Summary: "List the clusters of one or more regions (short for clusters list)", Run: untimed(runListCommand)},
+		{Name: "status", Summary: "Show the state, expiry and node groups of a cluster", Run: untimed(runStatus)},
+		{Name: "clusters", Summary: "List or describe clusters", Run: untimed(runClusters)},
+		{Name: "inventory", Summary: "List the sandboxes created with est", Run: untimed(runInventory)},
+		{Name: "template", Summary: "Save, list and apply answer templates", Run: runTemplate},
+		{Name: "addons", Summary: "Show the addon versions of a Kubernetes version", Run: untimed(runAddons)},
+		{Name: "irsa", Summary: "Create an IAM role for a service account", Run: untimed(runIRSA)},
+		{Name: "share", Summary: "Give a teammate temporary access to a cluster", Run: untimed(runShare)},
+		{Name: "schedule", Summary: "Pause the nodes of a cluster outside working hours", Run: untimed(runSchedule)},
+		{Name: "pool", Summary: "Fill or list a warm pool of ready clusters", Run: untimed(runPool)},
+		{Name: "claim", Summary: "Take a ready cluster from a warm pool", Run: untimed(runClaim)},
+		{Name: "workshop", Summary: "Set up or tear down a workshop", Run: untimed(runWorkshop)},
+		{Name: "zonal-shift", Summary: "Enable or disable ARC zonal shift on a cluster", Run: untimed(runZonalShift)},
+		{Name: "sweep", Summary: "Find and delete expired sandboxes across accounts", Run: untimed(runSweep)},
+		{Name: "version", Summary: "Print the version of est", Run: untimed(runVersion), Offline: true},
+		{Name: "help", Summary: "List the commands", Run: untimed(runHelp), Offline: true},
+	}
+}
+
+// untimed adapts a command that reports no step timings
+func untimed(run func(ctx context.Context, args []string) error) func(ctx context.Context, timer *stepTimer, args []string) error {
+	return func(ctx context.Context, timer *stepTimer, args []string) error {
+		return run(ctx, args)
+	}
+}
+
+// findCommand returns the subcommand of a name
+func findCommand(name string) (command, bool) {
+	for _, c := range commands {
+		if c.Name == name {
+			return c, true
+		}
+	}
+	return command{}, false
+}
+
+// runHelp implements `est help`
+func runHelp(ctx context.Context, args []string) error {
+	fmt.Println("Usage: est [flags] [command] [command flags]")
+	fmt.Println()
+	fmt.Println("Without a command est shows the interactive menu. Commands:")
+	fmt.Println()
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, c := range commands {
+		fmt.Fprintf(tw, "  %s\t%s\n", c.Name, c.Summary)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	fmt.Println()
+	fmt.Println("Run est <command> --help for the flags of a command, and est --help for the global flags.")
+	return nil
+}
+
+// runVersion implements `est version`
+func runVersion(ctx context.Context, args []string) error {
+	fmt.Printf("est %s (%s, %s/%s)\n", version, runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	return nil
+}
+
+// runListCommand implements `est list`, which is short for `est clusters list`
+func runListCommand(ctx context.Context, args []string) error {
+	return runClusters(ctx, append([]string{"list"}, args...))
+}
+
+// runDeleteCommand implements `est delete`, which skips the main menu. Questions not answered by
+// its flags are asked as in the Delete Cluster action.
+func runDeleteCommand(ctx context.Context, timer *stepTimer, args []string) error {
+	usage := validationErr("usage: est delete [--name <name>] [--region <region>] [--delete-vpc | --keep-vpc]")
+
+	var name, region string
+	var deleteVPC, keepVPC bool
+	fs := flag.NewFlagSet("delete", flag.ContinueOnError)
+	fs.StringVar(&name, "name", "", "Cluster to delete, with or without the cluster prefix")
+	fs.StringVar(&region, "region", "", "Region of the cluster")
+	fs.BoolVar(&deleteVPC, "delete-vpc", false, "Also delete the isolated VPC est created for the cluster")
+	fs.BoolVar(&keepVPC, "keep-vpc", false, "Leave the isolated VPC of the cluster in place")
+	if err := fs.Parse(args); err != nil || fs.NArg() > 0 || (deleteVPC && keepVPC) {
+		return usage
+	}
+	if name != "" {
+		presetAnswers[answerKey("EST_CLUSTER_NAME")] = name
+	}
+	if region != "" {
+		presetAnswers[answerKey("EST_REGION")] = region
+	}
+	if deleteVPC || keepVPC {
+		presetAnswers[answerKey("EST_DELETE_VPC")] = yesNo(deleteVPC)
+	}
+	if err := runDelete(ctx, timer); err != nil {
+		return err
+	}
+	return timer.PrintSummary(summaryWriter(), *outputFormat)
+}
+
+// runStatus implements `est status`
+func runStatus(ctx context.Context, args []string) error {
+	usage := validationErr("usage: est status --cluster <name> [--region <region>]")
+
+	var clusterName, region string
+	fs := flag.NewFlagSet("status", flag.ContinueOnError)
+	fs.StringVar(&clusterName, "cluster", "", "Name of the EKS cluster")
+	fs.StringVar(&region, "region", firstNonEmpty(os.Getenv("EST_REGION"), os.Getenv("AWS_REGION")), "Region of the cluster")
+	if err := fs.Parse(args); err != nil || fs.NArg() > 0 || clusterName == "" {
+		return usage
+	}
+	if region == "" {
+		return validationErr("no region given: pass --region or set EST_REGION")
+	}
+
+	cfg, err := loadAWSConfig(ctx, region)
+	if err != nil {
+		return wrapErr("loading AWS configuration", err)
+	}
+	client := eks.NewFromConfig(cfg)
+	output, err := client.DescribeCluster(ctx, &eks.DescribeClusterInput{Name: aws.String(clusterName)})
+	if err != nil {
+		return wrapErr("describing cluster "+clusterName, err)
+	}
+	cluster := output.Cluster
+	nodegroups, err := describeNodegroups(ctx, client, clusterName)
+	if err != nil {
+		return wrapErr("listing node groups", err)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Cluster:\t%s\n", clusterName)
+	fmt.Fprintf(tw, "Status:\t%s\n", cluster.Status)
+	fmt.Fprintf(tw, "Version:\t%s\n", aws.ToString(cluster.Version))
+	fmt.Fprintf(tw, "Created:\t%s\n", aws.ToTime(cluster.CreatedAt).Local().Format("2006-01-02 15:04"))
+	fmt.Fprintf(tw, "Owner:\t%s\n", firstNonEmpty(cluster.Tags[ownerTag], "-"))
+	fmt.Fprintf(tw, "Expires:\t%s\n", firstNonEmpty(cluster.Tags["ExpiresAt"], "never"))
+	if pool := cluster.Tags[poolTag]; pool != "" {
+		fmt.Fprintf(tw, "Pool:\t%s, not claimed yet\n", pool)
+	}
+	if cluster.ComputeConfig != nil && aws.ToBool(cluster.ComputeConfig.Enabled) {
+		fmt.Fprintf(tw, "Auto mode:\tnode pools %s\n", firstNonEmpty(strings.Join(cluster.ComputeConfig.NodePools, ", "), "none"))
+	}
+	for _, ng := range nodegroups {
+		state := string(ng.Status)
+		if scaling := ng.ScalingConfig; scaling != nil {
+			state += fmt.Sprintf(", %d nodes (min %d, max %d)", aws.ToInt32(scaling.DesiredSize), aws.ToInt32(scaling.MinSize), aws.ToInt32(scaling.MaxSize))
+		}
+		if _, paused := ng.Tags[pausedScalingTag]; paused {
+			state += ", paused"
+		}
+		if _, scheduled := ng.Tags[scheduledScalingTag]; scheduled {
+			state += ", paused on a schedule"
+		}
+		fmt.Fprintf(tw, "Node group:\t%s\t%s\n", aws.ToString(ng.NodegroupName), state)
+	}
+	if cluster.Health != nil && len(cluster.Health.Issues) > 0 {
+		fmt.Fprintf(tw, "Health issues:\t%d, see est clusters describe\n", len(cluster.Health.Issues))
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,620 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	eks "github.com/aws/aws-sdk-go-v2/service/eks"
+	"github.com/aws/aws-sdk-go-v2/service/eks/types"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	iamtypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
+)
+
+// Compute options of clusters without auto mode, which otherwise have nowhere to run pods
+const (
+	computeNodegroup = "managed-nodegroup"
+	computeFargate   = "fargate"
+	computeKarpenter = "karpenter"
+	computeNone      = "none"
+)
+
+// computeNodegroupName is the managed node group created for computeNodegroup, and for the
+// Karpenter controller with computeKarpenter
+const computeNodegroupName = "est-nodes"
+
+// computeNodeInstanceType is the instance type of the nodes of computeNodegroupName
+const computeNodeInstanceType = "t3.medium"
+
+// computeFargateProfile is the Fargate profile created for computeFargate
+const computeFargateProfile = "est-default"
+
+// fargateNamespaces are the namespaces whose pods the Fargate profile runs
+var fargateNamespaces = []string{"default", "kube-system"}
+
+// karpenterVersion is the Karpenter chart installed for computeKarpenter
+const karpenterVersion = "1.6.0"
+
+// Karpenter runs as its controller service account, which gets its role through IRSA
+const (
+	karpenterNamespace      = "kube-system"
+	karpenterServiceAccount = "karpenter"
+	karpenterPolicyName     = "est-karpenter-controller"
+	karpenterDiscoveryTag   = "karpenter.sh/discovery"
+)
+
+// coreDNSOnFargate makes the coredns addon schedule on Fargate when the cluster has no nodes
+const coreDNSOnFargate = `{"computeType":"Fargate"}`
+
+// karpenterNodePool is the EC2NodeClass and NodePool Karpenter launches nodes from. Nodes use the
+// node role, the subnets tagged for discovery and the cluster security group.
+const karpenterNodePool = `apiVersion: karpenter.k8s.aws/v1
+kind: EC2NodeClass
+metadata:
+  name: default
+spec:
+  role: %[2]s
+  amiSelectorTerms:
+    - alias: al2023@latest
+  subnetSelectorTerms:
+    - tags:
+        karpenter.sh/discovery: %[1]s
+  securityGroupSelectorTerms:
+    - tags:
+        aws:eks:cluster-name: %[1]s
+  tags:
+%[3]s---
+apiVersion: karpenter.sh/v1
+kind: NodePool
+metadata:
+  name: default
+spec:
+  template:
+    spec:
+      nodeClassRef:
+        group: karpenter.k8s.aws
+        kind: EC2NodeClass
+        name: default
+      requirements:
+        - key: kubernetes.io/arch
+          operator: In
+          values: ["amd64"]
+        - key: karpenter.sh/capacity-type
+          operator: In
+          values: ["on-demand"]
+%[4]s%[5]s  limits:
+    cpu: "32"
+  disruption:
+    consolidationPolicy: WhenEmptyOrUnderutilized
+    consolidateAfter: 1m
+`
+
+// karpenterTaintEffects are the Kubernetes names of the taint effects of the EKS API
+var karpenterTaintEffects = map[types.TaintEffect]string{
+	types.TaintEffectNoSchedule:       "NoSchedule",
+	types.TaintEffectNoExecute:        "NoExecute",
+	types.TaintEffectPreferNoSchedule: "PreferNoSchedule",
+}
+
+// autoModeOnly skips questions that only apply to clusters without auto mode
+func autoModeOnly(a *createAnswers) bool {
+	return a.AutoMode
+}
+
+func askCompute(ctx context.Context, a *createAnswers) error {
+	descriptions := map[string]string{
+		computeNodegroup: "two t3.medium nodes that can be scaled later",
+		computeFargate:   "pods of the default and kube-system namespaces run serverless",
+		computeKarpenter: "nodes launched on demand, controller on two t3.medium nodes",
+		computeNone:      "no compute, add nodes yourself",
+	}
+	computePrompt := &survey.Select{
+		Message:     "Without auto mode the cluster needs compute. Where should pods run?",
+		Options:     []string{computeNodegroup, computeFargate, computeKarpenter, computeNone},
+		Default:     firstNonEmpty(a.Compute, computeNodegroup),
+		Description: func(value string, index int) string { return descriptions[value] },
+	}
+	if err := askOne("EST_COMPUTE", computePrompt, &a.Compute); err != nil {
+		return wrapErr("reading compute choice", err)
+	}
+	return nil
+}
+
+// computeRoleName derives the name of a role created for the compute of a cluster, within IAM's
+// 64 character limit
+func computeRoleName(cluster, kind string) string {
+	name := strings.TrimPrefix(cluster, "Sandbox-")
+	if limit := 64 - len("est--") - len(kind); len(name) > limit {
+		name = name[:limit]
+	}
+	return "est-" + name + "-" + kind
+}
+
+// ProvisionCompute gives a cluster without auto mode somewhere to run pods. Nodes and Fargate pods
+// are placed in subnets, which should be private when the VPC has private subnets. Nodes start
+// with taints.
+func ProvisionCompute(ctx context.Context, region, clusterName, compute string, subnets []string, taints []types.Taint) error {
+	cfg, err := loadAWSConfig(ctx, region)
+	if err != nil {
+		return fmt.Errorf("unable to load AWS configuration: %w", err)
+	}
+	iamClient := iam.NewFromConfig(cfg)
+	eksClient := eks.NewFromConfig(cfg)
+	partition := regionPartition(region)
+
+	switch compute {
+	case computeNodegroup:
+		nodeRoleArn, err := ensureNodeRole(ctx, iamClient, partition, clusterName)
+		if err != nil {
+			return err
+		}
+		return createComputeNodegroup(ctx, eksClient, clusterName, nodeRoleArn, subnets, taints)
+	case computeFargate:
+		podRoleName := computeRoleName(clusterName, "fargate-pod")
+		podRoleArn, err := ensureComputeRole(ctx, iamClient, podRoleName, []string{servicePrincipal(partition, "eks-fargate-pods")}, []string{
+			managedPolicyArn(partition, "AmazonEKSFargatePodExecutionRolePolicy"),
+		}, false)
+		if err != nil {
+			return err
+		}
+		return createFargateProfile(ctx, eksClient, clusterName, podRoleArn, subnets)
+	case computeKarpenter:
+		nodeRoleArn, err := ensureNodeRole(ctx, iamClient, partition, clusterName)
+		if err != nil {
+			return err
+		}
+		// The controller cannot launch the nodes it runs on
+		if err := createComputeNodegroup(ctx, eksClient, clusterName, nodeRoleArn, subnets, taints); err != nil {
+			return err
+		}
+		return installKarpenter(ctx, region, clusterName, nodeRoleArn, subnets, taints)
+	case computeNone, "":
+		infof("The cluster has no compute; pods stay pending until nodes are added")
+		return nil
+	}
+	return validationErr("unknown compute option %q", compute)
+}
+
+// ensureNodeRole creates the role of the nodes of a cluster. The extra policies of the iam
+// section apply to it like to the cluster role.
+func ensureNodeRole(ctx context.Context, iamClient *iam.Client, partition, clusterName string) (string, error) {
+	roleName := computeRoleName(clusterName, "node")
+	policies := []string{
+		managedPolicyArn(partition, "AmazonEKSWorkerNodePolicy"),
+		managedPolicyArn(partition, "AmazonEKS_CNI_Policy"),
+		managedPolicyArn(partition, "AmazonEC2ContainerRegistryReadOnly"),
+		managedPolicyArn(partition, ssmManagedPolicy),
+	}
+	return ensureComputeRole(ctx, iamClient, roleName, []string{servicePrincipal(partition, "ec2")}, append(policies, userConfig.IAM.ExtraPolicyArns...), true)
+}
+
+// ensureComputeRole creates or reuses a role that the service principals may assume and attaches
+// policies to it, and with inline the inline policy of the iam section
+func ensureComputeRole(ctx context.Context, iamClient *iam.Client, roleName string, principals, policies []string, inline bool) (string, error) {
+	trustPolicy, err := assumeRolePolicy(principals...)
+	if err != nil {
+		return "", err
+	}
+
+	var roleArn string
+	createInput := &iam.CreateRoleInput{
+		RoleName:                 aws.String(roleName),
+		AssumeRolePolicyDocument: aws.String(trustPolicy),
+		Tags:                     iamTags(),
+	}
+	if boundary := userConfig.IAM.PermissionsBoundary; boundary != "" {
+		createInput.PermissionsBoundary = aws.String(boundary)
+	}
+	created, err := iamClient.CreateRole(ctx, createInput)
+	if err != nil {
+		var alreadyExists *iamtypes.EntityAlreadyExistsException
+		if !errors.As(err, &alreadyExists) {
+			return "", fmt.Errorf("failed to create role %s: %w%s", roleName, err, boundaryHint(err))
+		}
+		existing, err := iamClient.GetRole(ctx, &iam.GetRoleInput{RoleName: aws.String(roleName)})
+		if err != nil {
+			return "", fmt.Errorf("unable to read role %s: %w", roleName, err)
+		}
+		roleArn = aws.ToString(existing.Role.Arn)
+		infof("Role %s already exists. Proceeding...", roleName)
+	} else {
+		roleArn = aws.ToString(created.Role.Arn)
+		successf("Created role %s", roleName)
+	}
+
+	if err := syncRolePolicies(ctx, iamClient, roleName, policies, inline); err != nil {
+		return "", err
+	}
+	return roleArn, nil
+}
+
+// createComputeNodegroup creates a small managed node group and waits until its nodes are ready
+func createComputeNodegroup(ctx context.Context, client *eks.Client, clusterName, nodeRoleArn string, subnets []string, taints []types.Taint) error {
+	stepf("Creating node group %s...", computeNodegroupName)
+	tags := map[string]string{"CreatedBy": "EKS-Sandbox-Tool", clusterNameTag: clusterName}
+	if costCenter != "" {
+		tags[costCenterTag] = costCenter
+	}
+	if clusterOwner != "" {
+		tags[ownerTag] = clusterOwner
+	}
+	_, err := client.CreateNodegroup(ctx, &eks.CreateNodegroupInput{
+		ClusterName:   aws.String(clusterName),
+		NodegroupName: aws.String(computeNodegroupName),
+		NodeRole:      aws.String(nodeRoleArn),
+		Subnets:       subnets,
+		AmiType:       types.AMITypesAl2023X8664Standard,
+		InstanceTypes: []string{computeNodeInstanceType},
+		ScalingConfig: &types.NodegroupScalingConfig{
+			MinSize:     aws.Int32(2),
+			DesiredSize: aws.Int32(2),
+			MaxSize:     aws.Int32(3),
+		},
+		Taints: taints,
+		Tags:   tags,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create node group %s: %w", computeNodegroupName, err)
+	}
+	waiter := eks.NewNodegroupActiveWaiter(client)
+	err = waiter.Wait(ctx, &eks.DescribeNodegroupInput{
+		ClusterName:   aws.String(clusterName),
+		NodegroupName: aws.String(computeNodegroupName),
+	}, 20*time.Minute)
+	if err != nil {
+		return fmt.Errorf("node group %s did not become active: %w", computeNodegroupName, err)
+	}
+	successf("Node group %s is active", computeNodegroupName)
+	return nil
+}
+
+// createFargateProfile runs the pods of fargateNamespaces on Fargate. Fargate only accepts private subnets.
+func createFargateProfile(ctx context.Context, client *eks.Client, clusterName, podRoleArn string, subnets []string) error {
+	stepf("Creating Fargate profile %s...", computeFargateProfile)
+	var selectors []types.FargateProfileSelector
+	for _, namespace := range fargateNamespaces {
+		selectors = append(selectors, types.FargateProfileSelector{Namespace: aws.String(namespace)})
+	}
+	tags := map[string]string{"CreatedBy": "EKS-Sandbox-Tool", clusterNameTag: clusterName}
+	if costCenter != "" {
+		tags[costCenterTag] = costCenter
+	}
+	if clusterOwner != "" {
+		tags[ownerTag] = clusterOwner
+	}
+	_, err := client.CreateFargateProfile(ctx, &eks.CreateFargateProfileInput{
+		ClusterName:         aws.String(clusterName),
+		FargateProfileName:  aws.String(computeFargateProfile),
+		PodExecutionRoleArn: aws.String(podRoleArn),
+		Subnets:             subnets,
+		Selectors:           selectors,
+		Tags:                tags,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create Fargate profile %s: %w", computeFargateProfile, err)
+	}
+	waiter := eks.NewFargateProfileActiveWaiter(client)
+	err = waiter.Wait(ctx, &eks.DescribeFargateProfileInput{
+		ClusterName:        aws.String(clusterName),
+		FargateProfileName: aws.String(computeFargateProfile),
+	}, 15*time.Minute)
+	if err != nil {
+		return fmt.Errorf("the Fargate profile %s did not become active: %w", computeFargateProfile, err)
+	}
+	successf("Pods in namespaces %s run on Fargate", strings.Join(fargateNamespaces, ", "))
+	return nil
+}
+
+// installKarpenter creates the controller role, tags the node subnets for discovery, installs
+// Karpenter with helm and creates a default NodePool. As with the other helm installs, the NodePool
+// is saved for the user to apply later when Karpenter does not come up in time.
+func installKarpenter(ctx context.Context, region, clusterName, nodeRoleArn string, subnets []string, taints []types.Taint) error {
+	if _, err := exec.LookPath("helm"); err != nil {
+		return fmt.Errorf("helm is not installed: %w", err)
+	}
+	accountID, callerID, err := GetAWSAccountDetails(ctx, region)
+	if err != nil {
+		return err
+	}
+	partition := partitionOf(callerID)
+
+	req := irsaRequest{
+		Region:         region,
+		Cluster:        clusterName,
+		Namespace:      karpenterNamespace,
+		ServiceAccount: karpenterServiceAccount,
+		RoleName:       irsaRoleName(clusterName, karpenterNamespace, karpenterServiceAccount),
+	}
+	roleArn, err := CreateIRSARole(ctx, req)
+	if err != nil {
+		return err
+	}
+	err = putRolePolicy(ctx, region, req.RoleName, karpenterPolicyName, []map[string]interface{}{
+		{
+			"Effect": "Allow",
+			"Action": []string{"ec2:CreateFleet", "ec2:CreateLaunchTemplate", "ec2:CreateTags", "ec2:DeleteLaunchTemplate",
+				"ec2:RunInstances", "ec2:TerminateInstances", "ec2:Describe*"},
+			"Resource": "*",
+		},
+		{
+			"Effect":   "Allow",
+			"Action":   "iam:PassRole",
+			"Resource": nodeRoleArn,
+		},
+		{
+			"Effect": "Allow",
+			"Action": []string{"iam:CreateInstanceProfile", "iam:TagInstanceProfile", "iam:AddRoleToInstanceProfile",
+				"iam:RemoveRoleFromInstanceProfile", "iam:DeleteInstanceProfile", "iam:GetInstanceProfile", "iam:ListInstanceProfiles"},
+			"Resource": "*",
+		},
+		{
+			"Effect":   "Allow",
+			"Action":   "ssm:GetParameter",
+			"Resource": fmt.Sprintf("arn:%s:ssm:%s::parameter/aws/service/*", partition, region),
+		},
+		{
+			"Effect":   "Allow",
+			"Action":   "pricing:GetProducts",
+			"Resource": "*",
+		},
+		{
+			"Effect":   "Allow",
+			"Action":   "eks:DescribeCluster",
+			"Resource": fmt.Sprintf("arn:%s:eks:%s:%s:cluster/%s", partition, region, accountID, clusterName),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	cfg, err := loadAWSConfig(ctx, region)
+	if err != nil {
+		return fmt.Errorf("unable to load AWS configuration: %w", err)
+	}
+	_, err = ec2.NewFromConfig(cfg).CreateTags(ctx, &ec2.CreateTagsInput{
+		Resources: subnets,
+		Tags:      []ec2types.Tag{{Key: aws.String(karpenterDiscoveryTag), Value: aws.String(clusterName)}},
+	})
+	if err != nil {
+		return fmt.Errorf("unable to tag subnets for Karpenter: %w", err)
+	}
+
+	kube, err := newKubectlSession(ctx, region, clusterName)
+	if err != nil {
+		return err
+	}
+	defer kube.Close()
+
+	cmd := exec.CommandContext(ctx, "helm", "upgrade", "--install", "karpenter", "oci://public.ecr.aws/karpenter/karpenter",
+		"--version", karpenterVersion, "--namespace", karpenterNamespace, "--kubeconfig", kube.kubeconfig,
+		"--set", "settings.clusterName="+clusterName,
+		"--set", "serviceAccount.name="+karpenterServiceAccount,
+		"--set", `serviceAccount.annotations.eks\.amazonaws\.com/role-arn=`+roleArn)
+	debugf("installing karpenter %s with helm", karpenterVersion)
+	if _, stderr, err := runCommand("helm upgrade", cmd); err != nil {
+		return fmt.Errorf("helm install: %w: %s", err, strings.TrimSpace(stderr))
+	}
+	successf("Installed Karpenter %s", karpenterVersion)
+
+	tags := fmt.Sprintf("    CreatedBy: EKS-Sandbox-Tool\n    %s: %s\n", clusterNameTag, clusterName)
+	if costCenter != "" {
+		tags += fmt.Sprintf("    %s: %q\n", costCenterTag, costCenter)
+	}
+	if clusterOwner != "" {
+		tags += fmt.Sprintf("    %s: %q\n", ownerTag, clusterOwner)
+	}
+	// Nodes Karpenter launches are held to the instance types the guardrails allow, or to general
+	// purpose, compute and memory optimised types
+	requirements := "        - key: karpenter.k8s.aws/instance-category\n          operator: In\n          values: [\"c\", \"m\", \"r\"]\n"
+	if allowed := activeGuardrails.AllowedInstanceTypes; len(allowed) > 0 {
+		quoted := make([]string, len(allowed))
+		for i, instanceType := range allowed {
+			quoted[i] = fmt.Sprintf("%q", instanceType)
+		}
+		requirements = fmt.Sprintf("        - key: node.kubernetes.io/instance-type\n          operator: In\n          values: [%s]\n", strings.Join(quoted, ", "))
+	}
+	// Nodes Karpenter launches start with the same taints as the node group
+	var startupTaints string
+	if len(taints) > 0 {
+		startupTaints = "      startupTaints:\n"
+		for _, taint := range taints {
+			startupTaints += fmt.Sprintf("        - key: %s\n          value: %q\n          effect: %s\n",
+				aws.ToString(taint.Key), aws.ToString(taint.Value), karpenterTaintEffects[taint.Effect])
+		}
+	}
+	nodePool := fmt.Sprintf(karpenterNodePool, clusterName, nodeRoleArn[strings.LastIndex(nodeRoleArn, "/")+1:], tags, requirements, startupTaints)
+	err = pollUntil(ctx, "Karpenter NodePool", pollOptions{Timeout: 5 * time.Minute}, func(ctx context.Context) (bool, string, error) {
+		if err := kube.Apply(ctx, nodePool); err != nil {
+			debugf("applying NodePool: %v", err)
+			return false, "waiting for the Karpenter webhook", nil
+		}
+		return true, "applied", nil
+	})
+	if err != nil {
+		path, saveErr := saveManifest("karpenter", clusterName, nodePool)
+		if saveErr != nil {
+			return fmt.Errorf("%v, and the NodePool could not be saved: %w", err, saveErr)
+		}
+		warnf("Karpenter is not running yet: %v", err)
+		infof("Create the NodePool once it runs: kubectl apply -f %s", path)
+		return nil
+	}
+	successf("Karpenter launches nodes for pending pods from NodePool default")
+	return nil
+}
+
+// RemoveKarpenterNodes deletes the NodePools and EC2NodeClasses of a cluster, so Karpenter drains
+// and terminates its nodes, and then terminates any Karpenter node still running. Karpenter nodes
+// are not part of a node group, so their instances would otherwise keep the VPC from being deleted.
+func RemoveKarpenterNodes(ctx context.Context, region, clusterName string) error {
+	cfg, err := loadAWSConfig(ctx, region)
+	if err != nil {
+		return fmt.Errorf("unable to load AWS configuration: %w", err)
+	}
+	ec2Client := ec2.NewFromConfig(cfg)
+
+	instances, err := karpenterInstances(ctx, ec2Client, clusterName)
+	if err != nil || len(instances) == 0 {
+		return err
+	}
+
+	if kube, err := newKubectlSession(ctx, region, clusterName); err != nil {
+		warnf("Unable to reach the cluster, Karpenter nodes are terminated without draining: %v", err)
+	} else {
+		stepf("Deleting Karpenter NodePools...")
+		for _, resource := range []string{"nodepools.karpenter.sh", "ec2nodeclasses.karpenter.k8s.aws"} {
+			if _, err := kube.Run(ctx, "delete", resource, "--all", "--ignore-not-found", "--timeout="+nodeDrainTimeout.String()); err != nil {
+				warnf("Unable to delete %s: %v", resource, err)
+			}
+		}
+		kube.Close()
+		if instances, err = karpenterInstances(ctx, ec2Client, clusterName); err != nil || len(instances) == 0 {
+			return err
+		}
+	}
+
+	stepf("Terminating %d Karpenter node(s)...", len(instances))
+	if _, err := ec2Client.TerminateInstances(ctx, &ec2.TerminateInstancesInput{InstanceIds: instances}); err != nil {
+		return fmt.Errorf("unable to terminate Karpenter nodes: %w", err)
+	}
+	waiter := ec2.NewInstanceTerminatedWaiter(ec2Client)
+	if err := waiter.Wait(ctx, &ec2.DescribeInstancesInput{InstanceIds: instances}, 10*time.Minute); err != nil {
+		return fmt.Errorf("the Karpenter nodes were not terminated: %w", err)
+	}
+	successf("Terminated Karpenter nodes %s", strings.Join(instances, ", "))
+	return nil
+}
+
+// karpenterInstances returns the instances Karpenter launched for a cluster that are not terminated
+func karpenterInstances(ctx context.Context, ec2Client *ec2.Client, clusterName string) ([]string, error) {
+	var instances []string
+	paginator := ec2.NewDescribeInstancesPaginator(ec2Client, &ec2.DescribeInstancesInput{
+		Filters: []ec2types.Filter{
+			{Name: aws.String("tag-key"), Values: []string{"karpenter.sh/nodepool"}},
+			{Name: aws.String("tag:" + clusterNameTag), Values: []string{clusterName}},
+			{Name: aws.String("instance-state-name"), Values: []string{"pending", "running", "stopping", "stopped"}},
+		},
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("unable to list Karpenter nodes: %w", err)
+		}
+		for _, reservation := range page.Reservations {
+			for _, instance := range reservation.Instances {
+				instances = append(instances, aws.ToString(instance.InstanceId))
+			}
+		}
+	}
+	return instances, nil
+}
+
+// DeleteFargateProfiles deletes the Fargate profiles of a cluster. EKS deletes one profile at a
+// time, so each is waited for before the next.
+func DeleteFargateProfiles(ctx context.Context, region, clusterName string) error {
+	cfg, err := loadAWSConfig(ctx, region)
+	if err != nil {
+		return fmt.Errorf("unable to load AWS configuration: %w", err)
+	}
+	client := eks.NewFromConfig(cfg)
+
+	var profiles []string
+	paginator := eks.NewListFargateProfilesPaginator(client, &eks.ListFargateProfilesInput{ClusterName: aws.String(clusterName)})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return fmt.Errorf("unable to list Fargate profiles: %w", err)
+		}
+		profiles = append(profiles, page.FargateProfileNames...)
+	}
+	for _, profile := range profiles {
+		stepf("Deleting Fargate profile %s...", profile)
+		_, err := client.DeleteFargateProfile(ctx, &eks.DeleteFargateProfileInput{
+			ClusterName:        aws.String(clusterName),
+			FargateProfileName: aws.String(profile),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to delete Fargate profile %s: %w", profile, err)
+		}
+		waiter := eks.NewFargateProfileDeletedWaiter(client)
+		err = waiter.Wait(ctx, &eks.DescribeFargateProfileInput{
+			ClusterName:        aws.String(clusterName),
+			FargateProfileName: aws.String(profile),
+		}, 15*time.Minute)
+		if err != nil {
+			return fmt.Errorf("the Fargate profile %s was not deleted: %w", profile, err)
+		}
+		successf("Fargate profile %s deleted", profile)
+	}
+	return nil
+}
+
+// CleanupComputeRoles deletes the node, Fargate and Karpenter roles created for a cluster. It runs
+// after the cluster is deleted and only warns, as leftovers do not block anything.
+func CleanupComputeRoles(ctx context.Context, region, clusterName string) {
+	cfg, err := loadAWSConfig(ctx, region)
+	if err != nil {
+		warnf("Unable to clean up compute roles: %v", err)
+		return
+	}
+	iamClient := iam.NewFromConfig(cfg)
+	for _, kind := range []string{"node", "fargate-pod"} {
+		if err := deleteComputeRole(ctx, iamClient, computeRoleName(clusterName, kind)); err != nil {
+			warnf("Unable to delete the %s role of %s: %v", kind, clusterName, err)
+		}
+	}
+	roleName := irsaRoleName(clusterName, karpenterNamespace, karpenterServiceAccount)
+	if _, err := deleteAddonRole(ctx, region, roleName, karpenterPolicyName); err != nil {
+		warnf("Unable to delete the Karpenter role of %s: %v", clusterName, err)
+	}
+}
+
+// deleteComputeRole detaches the policies of a compute role, removes it from the instance profiles
+// Karpenter made for it and deletes it. A role that does not exist is skipped.
+func deleteComputeRole(ctx context.Context, iamClient *iam.Client, roleName string) error {
+	var noSuchEntity *iamtypes.NoSuchEntityException
+	attached, err := iamClient.ListAttachedRolePolicies(ctx, &iam.ListAttachedRolePoliciesInput{RoleName: aws.String(roleName)})
+	if errors.As(err, &noSuchEntity) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("unable to list policies of role %s: %w", roleName, err)
+	}
+	for _, policy := range attached.AttachedPolicies {
+		_, err := iamClient.DetachRolePolicy(ctx, &iam.DetachRolePolicyInput{RoleName: aws.String(roleName), PolicyArn: policy.PolicyArn})
+		if err != nil {
+			return fmt.Errorf("unable to detach policy %s from role %s: %w", aws.ToString(policy.PolicyArn), roleName, err)
+		}
+	}
+	_, err = iamClient.DeleteRolePolicy(ctx, &iam.DeleteRolePolicyInput{RoleName: aws.String(roleName), PolicyName: aws.String(inlinePolicyName)})
+	if err != nil && !errors.As(err, &noSuchEntity) {
+		return fmt.Errorf("unable to remove policy %s from role %s: %w", inlinePolicyName, roleName, err)
+	}
+	profiles, err := iamClient.ListInstanceProfilesForRole(ctx, &iam.ListInstanceProfilesForRoleInput{RoleName: aws.String(roleName)})
+	if err != nil {
+		return fmt.Errorf("unable to list instance profiles of role %s: %w", roleName, err)
+	}
+	for _, profile := range profiles.InstanceProfiles {
+		if _, err := iamClient.RemoveRoleFromInstanceProfile(ctx, &iam.RemoveRoleFromInstanceProfileInput{
+			InstanceProfileName: profile.InstanceProfileName,
+			RoleName:            aws.String(roleName),
+		}); err != nil {
+			return fmt.Errorf("unable to remove role %s from instance profile %s: %w", roleName, aws.ToString(profile.InstanceProfileName), err)
+		}
+		if _, err := iamClient.DeleteInstanceProfile(ctx, &iam.DeleteInstanceProfileInput{InstanceProfileName: profile.InstanceProfileName}); err != nil {
+			return fmt.Errorf("unable to delete instance profile %s: %w", aws.ToString(profile.InstanceProfileName), err)
+		}
+	}
+	if _, err := iamClient.DeleteRole(ctx, &iam.DeleteRoleInput{RoleName: aws.String(roleName)}); err != nil {
+		return fmt.Errorf("unable to delete role %s: %w", roleName, err)
+	}
+	successf("Deleted role %s", roleName)
+	return nil
+}
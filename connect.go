@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// adminPrincipalArn returns the IAM principal EKS grants cluster admin to when the cluster is
+// created by callerID. Sessions of an assumed role map to the role; role paths are not part of
+// session ARNs and EKS does not need them.
+func adminPrincipalArn(callerID string) string {
+	parts := strings.SplitN(callerID, ":", 6)
+	if len(parts) < 6 || parts[2] != "sts" {
+		return callerID
+	}
+	resource := strings.Split(parts[5], "/")
+	if resource[0] != "assumed-role" || len(resource) < 2 {
+		return callerID
+	}
+	return fmt.Sprintf("arn:%s:iam::%s:role/%s", parts[1], parts[4], resource[1])
+}
+
+// printConnectionInstructions writes a copy-pasteable block telling the user how to reach a new
+// cluster. tunnelScript is the SSM tunnel to open first for a private API server, if any; its
+// kubeconfig sits next to it.
+func printConnectionInstructions(w io.Writer, conn clusterConnection, contextName, callerID, tunnelScript string) {
+	if contextName == "" {
+		contextName = conn.Arn
+	}
+
+	fmt.Fprintf(w, "\nConnect to %s:\n\n", conn.Name)
+	if tunnelScript != "" {
+		fmt.Fprintf(w, "  %s &\n", tunnelScript)
+		fmt.Fprintf(w, "  kubectl --kubeconfig %s get nodes\n\n", filepath.Join(filepath.Dir(tunnelScript), "kubeconfig"))
+	} else {
+		updateKubeconfig := fmt.Sprintf("aws eks update-kubeconfig --region %s --name %s", conn.Region, conn.Name)
+		if contextName != conn.Arn {
+			updateKubeconfig += " --alias " + contextName
+		}
+		fmt.Fprintf(w, "  %s\n", updateKubeconfig)
+		fmt.Fprintf(w, "  kubectl --context %s get nodes\n\n", contextName)
+	}
+	fmt.Fprintf(w, "  Context:       %s\n", contextName)
+	fmt.Fprintf(w, "  Console:       %s\n", clusterConsoleURL(partitionOf(conn.Arn), conn.Region, conn.Name))
+	fmt.Fprintf(w, "  Cluster admin: %s\n\n", adminPrincipalArn(callerID))
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// partitionOf returns the partition of an ARN, "aws" when it cannot be read
+func partitionOf(arn string) string {
+	if parts := strings.SplitN(arn, ":", 3); len(parts) == 3 && parts[1] != "" {
+		return parts[1]
+	}
+	return "aws"
+}
+
+// consoleBaseURL returns the AWS Management Console of a partition, regional where the partition
+// has regional console endpoints
+func consoleBaseURL(partition, region string) string {
+	switch partition {
+	case "aws-cn":
+		return "https://console.amazonaws.cn"
+	case "aws-us-gov":
+		return "https://console.amazonaws-us-gov.com"
+	default:
+		return fmt.Sprintf("https://%s.console.aws.amazon.com", region)
+	}
+}
+
+// clusterConsoleURL links to the overview of an EKS cluster in the console
+func clusterConsoleURL(partition, region, clusterName string) string {
+	return fmt.Sprintf("%s/eks/home?region=%s#/clusters/%s", consoleBaseURL(partition, region), region, url.PathEscape(clusterName))
+}
+
+// vpcConsoleURL links to a VPC in the VPC console
+func vpcConsoleURL(partition, region, vpcID string) string {
+	return fmt.Sprintf("%s/vpcconsole/home?region=%s#VpcDetails:VpcId=%s", consoleBaseURL(partition, region), region, vpcID)
+}
+
+// securityGroupConsoleURL links to a security group in the EC2 console
+func securityGroupConsoleURL(partition, region, groupID string) string {
+	return fmt.Sprintf("%s/ec2/home?region=%s#SecurityGroup:groupId=%s", consoleBaseURL(partition, region), region, groupID)
+}
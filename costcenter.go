@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	iamtypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
+)
+
+// costCenterTag is the tag finance reports sandbox spend by
+const costCenterTag = "CostCenter"
+
+// costCenter is the cost center of the cluster being created. Every resource created for it is
+// tagged with it; empty when none was given.
+var costCenter string
+
+// ownerTag records who created a resource, derived from the caller identity by ownerFromARN
+const ownerTag = "Owner"
+
+// clusterOwner is the owner of the cluster being created. Every resource created for it is tagged
+// with it; empty outside of creation.
+var clusterOwner string
+
+// ownerFromARN derives the owner of new resources from the ARN of the caller: the user name of an
+// IAM user, the session name of an assumed role, which SSO sets to the user's name or email, or the
+// name of a federated user. Characters tag values cannot hold are replaced with dashes.
+func ownerFromARN(arn string) string {
+	parts := strings.SplitN(arn, ":", 6)
+	if len(parts) < 6 {
+		return ""
+	}
+	resource := parts[5]
+	owner := resource[strings.LastIndex(resource, "/")+1:]
+	if strings.HasPrefix(resource, "assumed-role/") && strings.Count(resource, "/") < 2 {
+		owner = ""
+	}
+	owner = strings.Map(func(r rune) rune {
+		if costCenterPattern.MatchString(string(r)) {
+			return r
+		}
+		return '-'
+	}, owner)
+	if len(owner) > 128 {
+		owner = owner[:128]
+	}
+	return owner
+}
+
+// costCenterPattern keeps values valid as tag values in every service and in the aws CLI's
+// shorthand syntax, which splits on commas and equals signs
+var costCenterPattern = regexp.MustCompile(`^[\w .:/+@-]{1,128}$`)
+
+func askCostCenter(ctx context.Context, a *createAnswers) error {
+	costCenterPrompt := &survey.Input{
+		Message: "Enter the cost center or owning team to tag resources with (empty for none):",
+		Default: a.CostCenter,
+	}
+	err := askOne("EST_COST_CENTER", costCenterPrompt, &a.CostCenter, survey.WithValidator(func(ans interface{}) error {
+		if value := strings.TrimSpace(ans.(string)); value != "" && !costCenterPattern.MatchString(value) {
+			return fmt.Errorf("use at most 128 letters, digits, spaces and _ . : / + @ -")
+		}
+		return nil
+	}))
+	if err != nil {
+		return wrapErr("reading cost center", err)
+	}
+	a.CostCenter = strings.TrimSpace(a.CostCenter)
+	return nil
+}
+
+func askActivateCostTag(ctx context.Context, a *createAnswers) error {
+	activatePrompt := &survey.Confirm{
+		Message: fmt.Sprintf("Activate %s as a cost allocation tag so it shows up in Cost Explorer? Needs Billing access.", costCenterTag),
+		Default: a.ActivateCostTag,
+	}
+	if err := askOne("EST_ACTIVATE_COST_TAG", activatePrompt, &a.ActivateCostTag); err != nil {
+		return wrapErr("reading cost allocation tag choice", err)
+	}
+	return nil
+}
+
+// ActivateCostAllocationTag activates the cost center tag in the billing console's cost allocation
+// tags. The tool has no Cost Explorer SDK client, so the aws CLI is used.
+func ActivateCostAllocationTag(ctx context.Context) error {
+	// Cost Explorer is served from us-east-1 only
+	_, err := runAWSCLI(ctx, "us-east-1", "ce", "update-cost-allocation-tags-status",
+		"--cost-allocation-tags-status", fmt.Sprintf("TagKey=%s,Status=Active", costCenterTag))
+	if err != nil && strings.Contains(err.Error(), "not found") {
+		return fmt.Errorf("billing does not know the tag %s yet; it takes up to 24 hours after the first tagged resource, activate it again then: %w", costCenterTag, err)
+	}
+	return err
+}
+
+// ec2Tags returns the tags of an EC2 resource created by this tool
+func ec2Tags(name string) []ec2types.Tag {
+	tags := []ec2types.Tag{
+		{Key: aws.String("Name"), Value: aws.String(name)},
+		{Key: aws.String("CreatedBy"), Value: aws.String("EKS-Sandbox-Tool")},
+	}
+	if taggedCluster != "" {
+		tags = append(tags, ec2types.Tag{Key: aws.String(clusterNameTag), Value: aws.String(taggedCluster)})
+	}
+	if costCenter != "" {
+		tags = append(tags, ec2types.Tag{Key: aws.String(costCenterTag), Value: aws.String(costCenter)})
+	}
+	if clusterOwner != "" {
+		tags = append(tags, ec2types.Tag{Key: aws.String(ownerTag), Value: aws.String(clusterOwner)})
+	}
+	return tags
+}
+
+// iamTags returns the tags of an IAM role or instance profile created by this tool
+func iamTags() []iamtypes.Tag {
+	tags := []iamtypes.Tag{
+		{Key: aws.String("CreatedBy"), Value: aws.String("EKS-Sandbox-Tool")},
+	}
+	if costCenter != "" {
+		tags = append(tags, iamtypes.Tag{Key: aws.String(costCenterTag), Value: aws.String(costCenter)})
+	}
+	if clusterOwner != "" {
+		tags = append(tags, iamtypes.Tag{Key: aws.String(ownerTag), Value: aws.String(clusterOwner)})
+	}
+	return tags
+}
+
+// cliTags returns the tags of a resource created through the aws CLI in the Key=...,Value=...
+// shorthand, one argument per tag
+func cliTags() []string {
+	tags := []string{"Key=CreatedBy,Value=EKS-Sandbox-Tool"}
+	if costCenter != "" {
+		tags = append(tags, fmt.Sprintf("Key=%s,Value=%s", costCenterTag, costCenter))
+	}
+	if clusterOwner != "" {
+		tags = append(tags, fmt.Sprintf("Key=%s,Value=%s", ownerTag, clusterOwner))
+	}
+	return tags
+}
@@ -0,0 +1,1466 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/AlecAivazis/survey/v2/core"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/eks/types"
+)
+
+// createAnswers holds everything the user chose for a new cluster
+type createAnswers struct {
+	Region      string
+	ClusterName string
+	K8sVersion  string
+	AutoMode    bool
+	// Compute is where pods run without auto mode: computeNodegroup, computeFargate,
+	// computeKarpenter or computeNone
+	Compute       string
+	InstallAddons bool
+	// CNI gives pods their network: cniVPC, or Cilium in its place with cniCiliumENI or cniCiliumOverlay
+	CNI string
+	// NetworkPolicy turns on the network policy controller of the VPC CNI, so NetworkPolicies are enforced
+	NetworkPolicy bool
+	// ZonalShift enables ARC zonal shift, so traffic can be moved away from an availability zone
+	ZonalShift bool
+	// SecretsEncryption is secretsEncryptionOff, secretsEncryptionNew or the ARN of the KMS key that
+	// encrypts Kubernetes secrets
+	SecretsEncryption string
+	// OptionalAddons are addons from the addon catalogue to install next to the default ones
+	OptionalAddons []string
+	// PolicyBundle installs Gatekeeper with a constraint library once the cluster is up
+	PolicyBundle bool
+	// ExistingVPC is the ID of a VPC to place the cluster in; empty creates an isolated VPC
+	ExistingVPC string
+	// SharedVPC places the cluster in the shared sandbox VPC of the region, creating it when it does
+	// not exist yet; sharedVPCID is that VPC once it exists
+	SharedVPC   bool
+	sharedVPCID string
+	// ExistingSubnets are the chosen subnets of ExistingVPC, and subnetZones their availability zones
+	ExistingSubnets []string
+	subnetZones     map[string]string
+	// localZoneSubnets are the chosen Local Zone subnets of ExistingVPC, which are left to nodes
+	localZoneSubnets []string
+	// outpostArn is the Outpost of the chosen subnets when they are Outpost subnets
+	outpostArn string
+	// OutpostInstanceType is the instance type of the control plane of a local cluster on an Outpost
+	OutpostInstanceType string
+	// VPCCIDR is the primary CIDR block of the new VPC
+	VPCCIDR string
+	// PublicSubnets and PrivateSubnets are how many subnets of each kind the VPC CIDR is split into
+	PublicSubnets  int
+	PrivateSubnets int
+	// SecondaryCIDRs are extra IPv4 CIDR blocks associated with the VPC
+	SecondaryCIDRs []string
+	// LocalZones get one subnet each in the new VPC, for nodes close to users
+	LocalZones []string
+	// CustomNetworking gives pods addresses from a secondary CIDR through VPC CNI custom networking
+	CustomNetworking bool
+	// FlowLogs is where VPC flow logs are sent: flowLogsOff, flowLogsCloudWatch or flowLogsS3
+	FlowLogs string
+	// PrivateEndpoint turns off the public API endpoint; the API is reached through an SSM session instead
+	PrivateEndpoint bool
+	// ClusterRole is the name of the cluster role to create or reuse, or the ARN of an existing role
+	ClusterRole string
+	// CostCenter tags every resource created for the cluster so its spend can be attributed
+	CostCenter string
+	// ActivateCostTag activates the cost center tag as a cost allocation tag
+	ActivateCostTag bool
+	// Purpose says what the cluster is for, recorded in the cluster registry
+	Purpose string
+	// PauseHours is when the nodes are paused on weekdays, e.g. "19:00-08:00 Europe/London"; empty never
+	PauseHours string
+}
+
+// defaultClusterRole is the cluster role created when the user does not pick one
+const defaultClusterRole = "EKSClusterRole"
+
+var (
+	roleNamePattern = regexp.MustCompile(`^[\w+=,.@-]{1,64}$`)
+	roleArnPattern  = regexp.MustCompile(`^arn:aws[a-z-]*:iam::\d{12}:role/[\w+=,.@/-]+$`)
+)
+
+// runCreateCommand implements `est create`, which skips the main menu. With --defaults every
+// question not answered through the environment or --answers takes its default.
+func runCreateCommand(ctx context.Context, timer *stepTimer, args []string) error {
+	usage := validationErr("usage: est create [--defaults] [--name <name>] [--region <region>]")
+
+	var name, region string
+	fs := flag.NewFlagSet("create", flag.ContinueOnError)
+	fs.BoolVar(&acceptDefaults, "defaults", false, "Create a sandbox without prompts, taking the default of every question")
+	fs.StringVar(&name, "name", "", "Cluster name without the cluster prefix (default quick-<date>-<time> with --defaults)")
+	fs.StringVar(&region, "region", "", "Region to create the cluster in")
+	if err := fs.Parse(args); err != nil || fs.NArg() > 0 {
+		return usage
+	}
+	if name != "" {
+		presetAnswers[answerKey("EST_CLUSTER_NAME")] = name
+	}
+	if region != "" {
+		presetAnswers[answerKey("EST_REGION")] = region
+	}
+	if err := runCreate(ctx, timer); err != nil {
+		return err
+	}
+	return timer.PrintSummary(summaryWriter(), *outputFormat)
+}
+
+// runCreate prompts for the cluster settings, lets the user review them and then provisions
+// the VPC, IAM role, cluster and addons
+func runCreate(ctx context.Context, timer *stepTimer) error {
+	if err := requireWritable("Create Cluster"); err != nil {
+		return err
+	}
+	answers, err := collectCreateAnswers(ctx, true)
+	if err != nil {
+		return err
+	}
+	proceed, err := reviewCreateAnswers(ctx, answers)
+	if err != nil {
+		return err
+	}
+	if !proceed {
+		infof("Cluster creation cancelled. Nothing was changed in AWS.")
+		return nil
+	}
+	return provisionCluster(ctx, timer, answers)
+}
+
+// createQuestion is one setting of a new cluster that can be asked and later edited on the review screen
+type createQuestion struct {
+	Label string
+	Env   string
+	Value func(a *createAnswers) string
+	Ask   func(ctx context.Context, a *createAnswers) error
+	// Skip reports that the question does not apply given the other answers
+	Skip func(a *createAnswers) bool
+}
+
+// newVPCOnly skips questions about the layout of a VPC the tool creates
+func newVPCOnly(a *createAnswers) bool {
+	return a.ExistingVPC != "" || a.sharedVPCID != ""
+}
+
+// isolatedVPCOnly skips questions about resources that belong to a single cluster's VPC
+func isolatedVPCOnly(a *createAnswers) bool {
+	return a.ExistingVPC != "" || a.SharedVPC
+}
+
+// existingVPCOnly skips questions about a reused VPC
+func existingVPCOnly(a *createAnswers) bool {
+	return a.ExistingVPC == ""
+}
+
+// outpostOnly skips questions about local clusters when the chosen subnets are not on an Outpost
+func outpostOnly(a *createAnswers) bool {
+	return a.ExistingVPC == "" || a.outpostArn == ""
+}
+
+// createQuestions returns the create prompts in the order they are asked. askName is false
+// when only reusable settings are wanted, e.g. when saving a template.
+func createQuestions(askName bool) []createQuestion {
+	questions := []createQuestion{
+		{Label: "Region", Env: "EST_REGION", Value: func(a *createAnswers) string { return a.Region }, Ask: askRegion},
+	}
+	if askName {
+		questions = append(questions, createQuestion{
+			Label: "Cluster name", Env: "EST_CLUSTER_NAME", Value: func(a *createAnswers) string { return a.ClusterName }, Ask: askClusterName,
+		})
+	}
+	return append(questions,
+		createQuestion{Label: "Cost center", Env: "EST_COST_CENTER", Value: func(a *createAnswers) string { return a.CostCenter }, Ask: askCostCenter},
+		createQuestion{Label: "Activate cost tag", Env: "EST_ACTIVATE_COST_TAG", Value: func(a *createAnswers) string { return yesNo(a.ActivateCostTag) }, Ask: askActivateCostTag, Skip: func(a *createAnswers) bool { return a.CostCenter == "" }},
+		createQuestion{Label: "Purpose", Env: "EST_PURPOSE", Value: func(a *createAnswers) string { return a.Purpose }, Ask: askPurpose, Skip: registryOnly},
+		createQuestion{Label: "Kubernetes version", Env: "EST_K8S_VERSION", Value: func(a *createAnswers) string { return a.K8sVersion }, Ask: askK8sVersion},
+		createQuestion{Label: "Auto mode", Env: "EST_AUTO_MODE", Value: func(a *createAnswers) string { return yesNo(a.AutoMode) }, Ask: askAutoMode},
+		createQuestion{Label: "Compute", Env: "EST_COMPUTE", Value: func(a *createAnswers) string { return a.Compute }, Ask: askCompute, Skip: autoModeOnly},
+		createQuestion{Label: "Zonal shift", Env: "EST_ZONAL_SHIFT", Value: func(a *createAnswers) string { return yesNo(a.ZonalShift) }, Ask: askZonalShift},
+		createQuestion{Label: "Secrets encryption", Env: "EST_SECRETS_ENCRYPTION", Value: func(a *createAnswers) string { return firstNonEmpty(a.SecretsEncryption, secretsEncryptionOff) }, Ask: askSecretsEncryption},
+		createQuestion{Label: "Install addons", Env: "EST_INSTALL_ADDONS", Value: func(a *createAnswers) string { return yesNo(a.InstallAddons) }, Ask: askInstallAddons, Skip: autoModeOnly},
+		createQuestion{Label: "CNI", Env: "EST_CNI", Value: func(a *createAnswers) string { return firstNonEmpty(a.CNI, cniVPC) }, Ask: askCNI, Skip: autoModeOnly},
+		createQuestion{Label: "Network policies", Env: "EST_NETWORK_POLICY", Value: func(a *createAnswers) string { return yesNo(a.NetworkPolicy) }, Ask: askNetworkPolicy, Skip: vpcCNIOnly},
+		createQuestion{Label: "Optional addons", Env: "EST_OPTIONAL_ADDONS", Value: func(a *createAnswers) string { return strings.Join(a.OptionalAddons, ", ") }, Ask: askOptionalAddons},
+		createQuestion{Label: "Policy bundle", Env: "EST_POLICY_BUNDLE", Value: func(a *createAnswers) string { return yesNo(a.PolicyBundle) }, Ask: askPolicyBundle},
+		createQuestion{Label: "VPC", Env: "EST_VPC", Value: func(a *createAnswers) string { return vpcAnswer(a) }, Ask: askVPC},
+		createQuestion{Label: "Subnets", Env: "EST_SUBNETS", Value: func(a *createAnswers) string { return strings.Join(a.ExistingSubnets, ", ") }, Ask: askExistingSubnets, Skip: existingVPCOnly},
+		createQuestion{Label: "Outpost instance type", Env: "EST_OUTPOST_INSTANCE_TYPE", Value: func(a *createAnswers) string { return a.OutpostInstanceType }, Ask: askOutpostInstanceType, Skip: outpostOnly},
+		createQuestion{Label: "VPC CIDR", Env: "EST_VPC_CIDR", Value: func(a *createAnswers) string { return a.VPCCIDR }, Ask: askVPCCIDR, Skip: newVPCOnly},
+		createQuestion{Label: "Public subnets", Env: "EST_PUBLIC_SUBNETS", Value: func(a *createAnswers) string { return strconv.Itoa(a.PublicSubnets) }, Ask: askPublicSubnets, Skip: newVPCOnly},
+		createQuestion{Label: "Private subnets", Env: "EST_PRIVATE_SUBNETS", Value: func(a *createAnswers) string { return strconv.Itoa(a.PrivateSubnets) }, Ask: askPrivateSubnets, Skip: newVPCOnly},
+		createQuestion{Label: "Secondary VPC CIDRs", Env: "EST_SECONDARY_CIDRS", Value: func(a *createAnswers) string { return cidrList(a.SecondaryCIDRs) }, Ask: askSecondaryCIDRs, Skip: newVPCOnly},
+		createQuestion{Label: "Local Zone subnets", Env: "EST_LOCAL_ZONES", Value: func(a *createAnswers) string { return strings.Join(a.LocalZones, ", ") }, Ask: askLocalZones, Skip: isolatedVPCOnly},
+		createQuestion{Label: "Custom networking", Env: "EST_CUSTOM_NETWORKING", Value: func(a *createAnswers) string { return yesNo(a.CustomNetworking) }, Ask: askCustomNetworking, Skip: isolatedVPCOnly},
+		createQuestion{Label: "Flow logs", Env: "EST_FLOW_LOGS", Value: func(a *createAnswers) string { return firstNonEmpty(a.FlowLogs, flowLogsOff) }, Ask: askFlowLogs, Skip: newVPCOnly},
+		createQuestion{Label: "Private endpoint", Env: "EST_PRIVATE_ENDPOINT", Value: func(a *createAnswers) string { return yesNo(a.PrivateEndpoint) }, Ask: askPrivateEndpoint, Skip: isolatedVPCOnly},
+		createQuestion{Label: "Cluster role", Env: "EST_CLUSTER_ROLE", Value: func(a *createAnswers) string { return a.ClusterRole }, Ask: askClusterRole},
+		createQuestion{Label: "Pause hours", Env: "EST_PAUSE_HOURS", Value: func(a *createAnswers) string { return a.PauseHours }, Ask: askPauseHours, Skip: managedNodegroupOnly},
+	)
+}
+
+// newCreateAnswers returns answers holding the defaults of the yes/no questions, so each prompt
+// offers its default at first and the current answer when edited on the review screen
+func newCreateAnswers() *createAnswers {
+	return &createAnswers{
+		AutoMode:         confirmDefault("EST_AUTO_MODE"),
+		Compute:          computeNodegroup,
+		CNI:              cniVPC,
+		ZonalShift:       confirmDefault("EST_ZONAL_SHIFT"),
+		InstallAddons:    confirmDefault("EST_INSTALL_ADDONS"),
+		PolicyBundle:     confirmDefault("EST_POLICY_BUNDLE"),
+		CustomNetworking: confirmDefault("EST_CUSTOM_NETWORKING"),
+		NetworkPolicy:    confirmDefault("EST_NETWORK_POLICY"),
+		PrivateEndpoint:  confirmDefault("EST_PRIVATE_ENDPOINT"),
+		ActivateCostTag:  confirmDefault("EST_ACTIVATE_COST_TAG"),
+	}
+}
+
+// collectCreateAnswers asks every create question before anything is changed in AWS
+func collectCreateAnswers(ctx context.Context, askName bool) (*createAnswers, error) {
+	answers := newCreateAnswers()
+	for _, q := range createQuestions(askName) {
+		if q.Skip != nil && q.Skip(answers) {
+			continue
+		}
+		if err := q.Ask(ctx, answers); err != nil {
+			return nil, err
+		}
+	}
+	if err := requireInputs(); err != nil {
+		return nil, err
+	}
+	if err := answers.validate(); err != nil {
+		return nil, err
+	}
+	return answers, nil
+}
+
+// vpcAnswer is the answer to the VPC question: new, shared or the ID of an existing VPC
+func vpcAnswer(a *createAnswers) string {
+	if a.SharedVPC {
+		return hostingVPCShared
+	}
+	return firstNonEmpty(a.ExistingVPC, "new")
+}
+
+// validate rejects combinations of answers that cannot be provisioned
+func (a *createAnswers) validate() error {
+	if err := activeGuardrails.checkRegion(a.Region); err != nil {
+		return err
+	}
+	if err := a.validateCilium(); err != nil {
+		return err
+	}
+	if compute := a.compute(); compute == computeNodegroup || compute == computeKarpenter {
+		if err := activeGuardrails.checkInstanceType(computeNodeInstanceType); err != nil {
+			return err
+		}
+	}
+	if a.sharedVPCID != "" {
+		// The layout of the shared VPC was fixed by the cluster that created it
+		return nil
+	}
+	if a.ExistingVPC != "" {
+		if a.outpostArn != "" {
+			// The control plane of a local cluster runs on the Outpost, in its one zone
+			if a.AutoMode {
+				return validationErr("auto mode is not available for local clusters on Outposts; disable auto mode")
+			}
+			if a.ZonalShift {
+				return validationErr("zonal shift is not available for local clusters on Outposts; disable zonal shift")
+			}
+			return nil
+		}
+		zones := map[string]bool{}
+		for _, subnetID := range a.ExistingSubnets {
+			zones[a.subnetZones[subnetID]] = true
+		}
+		if len(zones) < 2 {
+			return validationErr("EKS needs subnets in at least two availability zones; choose subnets of VPC %s in two or more zones", a.ExistingVPC)
+		}
+		return nil
+	}
+	if a.CustomNetworking && a.PrivateEndpoint {
+		return validationErr("custom networking is configured with kubectl, which cannot reach a private endpoint; disable one of them")
+	}
+	if names := kubectlCatalogueAddons(a.OptionalAddons); len(names) > 0 && a.PrivateEndpoint {
+		return validationErr("%s is installed with kubectl, which cannot reach a private endpoint; deselect it or keep the endpoint public", strings.Join(names, ", "))
+	}
+	if a.PolicyBundle && a.PrivateEndpoint {
+		return validationErr("the policy bundle is installed with kubectl, which cannot reach a private endpoint; install it through the SSM tunnel instead")
+	}
+	if a.CustomNetworking && a.AutoMode {
+		return validationErr("custom networking configures the VPC CNI, which auto mode clusters do not use; disable auto mode or custom networking")
+	}
+	if a.PublicSubnets < 1 {
+		return validationErr("at least one public subnet is required for the Internet Gateway")
+	}
+	if a.PublicSubnets+a.PrivateSubnets < 2 {
+		return validationErr("EKS needs subnets in at least two availability zones; ask for two or more subnets")
+	}
+	if _, err := splitSubnets(a.VPCCIDR, a.PublicSubnets+a.PrivateSubnets+len(a.LocalZones)); err != nil {
+		return validationErr("%v", err)
+	}
+	if _, err := parseSecondaryCIDRs(cidrList(a.SecondaryCIDRs), a.VPCCIDR); err != nil {
+		return validationErr("secondary CIDRs: %v", err)
+	}
+	if a.CustomNetworking && cidrsOverlap(a.VPCCIDR, podCIDR) {
+		return validationErr("VPC CIDR %s overlaps the custom networking pod CIDR %s", a.VPCCIDR, podCIDR)
+	}
+	for _, cidr := range a.SecondaryCIDRs {
+		if a.CustomNetworking && cidrsOverlap(cidr, podCIDR) {
+			return validationErr("secondary CIDR %s overlaps the custom networking pod CIDR %s", cidr, podCIDR)
+		}
+	}
+	if a.Compute == computeFargate && !a.AutoMode && a.PrivateSubnets < 1 {
+		return validationErr("Fargate only runs pods in private subnets; ask for at least one private subnet or choose other compute")
+	}
+	if a.Compute == computeKarpenter && !a.AutoMode && a.PrivateEndpoint {
+		return validationErr("Karpenter is installed with helm, which cannot reach a private endpoint; choose other compute or keep the endpoint public")
+	}
+	if a.CustomNetworking && !a.InstallAddons {
+		return validationErr("custom networking needs the vpc-cni addon; enable the addons or disable custom networking")
+	}
+	return nil
+}
+
+// reviewCreateAnswers shows all answers on one screen and lets the user edit any of them
+// before proceeding. It returns false when the user cancels.
+func reviewCreateAnswers(ctx context.Context, answers *createAnswers) (bool, error) {
+	if nonInteractive() {
+		if acceptDefaults {
+			fmt.Println("\nCreating a cluster with these settings:")
+			printCreateAnswers(answers)
+		}
+		return true, nil
+	}
+	const proceed, cancel = "Create the cluster", "Cancel"
+
+	questions := createQuestions(true)
+	// Questions that did not apply before an edit are asked as soon as they do
+	asked := map[string]bool{}
+	for _, q := range questions {
+		asked[q.Env] = q.Skip == nil || !q.Skip(answers)
+	}
+	for {
+		fmt.Println("\nReview the cluster settings:")
+		printCreateAnswers(answers)
+		options := []string{proceed}
+		for _, q := range questions {
+			if q.Skip == nil || !q.Skip(answers) {
+				options = append(options, "Edit "+strings.ToLower(q.Label))
+			}
+		}
+		options = append(options, cancel)
+
+		var choice string
+		if err := survey.AskOne(&survey.Select{Message: "Proceed?", Options: options, Default: proceed}, &choice); err != nil {
+			return false, wrapErr("reading review choice", err)
+		}
+		switch choice {
+		case proceed:
+			if err := answers.validate(); err != nil {
+				errorf("%v", err)
+				continue
+			}
+			return true, nil
+		case cancel:
+			return false, nil
+		}
+		for _, q := range questions {
+			if choice == "Edit "+strings.ToLower(q.Label) {
+				if err := editAnswer(q.Env, func() error { return q.Ask(ctx, answers) }); err != nil {
+					return false, err
+				}
+			}
+		}
+		for _, q := range questions {
+			if asked[q.Env] || (q.Skip != nil && q.Skip(answers)) {
+				continue
+			}
+			if err := editAnswer(q.Env, func() error { return q.Ask(ctx, answers) }); err != nil {
+				return false, err
+			}
+			asked[q.Env] = true
+		}
+	}
+}
+
+// printCreateAnswers lists the settings of the cluster about to be created
+func printCreateAnswers(answers *createAnswers) {
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, q := range createQuestions(true) {
+		if q.Skip == nil || !q.Skip(answers) {
+			fmt.Fprintf(tw, "  %s\t%s\n", q.Label, q.Value(answers))
+		}
+	}
+	tw.Flush()
+}
+
+// compute returns where pods of the new cluster run when the tool has to provide compute. Auto
+// mode brings its own and local clusters on Outposts only support self-managed nodes.
+func (a *createAnswers) compute() string {
+	if a.AutoMode || a.outpostArn != "" {
+		return computeNone
+	}
+	return firstNonEmpty(a.Compute, computeNone)
+}
+
+// installsDefaultAddons reports whether coredns, kube-proxy and vpc-cni are installed, which
+// auto mode clusters do not need
+func (a *createAnswers) installsDefaultAddons() bool {
+	return a.InstallAddons && !a.AutoMode
+}
+
+// clusterAddons returns the default addons to install, all but vpc-cni when Cilium replaces it
+func (a *createAnswers) clusterAddons() []string {
+	if !a.cilium() {
+		return defaultAddons
+	}
+	return slices.DeleteFunc(slices.Clone(defaultAddons), func(addon string) bool { return addon == "vpc-cni" })
+}
+
+// plannedSteps returns the timed steps provisioning runs for these answers, in order, so the
+// time left can be estimated from past runs
+func (a *createAnswers) plannedSteps() []string {
+	steps := []string{"IAM", "VPC", "cluster"}
+	if *ciMode {
+		steps = append(steps, "cluster-active")
+	}
+	if a.cilium() {
+		steps = append(steps, "cilium")
+	}
+	if a.compute() != computeNone {
+		steps = append(steps, "compute")
+	}
+	if a.installsDefaultAddons() {
+		steps = append(steps, "addons")
+	}
+	if a.CustomNetworking {
+		steps = append(steps, "custom-networking")
+	}
+	if len(a.OptionalAddons) > 0 {
+		steps = append(steps, "optional-addons")
+	}
+	if a.PolicyBundle {
+		steps = append(steps, "policy")
+	}
+	if a.PrivateEndpoint {
+		steps = append(steps, "ssm-tunnel")
+	}
+	return steps
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+func askRegion(ctx context.Context, a *createAnswers) error {
+	// Create a list of regions
+	regionNames := []string{
+		"us-east-1",
+		"us-east-2",
+		"us-west-1",
+		"us-west-2",
+		"af-south-1",
+		"ap-east-1",
+		"ap-south-1",
+		"ap-northeast-3",
+		"ap-northeast-2",
+		"ap-southeast-1",
+		"ap-southeast-2",
+		"ca-central-1",
+		"eu-central-1",
+		"eu-west-1",
+		"eu-west-2",
+		"eu-south-1",
+		"eu-west-3",
+		"eu-north-1",
+		"me-south-1",
+		"sa-east-1",
+	}
+	prompt := &survey.Select{
+		Message:  "Select a region:",
+		Options:  regionNames,
+		Default:  "eu-west-1",
+		PageSize: 15,
+	}
+	if slices.Contains(regionNames, sessionRegion) {
+		prompt.Default = sessionRegion
+	}
+	if a.Region != "" {
+		prompt.Default = a.Region
+	}
+	if err := askOne("EST_REGION", prompt, &a.Region); err != nil {
+		return wrapErr("reading region", err)
+	}
+	notifier.Region = a.Region
+	// The account is needed once provisioning starts; look it up while the remaining questions are answered
+	PrefetchAccountDetails(a.Region)
+	return nil
+}
+
+func askClusterName(ctx context.Context, a *createAnswers) error {
+	// Prompt for EKS Cluster Name
+	var clusterName string
+	promptCluster := &survey.Input{
+		Message: "Enter the name of the EKS cluster:",
+		Default: strings.TrimPrefix(a.ClusterName, userConfig.ClusterPrefix),
+	}
+	if *ciMode && promptCluster.Default == "" {
+		promptCluster.Default = ciClusterName()
+	}
+	if acceptDefaults && promptCluster.Default == "" {
+		promptCluster.Default = "quick-" + time.Now().Format("0102-150405")
+	}
+	if err := askOne("EST_CLUSTER_NAME", promptCluster, &clusterName, survey.WithValidator(survey.Required), survey.WithValidator(validClusterName)); err != nil {
+		return wrapErr("reading cluster name", err)
+	}
+	a.ClusterName = userConfig.ClusterPrefix + clusterName
+	notifier.Cluster = a.ClusterName
+	return nil
+}
+
+func askK8sVersion(ctx context.Context, a *createAnswers) error {
+	defaultVersion := a.K8sVersion
+	if defaultVersion == "" {
+		// Fetch the latest EKS version from AWS
+		latestVersion, err := GetLatestEKSVersion(ctx, a.Region)
+		if err != nil {
+			return wrapErr("fetching latest EKS version", err)
+		}
+		defaultVersion = latestVersion
+	}
+	// Prompt for Kubernetes version
+	promptK8sVersion := &survey.Input{
+		Message: "Enter the Kubernetes version default:",
+		Default: defaultVersion,
+	}
+	if err := askOne("EST_K8S_VERSION", promptK8sVersion, &a.K8sVersion, survey.WithValidator(validK8sVersion)); err != nil {
+		return wrapErr("reading Kubernetes version", err)
+	}
+	return nil
+}
+
+func askAutoMode(ctx context.Context, a *createAnswers) error {
+	//prompt for auto mode enabled or not
+	autoModePrompt := &survey.Confirm{
+		Message: "Do you want to enable auto mode for the cluster?",
+		Default: a.AutoMode,
+	}
+	if err := askOne("EST_AUTO_MODE", autoModePrompt, &a.AutoMode); err != nil {
+		return wrapErr("reading auto mode", err)
+	}
+	if a.AutoMode {
+		infof("Auto mode launches nodes as pods need them and runs pod networking, kube-proxy and DNS itself, so no node group is created and the CoreDNS, kube-proxy and VPC CNI addons are not installed")
+	}
+	return nil
+}
+
+func askZonalShift(ctx context.Context, a *createAnswers) error {
+	zonalShiftPrompt := &survey.Confirm{
+		Message: "Enable ARC zonal shift to experiment with evacuating an availability zone?",
+		Default: a.ZonalShift,
+	}
+	if err := askOne("EST_ZONAL_SHIFT", zonalShiftPrompt, &a.ZonalShift); err != nil {
+		return wrapErr("reading zonal shift choice", err)
+	}
+	return nil
+}
+
+func askInstallAddons(ctx context.Context, a *createAnswers) error {
+	//Ask to install addons
+	confirmPrompt := &survey.Confirm{
+		Message: "Do you want to install CoreDNS, Kubeproxy, VPC_CNI  addons ?",
+		Default: a.InstallAddons,
+	}
+	if err := askOne("EST_INSTALL_ADDONS", confirmPrompt, &a.InstallAddons); err != nil {
+		return wrapErr("reading addon choice", err)
+	}
+	return nil
+}
+
+func askPolicyBundle(ctx context.Context, a *createAnswers) error {
+	policyPrompt := &survey.Confirm{
+		Message: "Install Gatekeeper with a constraint library to mirror production admission policies?",
+		Default: a.PolicyBundle,
+	}
+	if err := askOne("EST_POLICY_BUNDLE", policyPrompt, &a.PolicyBundle); err != nil {
+		return wrapErr("reading policy bundle choice", err)
+	}
+	return nil
+}
+
+// newVPCOption is the VPC choice that creates an isolated VPC for the cluster
+const newVPCOption = "new (create an isolated VPC)"
+
+func askVPC(ctx context.Context, a *createAnswers) error {
+	vpcs, err := DescribeVPCs(ctx, a.Region)
+	if err != nil {
+		return wrapErr("listing VPCs", err)
+	}
+	sharedID, err := FindSharedVPC(ctx, a.Region)
+	if err != nil {
+		return wrapErr("looking for the shared VPC", err)
+	}
+	sharedOption := "shared (create a VPC that several sandbox clusters can join)"
+	if sharedID != "" {
+		clusters, err := SharedVPCClusters(ctx, a.Region, sharedID)
+		if err != nil {
+			return wrapErr("counting the clusters of the shared VPC", err)
+		}
+		sharedOption = fmt.Sprintf("shared (join the shared sandbox VPC %s, used by %d clusters)", sharedID, len(clusters))
+	}
+	// The ID comes first so that EST_VPC can be answered with it alone. The shared VPC is only
+	// offered through its own option, so its clusters are always counted.
+	var rows [][]string
+	var listed []vpcInfo
+	for _, vpc := range vpcs {
+		if vpc.ID == sharedID {
+			continue
+		}
+		listed = append(listed, vpc)
+		warning := ""
+		if len(vpc.OpenIngress) > 0 {
+			warning = fmt.Sprintf("[OPEN INGRESS: %s]", strings.Join(vpc.OpenIngress, " "))
+		}
+		rows = append(rows, []string{vpc.ID, vpc.CIDR, strings.ReplaceAll(vpc.Name, ",", " "), warning})
+	}
+	labels := alignedOptions(rows)
+	options := append([]string{newVPCOption, sharedOption}, labels...)
+	defaultOption := newVPCOption
+	if a.SharedVPC {
+		defaultOption = sharedOption
+	}
+	for i, vpc := range listed {
+		if vpc.ID == a.ExistingVPC {
+			defaultOption = labels[i]
+		}
+	}
+	vpcPrompt := &survey.Select{
+		Message:  "Create a new VPC or place the cluster in an existing one? (ID, CIDR, Name)",
+		Options:  options,
+		Default:  defaultOption,
+		PageSize: 15,
+	}
+	var choice string
+	if err := askOne("EST_VPC", vpcPrompt, &choice); err != nil {
+		return wrapErr("reading VPC choice", err)
+	}
+	previous := a.ExistingVPC
+	a.ExistingVPC, a.SharedVPC, a.sharedVPCID = "", false, ""
+	switch choice {
+	case newVPCOption:
+	case sharedOption:
+		a.SharedVPC, a.sharedVPCID = true, sharedID
+		// An SSM instance, pod subnets and Local Zone subnets would serve one cluster only
+		a.PrivateEndpoint, a.CustomNetworking, a.LocalZones = false, false, nil
+	default:
+		a.ExistingVPC = strings.Fields(choice)[0]
+	}
+	if a.ExistingVPC != previous {
+		a.ExistingSubnets, a.subnetZones, a.localZoneSubnets, a.outpostArn = nil, nil, nil, ""
+	}
+	return nil
+}
+
+func askExistingSubnets(ctx context.Context, a *createAnswers) error {
+	subnets, err := DescribeVPCSubnets(ctx, a.Region, a.ExistingVPC)
+	if err != nil {
+		return wrapErr("listing subnets", err)
+	}
+	if len(subnets) == 0 {
+		return validationErr("VPC %s has no subnets", a.ExistingVPC)
+	}
+	var rows [][]string
+	for _, subnet := range subnets {
+		kind := "private"
+		if subnet.Public {
+			kind = "public"
+		}
+		switch {
+		case subnet.OutpostArn != "":
+			kind += " outpost"
+		case subnet.LocalZone:
+			kind += " local-zone"
+		}
+		rows = append(rows, []string{subnet.ID, subnet.AZ, subnet.CIDR, kind, strings.ReplaceAll(subnet.Name, ",", " ")})
+	}
+	options := alignedOptions(rows)
+	byLabel := map[string]subnetInfo{}
+	var defaults []string
+	for i, subnet := range subnets {
+		label := options[i]
+		byLabel[label] = subnet
+		if slices.Contains(a.ExistingSubnets, subnet.ID) || slices.Contains(a.localZoneSubnets, subnet.ID) {
+			defaults = append(defaults, label)
+		}
+	}
+	subnetPrompt := &survey.MultiSelect{
+		Message:  "Select the subnets for the cluster (at least two availability zones, or all on one Outpost) (ID, AZ, CIDR, Type, Name):",
+		Options:  options,
+		Default:  defaults,
+		PageSize: 15,
+	}
+	// Local Zone subnets only host nodes, so the control plane needs two zones without them. Outpost
+	// subnets host a local cluster instead and cannot be mixed with regional subnets.
+	spansTwoZones := func(ans interface{}) error {
+		zones, outposts := map[string]bool{}, map[string]bool{}
+		regional := 0
+		if selected, ok := ans.([]core.OptionAnswer); ok {
+			for _, option := range selected {
+				subnet := byLabel[option.Value]
+				switch {
+				case subnet.OutpostArn != "":
+					outposts[subnet.OutpostArn] = true
+				case !subnet.LocalZone:
+					zones[subnet.AZ] = true
+					regional++
+				}
+			}
+		}
+		if len(outposts) > 1 || (len(outposts) == 1 && regional > 0) {
+			return fmt.Errorf("subnets of a local cluster must all be on the same Outpost")
+		}
+		if len(outposts) == 0 && len(zones) < 2 {
+			return fmt.Errorf("select subnets in at least two availability zones, not counting Local Zones")
+		}
+		return nil
+	}
+	var chosen []string
+	if err := askOne("EST_SUBNETS", subnetPrompt, &chosen, survey.WithValidator(spansTwoZones)); err != nil {
+		return wrapErr("reading subnets", err)
+	}
+	a.ExistingSubnets, a.subnetZones, a.localZoneSubnets, a.outpostArn = nil, map[string]string{}, nil, ""
+	for _, label := range chosen {
+		subnet := byLabel[label]
+		if subnet.LocalZone {
+			a.localZoneSubnets = append(a.localZoneSubnets, subnet.ID)
+			continue
+		}
+		a.ExistingSubnets = append(a.ExistingSubnets, subnet.ID)
+		a.subnetZones[subnet.ID] = subnet.AZ
+		a.outpostArn = subnet.OutpostArn
+	}
+	return nil
+}
+
+// encryptionKeyArn returns the key secrets are encrypted with, "" when encryption is off
+func encryptionKeyArn(choice string) string {
+	if choice == secretsEncryptionOff {
+		return ""
+	}
+	return choice
+}
+
+// defaultOutpostInstanceType is the control plane instance type of local clusters when none is given
+const defaultOutpostInstanceType = "m5.large"
+
+func askOutpostInstanceType(ctx context.Context, a *createAnswers) error {
+	instanceTypePrompt := &survey.Input{
+		Message: "The subnets are on an Outpost, so a local cluster is created. Enter the control plane instance type:",
+		Default: firstNonEmpty(a.OutpostInstanceType, defaultOutpostInstanceType),
+	}
+	if err := askOne("EST_OUTPOST_INSTANCE_TYPE", instanceTypePrompt, &a.OutpostInstanceType, survey.WithValidator(validInstanceType)); err != nil {
+		return wrapErr("reading Outpost instance type", err)
+	}
+	return nil
+}
+
+// noLocalZones is the choice that creates no Local Zone subnets
+const noLocalZones = "none"
+
+func askLocalZones(ctx context.Context, a *createAnswers) error {
+	zones, err := ListLocalZones(ctx, a.Region)
+	if err != nil {
+		return wrapErr("listing local zones", err)
+	}
+	if len(zones) == 0 {
+		debugf("the account has opted in to no Local Zones of %s", a.Region)
+		a.LocalZones = nil
+		return nil
+	}
+	selected := a.LocalZones
+	if len(selected) == 0 {
+		selected = []string{noLocalZones}
+	}
+	localZonePrompt := &survey.MultiSelect{
+		Message: "Select Local Zones to create a node subnet in:",
+		Options: append([]string{noLocalZones}, zones...),
+		Default: selected,
+	}
+	if err := askOne("EST_LOCAL_ZONES", localZonePrompt, &selected); err != nil {
+		return wrapErr("reading local zones", err)
+	}
+	a.LocalZones = nil
+	for _, zone := range selected {
+		if zone != noLocalZones {
+			a.LocalZones = append(a.LocalZones, zone)
+		}
+	}
+	return nil
+}
+
+func askVPCCIDR(ctx context.Context, a *createAnswers) error {
+	cidrPrompt := &survey.Input{
+		Message: "Enter the VPC CIDR block:",
+		Default: defaultVPCCIDR,
+	}
+	if a.VPCCIDR != "" {
+		cidrPrompt.Default = a.VPCCIDR
+	}
+	if err := askOne("EST_VPC_CIDR", cidrPrompt, &a.VPCCIDR, survey.WithValidator(func(ans interface{}) error {
+		_, err := parseVPCCIDR(ans.(string))
+		return err
+	})); err != nil {
+		return wrapErr("reading VPC CIDR", err)
+	}
+	a.VPCCIDR = strings.TrimSpace(a.VPCCIDR)
+	return nil
+}
+
+func askPublicSubnets(ctx context.Context, a *createAnswers) error {
+	return askSubnetCount("EST_PUBLIC_SUBNETS", "How many public subnets should the VPC have?", 2, &a.PublicSubnets)
+}
+
+func askPrivateSubnets(ctx context.Context, a *createAnswers) error {
+	return askSubnetCount("EST_PRIVATE_SUBNETS", "How many private subnets (reaching the internet through a NAT gateway)?", 0, &a.PrivateSubnets)
+}
+
+// askSubnetCount asks for a number of subnets between 0 and 16, keeping a previous answer as the default
+func askSubnetCount(env, message string, defaultCount int, count *int) error {
+	if *count > 0 {
+		defaultCount = *count
+	}
+	var answer string
+	countPrompt := &survey.Input{Message: message, Default: strconv.Itoa(defaultCount)}
+	if err := askOne(env, countPrompt, &answer, survey.WithValidator(func(ans interface{}) error {
+		n, err := strconv.Atoi(strings.TrimSpace(ans.(string)))
+		if err != nil || n < 0 || n > 16 {
+			return fmt.Errorf("enter a number between 0 and 16")
+		}
+		return nil
+	})); err != nil {
+		return wrapErr("reading subnet count", err)
+	}
+	*count, _ = strconv.Atoi(strings.TrimSpace(answer))
+	return nil
+}
+
+func askSecondaryCIDRs(ctx context.Context, a *createAnswers) error {
+	var answer string
+	cidrPrompt := &survey.Input{
+		Message: "Enter additional VPC CIDR blocks, comma separated (or none):",
+		Default: cidrList(a.SecondaryCIDRs),
+	}
+	if err := askOne("EST_SECONDARY_CIDRS", cidrPrompt, &answer, survey.WithValidator(func(ans interface{}) error {
+		_, err := parseSecondaryCIDRs(ans.(string), a.VPCCIDR)
+		return err
+	})); err != nil {
+		return wrapErr("reading secondary CIDRs", err)
+	}
+	a.SecondaryCIDRs, _ = parseSecondaryCIDRs(answer, a.VPCCIDR)
+	return nil
+}
+
+func askCustomNetworking(ctx context.Context, a *createAnswers) error {
+	customNetworkingPrompt := &survey.Confirm{
+		Message: "Do you want pods to use a secondary 100.64.0.0/16 CIDR (VPC CNI custom networking)?",
+		Default: a.CustomNetworking,
+	}
+	if err := askOne("EST_CUSTOM_NETWORKING", customNetworkingPrompt, &a.CustomNetworking); err != nil {
+		return wrapErr("reading custom networking choice", err)
+	}
+	return nil
+}
+
+func askNetworkPolicy(ctx context.Context, a *createAnswers) error {
+	networkPolicyPrompt := &survey.Confirm{
+		Message: "Do you want the VPC CNI to enforce Kubernetes NetworkPolicies?",
+		Default: a.NetworkPolicy,
+	}
+	if err := askOne("EST_NETWORK_POLICY", networkPolicyPrompt, &a.NetworkPolicy); err != nil {
+		return wrapErr("reading network policy choice", err)
+	}
+	return nil
+}
+
+func askFlowLogs(ctx context.Context, a *createAnswers) error {
+	flowLogsPrompt := &survey.Select{
+		Message: "Record VPC flow logs for debugging network traffic? They go to a log group or bucket deleted with the VPC:",
+		Options: []string{flowLogsOff, flowLogsCloudWatch, flowLogsS3},
+		Default: firstNonEmpty(a.FlowLogs, flowLogsOff),
+	}
+	if err := askOne("EST_FLOW_LOGS", flowLogsPrompt, &a.FlowLogs); err != nil {
+		return wrapErr("reading flow logs choice", err)
+	}
+	return nil
+}
+
+func askPrivateEndpoint(ctx context.Context, a *createAnswers) error {
+	privateEndpointPrompt := &survey.Confirm{
+		Message: "Make the API server private and reach it through an SSM port-forwarding session?",
+		Default: a.PrivateEndpoint,
+	}
+	if err := askOne("EST_PRIVATE_ENDPOINT", privateEndpointPrompt, &a.PrivateEndpoint); err != nil {
+		return wrapErr("reading private endpoint choice", err)
+	}
+	return nil
+}
+
+func askClusterRole(ctx context.Context, a *createAnswers) error {
+	rolePrompt := &survey.Input{
+		Message: "Enter the IAM role name to create or reuse for the cluster, or the ARN of an existing role:",
+		Default: defaultClusterRole,
+	}
+	if a.ClusterRole != "" {
+		rolePrompt.Default = a.ClusterRole
+	}
+	validate := func(ans interface{}) error {
+		role, _ := ans.(string)
+		if !roleNamePattern.MatchString(role) && !roleArnPattern.MatchString(role) {
+			return fmt.Errorf("%q is neither a valid IAM role name nor a role ARN", role)
+		}
+		return nil
+	}
+	if err := askOne("EST_CLUSTER_ROLE", rolePrompt, &a.ClusterRole, survey.WithValidator(validate)); err != nil {
+		return wrapErr("reading cluster role", err)
+	}
+	return nil
+}
+
+// clusterNetwork is the VPC a cluster is placed in
+type clusterNetwork struct {
+	VPCID          string
+	Subnets        []string
+	SecurityGroups []string
+	// PodSubnets are the custom networking pod subnets keyed by availability zone
+	PodSubnets map[string]string
+	// SSMInstance is the instance SSM sessions forward to a private API endpoint through
+	SSMInstance string
+	// LocalZoneSubnets are subnets for nodes in Local Zones; the control plane does not use them
+	LocalZoneSubnets []string
+	// PrivateSubnets are the subnets of Subnets without a route to the Internet Gateway, where
+	// nodes are placed; empty for existing VPCs
+	PrivateSubnets []string
+}
+
+// createClusterVPC creates an isolated VPC with the subnet layout, secondary CIDRs and pod subnets of answers
+func createClusterVPC(ctx context.Context, region string, answers *createAnswers) (*clusterNetwork, error) {
+	naming := userConfig.Naming
+	names := newResourceNames(answers.ClusterName)
+	if answers.SharedVPC {
+		// The shared VPC outlives the cluster that creates it, so its resources carry no ClusterName tag
+		names = newResourceNames(sharedVPCName)
+		taggedCluster = ""
+		defer func() { taggedCluster = answers.ClusterName }()
+	}
+	vpcName, err := names.render("vpc", naming.VPC)
+	if err != nil {
+		return nil, validationErr("%v", err)
+	}
+	vpcID, err := CreateVPC(ctx, region, answers.VPCCIDR, vpcName)
+	if err != nil {
+		return nil, wrapErr("creating VPC", err)
+	}
+	successf("Created VPC ID: %s", vpcID)
+	if answers.SharedVPC {
+		if err := TagSharedVPC(ctx, region, vpcID); err != nil {
+			return nil, wrapErr("tagging shared VPC", err)
+		}
+	}
+
+	zones, err := ListAvailabilityZones(ctx, region)
+	if err != nil {
+		return nil, wrapErr("listing availability zones", err)
+	}
+	if len(zones) < 2 {
+		return nil, wrapErr("creating subnets", fmt.Errorf("region %s has fewer than two availability zones", region))
+	}
+	subnetCIDRs, err := splitSubnets(answers.VPCCIDR, answers.PublicSubnets+answers.PrivateSubnets+len(answers.LocalZones))
+	if err != nil {
+		return nil, validationErr("%v", err)
+	}
+	// Local Zone subnets take the CIDRs after the regional ones
+	localZoneCIDRs := subnetCIDRs[answers.PublicSubnets+answers.PrivateSubnets:]
+	subnetCIDRs = subnetCIDRs[:answers.PublicSubnets+answers.PrivateSubnets]
+
+	// Public subnets come first in the VPC CIDR, private subnets after them; each kind is spread over the zones
+	var publicSubnets, privateSubnets []string
+	for i, cidr := range subnetCIDRs {
+		key, text, kind, index := "subnet", naming.Subnet, &publicSubnets, i
+		if i >= answers.PublicSubnets {
+			index = i - answers.PublicSubnets
+			key, text, kind = "private_subnet", naming.PrivateSubnet, &privateSubnets
+		}
+		name, err := names.subnet(zones[index%len(zones)], index+1).render(key, text)
+		if err != nil {
+			return nil, validationErr("%v", err)
+		}
+		subnetID, err := CreateSubnet(ctx, region, vpcID, cidr, name, zones[index%len(zones)])
+		if err != nil {
+			return nil, wrapErr("creating subnet "+name, err)
+		}
+		*kind = append(*kind, subnetID)
+		successf("Created subnet %s: %s (%s, %s)", name, subnetID, cidr, zones[index%len(zones)])
+	}
+	err = EnableAutoAssignPublicIP(ctx, region, publicSubnets)
+	if err != nil {
+		return nil, wrapErr("enabling auto-assign public IPv4", err)
+	}
+	successf("Successfully enabled auto-assign public IPv4 for all public subnets.")
+
+	igwName, err := names.render("internet_gateway", naming.InternetGateway)
+	if err != nil {
+		return nil, validationErr("%v", err)
+	}
+	igwID, err := CreateInternetGateway(ctx, region, igwName, vpcID)
+	if err != nil {
+		return nil, wrapErr("creating Internet Gateway", err)
+	}
+	successf("Created Internet Gateway ID: %s", igwID)
+
+	routeTableName, err := names.render("route_table", naming.RouteTable)
+	if err != nil {
+		return nil, validationErr("%v", err)
+	}
+	routeTableID, err := CreateRouteTable(ctx, region, vpcID, routeTableName)
+	if err != nil {
+		return nil, wrapErr("creating Route Table", err)
+	}
+	successf("Created Route Table ID: %s", routeTableID)
+
+	if err := CreateRoute(ctx, region, routeTableID, "0.0.0.0/0", igwID); err != nil {
+		return nil, wrapErr("creating default route", err)
+	}
+	for _, subnetID := range publicSubnets {
+		if err := AssociateRouteTable(ctx, region, routeTableID, subnetID); err != nil {
+			return nil, wrapErr("associating route table with "+subnetID, err)
+		}
+	}
+	// Local Zone subnets reach the internet like the private subnets when there are any
+	localZoneRouteTableID := routeTableID
+
+	if len(privateSubnets) > 0 {
+		stepf("Creating NAT gateway for the private subnets...")
+		natName, err := names.render("nat_gateway", naming.NATGateway)
+		if err != nil {
+			return nil, validationErr("%v", err)
+		}
+		natID, err := CreateNatGateway(ctx, region, vpcID, publicSubnets[0], natName)
+		if err != nil {
+			return nil, wrapErr("creating NAT gateway", err)
+		}
+		successf("Created NAT gateway ID: %s", natID)
+
+		privateRouteTableName, err := names.render("private_route_table", naming.PrivateRouteTable)
+		if err != nil {
+			return nil, validationErr("%v", err)
+		}
+		privateRouteTableID, err := CreateRouteTable(ctx, region, vpcID, privateRouteTableName)
+		if err != nil {
+			return nil, wrapErr("creating private Route Table", err)
+		}
+		if err := CreateNatRoute(ctx, region, privateRouteTableID, "0.0.0.0/0", natID); err != nil {
+			return nil, wrapErr("creating NAT route", err)
+		}
+		for _, subnetID := range privateSubnets {
+			if err := AssociateRouteTable(ctx, region, privateRouteTableID, subnetID); err != nil {
+				return nil, wrapErr("associating private route table with "+subnetID, err)
+			}
+		}
+		successf("Created private Route Table ID: %s", privateRouteTableID)
+		localZoneRouteTableID = privateRouteTableID
+	}
+
+	var localZoneSubnets []string
+	for i, zone := range answers.LocalZones {
+		name, err := names.subnet(zone, i+1).render("local_zone_subnet", naming.LocalZoneSubnet)
+		if err != nil {
+			return nil, validationErr("%v", err)
+		}
+		subnetID, err := CreateSubnet(ctx, region, vpcID, localZoneCIDRs[i], name, zone)
+		if err != nil {
+			return nil, wrapErr("creating subnet "+name, err)
+		}
+		if err := AssociateRouteTable(ctx, region, localZoneRouteTableID, subnetID); err != nil {
+			return nil, wrapErr("associating route table with "+subnetID, err)
+		}
+		localZoneSubnets = append(localZoneSubnets, subnetID)
+		successf("Created subnet %s: %s (%s, %s)", name, subnetID, localZoneCIDRs[i], zone)
+	}
+
+	sgName, err := names.render("security_group", naming.SecurityGroup)
+	if err != nil {
+		return nil, validationErr("%v", err)
+	}
+	sgID, err := CreateSecurityGroup(ctx, region, vpcID, sgName, "EKS Security Group")
+	if err != nil {
+		return nil, wrapErr("creating Security Group", err)
+	}
+	successf("Created Security Group ID: %s", sgID)
+
+	for _, cidr := range answers.SecondaryCIDRs {
+		if _, err := AssociateVpcCidrBlock(ctx, region, vpcID, cidr); err != nil {
+			return nil, wrapErr("associating secondary CIDR "+cidr, err)
+		}
+		successf("Associated secondary CIDR %s with VPC %s", cidr, vpcID)
+	}
+
+	network := &clusterNetwork{
+		VPCID:          vpcID,
+		Subnets:        append(publicSubnets, privateSubnets...),
+		SecurityGroups: []string{sgID},
+
+		LocalZoneSubnets: localZoneSubnets,
+		PrivateSubnets:   privateSubnets,
+	}
+	if answers.CustomNetworking {
+		// Every zone that can host nodes needs its own pod subnet and ENIConfig
+		nodeZones := zones[:min(len(zones), max(answers.PublicSubnets, answers.PrivateSubnets))]
+		network.PodSubnets, err = CreatePodSubnets(ctx, region, vpcID, routeTableID, nodeZones, names)
+		if err != nil {
+			return nil, wrapErr("creating pod subnets", err)
+		}
+	}
+
+	if answers.FlowLogs != "" && answers.FlowLogs != flowLogsOff {
+		stepf("Enabling VPC flow logs...")
+		target, err := EnableFlowLogs(ctx, region, vpcID, answers.ClusterName, answers.FlowLogs)
+		if err != nil {
+			return nil, wrapErr("enabling flow logs", err)
+		}
+		successf("VPC flow logs are delivered to %s", target)
+	}
+
+	if answers.PrivateEndpoint {
+		stepf("Launching SSM instance for private API access...")
+		if err := AuthorizeHTTPSWithinGroup(ctx, region, sgID); err != nil {
+			return nil, wrapErr("allowing HTTPS within "+sgID, err)
+		}
+		network.SSMInstance, err = CreateSSMBastion(ctx, region, answers.ClusterName, publicSubnets[0], sgID)
+		if err != nil {
+			return nil, wrapErr("launching SSM instance", err)
+		}
+		successf("Launched SSM instance %s", network.SSMInstance)
+	}
+	return network, nil
+}
+
+// provisionCluster creates the VPC, IAM role, cluster and addons described by answers
+func provisionCluster(ctx context.Context, timer *stepTimer, answers *createAnswers) error {
+	region, clusterName, k8sVersion := answers.Region, answers.ClusterName, answers.K8sVersion
+	costCenter = answers.CostCenter
+	taggedCluster = clusterName
+
+	// Fetch AWS Account ID
+	stepf("Fetching AWS Account ID...")
+	accountID, callerID, err := GetAWSAccountDetails(ctx, region)
+	if err != nil {
+		return wrapErr("fetching AWS Account ID", err)
+	}
+	ciMask(accountID)
+	ciMask(callerID)
+	infof("AWS Account ID: %s", accountID)
+	infof("Performing operations as the identity %s", callerID)
+
+	// Make sure nobody else has or is creating a cluster of the same name
+	release, err := ReserveClusterName(ctx, region, clusterName, callerID)
+	if err != nil {
+		return wrapErr("reserving cluster name "+clusterName, err)
+	}
+	defer release()
+
+	clusterOwner = ownerFromARN(callerID)
+	infof("Resources are tagged %s=%s", ownerTag, clusterOwner)
+	if err := activeGuardrails.checkClusterCount(ctx, region, clusterOwner); err != nil {
+		return err
+	}
+
+	// Refuse addon versions AWS does not support before anything is created
+	var plannedAddons []string
+	if answers.installsDefaultAddons() {
+		plannedAddons = append(plannedAddons, answers.clusterAddons()...)
+	}
+	plannedAddons = append(plannedAddons, eksCatalogueAddons(answers.OptionalAddons)...)
+	var addonVersions map[string]string
+	if len(plannedAddons) > 0 {
+		versions, err := ResolveAddonVersions(ctx, region, k8sVersion, plannedAddons)
+		if err != nil {
+			return wrapErr("checking addon compatibility", err)
+		}
+		addonVersions = versions
+		for _, addon := range plannedAddons {
+			infof("Addon %s will be installed at %s", addon, versions[addon])
+		}
+	}
+
+	if err := runHooks(ctx, hookPreCreate, region, clusterName); err != nil {
+		return wrapErr("running pre-create hooks", err)
+	}
+
+	// EKS Cluster Role
+	if !strings.HasPrefix(answers.ClusterRole, "arn:") || answers.compute() != computeNone {
+		if err := userConfig.IAM.checkPermissionsBoundary(); err != nil {
+			return err
+		}
+	}
+	timer.Plan("create", answers.plannedSteps()...)
+	stopIAM := timer.Start("IAM")
+	roleArn, err := IamOperations(ctx, region, answers.ClusterRole)
+	if err != nil {
+		return wrapErr("preparing cluster role "+answers.ClusterRole, err)
+	}
+	if answers.SecretsEncryption == secretsEncryptionNew {
+		stepf("Creating KMS key for secrets encryption...")
+		if answers.SecretsEncryption, err = CreateEncryptionKey(ctx, region, clusterName, roleArn); err != nil {
+			return wrapErr("creating KMS key", err)
+		}
+	}
+	stopIAM()
+
+	// Use the chosen VPC or create an isolated one
+	stopVPC := timer.Start("VPC")
+	network := &clusterNetwork{VPCID: answers.ExistingVPC, Subnets: answers.ExistingSubnets, LocalZoneSubnets: answers.localZoneSubnets}
+	hostingVPC := "existing"
+	switch {
+	case answers.sharedVPCID != "":
+		network, err = joinSharedVPC(ctx, region, answers.sharedVPCID)
+		if err != nil {
+			return wrapErr("reading shared VPC "+answers.sharedVPCID, err)
+		}
+		hostingVPC = hostingVPCShared
+		infof("Joining shared VPC %s with subnets %s", network.VPCID, strings.Join(network.Subnets, ", "))
+	case answers.ExistingVPC == "":
+		network, err = createClusterVPC(ctx, region, answers)
+		if err != nil {
+			return err
+		}
+		hostingVPC = "isolated"
+		if answers.SharedVPC {
+			hostingVPC = hostingVPCShared
+		}
+	default:
+		infof("Using existing VPC %s with subnets %s", network.VPCID, strings.Join(network.Subnets, ", "))
+	}
+	vpcID := network.VPCID
+	stopVPC()
+
+	// Create EKS Cluster
+	stepf("Creating EKS Cluster...")
+	stopCluster := timer.Start("cluster")
+	var outpost *types.OutpostConfigRequest
+	if answers.outpostArn != "" {
+		infof("The subnets are on Outpost %s, creating a local cluster", answers.outpostArn)
+		outpost = &types.OutpostConfigRequest{
+			OutpostArns:              []string{answers.outpostArn},
+			ControlPlaneInstanceType: aws.String(firstNonEmpty(answers.OutpostInstanceType, defaultOutpostInstanceType)),
+		}
+	}
+	err = CreateEKSCluster(ctx, region, clusterName, roleArn, network.Subnets, network.SecurityGroups, k8sVersion, vpcID, hostingVPC, answers.AutoMode, answers.PrivateEndpoint, answers.ZonalShift, !answers.cilium(), outpost, encryptionKeyArn(answers.SecretsEncryption))
+	if err != nil {
+		return wrapErr("creating EKS Cluster", err)
+	}
+	stopCluster()
+	recordSandbox(ctx, inventoryEntry{
+		Name:       clusterName,
+		Region:     region,
+		Owner:      clusterOwner,
+		CostCenter: costCenter,
+		Purpose:    answers.Purpose,
+		VPCID:      vpcID,
+		HostingVPC: hostingVPC,
+		Created:    time.Now().UTC(),
+		ExpiresAt:  activeGuardrails.expiresAt(),
+	})
+	if len(network.LocalZoneSubnets) > 0 {
+		infof("Run nodes in the Local Zones with subnets %s", strings.Join(network.LocalZoneSubnets, ", "))
+	}
+
+	if answers.CostCenter != "" && answers.ActivateCostTag {
+		if err := ActivateCostAllocationTag(ctx); err != nil {
+			warnf("Unable to activate the %s cost allocation tag: %v", costCenterTag, err)
+		} else {
+			successf("Activated %s as a cost allocation tag", costCenterTag)
+		}
+	}
+
+	// From here on the cluster exists, so any failure leaves a partially provisioned sandbox
+	if err := ciSetOutput("cluster-name", clusterName); err != nil {
+		return partialErr("setting step output", err)
+	}
+
+	// Workflows need a reachable cluster, so wait for it and hand over a kubeconfig
+	var kubeconfigPath string
+	if *ciMode {
+		stopWait := timer.Start("cluster-active")
+		conn, err := WaitForClusterConnection(ctx, region, clusterName, 30*time.Minute)
+		if err != nil {
+			return partialErr("waiting for EKS Cluster", err)
+		}
+		conn, err = withKubeconfigOptions(ctx, conn)
+		if err != nil {
+			return partialErr("preparing kubeconfig", err)
+		}
+		kubeconfigPath = ciKubeconfigPath(clusterName)
+		if err := WriteKubeconfig(kubeconfigPath, conn); err != nil {
+			return partialErr("writing kubeconfig", err)
+		}
+		stopWait()
+		if err := ciSetOutput("endpoint", conn.Endpoint); err != nil {
+			return partialErr("setting step output", err)
+		}
+		if err := ciSetOutput("kubeconfig-path", kubeconfigPath); err != nil {
+			return partialErr("setting step output", err)
+		}
+	}
+
+	// Hand over a kubeconfig entry; private clusters get theirs with the SSM tunnel
+	if !*ciMode && !answers.PrivateEndpoint {
+		conn, err := WaitForClusterConnection(ctx, region, clusterName, 30*time.Minute)
+		if err != nil {
+			return partialErr("waiting for EKS Cluster", err)
+		}
+		path, err := InstallKubeconfig(ctx, conn)
+		if err != nil {
+			return partialErr("writing kubeconfig", err)
+		}
+		successf("Added the cluster to %s", path)
+		kubeconfigPath = path
+	}
+
+	// Cilium has to be there before the first node, which would not become ready without a CNI
+	if answers.cilium() {
+		stopCilium := timer.Start("cilium")
+		if err := InstallCilium(ctx, region, clusterName, answers.CNI); err != nil {
+			return partialErr("installing Cilium", err)
+		}
+		stopCilium()
+	}
+
+	if compute := answers.compute(); compute != computeNone {
+		stopCompute := timer.Start("compute")
+		// Nodes and Fargate pods go in the private subnets when the VPC has any
+		subnets := network.Subnets
+		if len(network.PrivateSubnets) > 0 {
+			subnets = network.PrivateSubnets
+		}
+		if err := ProvisionCompute(ctx, region, clusterName, compute, subnets, answers.nodeTaints()); err != nil {
+			return partialErr("provisioning compute", err)
+		}
+		stopCompute()
+	}
+
+	if answers.PauseHours != "" {
+		// The hours were validated when asked
+		hours, _ := parsePauseHours(answers.PauseHours)
+		if err := SchedulePauses(ctx, region, clusterName, hours); err != nil {
+			warnf("Unable to schedule pauses: %v", err)
+			infof("Schedule them yourself with: est schedule --cluster %s --hours %q --region %s", clusterName, answers.PauseHours, region)
+		}
+	}
+
+	if answers.installsDefaultAddons() {
+		// Add code to install 3 addons
+		stopAddons := timer.Start("addons")
+		addonConfigs := map[string]string{}
+		if config := answers.vpcCNIConfig(); config != "" {
+			addonConfigs["vpc-cni"] = config
+		}
+		if answers.compute() == computeFargate {
+			addonConfigs["coredns"] = coreDNSOnFargate
+		}
+		err = InstallAddons(ctx, region, clusterName, k8sVersion, answers.clusterAddons(), addonConfigs)
+		if err != nil {
+			return partialErr("installing addons", err)
+		}
+		stopAddons()
+	}
+	if answers.CustomNetworking {
+		stopCNI := timer.Start("custom-networking")
+		if err := ConfigureCustomNetworking(ctx, region, clusterName, network.PodSubnets, network.SecurityGroups); err != nil {
+			return partialErr("configuring custom networking", err)
+		}
+		stopCNI()
+	}
+	if len(answers.OptionalAddons) > 0 {
+		stopOptional := timer.Start("optional-addons")
+		if err := InstallCatalogueAddons(ctx, region, clusterName, k8sVersion, answers.OptionalAddons); err != nil {
+			return partialErr("installing optional addons", err)
+		}
+		stopOptional()
+	}
+	// A missing default StorageClass leaves every PersistentVolumeClaim without a class pending
+	if !answers.PrivateEndpoint {
+		if err := ConfigureDefaultStorageClass(ctx, region, clusterName, answers.AutoMode); err != nil {
+			warnf("Unable to set up the default gp3 StorageClass: %v", err)
+		}
+	}
+	if answers.PolicyBundle {
+		stopPolicy := timer.Start("policy")
+		if err := InstallPolicyBundle(ctx, region, clusterName); err != nil {
+			return partialErr("installing policy bundle", err)
+		}
+		stopPolicy()
+	}
+	var tunnelScript string
+	if answers.PrivateEndpoint {
+		stopTunnel := timer.Start("ssm-tunnel")
+		conn, err := WaitForClusterConnection(ctx, region, clusterName, 30*time.Minute)
+		if err != nil {
+			return partialErr("waiting for EKS Cluster", err)
+		}
+		conn, err = withKubeconfigOptions(ctx, conn)
+		if err != nil {
+			return partialErr("preparing kubeconfig", err)
+		}
+		tunnelScript, err = WriteSSMTunnel(conn, network.SSMInstance)
+		if err != nil {
+			return partialErr("writing SSM tunnel script", err)
+		}
+		stopTunnel()
+		successf("The API server is private; open a tunnel to it with %s", tunnelScript)
+	}
+
+	conn, err := WaitForClusterConnection(ctx, region, clusterName, 30*time.Minute)
+	if err != nil {
+		return partialErr("waiting for EKS Cluster", err)
+	}
+	contextName, err := renderKubeconfigTemplate("context_name", userConfig.Kubeconfig.ContextName, conn)
+	if err != nil {
+		return partialErr("preparing kubeconfig", err)
+	}
+	printConnectionInstructions(summaryWriter(), conn, contextName, callerID, tunnelScript)
+	manifest.AddCluster(manifestCluster{
+		Name:              clusterName,
+		Region:            region,
+		Arn:               conn.Arn,
+		KubernetesVersion: k8sVersion,
+		Endpoint:          conn.Endpoint,
+		VPCID:             vpcID,
+		Subnets:           network.Subnets,
+		SecurityGroups:    network.SecurityGroups,
+		RoleArn:           roleArn,
+		Addons:            addonVersions,
+		Kubeconfig:        kubeconfigPath,
+	})
+	runPostHooks(ctx, hookPostCreate, region, clusterName)
+	notifier.Notify(eventClusterCreated, map[string]string{
+		"kubernetesVersion": k8sVersion,
+		"vpcId":             vpcID,
+	}, nil)
+	return nil
+}
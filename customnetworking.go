@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// podCIDR is the secondary VPC CIDR that pods get their addresses from with custom networking.
+// It is taken from the carrier-grade NAT range, which is what AWS recommends for IP-exhaustion mitigations.
+const podCIDR = "100.64.0.0/16"
+
+// cniCustomNetworkingEnv is the vpc-cni addon environment that makes nodes take pod addresses
+// from the ENIConfig named after their availability zone
+var cniCustomNetworkingEnv = map[string]string{"AWS_VPC_K8S_CNI_CUSTOM_NETWORK_CFG": "true", "ENI_CONFIG_LABEL_DEF": "topology.kubernetes.io/zone"}
+
+// vpcCNIOnly skips questions about the vpc-cni addon when it is not installed
+func vpcCNIOnly(a *createAnswers) bool {
+	return a.AutoMode || !a.InstallAddons || a.cilium()
+}
+
+// vpcCNIConfig returns the configuration of the vpc-cni addon, "" for its defaults. With
+// enableNetworkPolicy the addon runs the network policy agent on every node and EKS the
+// controller that turns NetworkPolicies into eBPF programs.
+func (a *createAnswers) vpcCNIConfig() string {
+	config := map[string]interface{}{}
+	if a.CustomNetworking {
+		config["env"] = cniCustomNetworkingEnv
+	}
+	if a.NetworkPolicy {
+		config["enableNetworkPolicy"] = "true"
+	}
+	if len(config) == 0 {
+		return ""
+	}
+	data, _ := json.Marshal(config)
+	return string(data)
+}
+
+// CreatePodSubnets attaches the pod CIDR to the VPC and splits it into one pod subnet per
+// availability zone, named with names. It returns the subnet IDs keyed by availability zone.
+func CreatePodSubnets(ctx context.Context, region, vpcID, routeTableID string, zones []string, names resourceNames) (map[string]string, error) {
+	if _, err := AssociateVpcCidrBlock(ctx, region, vpcID, podCIDR); err != nil {
+		return nil, err
+	}
+	successf("Associated secondary CIDR %s with VPC %s", podCIDR, vpcID)
+
+	cidrs, err := splitSubnets(podCIDR, len(zones))
+	if err != nil {
+		return nil, err
+	}
+	podSubnets := map[string]string{}
+	for i, cidr := range cidrs {
+		name, err := names.subnet(zones[i], i+1).render("pod_subnet", userConfig.Naming.PodSubnet)
+		if err != nil {
+			return nil, err
+		}
+		subnetID, err := CreateSubnet(ctx, region, vpcID, cidr, name, zones[i])
+		if err != nil {
+			return nil, fmt.Errorf("unable to create pod subnet %s: %w", cidr, err)
+		}
+		if err := AssociateRouteTable(ctx, region, routeTableID, subnetID); err != nil {
+			return nil, fmt.Errorf("unable to associate route table with pod subnet %s: %w", subnetID, err)
+		}
+		podSubnets[zones[i]] = subnetID
+		successf("Created pod subnet %s (%s, %s)", subnetID, cidr, zones[i])
+	}
+	return podSubnets, nil
+}
+
+// ConfigureCustomNetworking creates an ENIConfig per availability zone once the vpc-cni addon,
+// which installs the ENIConfig resource type, is active
+func ConfigureCustomNetworking(ctx context.Context, region, clusterName string, podSubnets map[string]string, securityGroupIDs []string) error {
+	if err := WaitForAddonActive(ctx, region, clusterName, "vpc-cni", 15*time.Minute); err != nil {
+		return err
+	}
+
+	kube, err := newKubectlSession(ctx, region, clusterName)
+	if err != nil {
+		return err
+	}
+	defer kube.Close()
+
+	var manifest strings.Builder
+	for az, subnetID := range podSubnets {
+		fmt.Fprintf(&manifest, "---\napiVersion: crd.k8s.amazonaws.com/v1alpha1\nkind: ENIConfig\nmetadata:\n  name: %s\nspec:\n  subnet: %s\n  securityGroups:\n", az, subnetID)
+		for _, sg := range securityGroupIDs {
+			fmt.Fprintf(&manifest, "  - %s\n", sg)
+		}
+	}
+	if err := kube.Apply(ctx, manifest.String()); err != nil {
+		return err
+	}
+	successf("Configured VPC CNI custom networking for %d availability zones", len(podSubnets))
+	return nil
+}
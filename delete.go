@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/AlecAivazis/survey/v2"
+)
+
+// runDelete prompts for a cluster and deletes it, optionally together with its isolated or shared VPC
+func runDelete(ctx context.Context, timer *stepTimer) error {
+	if err := requireWritable("Delete Cluster"); err != nil {
+		return err
+	}
+	var region string
+
+	// Logic for deleting a cluster
+	promptRegion := &survey.Input{
+		Message: "Enter the AWS region:",
+		Default: regionDefault("eu-west-2"),
+	}
+	if err := askOne("EST_REGION", promptRegion, &region, survey.WithValidator(validRegion)); err != nil {
+		return wrapErr("reading region", err)
+	}
+
+	notifier.Region = region
+
+	// Fetch existing clusters
+	clusters, err := ListEKSClusters(ctx, region)
+	if err != nil {
+		return wrapErr("fetching clusters", err)
+	}
+
+	if len(clusters) == 0 {
+		infof("No clusters found in the specified region.")
+		return nil
+	}
+
+	// Prompt the user to select a cluster to delete
+	selectedCluster, err := askCluster(clusters, "Select the cluster to delete:")
+	if err != nil {
+		return err
+	}
+
+	notifier.Cluster = selectedCluster
+
+	// Check if the cluster has the required "CreatedBy" tag
+	isCreatedByTool, err := CheckClusterTag(ctx, region, selectedCluster, "CreatedBy", "EKS-Sandbox-Tool")
+	if err != nil {
+		return wrapErr("checking cluster tags", err)
+	}
+	if !isCreatedByTool {
+		// Warn the user
+		var confirmDelete bool
+		warningPrompt := &survey.Confirm{
+			Message: "This cluster does not appear to be created by this tool. Are you sure you want to delete it? Danger!!",
+		}
+		if err := askOne("EST_CONFIRM_DELETE", warningPrompt, &confirmDelete); err != nil {
+			return wrapErr("reading confirmation", err)
+		}
+
+		if !confirmDelete {
+			warnf("Cluster deletion aborted.")
+			return nil
+		}
+	}
+	isIsolatedVpc, err := CheckClusterTag(ctx, region, selectedCluster, "HostingVPC", "isolated")
+	if err != nil {
+		return wrapErr("checking cluster tags", err)
+	}
+	isSharedVpc, err := CheckClusterTag(ctx, region, selectedCluster, "HostingVPC", hostingVPCShared)
+	if err != nil {
+		return wrapErr("checking cluster tags", err)
+	}
+	if isSharedVpc {
+		return deleteSharedVPCCluster(ctx, timer, region, selectedCluster)
+	}
+	if !isIsolatedVpc {
+		// The VPC is shared or was not created by this tool, so only the cluster and what runs in it go
+		vpcId, err := GetVPCIDFromCluster(ctx, region, selectedCluster)
+		if err != nil {
+			warnf("Unable to find the VPC of cluster %s: %v", selectedCluster, err)
+		}
+		infof("Cluster %s is not in an isolated VPC; deleting just the cluster", selectedCluster)
+		timer.Plan("delete", "cluster")
+		if err := deleteClusterOnly(ctx, timer, region, selectedCluster); err != nil {
+			return err
+		}
+		notifier.Notify(eventClusterDeleted, map[string]string{"vpcId": vpcId, "vpcDeleted": "false"}, nil)
+		return nil
+	}
+
+	vpcId, err := GetVPCIDFromCluster(ctx, region, selectedCluster)
+	if err != nil {
+		return wrapErr("finding the VPC of the cluster", err)
+	}
+
+	//delete VPC too
+	var confirmDeleteVPC = confirmDefault("EST_DELETE_VPC")
+	askVpcDeletePrompt := &survey.Confirm{
+		Message: "Do you want to delete VPC and all dependent objects in it?",
+		Default: confirmDeleteVPC,
+	}
+	if err := askOne("EST_DELETE_VPC", askVpcDeletePrompt, &confirmDeleteVPC); err != nil {
+		return wrapErr("reading VPC deletion choice", err)
+	}
+	if !confirmDeleteVPC {
+		infof("Deleting just the cluster and leaving VPC intact")
+		timer.Plan("delete", "cluster")
+		if err := deleteClusterOnly(ctx, timer, region, selectedCluster); err != nil {
+			return err
+		}
+		notifier.Notify(eventClusterDeleted, map[string]string{"vpcId": vpcId, "vpcDeleted": "false"}, nil)
+		return nil
+	}
+
+	// Refuse before anything is deleted when the cluster's tag names another VPC, or the VPC's
+	// tags name another cluster
+	if err := CheckClusterVPCTag(ctx, region, selectedCluster, vpcId); err != nil {
+		return wrapErr("checking VPC tag", err)
+	}
+	if err := CheckVPCOwner(ctx, region, vpcId, selectedCluster); err != nil {
+		return wrapErr("checking VPC owner", err)
+	}
+	timer.Plan("delete", "cluster", "VPC")
+	if err := deleteClusterOnly(ctx, timer, region, selectedCluster); err != nil {
+		return err
+	}
+
+	stopVPC := timer.Start("VPC")
+	err = DeleteVPC(ctx, region, vpcId, selectedCluster)
+	if err != nil {
+		return partialErr("deleting VPC", err)
+	}
+	stopVPC()
+	successf("VPC and all components of the VPC deleted")
+	notifier.Notify(eventClusterDeleted, map[string]string{"vpcId": vpcId, "vpcDeleted": "true"}, nil)
+	return nil
+}
+
+// deleteSharedVPCCluster deletes a cluster of the shared VPC, and the VPC too when no other cluster
+// is left in it
+func deleteSharedVPCCluster(ctx context.Context, timer *stepTimer, region, clusterName string) error {
+	vpcId, err := GetVPCIDFromCluster(ctx, region, clusterName)
+	if err != nil {
+		return wrapErr("finding the VPC of the cluster", err)
+	}
+	if err := CheckClusterVPCTag(ctx, region, clusterName, vpcId); err != nil {
+		return wrapErr("checking VPC tag", err)
+	}
+	// Whether the shared VPC goes too is only known once the cluster is gone
+	timer.Plan("delete", "cluster")
+	if err := deleteClusterOnly(ctx, timer, region, clusterName); err != nil {
+		return err
+	}
+
+	remaining, err := SharedVPCClusters(ctx, region, vpcId)
+	if err != nil {
+		return partialErr("counting the clusters of the shared VPC", err)
+	}
+	if len(remaining) > 0 {
+		infof("Shared VPC %s is kept for %d other clusters: %s", vpcId, len(remaining), strings.Join(remaining, ", "))
+		notifier.Notify(eventClusterDeleted, map[string]string{"vpcId": vpcId, "vpcDeleted": "false"}, nil)
+		return nil
+	}
+
+	confirmDeleteVPC := confirmDefault("EST_DELETE_VPC")
+	askVpcDeletePrompt := &survey.Confirm{
+		Message: fmt.Sprintf("No clusters are left in shared VPC %s. Delete it and all dependent objects in it?", vpcId),
+		Default: confirmDeleteVPC,
+	}
+	if err := askOne("EST_DELETE_VPC", askVpcDeletePrompt, &confirmDeleteVPC); err != nil {
+		return partialErr("reading VPC deletion choice", err)
+	}
+	if !confirmDeleteVPC {
+		infof("Leaving shared VPC %s for the next cluster", vpcId)
+		notifier.Notify(eventClusterDeleted, map[string]string{"vpcId": vpcId, "vpcDeleted": "false"}, nil)
+		return nil
+	}
+	timer.Plan("delete", "cluster", "VPC")
+	stopVPC := timer.Start("VPC")
+	if err := DeleteVPC(ctx, region, vpcId, clusterName); err != nil {
+		return partialErr("deleting VPC", err)
+	}
+	stopVPC()
+	successf("Shared VPC and all components of the VPC deleted")
+	notifier.Notify(eventClusterDeleted, map[string]string{"vpcId": vpcId, "vpcDeleted": "true"}, nil)
+	return nil
+}
+
+// deleteClusterOnly deletes a cluster with its node groups and addons, and the IAM roles and key
+// created for it, leaving its VPC alone
+func deleteClusterOnly(ctx context.Context, timer *stepTimer, region, clusterName string) error {
+	if err := runHooks(ctx, hookPreDelete, region, clusterName); err != nil {
+		return wrapErr("running pre-delete hooks", err)
+	}
+	plugins, err := InstalledPlugins(ctx, region, clusterName)
+	if err != nil {
+		warnf("Unable to read the installer plugins of %s, none are cleaned up: %v", clusterName, err)
+	}
+	stopCluster := timer.Start("cluster")
+	if err := DeleteEKSCluster(ctx, region, clusterName); err != nil {
+		return wrapErr("deleting cluster", err)
+	}
+	stopCluster()
+
+	successf("Cluster '%s' deleted.", clusterName)
+	forgetSandbox(ctx, region, clusterName)
+	CleanupCatalogueAddons(ctx, region, clusterName, plugins)
+	CleanupComputeRoles(ctx, region, clusterName)
+	CleanupPauseSchedules(ctx, region, clusterName)
+	DeleteEncryptionKey(ctx, region, clusterName)
+	runPostHooks(ctx, hookPostDelete, region, clusterName)
+	return nil
+}
+
+// askCluster lets the user pick one of clusters. EST_CLUSTER_NAME, or the cluster_name answer,
+// may also hold the name given at creation time, which is stored with the cluster prefix.
+func askCluster(clusters []string, message string) (string, error) {
+	name := firstNonEmpty(os.Getenv("EST_CLUSTER_NAME"), presetAnswers[answerKey("EST_CLUSTER_NAME")])
+	if prefixed := userConfig.ClusterPrefix + name; name != "" && !slices.Contains(clusters, name) && slices.Contains(clusters, prefixed) {
+		return prefixed, nil
+	}
+	var selectedCluster string
+	clusterPrompt := &survey.Select{
+		Message: message,
+		Options: clusters,
+	}
+	if err := askOne("EST_CLUSTER_NAME", clusterPrompt, &selectedCluster); err != nil {
+		return "", wrapErr("reading cluster", err)
+	}
+	if err := requireInputs(); err != nil {
+		return "", err
+	}
+	return selectedCluster, nil
+}
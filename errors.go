@@ -0,0 +1,128 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/AlecAivazis/survey/v2/terminal"
+	"github.com/aws/smithy-go"
+)
+
+// errorKind categorizes a failure so scripts can branch on the process exit code
+type errorKind int
+
+const (
+	kindUnknown errorKind = iota
+	kindValidation
+	kindAuth
+	kindQuota
+	kindAWSAPI
+	kindPartial
+	kindInterrupted
+)
+
+// Exit codes returned by the tool, one per errorKind
+const (
+	exitUnknown     = 1
+	exitValidation  = 2
+	exitAuth        = 3
+	exitQuota       = 4
+	exitAWSAPI      = 5
+	exitPartial     = 6
+	exitInterrupted = 130
+)
+
+// estError wraps an error with the operation that failed and its category
+type estError struct {
+	Kind errorKind
+	Op   string
+	Err  error
+}
+
+func (e *estError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Op, e.Err)
+}
+
+func (e *estError) Unwrap() error {
+	return e.Err
+}
+
+// wrapErr describes the failed operation and classifies the error from the AWS error code it carries
+func wrapErr(op string, err error) error {
+	if err == nil {
+		return nil
+	}
+	var existing *estError
+	if errors.As(err, &existing) {
+		return &estError{Kind: existing.Kind, Op: op, Err: err}
+	}
+	return &estError{Kind: classifyError(err), Op: op, Err: err}
+}
+
+// validationErr reports bad user input or configuration
+func validationErr(format string, args ...interface{}) error {
+	return &estError{Kind: kindValidation, Op: "invalid input", Err: fmt.Errorf(format, args...)}
+}
+
+// partialErr reports a run that created or deleted some resources before failing
+func partialErr(op string, err error) error {
+	return &estError{Kind: kindPartial, Op: op, Err: err}
+}
+
+// classifyError maps well-known AWS error codes onto an errorKind
+func classifyError(err error) errorKind {
+	if errors.Is(err, terminal.InterruptErr) {
+		return kindInterrupted
+	}
+
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		code := apiErr.ErrorCode()
+		switch {
+		case code == "AccessDenied", code == "AccessDeniedException", code == "UnauthorizedOperation",
+			code == "AuthFailure", code == "UnrecognizedClientException", code == "InvalidClientTokenId",
+			code == "ExpiredToken", code == "ExpiredTokenException", code == "SignatureDoesNotMatch":
+			return kindAuth
+		case strings.HasSuffix(code, "LimitExceeded"), strings.HasSuffix(code, "LimitExceededException"),
+			code == "ServiceQuotaExceededException", code == "InsufficientFreeAddressesInSubnet":
+			return kindQuota
+		case code == "ValidationError", code == "InvalidParameterException", code == "InvalidParameterValue",
+			code == "InvalidParameterCombination", code == "InvalidRequestException", code == "MalformedPolicyDocument":
+			return kindValidation
+		default:
+			return kindAWSAPI
+		}
+	}
+
+	if strings.Contains(err.Error(), "failed to refresh cached credentials") {
+		return kindAuth
+	}
+	return kindUnknown
+}
+
+// exitCodeFor returns the process exit code for an error returned by run
+func exitCodeFor(err error) int {
+	kind := classifyError(err)
+	var e *estError
+	if errors.As(err, &e) {
+		kind = e.Kind
+	}
+
+	switch kind {
+	case kindValidation:
+		return exitValidation
+	case kindAuth:
+		return exitAuth
+	case kindQuota:
+		return exitQuota
+	case kindAWSAPI:
+		return exitAWSAPI
+	case kindPartial:
+		return exitPartial
+	case kindInterrupted:
+		return exitInterrupted
+	default:
+		return exitUnknown
+	}
+}
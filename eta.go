@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// stepHistorySamples is how many past durations of each step are kept for estimates
+const stepHistorySamples = 10
+
+// stepHistory holds the durations in seconds of the steps of past runs by operation and step,
+// most recent last, e.g. history["create"]["cluster"]
+type stepHistory map[string]map[string][]float64
+
+// stepHistoryPath returns where the step durations of past runs are kept
+func stepHistoryPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".est", "step-history.json")
+}
+
+// loadStepHistory reads the step durations of past runs. Estimates are a convenience, so a
+// missing or unreadable history is an empty one.
+func loadStepHistory() stepHistory {
+	history := stepHistory{}
+	path := stepHistoryPath()
+	if path == "" {
+		return history
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			debugf("Unable to read step history %s: %v", path, err)
+		}
+		return history
+	}
+	if err := json.Unmarshal(data, &history); err != nil {
+		debugf("Ignoring step history %s: %v", path, err)
+		return stepHistory{}
+	}
+	return history
+}
+
+// recordStepDuration adds the duration of a finished step to the history. Runs replayed from a
+// cassette take no real time and are not recorded.
+func recordStepDuration(operation, step string, d time.Duration) {
+	path := stepHistoryPath()
+	if path == "" || *replayFile != "" {
+		return
+	}
+	history := loadStepHistory()
+	if history[operation] == nil {
+		history[operation] = map[string][]float64{}
+	}
+	samples := append(history[operation][step], d.Seconds())
+	if len(samples) > stepHistorySamples {
+		samples = samples[len(samples)-stepHistorySamples:]
+	}
+	history[operation][step] = samples
+
+	data, err := json.MarshalIndent(history, "", "  ")
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(path), 0700)
+	}
+	if err == nil {
+		// Write next to the history and rename, so concurrent runs never leave a half written file
+		tmp := path + ".tmp"
+		if err = os.WriteFile(tmp, data, 0600); err == nil {
+			err = os.Rename(tmp, path)
+		}
+	}
+	if err != nil {
+		debugf("Unable to update step history %s: %v", path, err)
+	}
+}
+
+// estimate returns the median of the past durations of a step, and whether there are any
+func (h stepHistory) estimate(operation, step string) (time.Duration, bool) {
+	samples := slices.Clone(h[operation][step])
+	if len(samples) == 0 {
+		return 0, false
+	}
+	slices.Sort(samples)
+	median := samples[len(samples)/2]
+	if len(samples)%2 == 0 {
+		median = (samples[len(samples)/2-1] + median) / 2
+	}
+	return time.Duration(median * float64(time.Second)), true
+}
+
+// Plan names the operation being timed and the steps it is going to run, in order. The durations
+// of its steps are then recorded, and each step starts with an estimate of the time left based on
+// past runs. Plan may be called again when a later answer adds steps.
+func (t *stepTimer) Plan(operation string, steps ...string) {
+	t.operation, t.plan = operation, steps
+	t.history = loadStepHistory()
+}
+
+// remaining estimates how long the planned steps that have not finished yet will take. known is
+// false when no past run ran any of them.
+func (t *stepTimer) remaining() (left time.Duration, known bool) {
+	finished := map[string]bool{}
+	for _, s := range t.steps {
+		finished[s.Name] = true
+	}
+	for _, step := range t.plan {
+		if finished[step] {
+			continue
+		}
+		if d, ok := t.history.estimate(t.operation, step); ok {
+			left += d
+			known = true
+		}
+	}
+	return left, known
+}
+
+// printETA reports the estimated time left before a step starts
+func (t *stepTimer) printETA(step string) {
+	if t.operation == "" || !slices.Contains(t.plan, step) {
+		return
+	}
+	left, known := t.remaining()
+	if !known {
+		return
+	}
+	done := time.Now().Add(left)
+	infof("%s: about %s left, done around %s (estimated from past runs)", step, formatETA(left), done.Format("15:04"))
+}
+
+// formatETA rounds an estimate to what is worth showing: seconds below a minute, whole minutes above
+func formatETA(d time.Duration) string {
+	if d < time.Minute {
+		return fmt.Sprintf("%ds", int(d.Round(time.Second).Seconds()))
+	}
+	return fmt.Sprintf("%d min", int(d.Round(time.Minute).Minutes()))
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// externalSecretsVersion is the External Secrets Operator chart installed from the catalogue
+const externalSecretsVersion = "0.10.7"
+
+// The operator reads secrets as its controller service account, which gets its role through IRSA
+const (
+	externalSecretsNamespace      = "external-secrets"
+	externalSecretsServiceAccount = "external-secrets"
+	externalSecretsPolicyName     = "est-external-secrets"
+)
+
+// externalSecretsStores are the ClusterSecretStores for Secrets Manager and Parameter Store. They
+// have no auth section, so the provider uses the controller's IRSA credentials.
+const externalSecretsStores = `apiVersion: external-secrets.io/v1beta1
+kind: ClusterSecretStore
+metadata:
+  name: aws-secrets-manager
+spec:
+  provider:
+    aws:
+      service: SecretsManager
+      region: %[1]s
+---
+apiVersion: external-secrets.io/v1beta1
+kind: ClusterSecretStore
+metadata:
+  name: aws-parameter-store
+spec:
+  provider:
+    aws:
+      service: ParameterStore
+      region: %[1]s
+`
+
+// prepareExternalSecrets creates the controller role. It may read the secrets under
+// est/<cluster>/ and the parameters under /est/<cluster>/ of the sandbox account.
+func prepareExternalSecrets(ctx context.Context, region, clusterName string) (string, error) {
+	accountID, callerID, err := GetAWSAccountDetails(ctx, region)
+	if err != nil {
+		return "", err
+	}
+	partition := strings.SplitN(callerID, ":", 3)[1]
+
+	req := irsaRequest{
+		Region:         region,
+		Cluster:        clusterName,
+		Namespace:      externalSecretsNamespace,
+		ServiceAccount: externalSecretsServiceAccount,
+		RoleName:       irsaRoleName(clusterName, externalSecretsNamespace, externalSecretsServiceAccount),
+	}
+	roleArn, err := CreateIRSARole(ctx, req)
+	if err != nil {
+		return "", err
+	}
+	err = putRolePolicy(ctx, region, req.RoleName, externalSecretsPolicyName, []map[string]interface{}{
+		{
+			"Effect":   "Allow",
+			"Action":   []string{"secretsmanager:GetSecretValue", "secretsmanager:DescribeSecret", "secretsmanager:ListSecretVersionIds"},
+			"Resource": fmt.Sprintf("arn:%s:secretsmanager:%s:%s:secret:est/%s/*", partition, region, accountID, clusterName),
+		},
+		{
+			"Effect":   "Allow",
+			"Action":   []string{"ssm:GetParameter", "ssm:GetParameters", "ssm:GetParametersByPath"},
+			"Resource": fmt.Sprintf("arn:%s:ssm:%s:%s:parameter/est/%s/*", partition, region, accountID, clusterName),
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	return roleArn, nil
+}
+
+// installExternalSecrets installs the operator with helm and creates the ClusterSecretStores. The
+// stores are validated by the operator's webhook, which needs nodes to run; when it does not come
+// up in time the stores are saved for the user to apply later.
+func installExternalSecrets(ctx context.Context, region, clusterName, roleArn string) error {
+	if _, err := exec.LookPath("helm"); err != nil {
+		return fmt.Errorf("helm is not installed: %w", err)
+	}
+	kube, err := newKubectlSession(ctx, region, clusterName)
+	if err != nil {
+		return err
+	}
+	defer kube.Close()
+
+	err = kube.Helm(ctx, "upgrade", "--install", "external-secrets", "external-secrets",
+		"--repo", "https://charts.external-secrets.io", "--version", externalSecretsVersion,
+		"--namespace", externalSecretsNamespace, "--create-namespace",
+		"--set", "serviceAccount.name="+externalSecretsServiceAccount,
+		"--set", `serviceAccount.annotations.eks\.amazonaws\.com/role-arn=`+roleArn)
+	if err != nil {
+		return err
+	}
+
+	stores := fmt.Sprintf(externalSecretsStores, region)
+	err = pollUntil(ctx, "ClusterSecretStores", pollOptions{Timeout: 5 * time.Minute}, func(ctx context.Context) (bool, string, error) {
+		if err := kube.Apply(ctx, stores); err != nil {
+			debugf("applying ClusterSecretStores: %v", err)
+			return false, "waiting for the operator webhook", nil
+		}
+		return true, "applied", nil
+	})
+	if err != nil {
+		path, saveErr := saveManifest("external-secrets", clusterName, stores)
+		if saveErr != nil {
+			return fmt.Errorf("%v, and the stores could not be saved: %w", err, saveErr)
+		}
+		warnf("The External Secrets Operator is not running yet, most likely because the cluster has no nodes: %v", err)
+		infof("Create the ClusterSecretStores once it runs: kubectl apply -f %s", path)
+		return nil
+	}
+	infof("Sync secrets under est/%s/ with ClusterSecretStore aws-secrets-manager and parameters under /est/%s/ with aws-parameter-store", clusterName, clusterName)
+	return nil
+}
+
+// cleanupExternalSecrets deletes the controller role
+func cleanupExternalSecrets(ctx context.Context, region, clusterName string) error {
+	roleName := irsaRoleName(clusterName, externalSecretsNamespace, externalSecretsServiceAccount)
+	_, err := deleteAddonRole(ctx, region, roleName, externalSecretsPolicyName)
+	return err
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/smithy-go"
+	"github.com/aws/smithy-go/middleware"
+)
+
+// faultPoint is an AWS operation that EST_FAIL_AT makes fail
+type faultPoint struct {
+	// operation is an operation name such as "CreateRouteTable", optionally prefixed with the
+	// service, e.g. "EC2.CreateRouteTable"
+	operation string
+	// call is the 1-based call of the operation that fails, 0 fails every call
+	call int
+}
+
+var (
+	faultMu     sync.Mutex
+	faultPoints []faultPoint
+	faultCalls  = map[string]int{}
+)
+
+// parseFaultPoints reads EST_FAIL_AT, a comma separated list of operations that are made to fail,
+// e.g. "CreateRouteTable" or "EC2.DeleteSubnet#2" to fail only the second DeleteSubnet call. It is
+// meant for developers checking that a run stops at the failing step and reports it well; est has
+// no rollback or resume, so what the run created before the failure is left in place.
+func parseFaultPoints(spec string) ([]faultPoint, error) {
+	var points []faultPoint
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		point := faultPoint{operation: entry}
+		if op, n, ok := strings.Cut(entry, "#"); ok {
+			call, err := strconv.Atoi(n)
+			if err != nil || call < 1 {
+				return nil, validationErr("EST_FAIL_AT entry %q must end in #<call number>, e.g. %s#2", entry, op)
+			}
+			point = faultPoint{operation: op, call: call}
+		}
+		points = append(points, point)
+	}
+	return points, nil
+}
+
+// faultInjectionOption returns the API option that fails the operations named in EST_FAIL_AT, nil
+// when it is not set
+func faultInjectionOption() (func(*middleware.Stack) error, error) {
+	spec := os.Getenv("EST_FAIL_AT")
+	if spec == "" {
+		return nil, nil
+	}
+	points, err := parseFaultPoints(spec)
+	if err != nil || len(points) == 0 {
+		return nil, err
+	}
+	faultMu.Lock()
+	faultPoints = points
+	faultMu.Unlock()
+	warnf("EST_FAIL_AT is set, these AWS calls will fail on purpose: %s", spec)
+	return addFaultInjection, nil
+}
+
+// addFaultInjection fails the chosen operations once the SDK has named them in the context, and
+// before they are signed or sent, so nothing reaches AWS and the retryer never sees the error. The
+// failure is an API error with the code InjectedFault, so it is classified and reported like an
+// error returned by AWS.
+func addFaultInjection(stack *middleware.Stack) error {
+	return stack.Initialize.Add(middleware.InitializeMiddlewareFunc("EstFaultInjection", injectFault), middleware.After)
+}
+
+func injectFault(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (middleware.InitializeOutput, middleware.Metadata, error) {
+	service, operation := awsmiddleware.GetServiceID(ctx), awsmiddleware.GetOperationName(ctx)
+	qualified := service + "." + operation
+
+	faultMu.Lock()
+	faultCalls[qualified]++
+	call := faultCalls[qualified]
+	var hit bool
+	for _, point := range faultPoints {
+		if (point.operation == operation || point.operation == qualified) && (point.call == 0 || point.call == call) {
+			hit = true
+			break
+		}
+	}
+	faultMu.Unlock()
+
+	if hit {
+		timelineEvent("FAULT", fmt.Sprintf("%s call %d failed by EST_FAIL_AT", qualified, call))
+		return middleware.InitializeOutput{}, middleware.Metadata{}, &smithy.GenericAPIError{
+			Code:    "InjectedFault",
+			Message: fmt.Sprintf("injected failure of %s call %d (EST_FAIL_AT)", qualified, call),
+		}
+	}
+	return next.HandleInitialize(ctx, in)
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+// newVPCAnswers creates a cluster in a new VPC of two public subnets, which the create-new-vpc
+// cassette holds the calls of up to the route table
+func newVPCAnswers() *createAnswers {
+	answers := existingVPCAnswers()
+	answers.ExistingVPC, answers.ExistingSubnets = "", nil
+	answers.VPCCIDR, answers.PublicSubnets = "10.0.0.0/16", 2
+	return answers
+}
+
+// provisionWithFault replays the creation of a new VPC with EST_FAIL_AT set to spec and returns
+// the steps the run finished and its error
+func provisionWithFault(t *testing.T, spec string) ([]string, error) {
+	t.Helper()
+	replayCassette(t, "create-new-vpc.json")
+	t.Setenv("EST_FAIL_AT", spec)
+
+	timer := newStepTimer()
+	err := provisionCluster(context.Background(), timer, newVPCAnswers())
+	if err == nil {
+		t.Fatalf("provisionCluster with EST_FAIL_AT=%s succeeded", spec)
+	}
+	return stepNames(timer), err
+}
+
+// checkInjectedFault checks that a run failed with the injected fault as an AWS API error
+func checkInjectedFault(t *testing.T, err error, op string) {
+	t.Helper()
+	var e *estError
+	if !errors.As(err, &e) {
+		t.Fatalf("error %v (%T) is not an estError", err, err)
+	}
+	if e.Op != op || e.Kind != kindAWSAPI {
+		t.Errorf("error op %q kind %d, want %q kind %d", e.Op, e.Kind, op, kindAWSAPI)
+	}
+	if code := exitCodeFor(err); code != exitAWSAPI {
+		t.Errorf("exitCodeFor = %d, want %d", code, exitAWSAPI)
+	}
+}
+
+func TestFaultAtCreateRouteTable(t *testing.T) {
+	steps, err := provisionWithFault(t, "CreateRouteTable")
+	checkInjectedFault(t, err, "creating Route Table")
+
+	// The VPC step did not finish, and nothing after the route table ran
+	if want := []string{"IAM"}; !slices.Equal(steps, want) {
+		t.Errorf("steps = %v, want %v", steps, want)
+	}
+	for _, operation := range []string{"EC2.CreateRouteTable", "EKS.CreateCluster"} {
+		if n := replayedCount(operation); n != 0 {
+			t.Errorf("%s was called %d times after the fault", operation, n)
+		}
+	}
+	if n := replayedCount("EC2.AttachInternetGateway"); n != 1 {
+		t.Errorf("EC2.AttachInternetGateway replayed %d times, want 1 before the fault", n)
+	}
+	// The name reservation is still released
+	if n := replayedCount("aws ssm delete-parameter"); n != 1 {
+		t.Errorf("aws ssm delete-parameter replayed %d times, want 1", n)
+	}
+}
+
+func TestFaultAtSecondCreateSubnet(t *testing.T) {
+	steps, err := provisionWithFault(t, "EC2.CreateSubnet#2")
+	checkInjectedFault(t, err, "creating subnet Sandbox-replay-Subnet-2")
+
+	if want := []string{"IAM"}; !slices.Equal(steps, want) {
+		t.Errorf("steps = %v, want %v", steps, want)
+	}
+	// Only the first subnet was created and waited for
+	for operation, want := range map[string]int{
+		"EC2.CreateSubnet":          1,
+		"EC2.DescribeSubnets":       1,
+		"EC2.ModifySubnetAttribute": 0,
+		"EC2.CreateInternetGateway": 0,
+		"EKS.CreateCluster":         0,
+	} {
+		if n := replayedCount(operation); n != want {
+			t.Errorf("%s replayed %d times, want %d", operation, n, want)
+		}
+	}
+}
+
+func TestParseFaultPoints(t *testing.T) {
+	points, err := parseFaultPoints("CreateRouteTable, EC2.DeleteSubnet#2,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []faultPoint{{operation: "CreateRouteTable"}, {operation: "EC2.DeleteSubnet", call: 2}}
+	if !slices.Equal(points, want) {
+		t.Errorf("points = %+v, want %+v", points, want)
+	}
+	for _, spec := range []string{"DeleteSubnet#0", "DeleteSubnet#x"} {
+		if _, err := parseFaultPoints(spec); err == nil || exitCodeFor(err) != exitValidation {
+			t.Errorf("parseFaultPoints(%q) = %v, want a validation error", spec, err)
+		}
+	}
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	iamtypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
+)
+
+// Flow log destinations offered when creating a VPC
+const (
+	flowLogsOff        = "off"
+	flowLogsCloudWatch = "cloudwatch"
+	flowLogsS3         = "s3"
+)
+
+// flowLogsRetentionDays is how long the CloudWatch log group keeps flow log records
+const flowLogsRetentionDays = "7"
+
+// flowLogsRolePolicy lets the flow logs service write to CloudWatch Logs
+const flowLogsRolePolicy = `{
+	"Version": "2012-10-17",
+	"Statement": [
+		{
+			"Effect": "Allow",
+			"Action": [
+				"logs:CreateLogStream",
+				"logs:PutLogEvents",
+				"logs:DescribeLogGroups",
+				"logs:DescribeLogStreams"
+			],
+			"Resource": "*"
+		}
+	]
+}`
+
+// EnableFlowLogs records all traffic of the VPC in a CloudWatch log group or S3 bucket that is
+// created for it, and returns the name of that log group or bucket
+func EnableFlowLogs(ctx context.Context, region, vpcID, clusterName, destination string) (string, error) {
+	cfg, err := loadAWSConfig(ctx, region)
+	if err != nil {
+		return "", fmt.Errorf("unable to load AWS configuration: %w", err)
+	}
+	ec2Client := ec2.NewFromConfig(cfg)
+
+	input := &ec2.CreateFlowLogsInput{
+		ResourceIds:  []string{vpcID},
+		ResourceType: ec2types.FlowLogsResourceTypeVpc,
+		TrafficType:  ec2types.TrafficTypeAll,
+		TagSpecifications: []ec2types.TagSpecification{
+			{
+				ResourceType: ec2types.ResourceTypeVpcFlowLog,
+				Tags:         ec2Tags(clusterName + "-flow-logs"),
+			},
+		},
+	}
+	var target string
+	switch destination {
+	case flowLogsCloudWatch:
+		target = fmt.Sprintf("/est/%s/vpc-flow-logs", clusterName)
+		if err := createFlowLogGroup(ctx, region, target); err != nil {
+			return "", err
+		}
+		roleArn, err := createFlowLogsRole(ctx, iam.NewFromConfig(cfg), vpcID)
+		if err != nil {
+			return "", err
+		}
+		input.LogDestinationType = ec2types.LogDestinationTypeCloudWatchLogs
+		input.LogGroupName = aws.String(target)
+		input.DeliverLogsPermissionArn = aws.String(roleArn)
+	case flowLogsS3:
+		target = strings.ToLower(fmt.Sprintf("est-flow-logs-%s-%s", vpcID, region))
+		// The flow logs service adds the bucket policy it needs itself
+		if err := createBucket(ctx, region, target); err != nil {
+			return "", err
+		}
+		input.LogDestinationType = ec2types.LogDestinationTypeS3
+		input.LogDestination = aws.String("arn:aws:s3:::" + target)
+	default:
+		return "", fmt.Errorf("unknown flow log destination %q", destination)
+	}
+
+	// A new delivery role takes a few seconds to become assumable
+	for attempt := 1; ; attempt++ {
+		output, err := ec2Client.CreateFlowLogs(ctx, input)
+		if err != nil {
+			return "", fmt.Errorf("unable to create flow log: %w", err)
+		}
+		if len(output.Unsuccessful) == 0 {
+			return target, nil
+		}
+		failure := output.Unsuccessful[0].Error
+		message := aws.ToString(failure.Message)
+		if attempt == 12 || !strings.Contains(message, "IAM role") {
+			return "", fmt.Errorf("unable to create flow log: %s", message)
+		}
+		debugf("flow log delivery role not ready yet: %s", message)
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
+
+// createFlowLogGroup creates the CloudWatch log group flow logs are delivered to
+func createFlowLogGroup(ctx context.Context, region, name string) error {
+	tags := "CreatedBy=EKS-Sandbox-Tool"
+	if costCenter != "" {
+		tags += "," + costCenterTag + "=" + costCenter
+	}
+	if clusterOwner != "" {
+		tags += "," + ownerTag + "=" + clusterOwner
+	}
+	_, err := runAWSCLI(ctx, region, "logs", "create-log-group", "--log-group-name", name, "--tags", tags)
+	if err != nil && !strings.Contains(err.Error(), "ResourceAlreadyExistsException") {
+		return fmt.Errorf("unable to create log group %s: %w", name, err)
+	}
+	if _, err := runAWSCLI(ctx, region, "logs", "put-retention-policy", "--log-group-name", name, "--retention-in-days", flowLogsRetentionDays); err != nil {
+		return fmt.Errorf("unable to set retention of log group %s: %w", name, err)
+	}
+	successf("Created log group %s", name)
+	return nil
+}
+
+// createBucket creates an S3 bucket tagged as created by this tool, e.g. the one flow logs are
+// delivered to
+func createBucket(ctx context.Context, region, name string) error {
+	args := []string{"s3api", "create-bucket", "--bucket", name}
+	if region != "us-east-1" {
+		args = append(args, "--create-bucket-configuration", "LocationConstraint="+region)
+	}
+	if _, err := runAWSCLI(ctx, region, args...); err != nil && !strings.Contains(err.Error(), "BucketAlreadyOwnedByYou") {
+		return fmt.Errorf("unable to create bucket %s: %w", name, err)
+	}
+	if _, err := runAWSCLI(ctx, region, "s3api", "put-bucket-tagging", "--bucket", name,
+		"--tagging", "TagSet=[{"+strings.Join(cliTags(), "},{")+"}]"); err != nil {
+		return fmt.Errorf("unable to tag bucket %s: %w", name, err)
+	}
+	successf("Created bucket %s", name)
+	return nil
+}
+
+// createFlowLogsRole creates the role the flow logs service delivers to CloudWatch Logs with
+func createFlowLogsRole(ctx context.Context, iamClient *iam.Client, vpcID string) (string, error) {
+	roleName := "EST-FlowLogs-" + vpcID
+	createInput := &iam.CreateRoleInput{
+		RoleName: aws.String(roleName),
+		AssumeRolePolicyDocument: aws.String(`{
+			"Version": "2012-10-17",
+			"Statement": [
+				{
+					"Effect": "Allow",
+					"Principal": {"Service": "vpc-flow-logs.amazonaws.com"},
+					"Action": "sts:AssumeRole"
+				}
+			]
+		}`),
+		Tags: iamTags(),
+	}
+	if boundary := userConfig.IAM.PermissionsBoundary; boundary != "" {
+		createInput.PermissionsBoundary = aws.String(boundary)
+	}
+	var roleArn string
+	created, err := iamClient.CreateRole(ctx, createInput)
+	if err != nil {
+		var alreadyExists *iamtypes.EntityAlreadyExistsException
+		if !errors.As(err, &alreadyExists) {
+			return "", fmt.Errorf("failed to create role %s: %w%s", roleName, err, boundaryHint(err))
+		}
+		existing, err := iamClient.GetRole(ctx, &iam.GetRoleInput{RoleName: aws.String(roleName)})
+		if err != nil {
+			return "", fmt.Errorf("unable to read role %s: %w", roleName, err)
+		}
+		roleArn = aws.ToString(existing.Role.Arn)
+	} else {
+		roleArn = aws.ToString(created.Role.Arn)
+	}
+
+	_, err = iamClient.PutRolePolicy(ctx, &iam.PutRolePolicyInput{
+		RoleName:       aws.String(roleName),
+		PolicyName:     aws.String("est-flow-logs-delivery"),
+		PolicyDocument: aws.String(flowLogsRolePolicy),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to add delivery policy to role %s: %w", roleName, err)
+	}
+	successf("Created flow log delivery role %s", roleName)
+	return roleArn, nil
+}
+
+// DeleteFlowLogs removes the flow logs the tool created for a VPC together with their log group
+// or bucket and delivery role. Failing to remove a destination only warns, as it does not block
+// deleting the VPC.
+func DeleteFlowLogs(ctx context.Context, region, vpcID string) error {
+	cfg, err := loadAWSConfig(ctx, region)
+	if err != nil {
+		return fmt.Errorf("unable to load AWS configuration: %w", err)
+	}
+	ec2Client := ec2.NewFromConfig(cfg)
+
+	output, err := ec2Client.DescribeFlowLogs(ctx, &ec2.DescribeFlowLogsInput{
+		Filter: []ec2types.Filter{
+			{Name: aws.String("resource-id"), Values: []string{vpcID}},
+			{Name: aws.String("tag:CreatedBy"), Values: []string{"EKS-Sandbox-Tool"}},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("unable to describe flow logs: %w", err)
+	}
+	for _, flowLog := range output.FlowLogs {
+		flowLogID := aws.ToString(flowLog.FlowLogId)
+		if _, err := ec2Client.DeleteFlowLogs(ctx, &ec2.DeleteFlowLogsInput{FlowLogIds: []string{flowLogID}}); err != nil {
+			return fmt.Errorf("unable to delete flow log %s: %w", flowLogID, err)
+		}
+		successf("Deleted flow log %s", flowLogID)
+
+		switch flowLog.LogDestinationType {
+		case ec2types.LogDestinationTypeCloudWatchLogs:
+			group := aws.ToString(flowLog.LogGroupName)
+			if _, err := runAWSCLI(ctx, region, "logs", "delete-log-group", "--log-group-name", group); err != nil {
+				warnf("unable to delete log group %s: %v", group, err)
+			} else {
+				successf("Deleted log group %s", group)
+			}
+		case ec2types.LogDestinationTypeS3:
+			bucket := strings.TrimPrefix(aws.ToString(flowLog.LogDestination), "arn:aws:s3:::")
+			bucket, _, _ = strings.Cut(bucket, "/")
+			if _, err := runAWSCLI(ctx, region, "s3", "rb", "s3://"+bucket, "--force"); err != nil {
+				warnf("unable to delete bucket %s: %v", bucket, err)
+			} else {
+				successf("Deleted bucket %s", bucket)
+			}
+		}
+
+		if roleArn := aws.ToString(flowLog.DeliverLogsPermissionArn); roleArn != "" {
+			if err := deleteFlowLogsRole(ctx, iam.NewFromConfig(cfg), roleArn[strings.LastIndex(roleArn, "/")+1:]); err != nil {
+				warnf("%v", err)
+			}
+		}
+	}
+	return nil
+}
+
+// deleteFlowLogsRole removes a delivery role created by createFlowLogsRole
+func deleteFlowLogsRole(ctx context.Context, iamClient *iam.Client, roleName string) error {
+	_, err := iamClient.DeleteRolePolicy(ctx, &iam.DeleteRolePolicyInput{
+		RoleName:   aws.String(roleName),
+		PolicyName: aws.String("est-flow-logs-delivery"),
+	})
+	if err != nil {
+		return fmt.Errorf("unable to remove delivery policy from role %s: %w", roleName, err)
+	}
+	if _, err := iamClient.DeleteRole(ctx, &iam.DeleteRoleInput{RoleName: aws.String(roleName)}); err != nil {
+		return fmt.Errorf("unable to delete role %s: %w", roleName, err)
+	}
+	successf("Deleted flow log delivery role %s", roleName)
+	return nil
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// guardrailsConfig tells the tool where the account's guardrails are published
+type guardrailsConfig struct {
+	// Source is ssm:<parameter name> or an https:// URL; EST_GUARDRAILS overrides it
+	Source string `json:"source"`
+	// Region is where the SSM parameter lives, by default EST_REGION or AWS_REGION
+	Region string `json:"region"`
+}
+
+// guardrails are limits an account admin sets for every sandbox created in the account. Empty
+// fields leave the matching setting unrestricted.
+type guardrails struct {
+	// AllowedRegions are the regions clusters may be created in
+	AllowedRegions []string `json:"allowed_regions"`
+	// MaxClusters is the most clusters created by this tool that may exist in a region at once
+	MaxClusters int `json:"max_clusters"`
+	// MaxClustersPerOwner is the most of those clusters one identity, found by the Owner tag, may have
+	MaxClustersPerOwner int `json:"max_clusters_per_owner"`
+	// AllowedInstanceTypes are the instance types nodes and Outpost control planes may use
+	AllowedInstanceTypes []string `json:"allowed_instance_types"`
+	// TTL is how long a cluster may live, e.g. "72h". Clusters are tagged ExpiresAt, which `est sweep` honours.
+	TTL string `json:"ttl"`
+
+	ttl time.Duration
+}
+
+// activeGuardrails are the guardrails fetched at start, empty when none are configured
+var activeGuardrails guardrails
+
+// loadGuardrails fetches the guardrails named in the configuration file or EST_GUARDRAILS
+func loadGuardrails(ctx context.Context) error {
+	source := firstNonEmpty(os.Getenv("EST_GUARDRAILS"), userConfig.Guardrails.Source)
+	if source == "" {
+		return nil
+	}
+	var data []byte
+	switch {
+	case strings.HasPrefix(source, "ssm:"):
+		region := firstNonEmpty(userConfig.Guardrails.Region, os.Getenv("EST_REGION"), os.Getenv("AWS_REGION"))
+		if region == "" {
+			return validationErr("guardrails are kept in SSM but no region is known; set guardrails.region in the config file")
+		}
+		out, err := runAWSCLI(ctx, region, "ssm", "get-parameter", "--name", strings.TrimPrefix(source, "ssm:"), "--with-decryption")
+		if err != nil {
+			return wrapErr("fetching guardrails from "+source, err)
+		}
+		var parameter struct {
+			Parameter struct {
+				Value string
+			}
+		}
+		if err := json.Unmarshal([]byte(out), &parameter); err != nil {
+			return wrapErr("fetching guardrails from "+source, err)
+		}
+		data = []byte(parameter.Parameter.Value)
+	case strings.HasPrefix(source, "https://"):
+		client := &http.Client{Timeout: 10 * time.Second}
+		resp, err := client.Get(source)
+		if err != nil {
+			return wrapErr("fetching guardrails from "+source, err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return wrapErr("fetching guardrails from "+source, fmt.Errorf("unexpected status %s", resp.Status))
+		}
+		if data, err = io.ReadAll(resp.Body); err != nil {
+			return wrapErr("fetching guardrails from "+source, err)
+		}
+	default:
+		return validationErr("guardrails source %q must be ssm:<parameter name> or an https:// URL", source)
+	}
+
+	var g guardrails
+	if err := json.Unmarshal(data, &g); err != nil {
+		return validationErr("guardrails from %s are not valid JSON: %v", source, err)
+	}
+	if err := g.validate(); err != nil {
+		return validationErr("guardrails from %s: %v", source, err)
+	}
+	activeGuardrails = g
+	debugf("loaded guardrails from %s", source)
+	return nil
+}
+
+func (g *guardrails) validate() error {
+	if g.MaxClusters < 0 {
+		return fmt.Errorf("max_clusters cannot be negative")
+	}
+	if g.MaxClustersPerOwner < 0 {
+		return fmt.Errorf("max_clusters_per_owner cannot be negative")
+	}
+	if g.TTL != "" {
+		ttl, err := time.ParseDuration(g.TTL)
+		if err != nil || ttl <= 0 {
+			return fmt.Errorf("ttl must be a positive duration such as 72h, got %q", g.TTL)
+		}
+		g.ttl = ttl
+	}
+	return nil
+}
+
+// checkRegion refuses regions the guardrails do not allow
+func (g guardrails) checkRegion(region string) error {
+	if len(g.AllowedRegions) > 0 && !slices.Contains(g.AllowedRegions, region) {
+		return validationErr("the account guardrails only allow clusters in %s, not %s", strings.Join(g.AllowedRegions, ", "), region)
+	}
+	return nil
+}
+
+// checkInstanceType refuses instance types the guardrails do not allow
+func (g guardrails) checkInstanceType(instanceType string) error {
+	if len(g.AllowedInstanceTypes) > 0 && !slices.Contains(g.AllowedInstanceTypes, instanceType) {
+		return validationErr("the account guardrails only allow the instance types %s, not %s", strings.Join(g.AllowedInstanceTypes, ", "), instanceType)
+	}
+	return nil
+}
+
+// checkClusterCount refuses another cluster when the region already has as many clusters created
+// by this tool as the guardrails allow, in total or owned by owner
+func (g guardrails) checkClusterCount(ctx context.Context, region, owner string) error {
+	if g.MaxClusters == 0 && g.MaxClustersPerOwner == 0 {
+		return nil
+	}
+	names, err := ListEKSClusters(ctx, region)
+	if err != nil {
+		return err
+	}
+	count := 0
+	var owned []string
+	for _, name := range names {
+		summary, err := DescribeClusterSummary(ctx, region, name)
+		if err != nil {
+			return err
+		}
+		if !summary.CreatedByTool {
+			continue
+		}
+		count++
+		if summary.Owner == owner {
+			owned = append(owned, name)
+		}
+	}
+	if g.MaxClusters > 0 && count >= g.MaxClusters {
+		return validationErr("the account guardrails allow %d sandbox clusters in %s and %d exist; delete one first", g.MaxClusters, region, count)
+	}
+	if g.MaxClustersPerOwner > 0 && len(owned) >= g.MaxClustersPerOwner {
+		return validationErr("the account guardrails allow %d sandbox clusters per person in %s and you own %d: %s; delete an old one first with Delete Cluster",
+			g.MaxClustersPerOwner, region, len(owned), strings.Join(owned, ", "))
+	}
+	return nil
+}
+
+// expiresAt returns when a cluster created now expires, empty without a TTL
+func (g guardrails) expiresAt() string {
+	if g.ttl == 0 {
+		return ""
+	}
+	return time.Now().Add(g.ttl).UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Points in a run at which hooks run
+const (
+	hookPreCreate  = "pre-create"
+	hookPostCreate = "post-create"
+	hookPreDelete  = "pre-delete"
+	hookPostDelete = "post-delete"
+)
+
+// hooksConfig lists the scripts or binaries run around creating and deleting clusters, so teams can
+// add their own registration, DNS or notification steps. Each command is a path followed by its
+// arguments, separated by spaces.
+type hooksConfig struct {
+	// PreCreate run before anything is created; a failing command stops the run
+	PreCreate []string `json:"pre_create"`
+	// PostCreate run once the cluster is ready
+	PostCreate []string `json:"post_create"`
+	// PreDelete run before a cluster is deleted; a failing command stops the deletion
+	PreDelete []string `json:"pre_delete"`
+	// PostDelete run once the cluster is deleted
+	PostDelete []string `json:"post_delete"`
+}
+
+// validate checks that no command is empty
+func (c hooksConfig) validate() error {
+	for point, commands := range map[string][]string{"pre_create": c.PreCreate, "post_create": c.PostCreate, "pre_delete": c.PreDelete, "post_delete": c.PostDelete} {
+		for _, command := range commands {
+			if strings.TrimSpace(command) == "" {
+				return fmt.Errorf("hooks.%s cannot contain an empty command", point)
+			}
+		}
+	}
+	return nil
+}
+
+// commands returns the commands of a hook point
+func (c hooksConfig) commands(point string) []string {
+	switch point {
+	case hookPreCreate:
+		return c.PreCreate
+	case hookPostCreate:
+		return c.PostCreate
+	case hookPreDelete:
+		return c.PreDelete
+	case hookPostDelete:
+		return c.PostDelete
+	}
+	return nil
+}
+
+// runHooks runs the commands of a hook point one after the other, stopping at the first that
+// fails. Each command gets the run manifest so far on stdin and EST_HOOK, EST_CLUSTER and
+// EST_REGION in its environment; its output goes to stderr and the run log.
+func runHooks(ctx context.Context, point, region, clusterName string) error {
+	commands := userConfig.Hooks.commands(point)
+	if len(commands) == 0 {
+		return nil
+	}
+	state, err := manifest.snapshot()
+	if err != nil {
+		return fmt.Errorf("unable to encode the run manifest for the %s hooks: %w", point, err)
+	}
+	for _, command := range commands {
+		stepf("Running %s hook %s...", point, command)
+		args := strings.Fields(command)
+		env := []string{"EST_HOOK=" + point, "EST_CLUSTER=" + clusterName, "EST_REGION=" + region}
+		if err := runExternal(ctx, bytes.NewReader(state), env, args[0], args[1:]...); err != nil {
+			return fmt.Errorf("%s hook %s failed: %w", point, command, err)
+		}
+	}
+	return nil
+}
+
+// runExternal runs a program supplied by the user with extra environment variables. Its output is
+// shown on stderr and written to the run log. A replayed run skips it, as the program would work
+// on real resources.
+func runExternal(ctx context.Context, stdin io.Reader, env []string, name string, args ...string) error {
+	if *replayFile != "" {
+		infof("Not running %s %s while replaying a cassette", name, strings.Join(args, " "))
+		return nil
+	}
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdin = stdin
+	cmd.Stdout = io.MultiWriter(os.Stderr, lockedRunLog{})
+	cmd.Stderr = cmd.Stdout
+	cmd.Env = append(os.Environ(), env...)
+	debugf("running %s %s", name, strings.Join(args, " "))
+	return cmd.Run()
+}
+
+// runPostHooks runs the commands of a post hook point. The cluster has been created or deleted
+// by then, so a failing command only warns.
+func runPostHooks(ctx context.Context, point, region, clusterName string) {
+	if err := runHooks(ctx, point, region, clusterName); err != nil {
+		warnf("%v", err)
+	}
+}
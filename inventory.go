@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+)
+
+// inventoryConfig tells the tool where the inventory of sandboxes is kept
+type inventoryConfig struct {
+	// Location is a local file, an s3://<bucket>/<key> object or a dynamodb://<table> shared by a
+	// team, or a location of a StateStore registered by the organization; EST_INVENTORY overrides
+	// it. The default is ~/.est/inventory.json.
+	Location string `json:"location"`
+	// Region is the region of the S3 bucket or DynamoDB table, by default EST_REGION or AWS_REGION
+	Region string `json:"region"`
+}
+
+// validate checks that the location is one a state store can be opened at
+func (c inventoryConfig) validate() error {
+	if c.Location == "" {
+		return nil
+	}
+	if _, err := OpenStateStore(c.Location, c.Region); err != nil {
+		return fmt.Errorf("inventory.location: %w", err)
+	}
+	return nil
+}
+
+// inventoryEntry is a sandbox recorded in a StateStore
+type inventoryEntry struct {
+	Name       string    `json:"name"`
+	Region     string    `json:"region"`
+	Owner      string    `json:"owner"`
+	CostCenter string    `json:"cost_center,omitempty"`
+	Purpose    string    `json:"purpose,omitempty"`
+	VPCID      string    `json:"vpc_id"`
+	HostingVPC string    `json:"hosting_vpc"`
+	Created    time.Time `json:"created"`
+	ExpiresAt  string    `json:"expires_at,omitempty"`
+}
+
+// inventoryLocation returns where the inventory is kept
+func inventoryLocation() string {
+	if location := firstNonEmpty(os.Getenv("EST_INVENTORY"), userConfig.Inventory.Location); location != "" {
+		return location
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".est", "inventory.json")
+}
+
+// inventoryStore opens the store the inventory is kept in
+func inventoryStore() (StateStore, error) {
+	location := inventoryLocation()
+	if location == "" {
+		return nil, fmt.Errorf("unable to find home directory for the inventory")
+	}
+	return OpenStateStore(location, inventoryRegion())
+}
+
+// sandboxStores returns the stores every created cluster is recorded in: the inventory and, when
+// one is configured, the registry
+func sandboxStores() []StateStore {
+	var stores []StateStore
+	if store, err := inventoryStore(); err != nil {
+		warnf("Unable to open the inventory: %v", err)
+	} else {
+		stores = append(stores, store)
+	}
+	if registryTable() != "" {
+		stores = append(stores, registryStore())
+	}
+	return stores
+}
+
+// recordSandbox records a newly created cluster in every sandbox store. The stores are
+// bookkeeping, so failing to update one only warns.
+func recordSandbox(ctx context.Context, entry inventoryEntry) {
+	for _, store := range sandboxStores() {
+		if err := store.Put(ctx, entry); err != nil {
+			warnf("Unable to add %s to %s: %v", entry.Name, store, err)
+		}
+	}
+}
+
+// forgetSandbox removes a deleted cluster from every sandbox store, warning when that fails
+func forgetSandbox(ctx context.Context, region, clusterName string) {
+	for _, store := range sandboxStores() {
+		if err := store.Delete(ctx, region, clusterName); err != nil {
+			warnf("Unable to remove %s from %s: %v", clusterName, store, err)
+		}
+	}
+}
+
+// inventoryRegion returns the region the AWS resource holding the inventory is in
+func inventoryRegion() string {
+	return firstNonEmpty(userConfig.Inventory.Region, os.Getenv("EST_REGION"), os.Getenv("AWS_REGION"), "us-east-1")
+}
+
+// runInventory implements `est inventory`
+func runInventory(ctx context.Context, args []string) error {
+	usage := validationErr("usage: est inventory [--mine] [--region <region>]")
+
+	var region string
+	var mine bool
+	fs := flag.NewFlagSet("inventory", flag.ContinueOnError)
+	fs.BoolVar(&mine, "mine", false, "Only list the sandboxes owned by the caller identity")
+	fs.StringVar(&region, "region", "", "Only list the sandboxes of this region")
+	if err := fs.Parse(args); err != nil || fs.NArg() > 0 {
+		return usage
+	}
+
+	store, err := inventoryStore()
+	if err != nil {
+		return wrapErr("opening inventory", err)
+	}
+	sandboxes, err := store.List(ctx)
+	if err != nil {
+		return wrapErr("reading inventory", err)
+	}
+	owner := ""
+	if mine {
+		_, callerID, err := GetAWSAccountDetails(ctx, inventoryRegion())
+		if err != nil {
+			return wrapErr("fetching caller identity", err)
+		}
+		owner = ownerFromARN(callerID)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tREGION\tOWNER\tCREATED\tEXPIRES\tVPC")
+	count := 0
+	for _, entry := range sandboxes {
+		if (region != "" && entry.Region != region) || (mine && entry.Owner != owner) {
+			continue
+		}
+		count++
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s (%s)\n", entry.Name, entry.Region, firstNonEmpty(entry.Owner, "-"),
+			entry.Created.Local().Format("2006-01-02 15:04"), firstNonEmpty(entry.ExpiresAt, "-"), entry.VPCID, entry.HostingVPC)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	infof("%d sandboxes in the inventory at %s", count, store)
+	return nil
+}
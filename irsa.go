@@ -0,0 +1,262 @@
+package main
+
+import (
+	"context"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	eks "github.com/aws/aws-sdk-go-v2/service/eks"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	iamtypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
+)
+
+// irsaRequest describes an IAM role for a Kubernetes service account
+type irsaRequest struct {
+	Region         string
+	Cluster        string
+	Namespace      string
+	ServiceAccount string
+	RoleName       string
+	Policies       []string
+}
+
+// runIRSA implements `est irsa create`
+func runIRSA(ctx context.Context, args []string) error {
+	if err := requireWritable("est irsa"); err != nil {
+		return err
+	}
+	usage := validationErr("usage: est irsa create --cluster <name> --namespace <ns> --serviceaccount <sa> --policy <arn> [--policy <arn>...] [--role-name <name>] [--region <region>]")
+	if len(args) == 0 || args[0] != "create" {
+		return usage
+	}
+
+	var req irsaRequest
+	var policies stringList
+	fs := flag.NewFlagSet("irsa create", flag.ContinueOnError)
+	fs.StringVar(&req.Cluster, "cluster", "", "Name of the EKS cluster")
+	fs.StringVar(&req.Namespace, "namespace", "default", "Namespace of the service account")
+	fs.StringVar(&req.ServiceAccount, "serviceaccount", "", "Name of the service account")
+	fs.StringVar(&req.RoleName, "role-name", "", "Name of the IAM role (default derived from cluster, namespace and service account)")
+	fs.StringVar(&req.Region, "region", firstNonEmpty(os.Getenv("EST_REGION"), os.Getenv("AWS_REGION")), "Region of the cluster")
+	fs.Var(&policies, "policy", "ARN of a managed policy to attach to the role (repeatable)")
+	if err := fs.Parse(args[1:]); err != nil {
+		return usage
+	}
+	req.Policies = policies
+	if req.Cluster == "" || req.ServiceAccount == "" || len(req.Policies) == 0 {
+		return usage
+	}
+	if req.Region == "" {
+		return validationErr("no region given: pass --region or set EST_REGION")
+	}
+	if req.RoleName == "" {
+		req.RoleName = irsaRoleName(req.Cluster, req.Namespace, req.ServiceAccount)
+	}
+	if err := userConfig.IAM.checkPermissionsBoundary(); err != nil {
+		return err
+	}
+	notifier.Cluster, notifier.Region = req.Cluster, req.Region
+
+	stepf("Creating IAM role for service account %s/%s", req.Namespace, req.ServiceAccount)
+	roleArn, err := CreateIRSARole(ctx, req)
+	if err != nil {
+		return wrapErr("creating IRSA role", err)
+	}
+	successf("Role %s is ready", roleArn)
+
+	if err := annotateServiceAccount(ctx, req, roleArn); err != nil {
+		warnf("unable to annotate the service account: %v", err)
+		infof("Annotate it yourself with: kubectl -n %s annotate serviceaccount %s eks.amazonaws.com/role-arn=%s --overwrite",
+			req.Namespace, req.ServiceAccount, roleArn)
+		return nil
+	}
+	successf("Annotated service account %s/%s with the role", req.Namespace, req.ServiceAccount)
+	return nil
+}
+
+// irsaRoleName derives a role name that fits IAM's 64 character limit
+func irsaRoleName(cluster, namespace, serviceAccount string) string {
+	name := fmt.Sprintf("%s-%s-%s", strings.TrimPrefix(cluster, "Sandbox-"), namespace, serviceAccount)
+	if len(name) > 59 {
+		name = name[:59]
+	}
+	return "irsa-" + name
+}
+
+// CreateIRSARole creates or updates a role that the service account can assume through the
+// cluster's OIDC provider, registering the provider in IAM first when necessary
+func CreateIRSARole(ctx context.Context, req irsaRequest) (string, error) {
+	cfg, err := loadAWSConfig(ctx, req.Region)
+	if err != nil {
+		return "", fmt.Errorf("unable to load AWS configuration: %w", err)
+	}
+	eksClient := eks.NewFromConfig(cfg)
+	iamClient := iam.NewFromConfig(cfg)
+
+	cluster, err := eksClient.DescribeCluster(ctx, &eks.DescribeClusterInput{Name: aws.String(req.Cluster)})
+	if err != nil {
+		return "", fmt.Errorf("unable to describe cluster %s: %w", req.Cluster, err)
+	}
+	if cluster.Cluster.Identity == nil || cluster.Cluster.Identity.Oidc == nil {
+		return "", fmt.Errorf("cluster %s has no OIDC issuer", req.Cluster)
+	}
+	issuer := aws.ToString(cluster.Cluster.Identity.Oidc.Issuer)
+
+	providerArn, err := ensureOIDCProvider(ctx, iamClient, issuer)
+	if err != nil {
+		return "", err
+	}
+
+	issuerPath := strings.TrimPrefix(issuer, "https://")
+	trustPolicy, err := json.Marshal(map[string]interface{}{
+		"Version": "2012-10-17",
+		"Statement": []map[string]interface{}{{
+			"Effect":    "Allow",
+			"Principal": map[string]string{"Federated": providerArn},
+			"Action":    "sts:AssumeRoleWithWebIdentity",
+			"Condition": map[string]interface{}{
+				"StringEquals": map[string]string{
+					issuerPath + ":sub": fmt.Sprintf("system:serviceaccount:%s:%s", req.Namespace, req.ServiceAccount),
+					issuerPath + ":aud": "sts.amazonaws.com",
+				},
+			},
+		}},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var roleArn string
+	createInput := &iam.CreateRoleInput{
+		RoleName:                 aws.String(req.RoleName),
+		AssumeRolePolicyDocument: aws.String(string(trustPolicy)),
+		Description:              aws.String(fmt.Sprintf("IRSA role for %s/%s on %s", req.Namespace, req.ServiceAccount, req.Cluster)),
+		Tags:                     append(iamTags(), iamtypes.Tag{Key: aws.String("Cluster"), Value: aws.String(req.Cluster)}),
+	}
+	if boundary := userConfig.IAM.PermissionsBoundary; boundary != "" {
+		createInput.PermissionsBoundary = aws.String(boundary)
+	}
+	created, err := iamClient.CreateRole(ctx, createInput)
+	if err != nil {
+		var alreadyExists *iamtypes.EntityAlreadyExistsException
+		if !errors.As(err, &alreadyExists) {
+			return "", fmt.Errorf("failed to create role %s: %w%s", req.RoleName, err, boundaryHint(err))
+		}
+		// Keep the trust policy in sync with the requested service account
+		if _, err := iamClient.UpdateAssumeRolePolicy(ctx, &iam.UpdateAssumeRolePolicyInput{
+			RoleName:       aws.String(req.RoleName),
+			PolicyDocument: aws.String(string(trustPolicy)),
+		}); err != nil {
+			return "", fmt.Errorf("failed to update trust policy of role %s: %w", req.RoleName, err)
+		}
+		existing, err := iamClient.GetRole(ctx, &iam.GetRoleInput{RoleName: aws.String(req.RoleName)})
+		if err != nil {
+			return "", fmt.Errorf("unable to read role %s: %w", req.RoleName, err)
+		}
+		roleArn = aws.ToString(existing.Role.Arn)
+		infof("Role %s already exists, updated its trust policy", req.RoleName)
+	} else {
+		roleArn = aws.ToString(created.Role.Arn)
+		successf("Created role %s", req.RoleName)
+	}
+
+	for _, policyArn := range req.Policies {
+		_, err := iamClient.AttachRolePolicy(ctx, &iam.AttachRolePolicyInput{
+			RoleName:  aws.String(req.RoleName),
+			PolicyArn: aws.String(policyArn),
+		})
+		if err != nil {
+			return "", fmt.Errorf("failed to attach policy %s to role %s: %w", policyArn, req.RoleName, err)
+		}
+		successf("Attached policy %s to role %s", policyArn, req.RoleName)
+	}
+	return roleArn, nil
+}
+
+// ensureOIDCProvider returns the ARN of the IAM OIDC provider for issuer, creating it when missing
+func ensureOIDCProvider(ctx context.Context, iamClient *iam.Client, issuer string) (string, error) {
+	issuerPath := strings.TrimPrefix(issuer, "https://")
+	providers, err := iamClient.ListOpenIDConnectProviders(ctx, &iam.ListOpenIDConnectProvidersInput{})
+	if err != nil {
+		return "", fmt.Errorf("unable to list OIDC providers: %w", err)
+	}
+	for _, p := range providers.OpenIDConnectProviderList {
+		if strings.HasSuffix(aws.ToString(p.Arn), ":oidc-provider/"+issuerPath) {
+			infof("Using existing OIDC provider %s", aws.ToString(p.Arn))
+			return aws.ToString(p.Arn), nil
+		}
+	}
+
+	thumbprint, err := oidcThumbprint(issuer)
+	if err != nil {
+		return "", err
+	}
+	created, err := iamClient.CreateOpenIDConnectProvider(ctx, &iam.CreateOpenIDConnectProviderInput{
+		Url:            aws.String(issuer),
+		ClientIDList:   []string{"sts.amazonaws.com"},
+		ThumbprintList: []string{thumbprint},
+		Tags:           iamTags(),
+	})
+	if err != nil {
+		return "", fmt.Errorf("unable to create OIDC provider for %s: %w", issuer, err)
+	}
+	successf("Registered the cluster OIDC issuer as IAM OIDC provider")
+	return aws.ToString(created.OpenIDConnectProviderArn), nil
+}
+
+// oidcThumbprint returns the SHA-1 fingerprint of the root certificate served by the issuer host
+func oidcThumbprint(issuer string) (string, error) {
+	u, err := url.Parse(issuer)
+	if err != nil {
+		return "", fmt.Errorf("invalid OIDC issuer %q: %w", issuer, err)
+	}
+	conn, err := tls.Dial("tcp", u.Host+":443", &tls.Config{ServerName: u.Hostname()})
+	if err != nil {
+		return "", fmt.Errorf("unable to connect to OIDC issuer %s: %w", u.Host, err)
+	}
+	defer conn.Close()
+	chains := conn.ConnectionState().VerifiedChains
+	if len(chains) == 0 || len(chains[0]) == 0 {
+		return "", fmt.Errorf("OIDC issuer %s presented no verified certificate chain", u.Host)
+	}
+	root := chains[0][len(chains[0])-1]
+	sum := sha1.Sum(root.Raw)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// annotateServiceAccount creates the service account if needed and points it at the role
+func annotateServiceAccount(ctx context.Context, req irsaRequest, roleArn string) error {
+	kube, err := newKubectlSession(ctx, req.Region, req.Cluster)
+	if err != nil {
+		return err
+	}
+	defer kube.Close()
+
+	if _, err := kube.Run(ctx, "-n", req.Namespace, "get", "serviceaccount", req.ServiceAccount); err != nil {
+		if _, err := kube.Run(ctx, "-n", req.Namespace, "create", "serviceaccount", req.ServiceAccount); err != nil {
+			return err
+		}
+		successf("Created service account %s/%s", req.Namespace, req.ServiceAccount)
+	}
+	_, err = kube.Run(ctx, "-n", req.Namespace, "annotate", "serviceaccount", req.ServiceAccount,
+		"eks.amazonaws.com/role-arn="+roleArn, "--overwrite")
+	return err
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
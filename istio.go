@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"time"
+)
+
+// istioVersion is the Istio release installed from the catalogue, from its helm charts
+const (
+	istioVersion   = "1.24.2"
+	istioChartRepo = "https://istio-release.storage.googleapis.com/charts"
+)
+
+// Istio runs its control plane in istioNamespace and the ingress gateway in istioIngressNamespace.
+// The demo app runs in istioDemoNamespace, where sidecars are injected.
+const (
+	istioNamespace        = "istio-system"
+	istioIngressNamespace = "istio-ingress"
+	istioIngressGateway   = "istio-ingressgateway"
+	istioDemoNamespace    = "mesh-demo"
+)
+
+// istioDemoManifest is an httpbin app behind a Gateway and VirtualService on the ingress gateway,
+// whose pods the gateway chart labels istio=ingressgateway
+const istioDemoManifest = `apiVersion: v1
+kind: Namespace
+metadata:
+  name: %[1]s
+  labels:
+    istio-injection: enabled
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: httpbin
+  namespace: %[1]s
+spec:
+  replicas: 1
+  selector:
+    matchLabels:
+      app: httpbin
+  template:
+    metadata:
+      labels:
+        app: httpbin
+    spec:
+      containers:
+      - name: httpbin
+        image: docker.io/kong/httpbin:0.1.0
+        ports:
+        - containerPort: 80
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: httpbin
+  namespace: %[1]s
+spec:
+  selector:
+    app: httpbin
+  ports:
+  - name: http
+    port: 8000
+    targetPort: 80
+---
+apiVersion: networking.istio.io/v1
+kind: Gateway
+metadata:
+  name: demo-gateway
+  namespace: %[1]s
+spec:
+  selector:
+    istio: ingressgateway
+  servers:
+  - port:
+      number: 80
+      name: http
+      protocol: HTTP
+    hosts:
+    - "*"
+---
+apiVersion: networking.istio.io/v1
+kind: VirtualService
+metadata:
+  name: httpbin
+  namespace: %[1]s
+spec:
+  hosts:
+  - "*"
+  gateways:
+  - demo-gateway
+  http:
+  - route:
+    - destination:
+        host: httpbin
+        port:
+          number: 8000
+`
+
+// installIstio installs the Istio CRDs, istiod and an ingress gateway with helm, then the demo app.
+// The gateway service is a NodePort rather than a load balancer, which the tool would not delete
+// with the VPC. The demo is validated by istiod's webhook, which needs nodes to run; when it does
+// not come up in time the demo is saved for the user to apply later.
+func installIstio(ctx context.Context, region, clusterName, roleArn string) error {
+	if _, err := exec.LookPath("helm"); err != nil {
+		return fmt.Errorf("helm is not installed: %w", err)
+	}
+	kube, err := newKubectlSession(ctx, region, clusterName)
+	if err != nil {
+		return err
+	}
+	defer kube.Close()
+
+	debugf("installing Istio %s with helm", istioVersion)
+	charts := []struct {
+		release, chart, namespace string
+		values                    []string
+	}{
+		{"istio-base", "base", istioNamespace, []string{"defaultRevision=default"}},
+		{"istiod", "istiod", istioNamespace, nil},
+		{istioIngressGateway, "gateway", istioIngressNamespace, []string{"service.type=NodePort"}},
+	}
+	for _, c := range charts {
+		args := []string{"upgrade", "--install", c.release, c.chart, "--repo", istioChartRepo, "--version", istioVersion,
+			"--namespace", c.namespace, "--create-namespace"}
+		for _, value := range c.values {
+			args = append(args, "--set", value)
+		}
+		if err := kube.Helm(ctx, args...); err != nil {
+			return err
+		}
+	}
+
+	demo := fmt.Sprintf(istioDemoManifest, istioDemoNamespace)
+	err = pollUntil(ctx, "Istio demo", pollOptions{Timeout: 5 * time.Minute}, func(ctx context.Context) (bool, string, error) {
+		if err := kube.Apply(ctx, demo); err != nil {
+			debugf("applying Istio demo: %v", err)
+			return false, "waiting for istiod", nil
+		}
+		return true, "applied", nil
+	})
+	if err != nil {
+		path, saveErr := saveManifest("istio", clusterName, demo)
+		if saveErr != nil {
+			return fmt.Errorf("%v, and the demo could not be saved: %w", err, saveErr)
+		}
+		warnf("istiod is not running yet, most likely because the cluster has no nodes: %v", err)
+		infof("Deploy the demo once it runs: kubectl apply -f %s", path)
+		return nil
+	}
+	infof("Reach the demo through the ingress gateway: kubectl -n %s port-forward svc/%s 8080:80, then curl localhost:8080/headers",
+		istioIngressNamespace, istioIngressGateway)
+	return nil
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/AlecAivazis/survey/v2"
+)
+
+// Secrets encryption choices besides the ARN of an existing key
+const (
+	secretsEncryptionOff = "off"
+	secretsEncryptionNew = "new"
+)
+
+// kmsKey is a customer managed KMS key that can encrypt Kubernetes secrets
+type kmsKey struct {
+	Alias string
+	Arn   string
+}
+
+// encryptionKeyAlias is the alias of the key created for a cluster
+func encryptionKeyAlias(clusterName string) string {
+	return "alias/est/" + clusterName
+}
+
+func askSecretsEncryption(ctx context.Context, a *createAnswers) error {
+	keys, err := ListKMSKeys(ctx, a.Region)
+	if err != nil {
+		// Listing needs the aws CLI; creating a new key does too, so only off is left
+		warnf("Unable to list KMS keys, secrets encryption is off: %v", err)
+		a.SecretsEncryption = secretsEncryptionOff
+		return nil
+	}
+	newOption := fmt.Sprintf("%s (create %s)", secretsEncryptionNew, encryptionKeyAlias(firstNonEmpty(a.ClusterName, "<cluster>")))
+	options := []string{secretsEncryptionOff, newOption}
+	defaultOption := secretsEncryptionOff
+	if a.SecretsEncryption == secretsEncryptionNew {
+		defaultOption = newOption
+	}
+	for _, key := range keys {
+		label := key.Arn + " " + key.Alias
+		options = append(options, label)
+		if key.Arn == a.SecretsEncryption {
+			defaultOption = label
+		}
+	}
+	encryptionPrompt := &survey.Select{
+		Message:  "Encrypt Kubernetes secrets with a KMS key?",
+		Options:  options,
+		Default:  defaultOption,
+		PageSize: 15,
+	}
+	var choice string
+	if err := askOne("EST_SECRETS_ENCRYPTION", encryptionPrompt, &choice); err != nil {
+		return wrapErr("reading secrets encryption choice", err)
+	}
+	a.SecretsEncryption = strings.Fields(choice)[0]
+	return nil
+}
+
+// ListKMSKeys returns the enabled symmetric customer managed keys that have an alias. The tool has
+// no KMS SDK client, so the aws CLI is used.
+func ListKMSKeys(ctx context.Context, region string) ([]kmsKey, error) {
+	out, err := runAWSCLI(ctx, region, "kms", "list-aliases")
+	if err != nil {
+		return nil, err
+	}
+	var aliases struct {
+		Aliases []struct {
+			AliasName   string
+			TargetKeyId string
+		}
+	}
+	if err := json.Unmarshal([]byte(out), &aliases); err != nil {
+		return nil, fmt.Errorf("unable to parse KMS aliases: %w", err)
+	}
+	var keys []kmsKey
+	for _, alias := range aliases.Aliases {
+		// AWS managed keys cannot encrypt cluster secrets
+		if alias.TargetKeyId == "" || strings.HasPrefix(alias.AliasName, "alias/aws/") {
+			continue
+		}
+		out, err := runAWSCLI(ctx, region, "kms", "describe-key", "--key-id", alias.TargetKeyId)
+		if err != nil {
+			debugf("skipping key %s: %v", alias.TargetKeyId, err)
+			continue
+		}
+		var described struct {
+			KeyMetadata struct {
+				Arn        string
+				KeyState   string
+				KeyManager string
+				KeySpec    string
+			}
+		}
+		if err := json.Unmarshal([]byte(out), &described); err != nil {
+			return nil, fmt.Errorf("unable to parse key %s: %w", alias.TargetKeyId, err)
+		}
+		meta := described.KeyMetadata
+		if meta.KeyState != "Enabled" || meta.KeyManager != "CUSTOMER" || meta.KeySpec != "SYMMETRIC_DEFAULT" {
+			continue
+		}
+		keys = append(keys, kmsKey{Alias: alias.AliasName, Arn: meta.Arn})
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Alias < keys[j].Alias })
+	return keys, nil
+}
+
+// CreateEncryptionKey creates a key for the secrets of a cluster with the alias est/<cluster>. Its
+// policy keeps the account in control through IAM and lets the cluster role use the key and grant
+// EKS access to it.
+func CreateEncryptionKey(ctx context.Context, region, clusterName, clusterRoleArn string) (string, error) {
+	accountID, callerID, err := GetAWSAccountDetails(ctx, region)
+	if err != nil {
+		return "", err
+	}
+	partition := strings.SplitN(callerID, ":", 3)[1]
+	policy, err := json.Marshal(map[string]interface{}{
+		"Version": "2012-10-17",
+		"Statement": []map[string]interface{}{
+			{
+				"Sid":       "EnableIAMPolicies",
+				"Effect":    "Allow",
+				"Principal": map[string]string{"AWS": fmt.Sprintf("arn:%s:iam::%s:root", partition, accountID)},
+				"Action":    "kms:*",
+				"Resource":  "*",
+			},
+			{
+				"Sid":       "AllowClusterRole",
+				"Effect":    "Allow",
+				"Principal": map[string]string{"AWS": clusterRoleArn},
+				"Action":    []string{"kms:Encrypt", "kms:Decrypt", "kms:ReEncrypt*", "kms:GenerateDataKey*", "kms:DescribeKey"},
+				"Resource":  "*",
+			},
+			{
+				"Sid":       "AllowClusterRoleGrants",
+				"Effect":    "Allow",
+				"Principal": map[string]string{"AWS": clusterRoleArn},
+				"Action":    []string{"kms:CreateGrant", "kms:ListGrants", "kms:RevokeGrant"},
+				"Resource":  "*",
+				"Condition": map[string]interface{}{"Bool": map[string]bool{"kms:GrantIsForAWSResource": true}},
+			},
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	args := []string{"kms", "create-key", "--description", "Secrets encryption of EKS cluster " + clusterName,
+		"--policy", string(policy), "--tags", "TagKey=CreatedBy,TagValue=EKS-Sandbox-Tool", "TagKey=Cluster,TagValue=" + clusterName}
+	if costCenter != "" {
+		args = append(args, fmt.Sprintf("TagKey=%s,TagValue=%s", costCenterTag, costCenter))
+	}
+	if clusterOwner != "" {
+		args = append(args, fmt.Sprintf("TagKey=%s,TagValue=%s", ownerTag, clusterOwner))
+	}
+	out, err := runAWSCLI(ctx, region, args...)
+	if err != nil {
+		return "", fmt.Errorf("unable to create KMS key: %w", err)
+	}
+	var created struct {
+		KeyMetadata struct {
+			KeyId string
+			Arn   string
+		}
+	}
+	if err := json.Unmarshal([]byte(out), &created); err != nil {
+		return "", fmt.Errorf("unable to parse the created key: %w", err)
+	}
+	alias := encryptionKeyAlias(clusterName)
+	if _, err := runAWSCLI(ctx, region, "kms", "create-alias", "--alias-name", alias, "--target-key-id", created.KeyMetadata.KeyId); err != nil {
+		return "", fmt.Errorf("unable to create alias %s for key %s: %w", alias, created.KeyMetadata.KeyId, err)
+	}
+	successf("Created KMS key %s (%s)", alias, created.KeyMetadata.Arn)
+	return created.KeyMetadata.Arn, nil
+}
+
+// kmsKeyDeletionDays is the waiting period before a deleted key is gone; seven days is the minimum
+const kmsKeyDeletionDays = "7"
+
+// DeleteEncryptionKey schedules the deletion of the key created for a cluster, found by its alias.
+// Keys the tool did not create are left alone. It only warns, as a leftover key blocks nothing.
+func DeleteEncryptionKey(ctx context.Context, region, clusterName string) {
+	alias := encryptionKeyAlias(clusterName)
+	out, err := runAWSCLI(ctx, region, "kms", "describe-key", "--key-id", alias)
+	if err != nil {
+		if !strings.Contains(err.Error(), "NotFoundException") {
+			warnf("Unable to look up KMS key %s: %v", alias, err)
+		}
+		return
+	}
+	var described struct {
+		KeyMetadata struct {
+			KeyId string
+		}
+	}
+	if err := json.Unmarshal([]byte(out), &described); err != nil {
+		warnf("Unable to parse KMS key %s: %v", alias, err)
+		return
+	}
+	keyID := described.KeyMetadata.KeyId
+
+	out, err = runAWSCLI(ctx, region, "kms", "list-resource-tags", "--key-id", keyID)
+	if err != nil {
+		warnf("Unable to read the tags of KMS key %s: %v", alias, err)
+		return
+	}
+	var tags struct {
+		Tags []struct {
+			TagKey   string
+			TagValue string
+		}
+	}
+	if err := json.Unmarshal([]byte(out), &tags); err != nil {
+		warnf("Unable to parse the tags of KMS key %s: %v", alias, err)
+		return
+	}
+	createdByTool := false
+	for _, tag := range tags.Tags {
+		if tag.TagKey == "CreatedBy" && tag.TagValue == "EKS-Sandbox-Tool" {
+			createdByTool = true
+		}
+	}
+	if !createdByTool {
+		infof("KMS key %s was not created by this tool and is kept", alias)
+		return
+	}
+
+	if _, err := runAWSCLI(ctx, region, "kms", "delete-alias", "--alias-name", alias); err != nil {
+		warnf("Unable to delete alias %s: %v", alias, err)
+	}
+	if _, err := runAWSCLI(ctx, region, "kms", "schedule-key-deletion", "--key-id", keyID, "--pending-window-in-days", kmsKeyDeletionDays); err != nil {
+		warnf("Unable to schedule the deletion of KMS key %s: %v", keyID, err)
+		return
+	}
+	successf("Scheduled KMS key %s for deletion in %s days", keyID, kmsKeyDeletionDays)
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	eks "github.com/aws/aws-sdk-go-v2/service/eks"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// kubeconfigConfig controls the kubeconfig entries handed to users for the clusters they create
+type kubeconfigConfig struct {
+	// ContextName is a template for the context name over the cluster's .Name, .Region, .Arn and .Account
+	ContextName string `json:"context_name"`
+	// Auth is "exec" to fetch tokens with the aws CLI on every use, or "token" to embed a token,
+	// which expires after 15 minutes but needs no aws CLI
+	Auth string `json:"auth"`
+	// Path is a standalone kubeconfig file to write instead of merging into the default kubeconfig.
+	// It may use the same fields as ContextName.
+	Path string `json:"path"`
+}
+
+// validate checks the auth mode and that the templates parse
+func (c kubeconfigConfig) validate() error {
+	if c.Auth != "exec" && c.Auth != "token" {
+		return fmt.Errorf("kubeconfig.auth must be exec or token, got %q", c.Auth)
+	}
+	if _, err := template.New("context_name").Parse(c.ContextName); err != nil {
+		return fmt.Errorf("kubeconfig.context_name: %v", err)
+	}
+	if _, err := template.New("path").Parse(c.Path); err != nil {
+		return fmt.Errorf("kubeconfig.path: %v", err)
+	}
+	return nil
+}
+
+// clusterConnection holds what a client needs to reach a cluster's API server
+type clusterConnection struct {
+	Name                 string
+	Region               string
+	Arn                  string
+	Endpoint             string
+	CertificateAuthority string
+	// FIPS makes the aws CLI fetch tokens from FIPS endpoints
+	FIPS bool
+	// Profile is the AWS profile the aws CLI fetches tokens with, the default chain when empty
+	Profile string
+	// TLSServerName is the name the API server certificate is checked against when Endpoint is a tunnel
+	TLSServerName string
+	// ContextName names the kubeconfig context, the ARN when empty
+	ContextName string
+	// Token is embedded in the kubeconfig instead of an aws CLI exec entry when set
+	Token string
+	// Namespace is the default namespace of the context, the cluster's default when empty
+	Namespace string
+	// RoleArn is a role the aws CLI assumes to fetch tokens, the caller's identity when empty
+	RoleArn string
+}
+
+// Account returns the AWS account the cluster is in, taken from its ARN
+func (c clusterConnection) Account() string {
+	if parts := strings.Split(c.Arn, ":"); len(parts) > 4 {
+		return parts[4]
+	}
+	return ""
+}
+
+var kubeconfigTemplate = template.Must(template.New("kubeconfig").Parse(`apiVersion: v1
+kind: Config
+clusters:
+- name: {{ .Arn }}
+  cluster:
+    server: {{ .Endpoint }}
+    certificate-authority-data: {{ .CertificateAuthority }}
+{{- if .TLSServerName }}
+    tls-server-name: {{ .TLSServerName }}
+{{- end }}
+contexts:
+- name: {{ or .ContextName .Arn }}
+  context:
+    cluster: {{ .Arn }}
+    user: {{ .Arn }}
+{{- if .Namespace }}
+    namespace: {{ .Namespace }}
+{{- end }}
+current-context: {{ or .ContextName .Arn }}
+users:
+- name: {{ .Arn }}
+  user:
+{{- if .Token }}
+    token: {{ .Token }}
+{{- else }}
+    exec:
+      apiVersion: client.authentication.k8s.io/v1beta1
+      command: aws
+      args:
+      - --region
+      - {{ .Region }}
+      - eks
+      - get-token
+      - --cluster-name
+      - {{ .Name }}
+{{- if .RoleArn }}
+      - --role-arn
+      - {{ .RoleArn }}
+{{- end }}
+      - --output
+      - json
+{{- if or .FIPS .Profile }}
+      env:
+{{- if .FIPS }}
+      - name: AWS_USE_FIPS_ENDPOINT
+        value: "true"
+{{- end }}
+{{- if .Profile }}
+      - name: AWS_PROFILE
+        value: {{ .Profile }}
+{{- end }}
+{{- end }}
+{{- end }}
+`))
+
+// WaitForClusterConnection waits until the cluster is ACTIVE and returns its endpoint and CA data
+func WaitForClusterConnection(ctx context.Context, region, clusterName string, timeout time.Duration) (clusterConnection, error) {
+	cfg, err := loadAWSConfig(ctx, region)
+	if err != nil {
+		return clusterConnection{}, fmt.Errorf("unable to load AWS configuration: %w", err)
+	}
+	client := eks.NewFromConfig(cfg)
+
+	input := &eks.DescribeClusterInput{Name: aws.String(clusterName)}
+	waiter := eks.NewClusterActiveWaiter(client)
+	output, err := waiter.WaitForOutput(ctx, input, timeout)
+	if err != nil {
+		return clusterConnection{}, fmt.Errorf("cluster %s did not become active: %w", clusterName, err)
+	}
+
+	conn := clusterConnection{
+		Name:     clusterName,
+		Region:   region,
+		Arn:      aws.ToString(output.Cluster.Arn),
+		Endpoint: aws.ToString(output.Cluster.Endpoint),
+		FIPS:     *useFIPS,
+		Profile:  sessionProfile,
+	}
+	if output.Cluster.CertificateAuthority != nil {
+		conn.CertificateAuthority = aws.ToString(output.Cluster.CertificateAuthority.Data)
+	}
+	return conn, nil
+}
+
+// WriteKubeconfig writes a standalone kubeconfig for the cluster that authenticates through the aws CLI
+func WriteKubeconfig(path string, conn clusterConnection) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("unable to create kubeconfig directory: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("unable to create kubeconfig %s: %w", path, err)
+	}
+	defer f.Close()
+
+	if err := kubeconfigTemplate.Execute(f, conn); err != nil {
+		return fmt.Errorf("unable to write kubeconfig %s: %w", path, err)
+	}
+	return nil
+}
+
+// withKubeconfigOptions applies the kubeconfig section of the configuration file to a connection:
+// the context name template and, for token auth, a freshly generated token
+func withKubeconfigOptions(ctx context.Context, conn clusterConnection) (clusterConnection, error) {
+	opts := userConfig.Kubeconfig
+	name, err := renderKubeconfigTemplate("context_name", opts.ContextName, conn)
+	if err != nil {
+		return conn, err
+	}
+	conn.ContextName = name
+	if opts.Auth == "token" {
+		if conn.Token, err = GenerateEKSToken(ctx, conn.Region, conn.Name); err != nil {
+			return conn, err
+		}
+	}
+	return conn, nil
+}
+
+func renderKubeconfigTemplate(name, text string, conn clusterConnection) (string, error) {
+	tmpl, err := template.New(name).Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("kubeconfig.%s: %w", name, err)
+	}
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, conn); err != nil {
+		return "", fmt.Errorf("kubeconfig.%s: %w", name, err)
+	}
+	return out.String(), nil
+}
+
+// GenerateEKSToken creates a bearer token for the cluster the way `aws eks get-token` does: a
+// presigned STS GetCallerIdentity URL naming the cluster. EKS accepts it for 15 minutes.
+func GenerateEKSToken(ctx context.Context, region, clusterName string) (string, error) {
+	cfg, err := loadAWSConfig(ctx, region)
+	if err != nil {
+		return "", fmt.Errorf("unable to load AWS configuration: %w", err)
+	}
+	presigner := sts.NewPresignClient(sts.NewFromConfig(cfg))
+	req, err := presigner.PresignGetCallerIdentity(ctx, &sts.GetCallerIdentityInput{}, func(o *sts.PresignOptions) {
+		o.ClientOptions = append(o.ClientOptions, func(o *sts.Options) {
+			o.APIOptions = append(o.APIOptions,
+				smithyhttp.AddHeaderValue("x-k8s-aws-id", clusterName),
+				smithyhttp.AddHeaderValue("X-Amz-Expires", "60"))
+		})
+	})
+	if err != nil {
+		return "", fmt.Errorf("unable to generate a token for %s: %w", clusterName, err)
+	}
+	return "k8s-aws-v1." + base64.RawURLEncoding.EncodeToString([]byte(req.URL)), nil
+}
+
+// InstallKubeconfig hands the user a kubeconfig entry for the cluster according to the kubeconfig
+// section of the configuration file. Without kubeconfig.path the entry is merged into the default
+// kubeconfig and becomes its current context. It returns the file written.
+func InstallKubeconfig(ctx context.Context, conn clusterConnection) (string, error) {
+	conn, err := withKubeconfigOptions(ctx, conn)
+	if err != nil {
+		return "", err
+	}
+	if userConfig.Kubeconfig.Path != "" {
+		path, err := renderKubeconfigTemplate("path", userConfig.Kubeconfig.Path, conn)
+		if err != nil {
+			return "", err
+		}
+		path = expandHome(path)
+		return path, WriteKubeconfig(path, conn)
+	}
+
+	target := defaultKubeconfigPath()
+	if _, err := os.Stat(target); os.IsNotExist(err) {
+		return target, WriteKubeconfig(target, conn)
+	}
+	return target, mergeKubeconfig(ctx, target, conn)
+}
+
+// defaultKubeconfigPath returns the file kubectl writes to: the first entry of $KUBECONFIG or ~/.kube/config
+func defaultKubeconfigPath() string {
+	if env := os.Getenv("KUBECONFIG"); env != "" {
+		return filepath.SplitList(env)[0]
+	}
+	return expandHome(filepath.Join("~", ".kube", "config"))
+}
+
+// expandHome replaces a leading ~ with the home directory
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~"+string(filepath.Separator)) {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, path[1:])
+}
+
+// mergeKubeconfig merges the entry for conn into an existing kubeconfig with kubectl, replacing an
+// older entry of the same cluster. The file is replaced only once the merge has succeeded.
+func mergeKubeconfig(ctx context.Context, target string, conn clusterConnection) error {
+	if _, err := exec.LookPath("kubectl"); err != nil {
+		return fmt.Errorf("kubectl is needed to merge into %s, or set kubeconfig.path to write a separate file: %w", target, err)
+	}
+	dir, err := os.MkdirTemp("", "est-kube-")
+	if err != nil {
+		return fmt.Errorf("unable to create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+	entry := filepath.Join(dir, "entry")
+	if err := WriteKubeconfig(entry, conn); err != nil {
+		return err
+	}
+
+	// The first file wins on conflicts, so the new entry and its current-context take precedence
+	cmd := exec.CommandContext(ctx, "kubectl", "config", "view", "--raw", "--flatten")
+	cmd.Env = append(os.Environ(), "KUBECONFIG="+entry+string(filepath.ListSeparator)+target)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("unable to merge kubeconfig: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	merged := target + ".est-merge"
+	if err := os.WriteFile(merged, stdout.Bytes(), 0600); err != nil {
+		return fmt.Errorf("unable to write kubeconfig %s: %w", target, err)
+	}
+	if err := os.Rename(merged, target); err != nil {
+		return fmt.Errorf("unable to write kubeconfig %s: %w", target, err)
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+)
+
+// kubecostVersion is the Kubecost chart installed from the catalogue, with the values of its
+// EKS cost monitoring bundle
+const (
+	kubecostVersion   = "2.5.3"
+	kubecostChart     = "oci://public.ecr.aws/kubecost/cost-analyzer"
+	kubecostEKSValues = "https://raw.githubusercontent.com/kubecost/cost-analyzer-helm-chart/v" + kubecostVersion + "/cost-analyzer/values-eks-cost-monitoring.yaml"
+	kubecostNamespace = "kubecost"
+)
+
+// installKubecost installs Kubecost with its bundled Prometheus. Nothing is persisted, as the data
+// of a sandbox is not worth EBS volumes that outlive it, so history starts over when the pods do.
+// Costs come from public on-demand prices rather than the bill.
+func installKubecost(ctx context.Context, region, clusterName, roleArn string) error {
+	if _, err := exec.LookPath("helm"); err != nil {
+		return fmt.Errorf("helm is not installed: %w", err)
+	}
+	kube, err := newKubectlSession(ctx, region, clusterName)
+	if err != nil {
+		return err
+	}
+	defer kube.Close()
+
+	debugf("installing Kubecost %s with helm", kubecostVersion)
+	err = kube.Helm(ctx, "upgrade", "--install", "kubecost", kubecostChart, "--version", kubecostVersion,
+		"--namespace", kubecostNamespace, "--create-namespace", "-f", kubecostEKSValues,
+		"--set", "persistentVolume.enabled=false",
+		"--set", "prometheus.server.persistentVolume.enabled=false")
+	if err != nil {
+		return err
+	}
+	infof("See the cost of each namespace once Kubecost runs: kubectl -n %s port-forward deployment/kubecost-cost-analyzer 9090, then open http://localhost:9090",
+		kubecostNamespace)
+	return nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// kubectlSession runs kubectl against a cluster through a temporary kubeconfig
+type kubectlSession struct {
+	kubeconfig string
+	dir        string
+}
+
+// newKubectlSession writes a kubeconfig for the cluster to a temporary directory. Close removes it.
+// kubectl and helm calls are recorded and replayed with the cassette like AWS calls.
+func newKubectlSession(ctx context.Context, region, clusterName string) (*kubectlSession, error) {
+	if *replayFile == "" {
+		if _, err := exec.LookPath("kubectl"); err != nil {
+			return nil, fmt.Errorf("kubectl is not installed: %w", err)
+		}
+	}
+	conn, err := WaitForClusterConnection(ctx, region, clusterName, 30*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	dir, err := os.MkdirTemp("", "est-kube-")
+	if err != nil {
+		return nil, fmt.Errorf("unable to create temporary directory: %w", err)
+	}
+	path := filepath.Join(dir, "kubeconfig")
+	if err := WriteKubeconfig(path, conn); err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	return &kubectlSession{kubeconfig: path, dir: dir}, nil
+}
+
+// Run executes kubectl with args and returns its standard output
+func (k *kubectlSession) Run(ctx context.Context, args ...string) (string, error) {
+	if err := checkReadOnlyKubectl(args); err != nil {
+		return "", err
+	}
+	cmd := exec.CommandContext(ctx, "kubectl", append([]string{"--kubeconfig", k.kubeconfig}, args...)...)
+	debugf("running kubectl %s", strings.Join(args, " "))
+	stdout, stderr, err := runCommand("kubectl "+args[0], cmd)
+	if err != nil {
+		return stdout, fmt.Errorf("kubectl %s: %w: %s", args[0], err, strings.TrimSpace(stderr))
+	}
+	return stdout, nil
+}
+
+// Apply applies a manifest with kubectl apply
+func (k *kubectlSession) Apply(ctx context.Context, manifest string) error {
+	if err := checkReadOnlyKubectl([]string{"apply"}); err != nil {
+		return err
+	}
+	cmd := exec.CommandContext(ctx, "kubectl", "--kubeconfig", k.kubeconfig, "apply", "-f", "-")
+	cmd.Stdin = strings.NewReader(manifest)
+	debugf("applying manifest:\n%s", manifest)
+	if _, stderr, err := runCommand("kubectl apply", cmd); err != nil {
+		return fmt.Errorf("kubectl apply: %w: %s", err, strings.TrimSpace(stderr))
+	}
+	return nil
+}
+
+// Helm runs helm with args against the cluster
+func (k *kubectlSession) Helm(ctx context.Context, args ...string) error {
+	cmd := exec.CommandContext(ctx, "helm", append(args, "--kubeconfig", k.kubeconfig)...)
+	debugf("running helm %s", strings.Join(args, " "))
+	if _, stderr, err := runCommand("helm "+args[0], cmd); err != nil {
+		return fmt.Errorf("helm %s: %w: %s", args[0], err, strings.TrimSpace(stderr))
+	}
+	return nil
+}
+
+// Close removes the temporary kubeconfig
+func (k *kubectlSession) Close() {
+	os.RemoveAll(k.dir)
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	logDir       = flag.String("log-dir", "", "Directory for per-run log files (default ~/.est/logs)")
+	logRetention = flag.Int("log-retention", 20, "Number of run log files to keep; 0 disables file logging")
+	verbose      = flag.Bool("verbose", false, "Also print debug detail to the terminal")
+)
+
+var (
+	runLogMu   sync.Mutex
+	runLog     io.Writer = io.Discard
+	runLogPath string
+)
+
+// openRunLog starts a log file for this run and prunes the oldest files beyond the retention count.
+// The returned function closes the file.
+func openRunLog() (func(), error) {
+	if *logRetention <= 0 {
+		return func() {}, nil
+	}
+	dir := *logDir
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("unable to find home directory: %w", err)
+		}
+		dir = filepath.Join(home, ".est", "logs")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("unable to create log directory: %w", err)
+	}
+
+	base := filepath.Join(dir, fmt.Sprintf("est-%s-%d", time.Now().Format("20060102-150405"), os.Getpid()))
+	f, err := os.OpenFile(base+".log", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create log file: %w", err)
+	}
+	runLogMu.Lock()
+	runLog, runLogPath = f, base+".log"
+	timeline, err := openTimeline(base + ".timeline")
+	runLogMu.Unlock()
+	if err != nil {
+		warnf("%v", err)
+	}
+	logLine("INFO", fmt.Sprintf("est started with arguments %q", os.Args[1:]))
+
+	for _, pattern := range []string{"est-*.log", "est-*.timeline"} {
+		if err := pruneRunLogs(dir, pattern, *logRetention); err != nil {
+			warnf("unable to prune old log files: %v", err)
+		}
+	}
+	return func() {
+		runLogMu.Lock()
+		runLog, runTimeline = io.Discard, io.Discard
+		runLogMu.Unlock()
+		f.Close()
+		if timeline != nil {
+			timeline.Close()
+		}
+	}, nil
+}
+
+// pruneRunLogs removes the oldest run files matching pattern so that at most keep files remain
+func pruneRunLogs(dir, pattern string, keep int) error {
+	paths, err := filepath.Glob(filepath.Join(dir, pattern))
+	if err != nil {
+		return err
+	}
+	if len(paths) <= keep {
+		return nil
+	}
+	// File names start with a sortable timestamp
+	sort.Strings(paths)
+	for _, path := range paths[:len(paths)-keep] {
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// summaryWriter writes to stdout and the run log
+func summaryWriter() io.Writer {
+	return io.MultiWriter(os.Stdout, lockedRunLog{})
+}
+
+// lockedRunLog writes to the current run log under its lock
+type lockedRunLog struct{}
+
+func (lockedRunLog) Write(p []byte) (int, error) {
+	runLogMu.Lock()
+	defer runLogMu.Unlock()
+	return runLog.Write(p)
+}
+
+// logLine appends a timestamped line to the run log
+func logLine(level, msg string) {
+	runLogMu.Lock()
+	defer runLogMu.Unlock()
+	fmt.Fprintf(runLog, "%s %-5s %s\n", time.Now().Format(time.RFC3339), level, msg)
+}
+
+// debugf records detail that is only useful when diagnosing a run. It always goes to the
+// run log and is printed to the terminal with --verbose.
+func debugf(format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	logLine("DEBUG", msg)
+	if *verbose {
+		fmt.Fprintf(os.Stdout, "    %s\n", msg)
+	}
+}
@@ -2,280 +2,164 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
-	"log"
-	"time"
+	"os"
+	"strings"
 
 	"github.com/AlecAivazis/survey/v2"
 )
 
-func main() {
-	var region, clusterName, k8sVersion string
-	// Prompt the user to choose between creating or deleting a cluster
-	var action string
-	actionPrompt := &survey.Select{
-		Message: "What action do you want to perform?",
-		Options: []string{"Create Cluster", "Delete Cluster"},
-	}
-	if err := survey.AskOne(actionPrompt, &action); err != nil {
-		log.Fatalf("Error: %v", err)
-	}
+// stringList is a flag.Value that collects every occurrence of a repeatable flag
+type stringList []string
 
-	switch action {
-	case "Create Cluster":
-		// Create a list of regions
-		regionNames := []string{
-			"us-east-1",
-			"us-east-2",
-			"us-west-1",
-			"us-west-2",
-			"af-south-1",
-			"ap-east-1",
-			"ap-south-1",
-			"ap-northeast-3",
-			"ap-northeast-2",
-			"ap-southeast-1",
-			"ap-southeast-2",
-			"ca-central-1",
-			"eu-central-1",
-			"eu-west-1",
-			"eu-west-2",
-			"eu-south-1",
-			"eu-west-3",
-			"eu-north-1",
-			"me-south-1",
-			"sa-east-1",
-		}
-		var region string
-		prompt := &survey.Select{
-			Message:  "Select a region:",
-			Options:  regionNames,
-			Default:  "eu-west-1",
-			PageSize: 15,
-		}
-		err := survey.AskOne(prompt, &region)
-		if err != nil {
-			fmt.Println("Failed to get user input:", err)
-			log.Fatalf("Failed to get user input: %v", err)
-		}
-
-		// Prompt for EKS Cluster Name
-		promptCluster := &survey.Input{
-			Message: "Enter the name of the EKS cluster:",
-		}
-		if err := survey.AskOne(promptCluster, &clusterName, survey.WithValidator(survey.Required)); err != nil {
-			log.Fatalf("Error: %v", err)
-		}
-		clusterName = "Sandbox-" + clusterName
-		// Fetch the latest EKS version from AWS
-		latestVersion, err := GetLatestEKSVersion(context.Background(), region)
-		if err != nil {
-			log.Fatalf("Error fetching latest EKS version: %v", err)
-		}
-		// Prompt for Kubernetes version
-		promptK8sVersion := &survey.Input{
-			Message: "Enter the Kubernetes version default:",
-			Default: latestVersion,
-		}
-		if err := survey.AskOne(promptK8sVersion, &k8sVersion); err != nil {
-			log.Fatalf("Error: %v", err)
-		}
-		//prompt for auto mode enabled or not
-		var autoMode = true
-		autoModePrompt := &survey.Confirm{
-			Message: "Do you want to enable auto mode for the cluster? Default: Yes",
-		}
-		if err := survey.AskOne(autoModePrompt, &autoMode); err != nil {
-			log.Fatalf("Error: %v", err)
-		}
-
-		// Fetch AWS Account ID
-		fmt.Println("\nFetching AWS Account ID...")
-		accountID, callerID, err := GetAWSAccountDetails(context.Background(), region)
-		if err != nil {
-			log.Fatalf("Error fetching AWS Account ID: %v", err)
-		}
-		fmt.Printf("AWS Account ID: %s\n", accountID)
-		fmt.Printf("Performing operations as the identity %s\n", callerID)
-
-		// EKS Cluster Role
-		if err := IamOperations(context.Background(), region, "EKSClusterRole"); err != nil {
-			log.Fatalf("Error creating or attaching policies to EKSClusterRole: %v", err)
-		}
-
-		// Resource handling
-		var vpcID, igwID, routeTableID string
-		var subnets []string
-		var securityGroups []string
-
-		// Create new resources
-		currentDate := time.Now().Format("2006-01-02")            // Format the date as YYYY-MM-DD
-		vpcName := fmt.Sprintf("Sandbox-EKS-VPC-%s", currentDate) // Append the date to "EKS-VPC"
-		vpcID, err = CreateVPC(context.Background(), region, "10.0.0.0/16", vpcName)
-		if err != nil {
-			log.Fatalf("Error creating VPC: %v", err)
-		}
-		fmt.Printf("Created VPC ID: %s\n", vpcID)
-
-		subnet1, err := CreateSubnet(context.Background(), region, vpcID, "10.0.1.0/24", "EKS-Subnet-1", "a")
-		if err != nil {
-			log.Fatalf("Error creating Subnet 1: %v", err)
-		}
-		subnet2, err := CreateSubnet(context.Background(), region, vpcID, "10.0.2.0/24", "EKS-Subnet-2", "b")
-		if err != nil {
-			log.Fatalf("Error creating Subnet 2: %v", err)
-		}
-		subnets = []string{subnet1, subnet2}
-		err = EnableAutoAssignPublicIP(context.Background(), region, subnets)
-		if err != nil {
-			log.Fatalf("Error enabling auto-assign public IPv4: %v", err)
-		}
-		fmt.Println("Successfully enabled auto-assign public IPv4 for all subnets.")
-		fmt.Printf("Created Subnets: %s, %s\n", subnet1, subnet2)
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
 
-		igwID, err = CreateInternetGateway(context.Background(), region, "EKS-IGW", vpcID)
-		if err != nil {
-			log.Fatalf("Error creating Internet Gateway: %v", err)
-		}
-		fmt.Printf("Created Internet Gateway ID: %s\n", igwID)
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
 
-		routeTableID, err = CreateRouteTable(context.Background(), region, vpcID, "EKS-Route-Table")
-		if err != nil {
-			log.Fatalf("Error creating Route Table: %v", err)
-		}
-		fmt.Printf("Created Route Table ID: %s\n", routeTableID)
+var (
+	outputFormat = flag.String("output", "text", "Format of the end-of-run summary: text or json")
+	answersFile  = flag.String("answers", "", "JSON file with answers for the prompts, required when stdin is not a terminal")
+	webhookURLs  stringList
+	notifier     *webhookNotifier
+)
 
-		CreateRoute(context.Background(), region, routeTableID, "0.0.0.0/0", igwID)
-		AssociateRouteTable(context.Background(), region, routeTableID, subnet1)
-		AssociateRouteTable(context.Background(), region, routeTableID, subnet2)
+func init() {
+	flag.Var(&webhookURLs, "webhook", "URL that receives JSON payloads on cluster-created, cluster-deleted and failure events (repeatable)")
+}
 
-		sgID, err := CreateSecurityGroup(context.Background(), region, vpcID, "EKS-SG", "EKS Security Group")
-		if err != nil {
-			log.Fatalf("Error creating Security Group: %v", err)
-		}
-		securityGroups = []string{sgID}
-		fmt.Printf("Created Security Group ID: %s\n", sgID)
+func main() {
+	flag.Parse()
+	notifier = newWebhookNotifier(webhookURLs)
 
-		// Create EKS Cluster
-		fmt.Println("\nCreating EKS Cluster...")
-		err = CreateEKSCluster(context.Background(), region, clusterName, accountID, subnets, securityGroups, k8sVersion, vpcID, autoMode)
-		if err != nil {
-			log.Fatalf("Error creating EKS Cluster: %v", err)
-		}
-		//Ask to install addons
-		var createAddons = true
-		confirmPrompt := &survey.Confirm{
-			Message: "Do you want to install CoreDNS, Kubeproxy, VPC_CNI  addons ? Default: Yes",
-		}
-		if err := survey.AskOne(confirmPrompt, &createAddons); err != nil {
-			log.Fatalf("Error: %v", err)
-		}
+	closeLog, err := openRunLog()
+	if err != nil {
+		warnf("file logging disabled: %v", err)
+		closeLog = func() {}
+	}
 
-		if createAddons {
-			// Add code to install 3 addons
-			err = InstallAddons(context.Background(), region, clusterName)
-			if err != nil {
-				log.Fatalf("Error installing addons:( %v", err)
-			}
-		}
+	err = run(context.Background())
+	if saveErr := saveCassette(); saveErr != nil {
+		warnf("%v", saveErr)
+	}
+	manifest.Write(context.Background(), err)
+	if err != nil {
+		notifier.Notify(eventFailure, nil, err)
+		errorf("Error: %v", err)
+		if runLogPath != "" {
+			infof("Full log of this run: %s", runLogPath)
+		}
+		if runTimelinePath != "" {
+			infof("Timeline for bug reports: %s", runTimelinePath)
+		}
+		closeLog()
+		os.Exit(exitCodeFor(err))
+	}
+	closeLog()
+}
 
-	case "Delete Cluster":
-		// Logic for deleting a cluster
-		promptRegion := &survey.Input{
-			Message: "Enter the AWS region (default: eu-west-2):",
-			Default: "eu-west-2",
+func run(ctx context.Context) error {
+	if *outputFormat != "text" && *outputFormat != "json" {
+		return validationErr("unsupported output format %q, expected text or json", *outputFormat)
+	}
+	var cmd command
+	if args := flag.Args(); len(args) > 0 {
+		var ok bool
+		if cmd, ok = findCommand(args[0]); !ok {
+			return validationErr("unknown command %q, run est help for the list of commands", args[0])
 		}
-		if err := survey.AskOne(promptRegion, &region); err != nil {
-			log.Fatalf("Error: %v", err)
+		if cmd.Offline {
+			return cmd.Run(ctx, nil, args[1:])
 		}
+	}
+	if err := loadUserConfig(); err != nil {
+		return err
+	}
+	loadInstallerPlugins()
+	if err := openCassette(); err != nil {
+		return err
+	}
+	if err := loadGuardrails(ctx); err != nil {
+		return err
+	}
 
-		// Fetch existing clusters
-		clusters, err := ListEKSClusters(context.Background(), region)
-		if err != nil {
-			log.Fatalf("Error fetching clusters: %v", err)
+	if *answersFile != "" {
+		if err := loadAnswersFile(*answersFile); err != nil {
+			return err
 		}
+	}
 
-		if len(clusters) == 0 {
-			fmt.Println("No clusters found in the specified region.")
-			return
-		}
+	timer := newStepTimer()
+	if cmd.Run != nil {
+		return cmd.Run(ctx, timer, flag.Args()[1:])
+	}
 
-		// Prompt the user to select a cluster to delete
-		var selectedCluster string
-		clusterPrompt := &survey.Select{
-			Message: "Select the cluster to delete:",
-			Options: clusters,
-		}
-		if err := survey.AskOne(clusterPrompt, &selectedCluster); err != nil {
-			log.Fatalf("Error: %v", err)
+	// An interactive session returns to the menu after each action, so the profile and region can
+	// be switched between actions. Scripted runs perform a single action.
+	session := !nonInteractive() && os.Getenv("EST_ACTION") == "" && *answersFile == ""
+	actions := []string{"Create Cluster", "Delete Cluster", "Manage Addons", "Upgrade Node Groups", "Pause Cluster", "Resume Cluster", "Switch Profile/Region"}
+	defaultAction := "Create Cluster"
+	if *readOnly {
+		// Only actions that change nothing are offered
+		actions, defaultAction = []string{"List Clusters", "Switch Profile/Region"}, "List Clusters"
+	}
+	if session {
+		actions = append(actions, "Exit")
+	}
+	for {
+		// Prompt the user to choose between creating or deleting a cluster
+		var action string
+		actionPrompt := &survey.Select{
+			Message: "What action do you want to perform?",
+			Options: actions,
+			Default: defaultAction,
+		}
+		if err := askOne("EST_ACTION", actionPrompt, &action); err != nil {
+			return wrapErr("reading action", err)
+		}
+		if err := requireInputs(); err != nil {
+			return err
+		}
+
+		var err error
+		switch action {
+		case "Create Cluster":
+			err = runCreate(ctx, timer)
+		case "Delete Cluster":
+			err = runDelete(ctx, timer)
+		case "Manage Addons":
+			err = runManageAddons(ctx, timer)
+		case "Upgrade Node Groups":
+			err = runUpgradeNodegroups(ctx, timer)
+		case "Pause Cluster":
+			err = runPauseCluster(ctx, timer, true)
+		case "Resume Cluster":
+			err = runPauseCluster(ctx, timer, false)
+		case "List Clusters":
+			err = runListClustersAction(ctx)
+		case "Switch Profile/Region":
+			err = runSwitchSession(ctx)
+		case "Exit":
+			return nil
 		}
-
-		// Check if the cluster has the required "CreatedBy" tag
-		isCreatedByTool, err := CheckClusterTag(context.Background(), region, selectedCluster, "CreatedBy", "EKS-Sandbox-Tool")
 		if err != nil {
-			log.Fatalf("Error checking cluster tags: %v", err)
+			return err
 		}
-		if !isCreatedByTool {
-			// Warn the user
-			var confirmDelete bool
-			warningPrompt := &survey.Confirm{
-				Message: "This cluster does not appear to be created by this tool. Are you sure you want to delete it? Danger!!",
-			}
-			if err := survey.AskOne(warningPrompt, &confirmDelete); err != nil {
-				log.Fatalf("Error: %v", err)
-			}
 
-			if !confirmDelete {
-				fmt.Println("Cluster deletion aborted.")
-				return
+		if action != "Switch Profile/Region" {
+			if err := timer.PrintSummary(summaryWriter(), *outputFormat); err != nil {
+				return fmt.Errorf("printing timing summary: %w", err)
 			}
 		}
-		isIsolatedVpc, err := CheckClusterTag(context.Background(), region, selectedCluster, "HostingVPC", "isolated")
-		if err != nil {
-			log.Fatalf("Error checking cluster tags: %v", err)
-		}
-		if isIsolatedVpc {
-			vpcId, err := GetVPCIDFromCluster(context.Background(), region, selectedCluster)
-			if err != nil {
-				log.Fatalf("Error getting VpcId from cluster tags: %v", err)
-			}
-
-			//delete VPC too
-			var confirmDeleteVPC = true
-			askVpcDeletePrompt := &survey.Confirm{
-				Message: "Do you want to delte VPC and all dependent objects in it? Default: Yes",
-				Default: confirmDeleteVPC,
-			}
-			if err := survey.AskOne(askVpcDeletePrompt, &confirmDeleteVPC); err != nil {
-				log.Fatalf("Error: %v", err)
-			}
-			if confirmDeleteVPC {
-				// Proceed to delete the cluster
-				err = DeleteEKSCluster(context.Background(), region, selectedCluster)
-				if err != nil {
-					log.Fatalf("Error deleting cluster: %v", err)
-				}
-
-				fmt.Printf("Cluster '%s' deletion initiated successfully.\n", selectedCluster)
-
-				// write delete VPC function passing VPc id as input
-
-				err = DeleteVPC(context.Background(), region, vpcId)
-				if err != nil {
-					log.Fatalf("Error deleting VPC: %v", err)
-				}
-				fmt.Println("VPC and all components of the VPC deleted")
-			} else {
-				fmt.Println("Deleting just the cluster and leaving VPC intact")
-				err = DeleteEKSCluster(context.Background(), region, selectedCluster)
-				if err != nil {
-					log.Fatalf("Error deleting cluster: %v", err)
-				}
-
-				fmt.Printf("Cluster '%s' deletion initiated successfully.\n", selectedCluster)
-			}
+		if !session {
+			return nil
 		}
-
+		timer = newStepTimer()
+		notifier.Cluster = ""
 	}
-
 }
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/user"
+	"text/template"
+	"time"
+)
+
+// clusterNameTag marks the EC2 resources created for a cluster with its name
+const clusterNameTag = "ClusterName"
+
+// taggedCluster is the cluster whose resources are being created, empty outside of creation
+var taggedCluster string
+
+// namingConfig holds the templates the network resources of a new VPC are named with. Each
+// template sees the fields of resourceNames.
+type namingConfig struct {
+	VPC               string `json:"vpc"`
+	Subnet            string `json:"subnet"`
+	PrivateSubnet     string `json:"private_subnet"`
+	LocalZoneSubnet   string `json:"local_zone_subnet"`
+	PodSubnet         string `json:"pod_subnet"`
+	InternetGateway   string `json:"internet_gateway"`
+	NATGateway        string `json:"nat_gateway"`
+	RouteTable        string `json:"route_table"`
+	PrivateRouteTable string `json:"private_route_table"`
+	SecurityGroup     string `json:"security_group"`
+}
+
+// templates returns each template with its key in the configuration file
+func (c namingConfig) templates() map[string]string {
+	return map[string]string{
+		"vpc":                 c.VPC,
+		"subnet":              c.Subnet,
+		"private_subnet":      c.PrivateSubnet,
+		"local_zone_subnet":   c.LocalZoneSubnet,
+		"pod_subnet":          c.PodSubnet,
+		"internet_gateway":    c.InternetGateway,
+		"nat_gateway":         c.NATGateway,
+		"route_table":         c.RouteTable,
+		"private_route_table": c.PrivateRouteTable,
+		"security_group":      c.SecurityGroup,
+	}
+}
+
+// validate checks that every template parses and renders a name for a sample cluster, so a bad
+// template is reported before anything is created
+func (c namingConfig) validate() error {
+	sample := resourceNames{Cluster: "Sandbox-demo", Date: "2006-01-02", User: "user", AZ: "eu-west-2a", Index: 1}
+	for key, text := range c.templates() {
+		name, err := sample.render(key, text)
+		if err != nil {
+			return err
+		}
+		if name == "" {
+			return fmt.Errorf("naming.%s renders an empty name", key)
+		}
+	}
+	return nil
+}
+
+// resourceNames are the fields a naming template can use
+type resourceNames struct {
+	// Cluster is the cluster name, including its cluster prefix
+	Cluster string
+	// Date is the creation date as YYYY-MM-DD
+	Date string
+	// User is the local user running the tool
+	User string
+	// AZ is the zone of a subnet, empty for other resources
+	AZ string
+	// Index counts subnets of one kind from 1, and is 0 for other resources
+	Index int
+}
+
+// newResourceNames returns the naming fields of the cluster being created today
+func newResourceNames(clusterName string) resourceNames {
+	username := os.Getenv("USER")
+	if u, err := user.Current(); err == nil {
+		username = u.Username
+	}
+	return resourceNames{Cluster: clusterName, Date: time.Now().Format("2006-01-02"), User: username}
+}
+
+// subnet returns the fields for the index-th subnet of a kind, in zone az
+func (n resourceNames) subnet(az string, index int) resourceNames {
+	n.AZ, n.Index = az, index
+	return n
+}
+
+// render names a resource with the template text configured under naming.<key>
+func (n resourceNames) render(key, text string) (string, error) {
+	tmpl, err := template.New(key).Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("naming.%s: %w", key, err)
+	}
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, n); err != nil {
+		return "", fmt.Errorf("naming.%s: %w", key, err)
+	}
+	return out.String(), nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/config"
+)
+
+// networkConfig describes how AWS is reached from networks that only allow traffic through a proxy.
+// Without it HTTPS_PROXY, NO_PROXY and AWS_CA_BUNDLE from the environment are used.
+type networkConfig struct {
+	// HTTPSProxy is the proxy URL used for AWS API calls
+	HTTPSProxy string `json:"https_proxy"`
+	// NoProxy is a comma separated list of hosts and domains that are reached directly
+	NoProxy string `json:"no_proxy"`
+	// CABundle is a PEM file with extra certificate authorities to trust, e.g. of a TLS-intercepting proxy
+	CABundle string `json:"ca_bundle"`
+}
+
+func (n networkConfig) validate() error {
+	if n.HTTPSProxy != "" {
+		u, err := url.Parse(n.HTTPSProxy)
+		if err != nil || u.Host == "" {
+			return fmt.Errorf("network.https_proxy %q is not a valid URL", n.HTTPSProxy)
+		}
+	}
+	if n.CABundle != "" {
+		if _, err := os.Stat(n.CABundle); err != nil {
+			return fmt.Errorf("network.ca_bundle: %v", err)
+		}
+	}
+	return nil
+}
+
+// networkLoadOptions returns the AWS config options that apply the network settings
+func networkLoadOptions(n networkConfig) ([]func(*config.LoadOptions) error, error) {
+	var opts []func(*config.LoadOptions) error
+	if n.HTTPSProxy != "" {
+		proxy, err := proxyFunc(n.HTTPSProxy, n.NoProxy)
+		if err != nil {
+			return nil, err
+		}
+		client := awshttp.NewBuildableClient().WithTransportOptions(func(tr *http.Transport) {
+			tr.Proxy = proxy
+		})
+		opts = append(opts, config.WithHTTPClient(client))
+	}
+	if n.CABundle != "" {
+		pem, err := os.ReadFile(n.CABundle)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read CA bundle: %w", err)
+		}
+		opts = append(opts, config.WithCustomCABundle(bytes.NewReader(pem)))
+	}
+	return opts, nil
+}
+
+// proxyFunc sends requests through proxyURL unless the host matches an entry of noProxy
+func proxyFunc(proxyURL, noProxy string) (func(*http.Request) (*url.URL, error), error) {
+	proxy, err := url.Parse(proxyURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL: %w", err)
+	}
+	var exclusions []string
+	for _, entry := range strings.Split(noProxy, ",") {
+		if entry = strings.ToLower(strings.TrimSpace(entry)); entry != "" {
+			exclusions = append(exclusions, entry)
+		}
+	}
+	return func(req *http.Request) (*url.URL, error) {
+		host := strings.ToLower(req.URL.Hostname())
+		for _, entry := range exclusions {
+			if entry == "*" || host == strings.TrimPrefix(entry, ".") || strings.HasSuffix(host, "."+strings.TrimPrefix(entry, ".")) {
+				return nil, nil
+			}
+			if _, cidr, err := net.ParseCIDR(entry); err == nil {
+				if ip := net.ParseIP(host); ip != nil && cidr.Contains(ip) {
+					return nil, nil
+				}
+			}
+		}
+		return proxy, nil
+	}, nil
+}
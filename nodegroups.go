@@ -0,0 +1,319 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	eks "github.com/aws/aws-sdk-go-v2/service/eks"
+	"github.com/aws/aws-sdk-go-v2/service/eks/types"
+)
+
+// nodegroupInfo describes a managed node group of a cluster
+type nodegroupInfo struct {
+	Name    string
+	Version string
+	Status  string
+	// MaxUnavailable is the node group's update setting, e.g. "1" or "33%"
+	MaxUnavailable string
+}
+
+// nodegroupUpgrade describes how node groups are brought to the control plane version
+type nodegroupUpgrade struct {
+	// MaxUnavailable, e.g. "1" or "33%", replaces the node groups' setting when not empty
+	MaxUnavailable string
+	// Force upgrades nodes whose pods cannot be drained because of pod disruption budgets
+	Force bool
+}
+
+// runUpgradeNodegroups upgrades the managed node groups the user selects to the control plane version
+func runUpgradeNodegroups(ctx context.Context, timer *stepTimer) error {
+	if err := requireWritable("Upgrade Node Groups"); err != nil {
+		return err
+	}
+	var region string
+	promptRegion := &survey.Input{
+		Message: "Enter the AWS region:",
+		Default: regionDefault("eu-west-2"),
+	}
+	if err := askOne("EST_REGION", promptRegion, &region, survey.WithValidator(validRegion)); err != nil {
+		return wrapErr("reading region", err)
+	}
+	notifier.Region = region
+
+	clusters, err := ListEKSClusters(ctx, region)
+	if err != nil {
+		return wrapErr("fetching clusters", err)
+	}
+	if len(clusters) == 0 {
+		infof("No clusters found in the specified region.")
+		return nil
+	}
+	clusterName, err := askCluster(clusters, "Select the cluster whose node groups to upgrade:")
+	if err != nil {
+		return err
+	}
+	notifier.Cluster = clusterName
+
+	clusterVersion, nodegroups, err := ListNodegroups(ctx, region, clusterName)
+	if err != nil {
+		return wrapErr("listing node groups", err)
+	}
+	var options []string
+	for _, ng := range nodegroups {
+		if ng.Version != clusterVersion {
+			options = append(options, fmt.Sprintf("%s %s %s maxUnavailable=%s", ng.Name, ng.Version, ng.Status, ng.MaxUnavailable))
+		}
+	}
+	if len(options) == 0 {
+		infof("Every managed node group of %s already runs Kubernetes %s.", clusterName, clusterVersion)
+		return nil
+	}
+
+	var selected []string
+	nodegroupPrompt := &survey.MultiSelect{
+		Message:  fmt.Sprintf("Select the node groups to upgrade to Kubernetes %s:", clusterVersion),
+		Options:  options,
+		Default:  options,
+		PageSize: 15,
+	}
+	if err := askOne("EST_NODEGROUPS", nodegroupPrompt, &selected); err != nil {
+		return wrapErr("reading node groups to upgrade", err)
+	}
+
+	var upgrade nodegroupUpgrade
+	surgePrompt := &survey.Input{
+		Message: "Nodes to replace at a time, as a count or a percentage (empty keeps each node group's setting):",
+	}
+	validSurge := func(ans interface{}) error {
+		_, _, err := parseMaxUnavailable(ans.(string))
+		return err
+	}
+	if err := askOne("EST_MAX_UNAVAILABLE", surgePrompt, &upgrade.MaxUnavailable, survey.WithValidator(validSurge)); err != nil {
+		return wrapErr("reading max unavailable nodes", err)
+	}
+	forcePrompt := &survey.Confirm{
+		Message: "Replace nodes even when pod disruption budgets prevent draining them?",
+		Default: confirmDefault("EST_FORCE_UPGRADE"),
+	}
+	if err := askOne("EST_FORCE_UPGRADE", forcePrompt, &upgrade.Force); err != nil {
+		return wrapErr("reading force choice", err)
+	}
+	if err := requireInputs(); err != nil {
+		return err
+	}
+	if len(selected) == 0 {
+		infof("No node groups selected, nothing was changed.")
+		return nil
+	}
+
+	stopUpgrade := timer.Start("nodegroup-upgrade")
+	for _, choice := range selected {
+		name := strings.Fields(choice)[0]
+		stepf("Upgrading node group %s to Kubernetes %s...", name, clusterVersion)
+		if err := UpgradeNodegroup(ctx, region, clusterName, name, clusterVersion, upgrade); err != nil {
+			return wrapErr("upgrading node group "+name, err)
+		}
+		successf("Node group %s runs Kubernetes %s", name, clusterVersion)
+	}
+	stopUpgrade()
+	return nil
+}
+
+// parseMaxUnavailable reads a node count such as "2" or a percentage such as "33%". An empty value
+// returns zeros.
+func parseMaxUnavailable(value string) (count, percentage int32, err error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, 0, nil
+	}
+	if trimmed, ok := strings.CutSuffix(value, "%"); ok {
+		n, err := strconv.Atoi(trimmed)
+		if err != nil || n < 1 || n > 100 {
+			return 0, 0, fmt.Errorf("%q is not a percentage between 1%% and 100%%", value)
+		}
+		return 0, int32(n), nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 || n > 100 {
+		return 0, 0, fmt.Errorf("%q is not a node count between 1 and 100", value)
+	}
+	return int32(n), 0, nil
+}
+
+// ListNodegroups returns the control plane version of a cluster and its managed node groups
+func ListNodegroups(ctx context.Context, region, clusterName string) (string, []nodegroupInfo, error) {
+	cfg, err := loadAWSConfig(ctx, region)
+	if err != nil {
+		return "", nil, fmt.Errorf("unable to load AWS configuration: %w", err)
+	}
+	client := eks.NewFromConfig(cfg)
+
+	cluster, err := client.DescribeCluster(ctx, &eks.DescribeClusterInput{Name: aws.String(clusterName)})
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to describe EKS cluster %s: %w", clusterName, err)
+	}
+
+	var nodegroups []nodegroupInfo
+	paginator := eks.NewListNodegroupsPaginator(client, &eks.ListNodegroupsInput{ClusterName: aws.String(clusterName)})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return "", nil, fmt.Errorf("unable to list node groups of %s: %w", clusterName, err)
+		}
+		for _, name := range page.Nodegroups {
+			output, err := client.DescribeNodegroup(ctx, &eks.DescribeNodegroupInput{
+				ClusterName:   aws.String(clusterName),
+				NodegroupName: aws.String(name),
+			})
+			if err != nil {
+				return "", nil, fmt.Errorf("unable to describe node group %s: %w", name, err)
+			}
+			ng := output.Nodegroup
+			info := nodegroupInfo{Name: name, Version: aws.ToString(ng.Version), Status: string(ng.Status), MaxUnavailable: "1"}
+			if update := ng.UpdateConfig; update != nil {
+				if update.MaxUnavailablePercentage != nil {
+					info.MaxUnavailable = fmt.Sprintf("%d%%", aws.ToInt32(update.MaxUnavailablePercentage))
+				} else if update.MaxUnavailable != nil {
+					info.MaxUnavailable = strconv.Itoa(int(aws.ToInt32(update.MaxUnavailable)))
+				}
+			}
+			nodegroups = append(nodegroups, info)
+		}
+	}
+	return aws.ToString(cluster.Cluster.Version), nodegroups, nil
+}
+
+// nodegroupUpgradeTimeout bounds one node group upgrade; nodes are replaced a few at a time
+const nodegroupUpgradeTimeout = 90 * time.Minute
+
+// UpgradeNodegroup applies the surge setting of upgrade to a node group and then upgrades it to
+// version, waiting for each update to complete
+func UpgradeNodegroup(ctx context.Context, region, clusterName, nodegroup, version string, upgrade nodegroupUpgrade) error {
+	cfg, err := loadAWSConfig(ctx, region)
+	if err != nil {
+		return fmt.Errorf("unable to load AWS configuration: %w", err)
+	}
+	client := eks.NewFromConfig(cfg)
+
+	count, percentage, err := parseMaxUnavailable(upgrade.MaxUnavailable)
+	if err != nil {
+		return validationErr("%v", err)
+	}
+	if count > 0 || percentage > 0 {
+		updateConfig := &types.NodegroupUpdateConfig{}
+		if percentage > 0 {
+			updateConfig.MaxUnavailablePercentage = aws.Int32(percentage)
+		} else {
+			updateConfig.MaxUnavailable = aws.Int32(count)
+		}
+		output, err := client.UpdateNodegroupConfig(ctx, &eks.UpdateNodegroupConfigInput{
+			ClusterName:   aws.String(clusterName),
+			NodegroupName: aws.String(nodegroup),
+			UpdateConfig:  updateConfig,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to set max unavailable nodes of %s: %w", nodegroup, err)
+		}
+		err = waitForEKSUpdate(ctx, client, clusterName, nodegroup, aws.ToString(output.Update.Id), "node group "+nodegroup+" config", 15*time.Minute)
+		if err != nil {
+			return err
+		}
+		infof("Node group %s replaces up to %s nodes at a time", nodegroup, upgrade.MaxUnavailable)
+	}
+
+	output, err := client.UpdateNodegroupVersion(ctx, &eks.UpdateNodegroupVersionInput{
+		ClusterName:   aws.String(clusterName),
+		NodegroupName: aws.String(nodegroup),
+		Version:       aws.String(version),
+		Force:         upgrade.Force,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to upgrade node group %s: %w", nodegroup, err)
+	}
+	return waitForEKSUpdate(ctx, client, clusterName, nodegroup, aws.ToString(output.Update.Id), "node group "+nodegroup+" upgrade", nodegroupUpgradeTimeout)
+}
+
+// nodeDrainTimeout bounds the drain of one node group. Evictions blocked by a pod disruption budget
+// are retried until then, after which the node group is deleted regardless.
+const nodeDrainTimeout = 5 * time.Minute
+
+// DeleteNodegroups cordons and drains the nodes of each managed node group of a cluster and then
+// deletes the node group, so running workloads terminate gracefully instead of with their instances
+func DeleteNodegroups(ctx context.Context, region, clusterName string) error {
+	_, nodegroups, err := ListNodegroups(ctx, region, clusterName)
+	if err != nil {
+		return err
+	}
+	if len(nodegroups) == 0 {
+		return nil
+	}
+
+	cfg, err := loadAWSConfig(ctx, region)
+	if err != nil {
+		return fmt.Errorf("unable to load AWS configuration: %w", err)
+	}
+	client := eks.NewFromConfig(cfg)
+
+	kube, err := newKubectlSession(ctx, region, clusterName)
+	if err != nil {
+		warnf("Unable to reach the cluster, node groups are deleted without draining: %v", err)
+	} else {
+		defer kube.Close()
+	}
+
+	for _, ng := range nodegroups {
+		if kube != nil {
+			drainNodegroup(ctx, kube, ng.Name)
+		}
+		stepf("Deleting node group %s...", ng.Name)
+		_, err := client.DeleteNodegroup(ctx, &eks.DeleteNodegroupInput{
+			ClusterName:   aws.String(clusterName),
+			NodegroupName: aws.String(ng.Name),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to delete node group %s: %w", ng.Name, err)
+		}
+		waiter := eks.NewNodegroupDeletedWaiter(client)
+		err = waiter.Wait(ctx, &eks.DescribeNodegroupInput{
+			ClusterName:   aws.String(clusterName),
+			NodegroupName: aws.String(ng.Name),
+		}, 30*time.Minute)
+		if err != nil {
+			return fmt.Errorf("node group %s was not deleted: %w", ng.Name, err)
+		}
+		successf("Node group %s deleted", ng.Name)
+	}
+	return nil
+}
+
+// drainNodegroup cordons the nodes of a node group and evicts their pods. Evictions go through the
+// eviction API, so pod disruption budgets are respected until nodeDrainTimeout. It only warns, as
+// the node group is deleted either way.
+func drainNodegroup(ctx context.Context, kube *kubectlSession, nodegroup string) {
+	selector := "eks.amazonaws.com/nodegroup=" + nodegroup
+	nodes, err := kube.Run(ctx, "get", "nodes", "-l", selector, "-o", "name")
+	if err != nil {
+		warnf("Unable to list the nodes of node group %s: %v", nodegroup, err)
+		return
+	}
+	if strings.TrimSpace(nodes) == "" {
+		return
+	}
+	stepf("Draining %d nodes of node group %s...", len(strings.Fields(nodes)), nodegroup)
+	if _, err := kube.Run(ctx, "cordon", "-l", selector); err != nil {
+		warnf("Unable to cordon the nodes of node group %s: %v", nodegroup, err)
+		return
+	}
+	_, err = kube.Run(ctx, "drain", "-l", selector, "--ignore-daemonsets", "--delete-emptydir-data",
+		"--timeout="+nodeDrainTimeout.String())
+	if err != nil {
+		warnf("Node group %s was not fully drained within %s, remaining pods stop with their nodes: %v", nodegroup, nodeDrainTimeout, err)
+		return
+	}
+	successf("Drained node group %s", nodegroup)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"strings"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// openIngressTag marks security groups that AuthorizeAllTraffic opened to the internet
+const openIngressTag = "OpenIngress"
+
+// openIngressPhrase has to be typed, or set in EST_CONFIRM_OPEN_INGRESS, before all inbound traffic is allowed
+const openIngressPhrase = "I understand this opens everything to the internet"
+
+// openIngressConsent is proof that the user confirmed opening a security group. It can only be
+// obtained from confirmOpenIngress, so AuthorizeAllTraffic cannot be called without asking.
+type openIngressConsent struct {
+	sgID string
+}
+
+// confirmOpenIngress asks the user to type openIngressPhrase before sgID is opened to the internet
+func confirmOpenIngress(sgID string) (openIngressConsent, error) {
+	warnf("Security group %s is about to allow ALL inbound traffic from 0.0.0.0/0", sgID)
+	var answer string
+	confirmPrompt := &survey.Input{
+		Message: "Type '" + openIngressPhrase + "' to continue:",
+	}
+	if err := askOne("EST_CONFIRM_OPEN_INGRESS", confirmPrompt, &answer); err != nil {
+		return openIngressConsent{}, wrapErr("reading open ingress confirmation", err)
+	}
+	if !strings.EqualFold(strings.TrimSpace(answer), openIngressPhrase) {
+		return openIngressConsent{}, validationErr("opening security group %s to the internet was not confirmed", sgID)
+	}
+	return openIngressConsent{sgID: sgID}, nil
+}
+
+// ListOpenIngressGroups returns the IDs of security groups in the region carrying the OpenIngress marker, keyed by VPC ID
+func ListOpenIngressGroups(ctx context.Context, region string) (map[string][]string, error) {
+	cfg, err := loadAWSConfig(ctx, region)
+	if err != nil {
+		return nil, err
+	}
+	ec2Client := ec2.NewFromConfig(cfg)
+
+	groups := map[string][]string{}
+	paginator := ec2.NewDescribeSecurityGroupsPaginator(ec2Client, &ec2.DescribeSecurityGroupsInput{
+		Filters: []ec2types.Filter{
+			{Name: aws.String("tag-key"), Values: []string{openIngressTag}},
+		},
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, sg := range page.SecurityGroups {
+			vpcID := aws.ToString(sg.VpcId)
+			groups[vpcID] = append(groups[vpcID], aws.ToString(sg.GroupId))
+		}
+	}
+	return groups, nil
+}
+
+// hasOpenIngressTag reports whether tags contain the OpenIngress marker
+func hasOpenIngressTag(tags []ec2types.Tag) bool {
+	for _, tag := range tags {
+		if aws.ToString(tag.Key) == openIngressTag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+)
+
+var noColor = flag.Bool("no-color", false, "Disable colors and status icons (also honored through the NO_COLOR environment variable)")
+
+// status is the kind of progress line printed by the output helpers
+type status int
+
+const (
+	statusStep status = iota
+	statusInfo
+	statusSuccess
+	statusWarn
+	statusError
+)
+
+// statusStyles maps each status to its ANSI color, icon, plain-text prefix and run log level
+var statusStyles = map[status]struct {
+	color, icon, plain, level string
+}{
+	statusStep:    {"\033[1;36m", "▶", "==>", "STEP"},
+	statusInfo:    {"\033[0m", "•", "   ", "INFO"},
+	statusSuccess: {"\033[32m", "✔", "[ok]", "OK"},
+	statusWarn:    {"\033[33m", "⚠", "[warn]", "WARN"},
+	statusError:   {"\033[31m", "✖", "[error]", "ERROR"},
+}
+
+// colorEnabled reports whether colors and icons should be used. They are disabled by --no-color,
+// by NO_COLOR (https://no-color.org), and when stdout is not a terminal unless running in CI,
+// whose log viewers render ANSI colors.
+func colorEnabled() bool {
+	if *noColor || os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	return *ciMode || term.IsTerminal(int(os.Stdout.Fd()))
+}
+
+// printStatus writes a single status line to w and to the run log
+func printStatus(w io.Writer, s status, format string, args ...interface{}) {
+	style := statusStyles[s]
+	msg := strings.TrimRight(fmt.Sprintf(format, args...), "\n")
+	logLine(style.level, msg)
+	timelineEvent(style.level, msg)
+	if *timestamps {
+		msg = fmt.Sprintf("%s %s %s", time.Now().Format("15:04:05"), elapsedLabel(), msg)
+	}
+	if !colorEnabled() {
+		fmt.Fprintf(w, "%s %s\n", style.plain, msg)
+		return
+	}
+	fmt.Fprintf(w, "%s%s %s\033[0m\n", style.color, style.icon, msg)
+}
+
+// stepf announces the start of a provisioning step
+func stepf(format string, args ...interface{}) {
+	printStatus(os.Stdout, statusStep, format, args...)
+}
+
+// infof prints progress detail
+func infof(format string, args ...interface{}) {
+	printStatus(os.Stdout, statusInfo, format, args...)
+}
+
+// successf reports a completed action
+func successf(format string, args ...interface{}) {
+	printStatus(os.Stdout, statusSuccess, format, args...)
+}
+
+// warnf reports a problem that does not stop the run
+func warnf(format string, args ...interface{}) {
+	printStatus(os.Stdout, statusWarn, format, args...)
+}
+
+// errorf reports the error that ended the run
+func errorf(format string, args ...interface{}) {
+	printStatus(os.Stderr, statusError, format, args...)
+}
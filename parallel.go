@@ -0,0 +1,51 @@
+package main
+
+import (
+	"errors"
+	"sync"
+)
+
+// teardownWorkers bounds how many deletions of one resource class run at the same time. The batch
+// rate limiter still paces the calls themselves.
+const teardownWorkers = 8
+
+// forEachParallel calls fn for every item with at most workers calls in flight and returns the
+// errors of all failed calls joined together
+func forEachParallel(items []string, workers int, fn func(item string) error) error {
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	slots := make(chan struct{}, workers)
+	for _, item := range items {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(item string) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			if err := fn(item); err != nil {
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+			}
+		}(item)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// runConcurrently runs independent functions at the same time and returns their errors joined together
+func runConcurrently(fns ...func() error) error {
+	errs := make([]error, len(fns))
+	var wg sync.WaitGroup
+	for i, fn := range fns {
+		wg.Add(1)
+		go func(i int, fn func() error) {
+			defer wg.Done()
+			errs[i] = fn()
+		}(i, fn)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
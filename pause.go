@@ -0,0 +1,337 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	eks "github.com/aws/aws-sdk-go-v2/service/eks"
+	"github.com/aws/aws-sdk-go-v2/service/eks/types"
+)
+
+// pausedScalingTag records on a paused node group the sizes resume restores, as "<min>/<desired>"
+const pausedScalingTag = "PausedScaling"
+
+// pausedLimitsAnnotation records on a paused NodePool the limits resume restores, as JSON
+const pausedLimitsAnnotation = "eks-sandbox-tool/paused-limits"
+
+// runPauseCluster prompts for a cluster created by the tool and pauses or resumes it
+func runPauseCluster(ctx context.Context, timer *stepTimer, pause bool) error {
+	action := "Resume Cluster"
+	if pause {
+		action = "Pause Cluster"
+	}
+	if err := requireWritable(action); err != nil {
+		return err
+	}
+	var region string
+	promptRegion := &survey.Input{
+		Message: "Enter the AWS region:",
+		Default: regionDefault("eu-west-2"),
+	}
+	if err := askOne("EST_REGION", promptRegion, &region, survey.WithValidator(validRegion)); err != nil {
+		return wrapErr("reading region", err)
+	}
+	notifier.Region = region
+
+	clusters, err := ListEKSClusters(ctx, region)
+	if err != nil {
+		return wrapErr("fetching clusters", err)
+	}
+	if len(clusters) == 0 {
+		infof("No clusters found in the specified region.")
+		return nil
+	}
+	message := "Select the cluster to resume:"
+	if pause {
+		message = "Select the cluster to pause:"
+	}
+	clusterName, err := askCluster(clusters, message)
+	if err != nil {
+		return err
+	}
+	notifier.Cluster = clusterName
+
+	createdByTool, err := CheckClusterTag(ctx, region, clusterName, "CreatedBy", "EKS-Sandbox-Tool")
+	if err != nil {
+		return wrapErr("checking cluster tags", err)
+	}
+	if !createdByTool {
+		return validationErr("%s was not created by this tool and is left as it is", clusterName)
+	}
+
+	if pause {
+		stop := timer.Start("pause")
+		defer stop()
+		return PauseCluster(ctx, region, clusterName)
+	}
+	stop := timer.Start("resume")
+	defer stop()
+	return ResumeCluster(ctx, region, clusterName)
+}
+
+// PauseCluster stops the nodes of a cluster while keeping the control plane, so an idle sandbox
+// only costs its control plane. NodePools of auto mode and Karpenter are paused first, as the
+// Karpenter controller runs on a node group and has to drain its nodes. Managed node groups are
+// then scaled to zero, with their sizes recorded in the pausedScalingTag. Fargate pods cannot be
+// paused and keep running.
+func PauseCluster(ctx context.Context, region, clusterName string) error {
+	if kube, err := newKubectlSession(ctx, region, clusterName); err != nil {
+		warnf("Unable to reach the cluster, NodePools are not paused: %v", err)
+	} else {
+		err := pauseNodePools(ctx, kube)
+		kube.Close()
+		if err != nil {
+			warnf("Unable to pause NodePools: %v", err)
+		}
+	}
+
+	cfg, err := loadAWSConfig(ctx, region)
+	if err != nil {
+		return fmt.Errorf("unable to load AWS configuration: %w", err)
+	}
+	client := eks.NewFromConfig(cfg)
+	nodegroups, err := describeNodegroups(ctx, client, clusterName)
+	if err != nil {
+		return err
+	}
+	for _, ng := range nodegroups {
+		name := aws.ToString(ng.NodegroupName)
+		scaling := ng.ScalingConfig
+		if _, paused := ng.Tags[pausedScalingTag]; paused || scaling == nil {
+			continue
+		}
+		if aws.ToInt32(scaling.MinSize) == 0 && aws.ToInt32(scaling.DesiredSize) == 0 {
+			continue
+		}
+		stepf("Scaling node group %s to zero...", name)
+		_, err := client.TagResource(ctx, &eks.TagResourceInput{
+			ResourceArn: ng.NodegroupArn,
+			Tags:        map[string]string{pausedScalingTag: fmt.Sprintf("%d/%d", aws.ToInt32(scaling.MinSize), aws.ToInt32(scaling.DesiredSize))},
+		})
+		if err != nil {
+			return fmt.Errorf("unable to record the size of node group %s: %w", name, err)
+		}
+		err = scaleNodegroup(ctx, client, clusterName, name, 0, 0, aws.ToInt32(scaling.MaxSize))
+		if err != nil {
+			return err
+		}
+		successf("Node group %s scaled to zero", name)
+	}
+
+	profiles, err := client.ListFargateProfiles(ctx, &eks.ListFargateProfilesInput{ClusterName: aws.String(clusterName)})
+	if err != nil {
+		return fmt.Errorf("unable to list Fargate profiles of %s: %w", clusterName, err)
+	}
+	if len(profiles.FargateProfileNames) > 0 {
+		infof("Fargate pods keep running; scale their deployments down to stop them")
+	}
+	successf("Paused %s; resume it to get its nodes back", clusterName)
+	return nil
+}
+
+// ResumeCluster brings back the nodes of a cluster paused with PauseCluster. Node groups are
+// restored to their recorded sizes before the NodePools, so the Karpenter controller runs again.
+func ResumeCluster(ctx context.Context, region, clusterName string) error {
+	cfg, err := loadAWSConfig(ctx, region)
+	if err != nil {
+		return fmt.Errorf("unable to load AWS configuration: %w", err)
+	}
+	client := eks.NewFromConfig(cfg)
+	nodegroups, err := describeNodegroups(ctx, client, clusterName)
+	if err != nil {
+		return err
+	}
+	for _, ng := range nodegroups {
+		name := aws.ToString(ng.NodegroupName)
+		recorded, paused := ng.Tags[pausedScalingTag]
+		if !paused && ng.ScalingConfig != nil && aws.ToInt32(ng.ScalingConfig.DesiredSize) == 0 {
+			// Paused by its schedule, which resumes it to the sizes recorded with the schedule
+			recorded, paused = ng.Tags[scheduledScalingTag]
+		}
+		if !paused || ng.ScalingConfig == nil {
+			continue
+		}
+		minSize, desiredSize, err := parsePausedScaling(recorded)
+		if err != nil {
+			return fmt.Errorf("node group %s: %w", name, err)
+		}
+		stepf("Scaling node group %s back to %d nodes...", name, desiredSize)
+		maxSize := max(aws.ToInt32(ng.ScalingConfig.MaxSize), desiredSize)
+		if err := scaleNodegroup(ctx, client, clusterName, name, minSize, desiredSize, maxSize); err != nil {
+			return err
+		}
+		if _, ok := ng.Tags[pausedScalingTag]; ok {
+			_, err = client.UntagResource(ctx, &eks.UntagResourceInput{ResourceArn: ng.NodegroupArn, TagKeys: []string{pausedScalingTag}})
+			if err != nil {
+				return fmt.Errorf("unable to clear the recorded size of node group %s: %w", name, err)
+			}
+		}
+		successf("Node group %s runs %d nodes again", name, desiredSize)
+	}
+
+	if kube, err := newKubectlSession(ctx, region, clusterName); err != nil {
+		warnf("Unable to reach the cluster, NodePools are not resumed: %v", err)
+	} else {
+		err := resumeNodePools(ctx, kube)
+		kube.Close()
+		if err != nil {
+			warnf("Unable to resume NodePools: %v", err)
+		}
+	}
+	successf("Resumed %s", clusterName)
+	return nil
+}
+
+// parsePausedScaling reads the sizes recorded in the pausedScalingTag
+func parsePausedScaling(value string) (minSize, desiredSize int32, err error) {
+	minValue, desiredValue, ok := strings.Cut(value, "/")
+	minParsed, minErr := strconv.ParseInt(minValue, 10, 32)
+	desiredParsed, desiredErr := strconv.ParseInt(desiredValue, 10, 32)
+	if !ok || minErr != nil || desiredErr != nil {
+		return 0, 0, fmt.Errorf("the %s tag %q is not <min>/<desired>", pausedScalingTag, value)
+	}
+	return int32(minParsed), int32(desiredParsed), nil
+}
+
+// describeNodegroups returns the managed node groups of a cluster
+func describeNodegroups(ctx context.Context, client *eks.Client, clusterName string) ([]*types.Nodegroup, error) {
+	var nodegroups []*types.Nodegroup
+	paginator := eks.NewListNodegroupsPaginator(client, &eks.ListNodegroupsInput{ClusterName: aws.String(clusterName)})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("unable to list node groups of %s: %w", clusterName, err)
+		}
+		for _, name := range page.Nodegroups {
+			output, err := client.DescribeNodegroup(ctx, &eks.DescribeNodegroupInput{
+				ClusterName:   aws.String(clusterName),
+				NodegroupName: aws.String(name),
+			})
+			if err != nil {
+				return nil, fmt.Errorf("unable to describe node group %s: %w", name, err)
+			}
+			nodegroups = append(nodegroups, output.Nodegroup)
+		}
+	}
+	return nodegroups, nil
+}
+
+// scaleNodegroup sets the sizes of a node group and waits for EKS to apply them
+func scaleNodegroup(ctx context.Context, client *eks.Client, clusterName, nodegroup string, minSize, desiredSize, maxSize int32) error {
+	output, err := client.UpdateNodegroupConfig(ctx, &eks.UpdateNodegroupConfigInput{
+		ClusterName:   aws.String(clusterName),
+		NodegroupName: aws.String(nodegroup),
+		ScalingConfig: &types.NodegroupScalingConfig{
+			MinSize:     aws.Int32(minSize),
+			DesiredSize: aws.Int32(desiredSize),
+			MaxSize:     aws.Int32(maxSize),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to scale node group %s: %w", nodegroup, err)
+	}
+	return waitForEKSUpdate(ctx, client, clusterName, nodegroup, aws.ToString(output.Update.Id), "node group "+nodegroup+" scaling", 30*time.Minute)
+}
+
+// nodePoolList is the part of `kubectl get nodepools -o json` pausing needs
+type nodePoolList struct {
+	Items []struct {
+		Metadata struct {
+			Name        string            `json:"name"`
+			Annotations map[string]string `json:"annotations"`
+		} `json:"metadata"`
+		Spec struct {
+			Limits json.RawMessage `json:"limits"`
+		} `json:"spec"`
+	} `json:"items"`
+}
+
+// listNodePools returns the NodePools of a cluster, none when neither auto mode nor Karpenter
+// provides them
+func listNodePools(ctx context.Context, kube *kubectlSession) (nodePoolList, error) {
+	var pools nodePoolList
+	out, err := kube.Run(ctx, "get", "nodepools.karpenter.sh", "-o", "json")
+	if err != nil {
+		if strings.Contains(err.Error(), "doesn't have a resource type") {
+			return pools, nil
+		}
+		return pools, err
+	}
+	if err := json.Unmarshal([]byte(out), &pools); err != nil {
+		return pools, fmt.Errorf("unable to parse NodePools: %w", err)
+	}
+	return pools, nil
+}
+
+// pauseNodePools sets the CPU limit of every NodePool to zero, so no node is launched for it,
+// and deletes its NodeClaims, which drains and terminates its nodes. The previous limits are
+// kept in the pausedLimitsAnnotation. Built-in auto mode NodePools that refuse the change only warn.
+func pauseNodePools(ctx context.Context, kube *kubectlSession) error {
+	pools, err := listNodePools(ctx, kube)
+	if err != nil {
+		return err
+	}
+	for _, pool := range pools.Items {
+		name := pool.Metadata.Name
+		if _, paused := pool.Metadata.Annotations[pausedLimitsAnnotation]; paused {
+			continue
+		}
+		limits := string(pool.Spec.Limits)
+		if limits == "" || limits == "null" {
+			limits = "{}"
+		}
+		stepf("Pausing NodePool %s...", name)
+		_, err := kube.Run(ctx, "annotate", "nodepools.karpenter.sh", name, pausedLimitsAnnotation+"="+limits, "--overwrite")
+		if err == nil {
+			_, err = kube.Run(ctx, "patch", "nodepools.karpenter.sh", name, "--type", "merge", "-p", `{"spec":{"limits":{"cpu":"0"}}}`)
+		}
+		if err != nil {
+			warnf("Unable to pause NodePool %s: %v", name, err)
+			continue
+		}
+		_, err = kube.Run(ctx, "delete", "nodeclaims.karpenter.sh", "-l", "karpenter.sh/nodepool="+name,
+			"--timeout="+nodeDrainTimeout.String())
+		if err != nil {
+			warnf("The nodes of NodePool %s were not all removed within %s: %v", name, nodeDrainTimeout, err)
+			continue
+		}
+		successf("Paused NodePool %s", name)
+	}
+	return nil
+}
+
+// resumeNodePools restores the limits recorded by pauseNodePools, after which nodes are launched
+// again for pending pods
+func resumeNodePools(ctx context.Context, kube *kubectlSession) error {
+	pools, err := listNodePools(ctx, kube)
+	if err != nil {
+		return err
+	}
+	for _, pool := range pools.Items {
+		name := pool.Metadata.Name
+		limits, paused := pool.Metadata.Annotations[pausedLimitsAnnotation]
+		if !paused {
+			continue
+		}
+		patch := `[{"op":"add","path":"/spec/limits","value":` + limits + `}]`
+		if limits == "{}" {
+			patch = `[{"op":"remove","path":"/spec/limits"}]`
+		}
+		_, err := kube.Run(ctx, "patch", "nodepools.karpenter.sh", name, "--type", "json", "-p", patch)
+		if err == nil {
+			_, err = kube.Run(ctx, "annotate", "nodepools.karpenter.sh", name, pausedLimitsAnnotation+"-")
+		}
+		if err != nil {
+			warnf("Unable to resume NodePool %s: %v", name, err)
+			continue
+		}
+		successf("Resumed NodePool %s", name)
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	eks "github.com/aws/aws-sdk-go-v2/service/eks"
+)
+
+// installerPluginPrefix starts the names of the executables that install extra addons. Like
+// kubectl plugins, an est-install-<name> found on PATH adds <name> to the addon catalogue.
+const installerPluginPrefix = "est-install-"
+
+var installerPluginName = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// findInstallerPlugins returns the path of every installer plugin on PATH by addon name. When
+// several directories hold a plugin of the same name the first one wins, as it would for a command.
+func findInstallerPlugins() map[string]string {
+	plugins := map[string]string{}
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			name, ok := strings.CutPrefix(entry.Name(), installerPluginPrefix)
+			if !ok || !installerPluginName.MatchString(name) || plugins[name] != "" {
+				continue
+			}
+			info, err := entry.Info()
+			if err != nil || info.IsDir() || info.Mode().Perm()&0111 == 0 {
+				continue
+			}
+			plugins[name] = filepath.Join(dir, entry.Name())
+		}
+	}
+	return plugins
+}
+
+// loadInstallerPlugins adds the installer plugins on PATH to the addon catalogue. Addons built into
+// the tool keep their name.
+func loadInstallerPlugins() {
+	plugins := findInstallerPlugins()
+	names := make([]string, 0, len(plugins))
+	for name := range plugins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		path := plugins[name]
+		if _, ok := catalogueEntry(name); ok {
+			warnf("Ignoring installer plugin %s, %s is built in", path, name)
+			continue
+		}
+		debugf("found installer plugin %s", path)
+		addonCatalogue = append(addonCatalogue, installerPlugin(name, path))
+	}
+}
+
+// installerPlugin returns the catalogue entry of a plugin. The plugin is run as
+// `est-install-<name> install` once the cluster is ready and as `est-install-<name> cleanup` after
+// a cluster it was installed on is deleted, with EST_CLUSTER and EST_REGION in its environment. For
+// install, KUBECONFIG points to a kubeconfig for the cluster. A plugin creates the AWS resources it
+// needs itself, with the caller's credentials, and removes them on cleanup.
+func installerPlugin(name, path string) catalogueAddon {
+	return catalogueAddon{
+		Name:        name,
+		Description: "installer plugin " + path,
+		Plugin:      true,
+		Install: func(ctx context.Context, region, clusterName, roleArn string) error {
+			kube, err := newKubectlSession(ctx, region, clusterName)
+			if err != nil {
+				return err
+			}
+			defer kube.Close()
+			env, err := pluginEnv(ctx, region, clusterName)
+			if err != nil {
+				return err
+			}
+			env = append(env, "KUBECONFIG="+kube.kubeconfig)
+			if err := runExternal(ctx, nil, env, path, "install"); err != nil {
+				return fmt.Errorf("plugin %s: %w", path, err)
+			}
+			// Recorded only once installed, so a failed install is not cleaned up for
+			if err := recordInstalledPlugin(ctx, region, clusterName, name); err != nil {
+				warnf("Unable to record plugin %s on %s, run %s cleanup yourself once the cluster is deleted: %v", name, clusterName, path, err)
+			}
+			return nil
+		},
+		Cleanup: func(ctx context.Context, region, clusterName string) error {
+			env, err := pluginEnv(ctx, region, clusterName)
+			if err != nil {
+				return err
+			}
+			if err := runExternal(ctx, nil, env, path, "cleanup"); err != nil {
+				return fmt.Errorf("plugin %s: %w", path, err)
+			}
+			return nil
+		},
+	}
+}
+
+// pluginEnv returns the environment of a plugin run: the cluster, its region and the credentials
+// of the run, so a plugin works in the profile or account role est uses
+func pluginEnv(ctx context.Context, region, clusterName string) ([]string, error) {
+	credentials, err := credentialsEnv(ctx, region)
+	if err != nil {
+		return nil, err
+	}
+	return append([]string{"EST_CLUSTER=" + clusterName, "EST_REGION=" + region}, credentials...), nil
+}
+
+// installedPluginsTag lists the installer plugins installed on a cluster, separated by spaces, as
+// tag values cannot hold commas
+const installedPluginsTag = "InstallerPlugins"
+
+// recordInstalledPlugin adds a plugin to the installedPluginsTag of a cluster
+func recordInstalledPlugin(ctx context.Context, region, clusterName, name string) error {
+	cfg, err := loadAWSConfig(ctx, region)
+	if err != nil {
+		return fmt.Errorf("unable to load AWS configuration: %w", err)
+	}
+	client := eks.NewFromConfig(cfg)
+	output, err := client.DescribeCluster(ctx, &eks.DescribeClusterInput{Name: aws.String(clusterName)})
+	if err != nil {
+		return fmt.Errorf("failed to describe EKS cluster %s: %w", clusterName, err)
+	}
+	plugins := pluginsFromTags(output.Cluster.Tags)
+	if slices.Contains(plugins, name) {
+		return nil
+	}
+	plugins = append(plugins, name)
+	_, err = client.TagResource(ctx, &eks.TagResourceInput{
+		ResourceArn: output.Cluster.Arn,
+		Tags:        map[string]string{installedPluginsTag: strings.Join(plugins, " ")},
+	})
+	if err != nil {
+		return fmt.Errorf("unable to tag cluster %s: %w", clusterName, err)
+	}
+	return nil
+}
+
+// InstalledPlugins returns the installer plugins installed on a cluster. It has to be read before
+// the cluster is deleted, as the tag goes with it.
+func InstalledPlugins(ctx context.Context, region, clusterName string) ([]string, error) {
+	cfg, err := loadAWSConfig(ctx, region)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load AWS configuration: %w", err)
+	}
+	output, err := eks.NewFromConfig(cfg).DescribeCluster(ctx, &eks.DescribeClusterInput{Name: aws.String(clusterName)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe EKS cluster %s: %w", clusterName, err)
+	}
+	return pluginsFromTags(output.Cluster.Tags), nil
+}
+
+// pluginsFromTags returns the installer plugins recorded in the tags of a cluster
+func pluginsFromTags(tags map[string]string) []string {
+	return strings.Fields(tags[installedPluginsTag])
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// gatekeeperVersion is the Gatekeeper release installed with the policy bundle
+const gatekeeperVersion = "v3.17.1"
+
+// gatekeeperManifest installs Gatekeeper with its default settings
+const gatekeeperManifest = "https://raw.githubusercontent.com/open-policy-agent/gatekeeper/" + gatekeeperVersion + "/deploy/gatekeeper.yaml"
+
+// policyConfig selects the constraint library installed with the policy bundle
+type policyConfig struct {
+	// LibraryDir is a directory of ConstraintTemplate and constraint manifests (*.yaml, *.yml) applied
+	// instead of the starter library, e.g. a checkout of the production policies
+	LibraryDir string `json:"library_dir"`
+}
+
+func (c policyConfig) validate() error {
+	if c.LibraryDir == "" {
+		return nil
+	}
+	if info, err := os.Stat(c.LibraryDir); err != nil || !info.IsDir() {
+		return fmt.Errorf("policy.library_dir %s is not a directory", c.LibraryDir)
+	}
+	return nil
+}
+
+// starterConstraintTemplates are the ConstraintTemplates of the starter library
+const starterConstraintTemplates = `apiVersion: templates.gatekeeper.sh/v1
+kind: ConstraintTemplate
+metadata:
+  name: k8sprivilegedcontainer
+spec:
+  crd:
+    spec:
+      names:
+        kind: K8sPrivilegedContainer
+  targets:
+  - target: admission.k8s.gatekeeper.sh
+    rego: |
+      package k8sprivilegedcontainer
+
+      violation[{"msg": msg}] {
+        c := input_containers[_]
+        c.securityContext.privileged
+        msg := sprintf("privileged container %v is not allowed", [c.name])
+      }
+
+      input_containers[c] {
+        c := input.review.object.spec.containers[_]
+      }
+
+      input_containers[c] {
+        c := input.review.object.spec.initContainers[_]
+      }
+---
+apiVersion: templates.gatekeeper.sh/v1
+kind: ConstraintTemplate
+metadata:
+  name: k8sdisallowedtags
+spec:
+  crd:
+    spec:
+      names:
+        kind: K8sDisallowedTags
+      validation:
+        openAPIV3Schema:
+          type: object
+          properties:
+            tags:
+              type: array
+              items:
+                type: string
+  targets:
+  - target: admission.k8s.gatekeeper.sh
+    rego: |
+      package k8sdisallowedtags
+
+      violation[{"msg": msg}] {
+        c := input_containers[_]
+        tag := input.parameters.tags[_]
+        endswith(c.image, concat(":", ["", tag]))
+        msg := sprintf("container %v uses the disallowed image tag %v", [c.name, tag])
+      }
+
+      violation[{"msg": msg}] {
+        c := input_containers[_]
+        not contains(c.image, "@")
+        not regex.match(":[^/]+$", c.image)
+        msg := sprintf("container %v uses an image without a tag: %v", [c.name, c.image])
+      }
+
+      input_containers[c] {
+        c := input.review.object.spec.containers[_]
+      }
+
+      input_containers[c] {
+        c := input.review.object.spec.initContainers[_]
+      }
+---
+apiVersion: templates.gatekeeper.sh/v1
+kind: ConstraintTemplate
+metadata:
+  name: k8srequiredlabels
+spec:
+  crd:
+    spec:
+      names:
+        kind: K8sRequiredLabels
+      validation:
+        openAPIV3Schema:
+          type: object
+          properties:
+            labels:
+              type: array
+              items:
+                type: string
+  targets:
+  - target: admission.k8s.gatekeeper.sh
+    rego: |
+      package k8srequiredlabels
+
+      violation[{"msg": msg}] {
+        provided := {label | input.review.object.metadata.labels[label]}
+        required := {label | label := input.parameters.labels[_]}
+        missing := required - provided
+        count(missing) > 0
+        msg := sprintf("missing required labels: %v", [missing])
+      }
+`
+
+// starterConstraints put the starter templates to use. They only warn, so a sandbox behaves like
+// production without blocking experiments.
+const starterConstraints = `apiVersion: constraints.gatekeeper.sh/v1beta1
+kind: K8sPrivilegedContainer
+metadata:
+  name: no-privileged-containers
+spec:
+  enforcementAction: warn
+  match:
+    kinds:
+    - apiGroups: [""]
+      kinds: ["Pod"]
+    excludedNamespaces: ["kube-system", "gatekeeper-system"]
+---
+apiVersion: constraints.gatekeeper.sh/v1beta1
+kind: K8sDisallowedTags
+metadata:
+  name: no-latest-images
+spec:
+  enforcementAction: warn
+  match:
+    kinds:
+    - apiGroups: [""]
+      kinds: ["Pod"]
+    excludedNamespaces: ["kube-system", "gatekeeper-system"]
+  parameters:
+    tags: ["latest"]
+---
+apiVersion: constraints.gatekeeper.sh/v1beta1
+kind: K8sRequiredLabels
+metadata:
+  name: namespaces-have-owner
+spec:
+  enforcementAction: warn
+  match:
+    kinds:
+    - apiGroups: [""]
+      kinds: ["Namespace"]
+    excludedNamespaces: ["default", "kube-system", "kube-public", "kube-node-lease", "gatekeeper-system"]
+  parameters:
+    labels: ["owner"]
+`
+
+// InstallPolicyBundle installs Gatekeeper and a constraint library: the library in
+// policy.library_dir when configured, the starter library otherwise. Gatekeeper only turns
+// templates into constraint kinds once its controller runs, which needs nodes; when it does not
+// come up in time the library is saved for the user to apply later.
+func InstallPolicyBundle(ctx context.Context, region, clusterName string) error {
+	kube, err := newKubectlSession(ctx, region, clusterName)
+	if err != nil {
+		return err
+	}
+	defer kube.Close()
+
+	stepf("Installing Gatekeeper %s...", gatekeeperVersion)
+	if _, err := kube.Run(ctx, "apply", "-f", gatekeeperManifest); err != nil {
+		return err
+	}
+
+	library, err := policyLibrary()
+	if err != nil {
+		return err
+	}
+
+	_, err = kube.Run(ctx, "-n", "gatekeeper-system", "rollout", "status", "deployment/gatekeeper-controller-manager", "--timeout=5m")
+	if err != nil {
+		path, saveErr := saveManifest("policies", clusterName, library)
+		if saveErr != nil {
+			return fmt.Errorf("gatekeeper did not start (%v) and the policy library could not be saved: %w", err, saveErr)
+		}
+		warnf("Gatekeeper did not start, most likely because the cluster has no nodes yet: %v", err)
+		infof("Apply the policy library once it runs: kubectl apply -f %s", path)
+		return nil
+	}
+
+	// Constraints can only be created once Gatekeeper has generated the kinds of their templates,
+	// so applying them is retried until it stops failing
+	err = pollUntil(ctx, "policy library", pollOptions{Timeout: 5 * time.Minute}, func(ctx context.Context) (bool, string, error) {
+		if err := kube.Apply(ctx, library); err != nil {
+			debugf("applying policy library: %v", err)
+			return false, "waiting for constraint kinds", nil
+		}
+		return true, "applied", nil
+	})
+	if err != nil {
+		return err
+	}
+	successf("Gatekeeper is running with the %s policy library", policyLibraryName())
+	return nil
+}
+
+// policyLibraryName describes the library being installed in messages
+func policyLibraryName() string {
+	if dir := userConfig.Policy.LibraryDir; dir != "" {
+		return dir
+	}
+	return "starter"
+}
+
+// policyLibrary returns the manifests of the constraint library, templates first
+func policyLibrary() (string, error) {
+	dir := userConfig.Policy.LibraryDir
+	if dir == "" {
+		return starterConstraintTemplates + "---\n" + starterConstraints, nil
+	}
+	var files []string
+	for _, pattern := range []string{"*.yaml", "*.yml"} {
+		matches, err := filepath.Glob(filepath.Join(dir, pattern))
+		if err != nil {
+			return "", err
+		}
+		files = append(files, matches...)
+	}
+	slices.Sort(files)
+	if len(files) == 0 {
+		return "", validationErr("policy.library_dir %s contains no .yaml or .yml files", dir)
+	}
+	var templates, constraints []string
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return "", fmt.Errorf("unable to read policy %s: %w", file, err)
+		}
+		if strings.Contains(string(data), "kind: ConstraintTemplate") {
+			templates = append(templates, string(data))
+		} else {
+			constraints = append(constraints, string(data))
+		}
+	}
+	return strings.Join(append(templates, constraints...), "\n---\n"), nil
+}
+
+// saveManifest writes a manifest that could not be applied yet to ~/.est/<kind>/<cluster>.yaml,
+// e.g. the policy library, and returns the path
+func saveManifest(kind, clusterName, manifest string) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("unable to find home directory: %w", err)
+	}
+	dir := filepath.Join(home, ".est", kind)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("unable to create directory %s: %w", dir, err)
+	}
+	path := filepath.Join(dir, clusterName+".yaml")
+	if err := os.WriteFile(path, []byte(manifest), 0644); err != nil {
+		return "", fmt.Errorf("unable to write %s: %w", path, err)
+	}
+	return path, nil
+}
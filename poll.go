@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	eks "github.com/aws/aws-sdk-go-v2/service/eks"
+	ekstypes "github.com/aws/aws-sdk-go-v2/service/eks/types"
+)
+
+// pollConfig sets how often resources without an SDK waiter are checked
+type pollConfig struct {
+	// IntervalSeconds is the time between two checks
+	IntervalSeconds int `json:"interval_seconds"`
+	// ProgressSeconds is how often a still-waiting message is printed while the state does not change
+	ProgressSeconds int `json:"progress_seconds"`
+}
+
+// pollOptions controls a single pollUntil call; zero values fall back to the polling configuration
+type pollOptions struct {
+	Interval time.Duration
+	Timeout  time.Duration
+}
+
+// pollCheck reports whether the awaited state is reached, and the current state for progress output
+type pollCheck func(ctx context.Context) (done bool, state string, err error)
+
+// pollUntil calls check until it reports done, fails, or opts.Timeout passes. State changes are
+// printed as they happen and a progress line is printed when the state stays the same for long.
+func pollUntil(ctx context.Context, what string, opts pollOptions, check pollCheck) error {
+	interval := opts.Interval
+	if interval <= 0 {
+		interval = time.Duration(userConfig.Polling.IntervalSeconds) * time.Second
+	}
+	progress := time.Duration(userConfig.Polling.ProgressSeconds) * time.Second
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	lastState, lastReport := "", start
+	for {
+		done, state, err := check(ctx)
+		if err != nil {
+			return fmt.Errorf("%s: %w", what, err)
+		}
+		if done {
+			debugf("%s reached %s after %s", what, state, time.Since(start).Round(time.Second))
+			return nil
+		}
+		if state != lastState {
+			infof("%s is %s", what, state)
+			lastState, lastReport = state, time.Now()
+		} else if progress > 0 && time.Since(lastReport) >= progress {
+			infof("Still waiting for %s (%s, %s elapsed)", what, state, time.Since(start).Round(time.Second))
+			lastReport = time.Now()
+		}
+
+		select {
+		case <-ctx.Done():
+			if ctx.Err() == context.DeadlineExceeded {
+				return fmt.Errorf("%s was still %s after %s", what, state, opts.Timeout)
+			}
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+// WaitForAddon polls an addon until it settles as ACTIVE, DEGRADED or CREATE_FAILED and returns it
+func WaitForAddon(ctx context.Context, region, clusterName, addonName string, timeout time.Duration) (*ekstypes.Addon, error) {
+	cfg, err := loadAWSConfig(ctx, region)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load AWS configuration: %w", err)
+	}
+	client := eks.NewFromConfig(cfg)
+
+	var addon *ekstypes.Addon
+	err = pollUntil(ctx, "addon "+addonName, pollOptions{Timeout: timeout}, func(ctx context.Context) (bool, string, error) {
+		output, err := client.DescribeAddon(ctx, &eks.DescribeAddonInput{
+			ClusterName: aws.String(clusterName),
+			AddonName:   aws.String(addonName),
+		})
+		if err != nil {
+			return false, "", err
+		}
+		addon = output.Addon
+		switch addon.Status {
+		case ekstypes.AddonStatusActive, ekstypes.AddonStatusDegraded, ekstypes.AddonStatusCreateFailed:
+			return true, string(addon.Status), nil
+		}
+		return false, string(addon.Status), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return addon, nil
+}
+
+// WaitForAddonActive polls an addon until it is ACTIVE. A failed or degraded addon ends the wait
+// with the health issues EKS reports for it.
+func WaitForAddonActive(ctx context.Context, region, clusterName, addonName string, timeout time.Duration) error {
+	addon, err := WaitForAddon(ctx, region, clusterName, addonName, timeout)
+	if err != nil {
+		return err
+	}
+	if addon.Status != ekstypes.AddonStatusActive {
+		return fmt.Errorf("addon %s is %s%s", addonName, addon.Status, addonIssues(addon.Health))
+	}
+	return nil
+}
+
+// addonIssues formats the health issues of an addon for an error message
+func addonIssues(health *ekstypes.AddonHealth) string {
+	if health == nil || len(health.Issues) == 0 {
+		return ""
+	}
+	var out string
+	for _, issue := range health.Issues {
+		out += fmt.Sprintf("; %s: %s", issue.Code, aws.ToString(issue.Message))
+	}
+	return out
+}
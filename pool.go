@@ -0,0 +1,425 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	eks "github.com/aws/aws-sdk-go-v2/service/eks"
+	"github.com/aws/aws-sdk-go-v2/service/eks/types"
+)
+
+// poolTag marks a cluster waiting in a warm pool to be claimed, with the pool's name as value.
+// Claiming a cluster removes the tag.
+const poolTag = "Pool"
+
+// defaultPool is the pool used when none is named
+const defaultPool = "default"
+
+// poolName is the pool the cluster being created joins, recorded in its Pool tag; empty outside
+// of `est pool fill`
+var poolName string
+
+var poolNamePattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,30}[a-z0-9])?$`)
+
+// poolCluster is a cluster of a warm pool
+type poolCluster struct {
+	Name    string
+	Status  types.ClusterStatus
+	Created time.Time
+}
+
+// runPool implements `est pool fill` and `est pool list`
+func runPool(ctx context.Context, args []string) error {
+	usage := validationErr("usage: est pool fill --size <n> [--pool <name>] [--region <region>] | est pool list [--pool <name>] [--region <region>]")
+	if len(args) == 0 || (args[0] != "fill" && args[0] != "list") {
+		return usage
+	}
+
+	var pool, region string
+	var size int
+	fs := flag.NewFlagSet("pool "+args[0], flag.ContinueOnError)
+	fs.StringVar(&pool, "pool", defaultPool, "Name of the pool")
+	fs.StringVar(&region, "region", firstNonEmpty(os.Getenv("EST_REGION"), os.Getenv("AWS_REGION")), "Region of the pool")
+	if args[0] == "fill" {
+		fs.IntVar(&size, "size", 0, "Clusters to keep ready in the pool")
+	}
+	if err := fs.Parse(args[1:]); err != nil || fs.NArg() > 0 {
+		return usage
+	}
+	if region == "" {
+		return validationErr("no region given: pass --region or set EST_REGION")
+	}
+	if !poolNamePattern.MatchString(pool) {
+		return validationErr("pool name %q must be at most 32 lowercase letters, digits and hyphens", pool)
+	}
+	notifier.Region = region
+
+	if args[0] == "list" {
+		return listPool(ctx, region, pool)
+	}
+	if err := requireWritable("est pool fill"); err != nil {
+		return err
+	}
+	if size < 1 {
+		return validationErr("--size must be at least 1")
+	}
+	return fillPool(ctx, region, pool, size)
+}
+
+// listPoolClusters returns the unclaimed clusters of a pool, oldest first
+func listPoolClusters(ctx context.Context, region, pool string) ([]poolCluster, error) {
+	cfg, err := loadAWSConfig(ctx, region)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load AWS configuration: %w", err)
+	}
+	client := eks.NewFromConfig(cfg)
+	names, err := ListEKSClusters(ctx, region)
+	if err != nil {
+		return nil, err
+	}
+	var clusters []poolCluster
+	for _, name := range names {
+		output, err := client.DescribeCluster(ctx, &eks.DescribeClusterInput{Name: aws.String(name)})
+		var notFound *types.ResourceNotFoundException
+		if errors.As(err, &notFound) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe EKS cluster %s: %w", name, err)
+		}
+		cluster := output.Cluster
+		if cluster.Tags[poolTag] != pool || cluster.Tags["CreatedBy"] != "EKS-Sandbox-Tool" {
+			continue
+		}
+		clusters = append(clusters, poolCluster{Name: name, Status: cluster.Status, Created: aws.ToTime(cluster.CreatedAt)})
+	}
+	sort.Slice(clusters, func(i, j int) bool { return clusters[i].Created.Before(clusters[j].Created) })
+	return clusters, nil
+}
+
+// listPool prints the unclaimed clusters of a pool
+func listPool(ctx context.Context, region, pool string) error {
+	clusters, err := listPoolClusters(ctx, region, pool)
+	if err != nil {
+		return wrapErr("listing pool "+pool, err)
+	}
+	if len(clusters) == 0 {
+		infof("Pool %s in %s is empty.", pool, region)
+		return nil
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tSTATUS\tCREATED")
+	for _, cluster := range clusters {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", cluster.Name, cluster.Status, cluster.Created.Local().Format("2006-01-02 15:04"))
+	}
+	return tw.Flush()
+}
+
+// fillPool creates clusters one after another until the pool holds size unclaimed clusters,
+// counting those still being created. The clusters take the default of every create question not
+// answered through the environment or --answers, and join the shared VPC unless told otherwise.
+func fillPool(ctx context.Context, region, pool string, size int) error {
+	if os.Getenv("EST_CLUSTER_NAME") != "" {
+		return validationErr("EST_CLUSTER_NAME cannot be set with est pool fill, pool clusters are named after the pool")
+	}
+	clusters, err := listPoolClusters(ctx, region, pool)
+	if err != nil {
+		return wrapErr("listing pool "+pool, err)
+	}
+	missing := size - len(clusters)
+	if missing <= 0 {
+		infof("Pool %s already holds %d clusters.", pool, len(clusters))
+		return nil
+	}
+
+	// The pool clusters are created with defaults and preset answers, which are put back afterwards
+	// so whatever runs next in the process asks and tags as usual
+	savedDefaults, savedPool, savedEvent, savedPresets := acceptDefaults, poolName, workshopEvent, maps.Clone(presetAnswers)
+	defer func() {
+		acceptDefaults, poolName, workshopEvent, presetAnswers = savedDefaults, savedPool, savedEvent, savedPresets
+	}()
+	acceptDefaults = true
+	poolName = pool
+	presetAnswers[answerKey("EST_REGION")] = region
+	if _, ok := presetAnswers[answerKey("EST_VPC")]; !ok && os.Getenv("EST_VPC") == "" {
+		presetAnswers[answerKey("EST_VPC")] = hostingVPCShared
+	}
+
+	var failed []error
+	for i := 0; i < missing; i++ {
+		name := fmt.Sprintf("pool-%s-%s", pool, time.Now().Format("0102-150405"))
+		stepf("Creating pool cluster %s (%d of %d)...", name, i+1, missing)
+		presetAnswers[answerKey("EST_CLUSTER_NAME")] = name
+		answers, err := collectCreateAnswers(ctx, true)
+		if err != nil {
+			return err
+		}
+		if err := provisionCluster(ctx, newStepTimer(), answers); err != nil {
+			errorf("Unable to create pool cluster %s: %v", name, err)
+			failed = append(failed, err)
+			continue
+		}
+		successf("%s is ready to be claimed from pool %s", answers.ClusterName, pool)
+	}
+	if len(failed) > 0 {
+		return partialErr("filling pool "+pool, errors.Join(failed...))
+	}
+	return nil
+}
+
+// claimParameter returns the SSM parameter that marks a pool cluster as being claimed
+func claimParameter(clusterName string) string {
+	return "/est/claiming/" + clusterName
+}
+
+// runClaim implements `est claim`
+func runClaim(ctx context.Context, args []string) error {
+	if err := requireWritable("est claim"); err != nil {
+		return err
+	}
+	usage := validationErr("usage: est claim [--pool <name>] [--hours <n>] [--no-refill] [--region <region>]")
+
+	var pool, region string
+	var hours int
+	var noRefill bool
+	fs := flag.NewFlagSet("claim", flag.ContinueOnError)
+	fs.StringVar(&pool, "pool", defaultPool, "Name of the pool to claim a cluster from")
+	fs.IntVar(&hours, "hours", 8, "Hours after which the claimed cluster expires and est sweep deletes it; 0 never")
+	fs.BoolVar(&noRefill, "no-refill", false, "Do not replace the claimed cluster in the pool")
+	fs.StringVar(&region, "region", firstNonEmpty(os.Getenv("EST_REGION"), os.Getenv("AWS_REGION")), "Region of the pool")
+	if err := fs.Parse(args); err != nil || fs.NArg() > 0 {
+		return usage
+	}
+	if region == "" {
+		return validationErr("no region given: pass --region or set EST_REGION")
+	}
+	if hours < 0 {
+		return validationErr("--hours cannot be negative")
+	}
+	notifier.Region = region
+
+	_, callerID, err := GetAWSAccountDetails(ctx, region)
+	if err != nil {
+		return wrapErr("fetching caller identity", err)
+	}
+	clusters, err := listPoolClusters(ctx, region, pool)
+	if err != nil {
+		return wrapErr("listing pool "+pool, err)
+	}
+	var expiresAt string
+	if hours > 0 {
+		expiresAt = time.Now().Add(time.Duration(hours) * time.Hour).UTC().Format(time.RFC3339)
+	}
+	if ttl := activeGuardrails.ttl; ttl > 0 && (hours == 0 || time.Duration(hours)*time.Hour > ttl) {
+		// The account guardrails cap how long a cluster may live from the claim on
+		expiresAt = activeGuardrails.expiresAt()
+	}
+
+	var claimed string
+	for _, cluster := range clusters {
+		if cluster.Status != types.ClusterStatusActive {
+			continue
+		}
+		ok, err := ClaimPoolCluster(ctx, region, cluster.Name, pool, callerID, expiresAt)
+		if err != nil {
+			return wrapErr("claiming "+cluster.Name, err)
+		}
+		if ok {
+			claimed = cluster.Name
+			break
+		}
+	}
+	if claimed == "" {
+		if len(clusters) > 0 {
+			return validationErr("no cluster of pool %s in %s is ready yet; %d are being created, check with est pool list", pool, region, len(clusters))
+		}
+		return validationErr("pool %s in %s is empty; fill it with est pool fill --pool %s --size <n> --region %s", pool, region, pool, region)
+	}
+	notifier.Cluster = claimed
+	successf("Claimed %s from pool %s", claimed, pool)
+	if expiresAt != "" {
+		infof("It expires at %s, after which est sweep deletes it", expiresAt)
+	}
+
+	conn, err := WaitForClusterConnection(ctx, region, claimed, 30*time.Minute)
+	if err != nil {
+		return wrapErr("reading cluster endpoint", err)
+	}
+	path, err := InstallKubeconfig(ctx, conn)
+	if err != nil {
+		return wrapErr("writing kubeconfig", err)
+	}
+	successf("Added the cluster to %s", path)
+	contextName, err := renderKubeconfigTemplate("context_name", userConfig.Kubeconfig.ContextName, conn)
+	if err != nil {
+		return wrapErr("preparing kubeconfig", err)
+	}
+	printConnectionInstructions(summaryWriter(), conn, contextName, callerID, "")
+
+	if !noRefill {
+		refillPool(ctx, region, pool, len(clusters))
+	}
+	return nil
+}
+
+// ClaimPoolCluster hands a cluster of a pool to the caller: it takes the cluster out of the pool,
+// tags it with the caller as owner and with when it expires, gives the caller cluster admin and
+// updates the sandbox stores. A short-lived SSM parameter makes sure only one of several people
+// claiming at once gets the cluster; false means someone else claimed it first.
+func ClaimPoolCluster(ctx context.Context, region, clusterName, pool, callerID, expiresAt string) (bool, error) {
+	name := claimParameter(clusterName)
+	_, err := runAWSCLI(ctx, region, "ssm", "put-parameter", "--name", name, "--type", "String",
+		"--value", callerID, "--tags", "Key=CreatedBy,Value=EKS-Sandbox-Tool")
+	if err != nil && strings.Contains(err.Error(), "ParameterAlreadyExists") {
+		debugf("%s is being claimed by someone else", clusterName)
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("unable to mark %s as being claimed: %w", clusterName, err)
+	}
+	defer func() {
+		if _, err := runAWSCLI(context.Background(), region, "ssm", "delete-parameter", "--name", name); err != nil {
+			warnf("Unable to remove the claim marker of %s, delete SSM parameter %s: %v", clusterName, name, err)
+		}
+	}()
+
+	cfg, err := loadAWSConfig(ctx, region)
+	if err != nil {
+		return false, fmt.Errorf("unable to load AWS configuration: %w", err)
+	}
+	client := eks.NewFromConfig(cfg)
+	output, err := client.DescribeCluster(ctx, &eks.DescribeClusterInput{Name: aws.String(clusterName)})
+	if err != nil {
+		return false, fmt.Errorf("failed to describe EKS cluster %s: %w", clusterName, err)
+	}
+	if output.Cluster.Tags[poolTag] != pool {
+		// Claimed between listing the pool and marking it
+		return false, nil
+	}
+
+	owner := ownerFromARN(callerID)
+	tags := map[string]string{}
+	if owner != "" {
+		tags[ownerTag] = owner
+	}
+	if expiresAt != "" {
+		tags["ExpiresAt"] = expiresAt
+	}
+	if len(tags) > 0 {
+		if _, err := client.TagResource(ctx, &eks.TagResourceInput{ResourceArn: output.Cluster.Arn, Tags: tags}); err != nil {
+			return false, fmt.Errorf("unable to tag %s: %w", clusterName, err)
+		}
+	}
+	untag := []string{poolTag}
+	if expiresAt == "" {
+		// An expiry set while the cluster waited in the pool no longer applies
+		untag = append(untag, "ExpiresAt")
+	}
+	if _, err := client.UntagResource(ctx, &eks.UntagResourceInput{ResourceArn: output.Cluster.Arn, TagKeys: untag}); err != nil {
+		return false, fmt.Errorf("unable to take %s out of pool %s: %w", clusterName, pool, err)
+	}
+	if err := grantClusterAdmin(ctx, client, clusterName, adminPrincipalArn(callerID), map[string]string{"CreatedBy": "EKS-Sandbox-Tool"}); err != nil {
+		return true, err
+	}
+	claimInStores(ctx, region, clusterName, owner, expiresAt)
+	return true, nil
+}
+
+// claimInStores records the new owner and expiry of a claimed cluster in the sandbox stores
+func claimInStores(ctx context.Context, region, clusterName, owner, expiresAt string) {
+	for _, store := range sandboxStores() {
+		entries, err := store.List(ctx)
+		if err != nil {
+			warnf("Unable to read %s: %v", store, err)
+			continue
+		}
+		for _, entry := range entries {
+			if entry.Name != clusterName || entry.Region != region {
+				continue
+			}
+			entry.Owner, entry.ExpiresAt = owner, expiresAt
+			if err := store.Put(ctx, entry); err != nil {
+				warnf("Unable to update %s in %s: %v", clusterName, store, err)
+			}
+		}
+	}
+}
+
+// refillPool starts `est pool fill` in the background to bring the pool back to size, so the next
+// claim finds a cluster ready. It outlives this run; its output goes to a log file. The refill gets
+// the global flags and credentials of this run, so it creates the clusters the way this run would.
+func refillPool(ctx context.Context, region, pool string, size int) {
+	executable, err := os.Executable()
+	if err != nil {
+		warnf("Unable to refill pool %s: %v", pool, err)
+		return
+	}
+	credentials, err := credentialsEnv(ctx, region)
+	if err != nil {
+		warnf("Unable to refill pool %s: %v", pool, err)
+		return
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		warnf("Unable to refill pool %s: %v", pool, err)
+		return
+	}
+	dir := firstNonEmpty(*logDir, filepath.Join(home, ".est", "logs"))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		warnf("Unable to refill pool %s: %v", pool, err)
+		return
+	}
+	logPath := filepath.Join(dir, fmt.Sprintf("est-pool-%s-%s.out", pool, time.Now().Format("20060102-150405")))
+	out, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		warnf("Unable to refill pool %s: %v", pool, err)
+		return
+	}
+	defer out.Close()
+
+	args := append(refillFlags(), "pool", "fill", "--pool", pool, "--size", strconv.Itoa(size), "--region", region)
+	cmd := exec.Command(executable, args...)
+	cmd.Env = append(os.Environ(), credentials...)
+	cmd.Stdout, cmd.Stderr = out, out
+	if err := cmd.Start(); err != nil {
+		warnf("Unable to refill pool %s: %v", pool, err)
+		return
+	}
+	cmd.Process.Release()
+	infof("Refilling pool %s in the background; follow it in %s", pool, logPath)
+}
+
+// refillFlags returns the global flags given to this run, such as --config, --answers, --fips or
+// --read-only, for the background refill. The cassette and manifests this run writes are left
+// out, as the refill would overwrite them, and so is the cassette it replays, which only holds the
+// calls of this run.
+func refillFlags() []string {
+	var args []string
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "record", "replay", "manifest", "manifest-s3":
+			return
+		}
+		if values, ok := f.Value.(*stringList); ok {
+			for _, value := range *values {
+				args = append(args, "--"+f.Name+"="+value)
+			}
+			return
+		}
+		args = append(args, "--"+f.Name+"="+f.Value.String())
+	})
+	return args
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"flag"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestRefillFlags(t *testing.T) {
+	savedConfig, savedWebhooks := *configFile, webhookURLs
+	savedRecord, savedReplay, savedManifest, savedManifestS3 := *recordFile, *replayFile, *manifestFile, *manifestS3
+	t.Cleanup(func() {
+		*configFile, webhookURLs = savedConfig, savedWebhooks
+		*recordFile, *replayFile, *manifestFile, *manifestS3 = savedRecord, savedReplay, savedManifest, savedManifestS3
+	})
+	webhookURLs = nil
+	for name, value := range map[string]string{
+		"config":      "/tmp/est.json",
+		"record":      "/tmp/claim-cassette.json",
+		"replay":      "testdata/claim.json",
+		"manifest":    "/tmp/claim-manifest.json",
+		"manifest-s3": "s3://manifests/claim.json",
+	} {
+		if err := flag.Set(name, value); err != nil {
+			t.Fatalf("setting --%s: %v", name, err)
+		}
+	}
+	for _, url := range []string{"https://hooks.example.com/a", "https://hooks.example.com/b"} {
+		if err := flag.Set("webhook", url); err != nil {
+			t.Fatalf("setting --webhook: %v", err)
+		}
+	}
+
+	args := refillFlags()
+	for _, want := range []string{"--config=/tmp/est.json", "--webhook=https://hooks.example.com/a", "--webhook=https://hooks.example.com/b"} {
+		if !slices.Contains(args, want) {
+			t.Errorf("refillFlags() = %v, want %s", args, want)
+		}
+	}
+	for _, arg := range args {
+		name, _, _ := strings.Cut(strings.TrimPrefix(arg, "--"), "=")
+		if slices.Contains([]string{"record", "replay", "manifest", "manifest-s3"}, name) {
+			t.Errorf("refillFlags() passes %s on to the refill", arg)
+		}
+	}
+}
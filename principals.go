@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+)
+
+// regionPartition returns the partition a region belongs to
+func regionPartition(region string) string {
+	switch {
+	case strings.HasPrefix(region, "cn-"):
+		return "aws-cn"
+	case strings.HasPrefix(region, "us-gov-"):
+		return "aws-us-gov"
+	default:
+		return "aws"
+	}
+}
+
+// chinaServicePrincipals are the services whose principal in the China regions ends in
+// amazonaws.com.cn instead of amazonaws.com
+var chinaServicePrincipals = map[string]bool{"ec2": true}
+
+// servicePrincipal returns the principal a service assumes roles as in a partition, e.g. "eks"
+// gives eks.amazonaws.com
+func servicePrincipal(partition, service string) string {
+	if partition == "aws-cn" && chinaServicePrincipals[service] {
+		return service + ".amazonaws.com.cn"
+	}
+	return service + ".amazonaws.com"
+}
+
+// eksPrincipals returns the principals that identify EKS as the service assuming a cluster role: the
+// global principal of the partition and the regional form some organisations write trust policies with
+func eksPrincipals(region string) []string {
+	return []string{servicePrincipal(regionPartition(region), "eks"), "eks." + region + ".amazonaws.com"}
+}
+
+// assumeRolePolicy returns a trust policy that lets the given service principals assume a role
+func assumeRolePolicy(principals ...string) (string, error) {
+	policy, err := json.Marshal(map[string]interface{}{
+		"Version":   "2012-10-17",
+		"Statement": []interface{}{assumeRoleStatement(principals)},
+	})
+	if err != nil {
+		return "", fmt.Errorf("unable to build trust policy: %w", err)
+	}
+	return string(policy), nil
+}
+
+// assumeRoleStatement is the trust policy statement that lets service principals assume a role
+func assumeRoleStatement(principals []string) map[string]interface{} {
+	var service interface{} = principals
+	if len(principals) == 1 {
+		service = principals[0]
+	}
+	return map[string]interface{}{
+		"Effect":    "Allow",
+		"Principal": map[string]interface{}{"Service": service},
+		"Action":    "sts:AssumeRole",
+	}
+}
+
+// addTrustedPrincipals returns a trust policy, given URL-encoded as IAM returns it, with a statement
+// appended that lets the service principals assume the role. The existing statements are kept, so
+// whoever else may assume the role still can.
+func addTrustedPrincipals(document string, principals ...string) (string, error) {
+	decoded, err := url.QueryUnescape(document)
+	if err != nil {
+		return "", fmt.Errorf("unable to decode trust policy: %w", err)
+	}
+	var policy map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(decoded), &policy); err != nil {
+		return "", fmt.Errorf("unable to parse trust policy: %w", err)
+	}
+	var statements []json.RawMessage
+	if raw, ok := policy["Statement"]; ok {
+		if err := json.Unmarshal(raw, &statements); err != nil {
+			// A policy with a single statement may use an object instead of a list
+			statements = []json.RawMessage{raw}
+		}
+	}
+	statement, err := json.Marshal(assumeRoleStatement(principals))
+	if err != nil {
+		return "", err
+	}
+	if policy["Statement"], err = json.Marshal(append(statements, statement)); err != nil {
+		return "", err
+	}
+	if _, ok := policy["Version"]; !ok {
+		policy["Version"] = json.RawMessage(`"2012-10-17"`)
+	}
+	updated, err := json.Marshal(policy)
+	if err != nil {
+		return "", fmt.Errorf("unable to build trust policy: %w", err)
+	}
+	return string(updated), nil
+}
+
+// managedPolicyArn returns the ARN of an AWS managed policy in a partition
+func managedPolicyArn(partition, name string) string {
+	return fmt.Sprintf("arn:%s:iam::aws:policy/%s", partition, name)
+}
+
+// fixClusterTrustPolicy offers to let EKS assume an existing cluster role whose trust policy does
+// not, by adding a statement for principals to it. Other statements of the policy are kept.
+func fixClusterTrustPolicy(ctx context.Context, iamClient *iam.Client, roleName, document string, principals []string, problem error) error {
+	warnf("Existing role %s cannot be used as an EKS cluster role: %v", roleName, problem)
+	update := confirmDefault("EST_UPDATE_TRUST_POLICY")
+	updatePrompt := &survey.Confirm{
+		Message: fmt.Sprintf("Add %s to the trust policy of role %s?", strings.Join(principals, ", "), roleName),
+		Default: update,
+	}
+	if err := askOne("EST_UPDATE_TRUST_POLICY", updatePrompt, &update); err != nil {
+		return wrapErr("reading trust policy choice", err)
+	}
+	if !update {
+		return validationErr("existing role %s cannot be used as an EKS cluster role: %v; update its trust policy or choose another role", roleName, problem)
+	}
+	updated, err := addTrustedPrincipals(document, principals...)
+	if err != nil {
+		return err
+	}
+	_, err = iamClient.UpdateAssumeRolePolicy(ctx, &iam.UpdateAssumeRolePolicyInput{
+		RoleName:       aws.String(roleName),
+		PolicyDocument: aws.String(updated),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update trust policy of role %s: %w", roleName, err)
+	}
+	successf("Updated the trust policy of role %s", roleName)
+	return nil
+}
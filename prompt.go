@@ -0,0 +1,368 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/AlecAivazis/survey/v2"
+	"golang.org/x/term"
+)
+
+var (
+	// presetAnswers are answers loaded from a template or answers file, keyed by answerKey
+	presetAnswers = map[string]string{}
+	// givenAnswers records every answer given during the run, keyed by answerKey
+	givenAnswers = map[string]string{}
+	// editing marks prompts the user chose to edit, which are always shown even when a value was pre-supplied
+	editing = map[string]bool{}
+)
+
+// editAnswer re-asks a question interactively, ignoring any environment or template value for it
+func editAnswer(env string, ask func() error) error {
+	editing[env] = true
+	defer delete(editing, env)
+	return ask()
+}
+
+// answerKey turns the environment variable of a prompt into the key used in templates, e.g. EST_K8S_VERSION -> k8s_version
+func answerKey(env string) string {
+	return strings.ToLower(strings.TrimPrefix(env, "EST_"))
+}
+
+// askOne answers a survey prompt from the environment variable env or a loaded template when
+// either provides a value, and otherwise shows the prompt, or answers it with the prompt's
+// default when running non-interactively
+func askOne(env string, prompt survey.Prompt, response interface{}, opts ...survey.AskOpt) error {
+	if err := resolveAnswer(env, prompt, response, opts); err != nil {
+		return err
+	}
+	switch r := response.(type) {
+	case *string:
+		givenAnswers[answerKey(env)] = *r
+	case *bool:
+		givenAnswers[answerKey(env)] = strconv.FormatBool(*r)
+	case *[]string:
+		givenAnswers[answerKey(env)] = strings.Join(*r, ",")
+	}
+	debugf("answer %s=%s", answerKey(env), givenAnswers[answerKey(env)])
+	return nil
+}
+
+func resolveAnswer(env string, prompt survey.Prompt, response interface{}, opts []survey.AskOpt) error {
+	if editing[env] {
+		return survey.AskOne(prompt, response, opts...)
+	}
+	if value, ok := os.LookupEnv(env); ok && env != "" {
+		if err := answerFromValue(prompt, response, value, opts); err != nil {
+			return validationErr("%s: %v", env, err)
+		}
+		infof("Using %s=%s", env, value)
+		return nil
+	}
+	if value, ok := presetAnswers[answerKey(env)]; ok {
+		if err := answerFromValue(prompt, response, value, opts); err != nil {
+			return validationErr("answer %s: %v", answerKey(env), err)
+		}
+		infof("Using answer %s=%s", answerKey(env), value)
+		return nil
+	}
+
+	if !nonInteractive() {
+		return survey.AskOne(prompt, response, opts...)
+	}
+
+	// Without a terminal fall back to the prompt's default, and remember prompts
+	// that have none so they can all be reported together
+	switch p := prompt.(type) {
+	case *survey.Select:
+		value, _ := p.Default.(string)
+		if value == "" {
+			missingInputs = append(missingInputs, missingInput{env, p.Message})
+		}
+		*(response.(*string)) = value
+	case *survey.Input:
+		if p.Default == "" && !optionalInputs[env] {
+			missingInputs = append(missingInputs, missingInput{env, p.Message})
+		}
+		*(response.(*string)) = p.Default
+	case *survey.Confirm:
+		*(response.(*bool)) = p.Default
+	case *survey.MultiSelect:
+		values, _ := p.Default.([]string)
+		if len(values) == 0 {
+			missingInputs = append(missingInputs, missingInput{env, p.Message})
+		}
+		*(response.(*[]string)) = values
+	default:
+		return fmt.Errorf("prompt %T cannot be answered non-interactively", prompt)
+	}
+	return nil
+}
+
+// missingInput is a prompt that could not be answered without a terminal
+type missingInput struct {
+	Env     string
+	Message string
+}
+
+var missingInputs []missingInput
+
+// optionalInputs are the text prompts for which an empty answer is valid, so they are not missing
+// when nothing was provided
+var optionalInputs = map[string]bool{
+	"EST_COST_CENTER":     true,
+	"EST_PURPOSE":         true,
+	"EST_MAX_UNAVAILABLE": true,
+	"EST_PAUSE_HOURS":     true,
+}
+
+// acceptDefaults answers every prompt that was not pre-answered with its default, as when running
+// without a terminal. It is set by est create --defaults.
+var acceptDefaults bool
+
+// nonInteractive reports whether prompts must be answered without user input, because CI mode
+// is on, defaults were asked for, or stdin is not a terminal
+func nonInteractive() bool {
+	return *ciMode || acceptDefaults || !term.IsTerminal(int(os.Stdin.Fd()))
+}
+
+// requireInputs fails with the full list of inputs that were needed but not provided
+func requireInputs() error {
+	if len(missingInputs) == 0 {
+		return nil
+	}
+	lines := []string{"stdin is not a terminal and these inputs were not provided:"}
+	for _, m := range missingInputs {
+		lines = append(lines, fmt.Sprintf("  %s (%s)", m.Env, strings.TrimSuffix(m.Message, ":")))
+	}
+	lines = append(lines, "Set them as environment variables or pass them in a file with --answers <file>")
+	missingInputs = nil
+	return validationErr("%s", strings.Join(lines, "\n"))
+}
+
+// loadAnswersFile reads pre-supplied answers, either in template format or as a flat JSON object
+func loadAnswersFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return validationErr("unable to read answers file: %v", err)
+	}
+	var t clusterTemplate
+	if err := json.Unmarshal(data, &t); err != nil {
+		return validationErr("answers file %s is not valid JSON: %v", path, err)
+	}
+	answers := t.Answers
+	if answers == nil {
+		if err := json.Unmarshal(data, &answers); err != nil {
+			return validationErr("answers file %s must map answer names to string values: %v", path, err)
+		}
+	}
+	for key, value := range answers {
+		presetAnswers[answerKey(key)] = value
+	}
+	return nil
+}
+
+// answerFromValue validates a pre-supplied answer against the prompt and stores it in response
+func answerFromValue(prompt survey.Prompt, response interface{}, value string, opts []survey.AskOpt) error {
+	switch p := prompt.(type) {
+	case *survey.Select:
+		option, err := matchOption(p.Options, value)
+		if err != nil {
+			return err
+		}
+		*(response.(*string)) = option
+	case *survey.Input:
+		var options survey.AskOptions
+		for _, opt := range opts {
+			if err := opt(&options); err != nil {
+				return err
+			}
+		}
+		for _, validate := range options.Validators {
+			if err := validate(value); err != nil {
+				return err
+			}
+		}
+		*(response.(*string)) = value
+	case *survey.Confirm:
+		b, err := parseBool(value)
+		if err != nil {
+			return err
+		}
+		*(response.(*bool)) = b
+	case *survey.MultiSelect:
+		// Several options are given comma separated, each matched like a Select answer
+		var selected []string
+		for _, part := range strings.Split(value, ",") {
+			if part = strings.TrimSpace(part); part == "" {
+				continue
+			}
+			option, err := matchOption(p.Options, part)
+			if err != nil {
+				return err
+			}
+			selected = append(selected, option)
+		}
+		*(response.(*[]string)) = selected
+	default:
+		return fmt.Errorf("prompt %T cannot be answered from the environment", prompt)
+	}
+	return nil
+}
+
+// alignedOptions lays rows out as the options of a select prompt, padding each column to its widest
+// cell so the options read as a table. Empty cells are shown as "-" to keep the columns in place.
+func alignedOptions(rows [][]string) []string {
+	var out strings.Builder
+	w := tabwriter.NewWriter(&out, 0, 0, 2, ' ', 0)
+	for _, row := range rows {
+		cells := make([]string, len(row))
+		for i, cell := range row {
+			cells[i] = firstNonEmpty(strings.TrimSpace(cell), "-")
+		}
+		fmt.Fprintln(w, strings.Join(cells, "\t"))
+	}
+	w.Flush()
+	options := strings.Split(strings.TrimRight(out.String(), "\n"), "\n")
+	for i := range options {
+		options[i] = strings.TrimRight(options[i], " ")
+	}
+	return options
+}
+
+// matchOption finds the option matching value exactly, ignoring how its columns are padded,
+// case-insensitively, as a unique prefix, or by its first word
+func matchOption(options []string, value string) (string, error) {
+	for _, option := range options {
+		if option == value {
+			return option, nil
+		}
+	}
+	// Aligned options pad their columns to the widest cell, which changes as resources come and go
+	for _, option := range options {
+		if strings.Join(strings.Fields(option), " ") == strings.Join(strings.Fields(value), " ") {
+			return option, nil
+		}
+	}
+	var matches []string
+	for _, option := range options {
+		if strings.EqualFold(option, value) {
+			return option, nil
+		}
+		if strings.HasPrefix(strings.ToLower(option), strings.ToLower(value)) {
+			matches = append(matches, option)
+		}
+	}
+	if len(matches) == 1 {
+		return matches[0], nil
+	}
+	// Options that start with an ID or keyword are still matched when the details after it changed
+	// since the answer was saved, like the clusters counted in the shared VPC option
+	if fields := strings.Fields(value); len(fields) > 1 {
+		matches = nil
+		for _, option := range options {
+			if optionFields := strings.Fields(option); len(optionFields) > 0 && optionFields[0] == fields[0] {
+				matches = append(matches, option)
+			}
+		}
+		if len(matches) == 1 {
+			return matches[0], nil
+		}
+	}
+	return "", fmt.Errorf("%q is not one of: %s", value, strings.Join(options, ", "))
+}
+
+// parseBool accepts yes/no in addition to the values understood by strconv.ParseBool
+func parseBool(value string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "y", "yes":
+		return true, nil
+	case "n", "no":
+		return false, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("%q is not a yes/no value", value)
+	}
+	return b, nil
+}
+
+var (
+	// regionPattern matches region codes such as eu-west-2, us-gov-west-1 and cn-north-1
+	regionPattern = regexp.MustCompile(`^[a-z]{2}(-[a-z]+)+-\d+$`)
+	// k8sVersionPattern matches the major.minor versions EKS takes
+	k8sVersionPattern = regexp.MustCompile(`^1\.\d{1,3}$`)
+	// clusterNamePattern is the EKS cluster name charset; the length is checked separately
+	clusterNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]*$`)
+	// instanceTypePattern matches instance types such as m5.large or c6gn.16xlarge
+	instanceTypePattern = regexp.MustCompile(`^[a-z][a-z0-9-]*\.[a-z0-9]+$`)
+)
+
+// validRegion rejects answers that are not region codes
+func validRegion(ans interface{}) error {
+	if !regionPattern.MatchString(strings.TrimSpace(ans.(string))) {
+		return fmt.Errorf("%q is not an AWS region code such as eu-west-2", ans)
+	}
+	return nil
+}
+
+// validK8sVersion rejects answers that are not a Kubernetes major.minor version
+func validK8sVersion(ans interface{}) error {
+	if !k8sVersionPattern.MatchString(strings.TrimSpace(ans.(string))) {
+		return fmt.Errorf("%q is not a Kubernetes version such as 1.31", ans)
+	}
+	return nil
+}
+
+// validClusterName rejects names EKS refuses once the cluster prefix is added: 100 characters
+// at most, starting with a letter or digit, and otherwise letters, digits, hyphens and underscores
+func validClusterName(ans interface{}) error {
+	name := ans.(string)
+	if !clusterNamePattern.MatchString(name) {
+		return fmt.Errorf("use letters, digits, hyphens and underscores, starting with a letter or digit")
+	}
+	if limit := 100 - len(userConfig.ClusterPrefix); len(name) > limit {
+		return fmt.Errorf("use at most %d characters", limit)
+	}
+	return nil
+}
+
+// validInstanceType rejects answers that are not EC2 instance types or that the guardrails forbid
+func validInstanceType(ans interface{}) error {
+	if !instanceTypePattern.MatchString(strings.TrimSpace(ans.(string))) {
+		return fmt.Errorf("%q is not an instance type such as m5.large", ans)
+	}
+	return activeGuardrails.checkInstanceType(strings.TrimSpace(ans.(string)))
+}
+
+// confirmPrompts are the yes/no prompts whose default the confirm_defaults section of the
+// configuration file can change, with their built-in defaults. Confirming the deletion of a
+// cluster the tool did not create always defaults to no and is deliberately not listed.
+var confirmPrompts = map[string]bool{
+	"EST_ACTIVATE_COST_TAG":   false,
+	"EST_AUTO_MODE":           true,
+	"EST_ZONAL_SHIFT":         false,
+	"EST_INSTALL_ADDONS":      true,
+	"EST_POLICY_BUNDLE":       false,
+	"EST_CUSTOM_NETWORKING":   false,
+	"EST_NETWORK_POLICY":      false,
+	"EST_PRIVATE_ENDPOINT":    false,
+	"EST_DELETE_VPC":          true,
+	"EST_PRESERVE_ADDONS":     false,
+	"EST_FORCE_UPGRADE":       false,
+	"EST_UPDATE_TRUST_POLICY": false,
+}
+
+// confirmDefault returns the default answer of a yes/no prompt: its confirm_defaults entry in the
+// configuration file, keyed like templates, or else the built-in default
+func confirmDefault(env string) bool {
+	if value, ok := userConfig.ConfirmDefaults[answerKey(env)]; ok {
+		return value
+	}
+	return confirmPrompts[env]
+}
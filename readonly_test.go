@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	eks "github.com/aws/aws-sdk-go-v2/service/eks"
+	"github.com/aws/aws-sdk-go-v2/service/eks/types"
+)
+
+func TestReadOnlyGuard(t *testing.T) {
+	replayCassette(t, "create-existing-vpc.json")
+	saved := *readOnly
+	t.Cleanup(func() { *readOnly = saved })
+	*readOnly = true
+
+	ctx := context.Background()
+	cfg, err := loadAWSConfig(ctx, "eu-west-2")
+	if err != nil {
+		t.Fatalf("loadAWSConfig: %v", err)
+	}
+	client := eks.NewFromConfig(cfg)
+
+	// The first DescribeCluster of the cassette is a 404, which only the cassette can answer
+	_, err = client.DescribeCluster(ctx, &eks.DescribeClusterInput{Name: aws.String("Sandbox-replay")})
+	if err == nil || !strings.Contains(err.Error(), "ResourceNotFoundException") {
+		t.Errorf("DescribeCluster error = %v, want the recorded ResourceNotFoundException", err)
+	}
+	if replayedCount("EKS.DescribeCluster") != 1 {
+		t.Error("DescribeCluster was not replayed")
+	}
+
+	_, err = client.CreateCluster(ctx, &eks.CreateClusterInput{
+		Name:               aws.String("Sandbox-replay"),
+		RoleArn:            aws.String("arn:aws:iam::123456789012:role/EKSClusterRole"),
+		ResourcesVpcConfig: &types.VpcConfigRequest{SubnetIds: []string{"subnet-0a", "subnet-0b"}},
+	})
+	if err == nil || !strings.Contains(err.Error(), "EKS.CreateCluster changes the account and --read-only is set") {
+		t.Errorf("CreateCluster error = %v, want it refused by --read-only", err)
+	}
+	if replayedCount("EKS.CreateCluster") != 0 {
+		t.Error("CreateCluster reached the cassette")
+	}
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+var (
+	recordFile = flag.String("record", "", "Record every AWS API response of the run to this cassette file")
+	replayFile = flag.String("replay", "", "Answer AWS API calls from a cassette recorded with --record instead of calling AWS")
+)
+
+// cassetteInteraction is one recorded HTTP exchange with an AWS API, or one run of the aws CLI,
+// kubectl or helm. For a command, Status is its exit code, Body its standard output and URL its
+// command line.
+type cassetteInteraction struct {
+	// Operation is the service and operation, e.g. "EC2.CreateVpc", or the command and its
+	// subcommand, e.g. "aws ssm put-parameter" or "kubectl apply"
+	Operation   string      `json:"operation"`
+	Method      string      `json:"method"`
+	URL         string      `json:"url"`
+	RequestBody string      `json:"requestBody,omitempty"`
+	Status      int         `json:"status"`
+	Header      http.Header `json:"header"`
+	Body        string      `json:"body"`
+	Stderr      string      `json:"stderr,omitempty"`
+}
+
+// cassette holds the AWS API interactions of a run in the order they happened
+type cassette struct {
+	mu           sync.Mutex
+	Interactions []cassetteInteraction `json:"interactions"`
+	// next is the index of the next unplayed interaction of each operation when replaying
+	next map[string]int
+}
+
+// activeCassette is the cassette being recorded or replayed, nil when neither is requested
+var activeCassette *cassette
+
+// openCassette prepares recording or replaying as requested on the command line
+func openCassette() error {
+	switch {
+	case *recordFile != "" && *replayFile != "":
+		return validationErr("--record and --replay cannot be combined")
+	case *recordFile != "":
+		activeCassette = &cassette{}
+	case *replayFile != "":
+		data, err := os.ReadFile(*replayFile)
+		if err != nil {
+			return validationErr("unable to read cassette: %v", err)
+		}
+		c := &cassette{next: map[string]int{}}
+		if err := json.Unmarshal(data, c); err != nil {
+			return validationErr("cassette %s is not valid JSON: %v", *replayFile, err)
+		}
+		activeCassette = c
+		infof("Replaying %d AWS API interactions from %s", len(c.Interactions), *replayFile)
+	}
+	return nil
+}
+
+// saveCassette writes the recorded interactions when recording. It also runs after a failed run,
+// whose interactions are just as useful for reproducing the failure.
+func saveCassette() error {
+	if activeCassette == nil || *recordFile == "" {
+		return nil
+	}
+	activeCassette.mu.Lock()
+	defer activeCassette.mu.Unlock()
+	data, err := json.MarshalIndent(activeCassette, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(*recordFile, data, 0600); err != nil {
+		return fmt.Errorf("unable to write cassette: %w", err)
+	}
+	infof("Recorded %d AWS API interactions to %s", len(activeCassette.Interactions), *recordFile)
+	return nil
+}
+
+// cassetteOptions returns the API option that records or replays calls. Replaying uses fixed
+// credentials, so no AWS account is needed.
+func cassetteOptions() (apiOption func(*middleware.Stack) error, credentials aws.CredentialsProvider) {
+	if activeCassette == nil {
+		return nil, nil
+	}
+	if *replayFile == "" {
+		return addCassetteRecorder, nil
+	}
+	return addCassetteReplayer, aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+		return aws.Credentials{AccessKeyID: "REPLAY", SecretAccessKey: "REPLAY", Source: "cassette"}, nil
+	})
+}
+
+// The cassette middlewares sit last in the deserialize step, right before the request is sent, so
+// they see every attempt of a call including retries.
+func addCassetteRecorder(stack *middleware.Stack) error {
+	return stack.Deserialize.Add(middleware.DeserializeMiddlewareFunc("EstCassetteRecorder", recordCall), middleware.After)
+}
+
+func addCassetteReplayer(stack *middleware.Stack) error {
+	return stack.Deserialize.Add(middleware.DeserializeMiddlewareFunc("EstCassetteReplayer", replayCall), middleware.After)
+}
+
+func recordCall(ctx context.Context, in middleware.DeserializeInput, next middleware.DeserializeHandler) (middleware.DeserializeOutput, middleware.Metadata, error) {
+	req, ok := in.Request.(*smithyhttp.Request)
+	if !ok {
+		return next.HandleDeserialize(ctx, in)
+	}
+	var reqBody []byte
+	if stream := req.GetStream(); stream != nil {
+		var err error
+		if reqBody, err = io.ReadAll(stream); err != nil {
+			return middleware.DeserializeOutput{}, middleware.Metadata{}, err
+		}
+		if req, err = req.SetStream(bytes.NewReader(reqBody)); err != nil {
+			return middleware.DeserializeOutput{}, middleware.Metadata{}, err
+		}
+		in.Request = req
+	}
+
+	out, metadata, err := next.HandleDeserialize(ctx, in)
+	resp, ok := out.RawResponse.(*smithyhttp.Response)
+	if !ok || resp.Response == nil {
+		return out, metadata, err
+	}
+	body, readErr := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if readErr != nil {
+		return out, metadata, readErr
+	}
+
+	activeCassette.mu.Lock()
+	activeCassette.Interactions = append(activeCassette.Interactions, cassetteInteraction{
+		Operation:   awsmiddleware.GetServiceID(ctx) + "." + awsmiddleware.GetOperationName(ctx),
+		Method:      req.Method,
+		URL:         req.URL.String(),
+		RequestBody: string(reqBody),
+		Status:      resp.StatusCode,
+		Header:      resp.Header,
+		Body:        string(body),
+	})
+	activeCassette.mu.Unlock()
+	return out, metadata, err
+}
+
+// replayCall answers a call with the next recorded interaction of the same operation. Calls are
+// matched per operation in recorded order, which tolerates concurrent teardown interleaving
+// operations differently; a call the recording has no response left for fails the run.
+func replayCall(ctx context.Context, in middleware.DeserializeInput, next middleware.DeserializeHandler) (middleware.DeserializeOutput, middleware.Metadata, error) {
+	req, ok := in.Request.(*smithyhttp.Request)
+	if !ok {
+		return next.HandleDeserialize(ctx, in)
+	}
+	operation := awsmiddleware.GetServiceID(ctx) + "." + awsmiddleware.GetOperationName(ctx)
+	found := activeCassette.nextInteraction(operation)
+	if found == nil {
+		return middleware.DeserializeOutput{}, middleware.Metadata{}, fmt.Errorf("cassette has no recorded response left for %s", operation)
+	}
+
+	debugf("replaying %s (HTTP %d)", operation, found.Status)
+	return middleware.DeserializeOutput{
+		RawResponse: &smithyhttp.Response{Response: &http.Response{
+			Status:        http.StatusText(found.Status),
+			StatusCode:    found.Status,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        found.Header.Clone(),
+			Body:          io.NopCloser(bytes.NewReader([]byte(found.Body))),
+			ContentLength: int64(len(found.Body)),
+			Request:       req.Request,
+		}},
+	}, middleware.Metadata{}, nil
+}
+
+// nextInteraction returns the next unplayed interaction of an operation, nil when none is left
+func (c *cassette) nextInteraction(operation string) *cassetteInteraction {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i := c.next[operation]; i < len(c.Interactions); i++ {
+		if c.Interactions[i].Operation == operation {
+			c.next[operation] = i + 1
+			return &c.Interactions[i]
+		}
+	}
+	return nil
+}
+
+// runCommand runs the aws CLI, kubectl or helm and returns its standard output and error output.
+// operation names the call in the cassette, e.g. "aws ssm put-parameter". With --record the
+// result is added to the cassette, and with --replay it is answered from the cassette without
+// running the command, so a replay reaches neither AWS nor a cluster.
+func runCommand(operation string, cmd *exec.Cmd) (string, string, error) {
+	if activeCassette != nil && *replayFile != "" {
+		found := activeCassette.nextInteraction(operation)
+		if found == nil {
+			return "", "", fmt.Errorf("cassette has no recorded output left for %s", operation)
+		}
+		debugf("replaying %s (exit status %d)", operation, found.Status)
+		if found.Status != 0 {
+			return found.Body, found.Stderr, fmt.Errorf("exit status %d", found.Status)
+		}
+		return found.Body, found.Stderr, nil
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	err := cmd.Run()
+	if activeCassette != nil {
+		status, errOutput := 0, stderr.String()
+		if err != nil {
+			// A command that could not be started has no exit code of its own
+			status, errOutput = -1, err.Error()
+			var exitErr *exec.ExitError
+			if errors.As(err, &exitErr) {
+				status, errOutput = exitErr.ExitCode(), stderr.String()
+			}
+		}
+		activeCassette.mu.Lock()
+		activeCassette.Interactions = append(activeCassette.Interactions, cassetteInteraction{
+			Operation: operation,
+			Method:    "EXEC",
+			URL:       strings.Join(cmd.Args, " "),
+			Status:    status,
+			Body:      stdout.String(),
+			Stderr:    errOutput,
+		})
+		activeCassette.mu.Unlock()
+	}
+	return stdout.String(), stderr.String(), err
+}
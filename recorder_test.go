@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// replayCassette answers every AWS, aws CLI, kubectl and helm call of the test from a cassette in
+// testdata, with a scratch home directory and fresh AWS configuration and account lookups
+func replayCassette(t *testing.T, name string) {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("RUNNER_TEMP", home)
+	t.Setenv("GITHUB_OUTPUT", filepath.Join(home, "github-output"))
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(home, "aws-config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(home, "aws-credentials"))
+	t.Setenv("EST_WEBHOOKS", "")
+
+	savedReplay, savedRecord, savedCI := *replayFile, *recordFile, *ciMode
+	savedConfig, savedNotifier, savedPresets := userConfig, notifier, presetAnswers
+	t.Cleanup(func() {
+		*replayFile, *recordFile, *ciMode = savedReplay, savedRecord, savedCI
+		userConfig, notifier, presetAnswers = savedConfig, savedNotifier, savedPresets
+		activeCassette, missingInputs = nil, nil
+		resetAWSState()
+	})
+
+	*replayFile, *recordFile, *ciMode = filepath.Join("testdata", name), "", true
+	userConfig, notifier, presetAnswers = defaultConfig(), newWebhookNotifier(nil), map[string]string{}
+	resetAWSState()
+	if err := openCassette(); err != nil {
+		t.Fatalf("opening cassette %s: %v", name, err)
+	}
+}
+
+// resetAWSState forgets the AWS configurations, account lookups and fault points of earlier tests,
+// which were built for another cassette
+func resetAWSState() {
+	awsConfigMu.Lock()
+	clear(awsConfigs)
+	awsConfigMu.Unlock()
+	accountMu.Lock()
+	clear(accountLookups)
+	accountMu.Unlock()
+	faultMu.Lock()
+	faultPoints = nil
+	clear(faultCalls)
+	faultMu.Unlock()
+}
+
+// stepNames returns the steps a timer timed, in order
+func stepNames(timer *stepTimer) []string {
+	var names []string
+	for _, step := range timer.steps {
+		names = append(names, step.Name)
+	}
+	return names
+}
+
+// replayedCount returns how many interactions of an operation the cassette has answered
+func replayedCount(operation string) int {
+	count := 0
+	for _, interaction := range activeCassette.Interactions[:activeCassette.next[operation]] {
+		if interaction.Operation == operation {
+			count++
+		}
+	}
+	return count
+}
+
+// existingVPCAnswers creates a control plane only cluster in the VPC of the cassette
+func existingVPCAnswers() *createAnswers {
+	return &createAnswers{
+		Region:          "eu-west-2",
+		ClusterName:     "Sandbox-replay",
+		K8sVersion:      "1.31",
+		ClusterRole:     "arn:aws:iam::123456789012:role/EKSClusterRole",
+		ExistingVPC:     "vpc-0replay",
+		ExistingSubnets: []string{"subnet-0a", "subnet-0b"},
+		Compute:         computeNone,
+	}
+}
+
+func TestReplayCreateStepOrder(t *testing.T) {
+	replayCassette(t, "create-existing-vpc.json")
+
+	timer := newStepTimer()
+	if err := provisionCluster(context.Background(), timer, existingVPCAnswers()); err != nil {
+		t.Fatalf("provisionCluster: %v", err)
+	}
+	want := []string{"IAM", "VPC", "cluster", "cluster-active"}
+	if got := stepNames(timer); !slices.Equal(got, want) {
+		t.Errorf("steps = %v, want %v", got, want)
+	}
+	// The name reservation is taken through the aws CLI and released once the run is done
+	for _, operation := range []string{"aws ssm put-parameter", "EKS.CreateCluster", "aws ssm delete-parameter"} {
+		if replayedCount(operation) == 0 {
+			t.Errorf("%s was not replayed", operation)
+		}
+	}
+}
+
+func TestReplayDeleteStepOrder(t *testing.T) {
+	replayCassette(t, "delete-isolated-vpc.json")
+	presetAnswers[answerKey("EST_REGION")] = "eu-west-2"
+	presetAnswers[answerKey("EST_CLUSTER_NAME")] = "replay"
+	presetAnswers[answerKey("EST_DELETE_VPC")] = "yes"
+
+	timer := newStepTimer()
+	if err := runDelete(context.Background(), timer); err != nil {
+		t.Fatalf("runDelete: %v", err)
+	}
+	want := []string{"cluster", "VPC"}
+	if got := stepNames(timer); !slices.Equal(got, want) {
+		t.Errorf("steps = %v, want %v", got, want)
+	}
+	for _, operation := range []string{"EKS.DeleteCluster", "EC2.DeleteVpc"} {
+		if replayedCount(operation) == 0 {
+			t.Errorf("%s was not replayed", operation)
+		}
+	}
+}
+
+func TestRunCommandReplaysRecordedOutput(t *testing.T) {
+	replayCassette(t, "delete-isolated-vpc.json")
+	ctx := context.Background()
+
+	out, err := runAWSCLI(ctx, "eu-west-2", "scheduler", "list-schedules", "--name-prefix", "est-")
+	if err != nil || !strings.Contains(out, "Schedules") {
+		t.Errorf("list-schedules = %q, %v; want the recorded output", out, err)
+	}
+	_, err = runAWSCLI(ctx, "eu-west-2", "kms", "describe-key", "--key-id", "alias/est/Sandbox-replay")
+	if err == nil || !strings.Contains(err.Error(), "NotFoundException") {
+		t.Errorf("describe-key error = %v, want the recorded NotFoundException", err)
+	}
+	// Nothing is run for real, so a call the cassette does not hold fails
+	if _, err := runAWSCLI(ctx, "eu-west-2", "s3", "rb", "s3://replay", "--force"); err == nil {
+		t.Error("aws s3 rb succeeded without a recorded interaction")
+	}
+}
+
+func TestRunCommandRecordsOutput(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no sh to run")
+	}
+	saved := activeCassette
+	t.Cleanup(func() { activeCassette = saved })
+	activeCassette = &cassette{}
+
+	stdout, _, err := runCommand("sh echo", exec.Command("sh", "-c", "echo recorded; echo oops >&2; exit 3"))
+	if err == nil || stdout != "recorded\n" {
+		t.Fatalf("runCommand = %q, %v; want the output and an exit error", stdout, err)
+	}
+	if len(activeCassette.Interactions) != 1 {
+		t.Fatalf("recorded %d interactions, want 1", len(activeCassette.Interactions))
+	}
+	got := activeCassette.Interactions[0]
+	if got.Operation != "sh echo" || got.Method != "EXEC" || got.Status != 3 || got.Body != "recorded\n" || got.Stderr != "oops\n" {
+		t.Errorf("recorded %+v", got)
+	}
+}
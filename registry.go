@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/AlecAivazis/survey/v2"
+)
+
+// registryConfig names the DynamoDB table clusters are registered in, so that `est list --registry`
+// shows the sandboxes of a team from any laptop or CI runner
+type registryConfig struct {
+	// Table is the name of the table; EST_REGISTRY_TABLE overrides it. Empty disables the registry.
+	Table string `json:"table"`
+	// Region is the region of the table, by default EST_REGION or AWS_REGION
+	Region string `json:"region"`
+}
+
+// registryTable returns the registry table, "" when clusters are not registered
+func registryTable() string {
+	return firstNonEmpty(os.Getenv("EST_REGISTRY_TABLE"), userConfig.Registry.Table)
+}
+
+// registryRegion returns the region the registry table is in
+func registryRegion() string {
+	return firstNonEmpty(userConfig.Registry.Region, os.Getenv("EST_REGION"), os.Getenv("AWS_REGION"), "us-east-1")
+}
+
+// registryKey is the partition key of a cluster's item, unique across the regions sharing a table
+func registryKey(region, clusterName string) string {
+	return region + "/" + clusterName
+}
+
+// registryStore returns the store of the registry table
+func registryStore() StateStore {
+	return dynamoDBStateStore{Table: registryTable(), Region: registryRegion()}
+}
+
+// dynamoDBStateStore keeps one item per cluster in a DynamoDB table whose partition key is
+// cluster_id. The ttl attribute holds the expiry as epoch seconds, so a table with TTL enabled on
+// it drops the items of clusters that outlive it. The table has no SDK client in this tool, so the
+// aws CLI is used.
+type dynamoDBStateStore struct {
+	Table  string
+	Region string
+}
+
+func openDynamoDBStateStore(location, region string) (StateStore, error) {
+	if location == "" || strings.Contains(location, "/") {
+		return nil, fmt.Errorf("a DynamoDB state store must name a table, dynamodb://<table>, got %q", "dynamodb://"+location)
+	}
+	return dynamoDBStateStore{Table: location, Region: region}, nil
+}
+
+func (s dynamoDBStateStore) String() string {
+	return "dynamodb://" + s.Table
+}
+
+func (s dynamoDBStateStore) Put(ctx context.Context, entry inventoryEntry) error {
+	item := map[string]map[string]string{
+		"cluster_id":  {"S": registryKey(entry.Region, entry.Name)},
+		"name":        {"S": entry.Name},
+		"region":      {"S": entry.Region},
+		"owner":       {"S": entry.Owner},
+		"vpc_id":      {"S": entry.VPCID},
+		"hosting_vpc": {"S": entry.HostingVPC},
+		"created":     {"S": entry.Created.Format(time.RFC3339)},
+	}
+	if entry.CostCenter != "" {
+		item["cost_center"] = map[string]string{"S": entry.CostCenter}
+	}
+	if entry.Purpose != "" {
+		item["purpose"] = map[string]string{"S": entry.Purpose}
+	}
+	if expires, err := time.Parse(time.RFC3339, entry.ExpiresAt); err == nil {
+		item["expires_at"] = map[string]string{"S": entry.ExpiresAt}
+		item["ttl"] = map[string]string{"N": strconv.FormatInt(expires.Unix(), 10)}
+	}
+	data, err := json.Marshal(item)
+	if err != nil {
+		return fmt.Errorf("unable to encode registry item: %w", err)
+	}
+	_, err = runAWSCLI(ctx, s.Region, "dynamodb", "put-item", "--table-name", s.Table, "--item", string(data))
+	if err != nil {
+		return fmt.Errorf("unable to register %s in %s: %w", entry.Name, s.Table, err)
+	}
+	return nil
+}
+
+func (s dynamoDBStateStore) Delete(ctx context.Context, region, clusterName string) error {
+	key, _ := json.Marshal(map[string]map[string]string{"cluster_id": {"S": registryKey(region, clusterName)}})
+	_, err := runAWSCLI(ctx, s.Region, "dynamodb", "delete-item", "--table-name", s.Table, "--key", string(key))
+	if err != nil {
+		return fmt.Errorf("unable to deregister %s from %s: %w", clusterName, s.Table, err)
+	}
+	return nil
+}
+
+// List scans the table. The aws CLI follows the pages of the scan.
+func (s dynamoDBStateStore) List(ctx context.Context) ([]inventoryEntry, error) {
+	out, err := runAWSCLI(ctx, s.Region, "dynamodb", "scan", "--table-name", s.Table)
+	if err != nil {
+		return nil, fmt.Errorf("unable to scan %s: %w", s.Table, err)
+	}
+	var result struct {
+		Items []map[string]struct {
+			S string
+		}
+	}
+	if err := json.Unmarshal([]byte(out), &result); err != nil {
+		return nil, fmt.Errorf("unable to read the items of %s: %w", s.Table, err)
+	}
+	var entries []inventoryEntry
+	for _, item := range result.Items {
+		created, _ := time.Parse(time.RFC3339, item["created"].S)
+		entries = append(entries, inventoryEntry{
+			Name:       item["name"].S,
+			Region:     item["region"].S,
+			Owner:      item["owner"].S,
+			CostCenter: item["cost_center"].S,
+			Purpose:    item["purpose"].S,
+			VPCID:      item["vpc_id"].S,
+			HostingVPC: item["hosting_vpc"].S,
+			Created:    created,
+			ExpiresAt:  item["expires_at"].S,
+		})
+	}
+	slices.SortFunc(entries, func(a, b inventoryEntry) int {
+		return strings.Compare(registryKey(a.Region, a.Name), registryKey(b.Region, b.Name))
+	})
+	return entries, nil
+}
+
+// listRegistry prints the registered clusters of regions, all regions when none are given, only
+// those owned by the caller with mine
+func listRegistry(ctx context.Context, regions []string, mine bool) error {
+	entries, err := registryStore().List(ctx)
+	if err != nil {
+		return wrapErr("reading cluster registry", err)
+	}
+	owner := ""
+	if mine {
+		_, callerID, err := GetAWSAccountDetails(ctx, registryRegion())
+		if err != nil {
+			return wrapErr("fetching caller identity", err)
+		}
+		owner = ownerFromARN(callerID)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tREGION\tOWNER\tPURPOSE\tCREATED\tEXPIRES")
+	count := 0
+	for _, entry := range entries {
+		if (len(regions) > 0 && !slices.Contains(regions, entry.Region)) || (mine && entry.Owner != owner) {
+			continue
+		}
+		count++
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", entry.Name, entry.Region, firstNonEmpty(entry.Owner, "-"), firstNonEmpty(entry.Purpose, "-"),
+			entry.Created.Local().Format("2006-01-02 15:04"), firstNonEmpty(entry.ExpiresAt, "-"))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	infof("%d clusters registered in %s", count, registryTable())
+	return nil
+}
+
+// registryOnly skips the purpose question when clusters are not registered
+func registryOnly(a *createAnswers) bool {
+	return registryTable() == ""
+}
+
+func askPurpose(ctx context.Context, a *createAnswers) error {
+	purposePrompt := &survey.Input{
+		Message: "What is the cluster for? Shown to your team in the cluster registry (empty for nothing):",
+		Default: a.Purpose,
+	}
+	if err := askOne("EST_PURPOSE", purposePrompt, &a.Purpose); err != nil {
+		return wrapErr("reading purpose", err)
+	}
+	a.Purpose = strings.TrimSpace(a.Purpose)
+	return nil
+}
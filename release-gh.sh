@@ -60,15 +60,15 @@ for TARGET in "${TARGETS[@]}"; do
   OUTPUT_NAME="${DIR_NAME}-${VERSION}-${OS}-${ARCH}"
   
   if [[ "$OS" == "windows" ]]; then
-    GOOS="$OS" GOARCH="$ARCH" go build -o "${RELEASE_DIR}/${OUTPUT_NAME}.exe" *.go
+    GOOS="$OS" GOARCH="$ARCH" go build -ldflags "-X main.version=$VERSION" -o "${RELEASE_DIR}/${OUTPUT_NAME}.exe" *.go
     zip -j "${RELEASE_DIR}/${OUTPUT_NAME}.zip" "${RELEASE_DIR}/${OUTPUT_NAME}.exe"
     rm "${RELEASE_DIR}/${OUTPUT_NAME}.exe"
   elif [[ "$OS" == "darwin" ]]; then
-    GOOS="$OS" GOARCH="$ARCH" go build -o "${RELEASE_DIR}/${OUTPUT_NAME}" *.go
+    GOOS="$OS" GOARCH="$ARCH" go build -ldflags "-X main.version=$VERSION" -o "${RELEASE_DIR}/${OUTPUT_NAME}" *.go
     gtar -czf "${RELEASE_DIR}/${OUTPUT_NAME}.tar.gz" -C "$RELEASE_DIR" "$OUTPUT_NAME"
     rm "${RELEASE_DIR}/${OUTPUT_NAME}"
   else
-    GOOS="$OS" GOARCH="$ARCH" go build -o "${RELEASE_DIR}/${OUTPUT_NAME}" *.go
+    GOOS="$OS" GOARCH="$ARCH" go build -ldflags "-X main.version=$VERSION" -o "${RELEASE_DIR}/${OUTPUT_NAME}" *.go
     tar -czf "${RELEASE_DIR}/${OUTPUT_NAME}.tar.gz" -C "$RELEASE_DIR" "$OUTPUT_NAME"
     rm "${RELEASE_DIR}/${OUTPUT_NAME}"
   fi
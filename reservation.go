@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	eks "github.com/aws/aws-sdk-go-v2/service/eks"
+	"github.com/aws/aws-sdk-go-v2/service/eks/types"
+)
+
+// reservationTTL is how long a cluster name reservation holds. A reservation older than this
+// belongs to a run that died without releasing it and may be taken over.
+const reservationTTL = time.Hour
+
+// nameReservation is stored as the value of the SSM parameter that reserves a cluster name
+type nameReservation struct {
+	Owner   string    `json:"owner"`
+	Started time.Time `json:"started"`
+}
+
+// reservationParameter returns the SSM parameter that marks a cluster name as being created
+func reservationParameter(clusterName string) string {
+	return "/est/creating/" + clusterName
+}
+
+// ReserveClusterName makes sure no cluster of the name exists and marks the name as being created
+// by owner, so two people creating the same cluster in a shared account minutes apart do not race
+// each other. The returned function releases the reservation. The marker is an SSM parameter
+// written through the aws CLI; when it cannot be written the run continues with the existence
+// check alone.
+func ReserveClusterName(ctx context.Context, region, clusterName, owner string) (func(), error) {
+	cfg, err := loadAWSConfig(ctx, region)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load AWS configuration: %w", err)
+	}
+	_, err = eks.NewFromConfig(cfg).DescribeCluster(ctx, &eks.DescribeClusterInput{Name: aws.String(clusterName)})
+	if err == nil {
+		return nil, validationErr("cluster %s already exists in %s", clusterName, region)
+	}
+	var notFound *types.ResourceNotFoundException
+	if !errors.As(err, &notFound) {
+		return nil, fmt.Errorf("unable to check whether cluster %s exists: %w", clusterName, err)
+	}
+
+	name := reservationParameter(clusterName)
+	value, _ := json.Marshal(nameReservation{Owner: owner, Started: time.Now().UTC()})
+	put := func() error {
+		_, err := runAWSCLI(ctx, region, "ssm", "put-parameter", "--name", name, "--type", "String",
+			"--value", string(value), "--tags", "Key=CreatedBy,Value=EKS-Sandbox-Tool")
+		return err
+	}
+	release := func() {
+		if _, err := runAWSCLI(context.Background(), region, "ssm", "delete-parameter", "--name", name); err != nil {
+			warnf("Unable to release the reservation of cluster name %s, delete SSM parameter %s: %v", clusterName, name, err)
+		}
+	}
+
+	err = put()
+	if err != nil && strings.Contains(err.Error(), "ParameterAlreadyExists") {
+		holder, stale, readErr := readReservation(ctx, region, name)
+		if readErr != nil {
+			return nil, fmt.Errorf("unable to read the reservation of cluster name %s: %w", clusterName, readErr)
+		}
+		if !stale {
+			return nil, validationErr("cluster %s is being created by %s since %s, pick another name",
+				clusterName, holder.Owner, holder.Started.Local().Format(time.Kitchen))
+		}
+		warnf("Taking over the reservation of cluster name %s left by %s at %s", clusterName, holder.Owner, holder.Started.Local().Format(time.RFC3339))
+		if _, err := runAWSCLI(ctx, region, "ssm", "delete-parameter", "--name", name); err != nil {
+			return nil, fmt.Errorf("unable to remove stale reservation of cluster name %s: %w", clusterName, err)
+		}
+		// Whoever puts the parameter first after the stale one is gone wins
+		if err = put(); err != nil && strings.Contains(err.Error(), "ParameterAlreadyExists") {
+			return nil, validationErr("cluster %s was just reserved by someone else, pick another name", clusterName)
+		}
+	}
+	if err != nil {
+		warnf("Unable to reserve cluster name %s, continuing without protection against simultaneous creation: %v", clusterName, err)
+		return func() {}, nil
+	}
+	debugf("reserved cluster name %s with SSM parameter %s", clusterName, name)
+	return release, nil
+}
+
+// readReservation reads the reservation parameter and reports whether it has expired
+func readReservation(ctx context.Context, region, name string) (nameReservation, bool, error) {
+	out, err := runAWSCLI(ctx, region, "ssm", "get-parameter", "--name", name)
+	if err != nil {
+		return nameReservation{}, false, err
+	}
+	var parameter struct {
+		Parameter struct {
+			Value string
+		}
+	}
+	var holder nameReservation
+	if err := json.Unmarshal([]byte(out), &parameter); err != nil {
+		return nameReservation{}, false, err
+	}
+	if err := json.Unmarshal([]byte(parameter.Parameter.Value), &holder); err != nil {
+		// Not written by this tool's format, treat it as expired
+		return nameReservation{Owner: "unknown"}, true, nil
+	}
+	return holder, time.Since(holder.Started) > reservationTTL, nil
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/smithy-go/middleware"
+)
+
+var (
+	manifestFile = flag.String("manifest", "", "File the run manifest of created resources is written to (default ~/.est/last-run.json)")
+	manifestS3   = flag.String("manifest-s3", "", "s3:// URI the run manifest is also uploaded to")
+)
+
+// runManifest is the machine-readable record of a run, written at its end for test harnesses and
+// other tools that pick up what the run created
+type runManifest struct {
+	Command  []string  `json:"command"`
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished"`
+	ExitCode int       `json:"exitCode"`
+	Error    string    `json:"error,omitempty"`
+	// Clusters are the clusters the run created, with how to reach them
+	Clusters []manifestCluster `json:"clusters,omitempty"`
+	// Created and Deleted are the AWS resources the run created and deleted, in call order
+	Created []manifestResource `json:"created,omitempty"`
+	Deleted []manifestResource `json:"deleted,omitempty"`
+
+	mu sync.Mutex
+}
+
+// manifestCluster describes a cluster created by the run
+type manifestCluster struct {
+	Name              string            `json:"name"`
+	Region            string            `json:"region"`
+	Arn               string            `json:"arn"`
+	KubernetesVersion string            `json:"kubernetesVersion"`
+	Endpoint          string            `json:"endpoint"`
+	VPCID             string            `json:"vpcId"`
+	Subnets           []string          `json:"subnets"`
+	SecurityGroups    []string          `json:"securityGroups,omitempty"`
+	RoleArn           string            `json:"roleArn"`
+	Addons            map[string]string `json:"addons,omitempty"`
+	Kubeconfig        string            `json:"kubeconfig,omitempty"`
+}
+
+// manifestResource is an AWS resource created or deleted by an API call of the run
+type manifestResource struct {
+	Service   string `json:"service"`
+	Operation string `json:"operation"`
+	Region    string `json:"region,omitempty"`
+	ID        string `json:"id"`
+}
+
+// manifest collects the run manifest as the run goes
+var manifest = &runManifest{Command: os.Args[1:], Started: time.Now().UTC()}
+
+// AddCluster records a cluster the run created
+func (m *runManifest) AddCluster(cluster manifestCluster) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Clusters = append(m.Clusters, cluster)
+}
+
+// Write writes the manifest to --manifest and, with --manifest-s3, uploads it. Problems are
+// reported but never change the outcome of the run.
+func (m *runManifest) Write(ctx context.Context, runErr error) {
+	m.mu.Lock()
+	m.Finished = time.Now().UTC()
+	if runErr != nil {
+		m.ExitCode, m.Error = exitCodeFor(runErr), runErr.Error()
+	}
+	data, err := json.MarshalIndent(m, "", "  ")
+	m.mu.Unlock()
+	if err != nil {
+		warnf("unable to encode the run manifest: %v", err)
+		return
+	}
+
+	path := *manifestFile
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			warnf("unable to write the run manifest: %v", err)
+			return
+		}
+		path = filepath.Join(home, ".est", "last-run.json")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		warnf("unable to create the run manifest directory: %v", err)
+		return
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0600); err != nil {
+		warnf("unable to write the run manifest: %v", err)
+		return
+	}
+	debugf("wrote run manifest %s", path)
+
+	if *manifestS3 == "" {
+		return
+	}
+	region := firstNonEmpty(os.Getenv("EST_REGION"), os.Getenv("AWS_REGION"), "us-east-1")
+	if len(m.Clusters) > 0 {
+		region = m.Clusters[0].Region
+	}
+	if _, err := runAWSCLI(ctx, region, "s3", "cp", path, *manifestS3); err != nil {
+		warnf("unable to upload the run manifest to %s: %v", *manifestS3, err)
+		return
+	}
+	debugf("uploaded run manifest to %s", *manifestS3)
+}
+
+// snapshot encodes the manifest as it stands, for hooks that run before the end of the run
+func (m *runManifest) snapshot() ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return json.Marshal(m)
+}
+
+// addManifestRecorder records the resources each AWS call creates or deletes in the manifest
+func addManifestRecorder(stack *middleware.Stack) error {
+	return stack.Initialize.Add(middleware.InitializeMiddlewareFunc("EstManifest", recordManifestResource), middleware.After)
+}
+
+// manifestCreatePrefixes and manifestDeletePrefixes are the prefixes of the operations that create
+// and delete a resource
+var (
+	manifestCreatePrefixes = []string{"Create", "Allocate"}
+	manifestDeletePrefixes = []string{"Delete", "Release", "Terminate"}
+)
+
+func recordManifestResource(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (middleware.InitializeOutput, middleware.Metadata, error) {
+	out, metadata, err := next.HandleInitialize(ctx, in)
+	if err != nil {
+		return out, metadata, err
+	}
+	operation := awsmiddleware.GetOperationName(ctx)
+	resource := manifestResource{
+		Service:   awsmiddleware.GetServiceID(ctx),
+		Operation: operation,
+		Region:    awsmiddleware.GetRegion(ctx),
+	}
+	for _, prefix := range manifestCreatePrefixes {
+		if name, ok := strings.CutPrefix(operation, prefix); ok {
+			if resource.ID = resourceIdentifier(name, out.Result); resource.ID != "" {
+				manifest.mu.Lock()
+				manifest.Created = append(manifest.Created, resource)
+				manifest.mu.Unlock()
+			}
+			return out, metadata, err
+		}
+	}
+	for _, prefix := range manifestDeletePrefixes {
+		if name, ok := strings.CutPrefix(operation, prefix); ok {
+			// A deleted resource is named by the request
+			if resource.ID = resourceIdentifier(name, in.Parameters); resource.ID != "" {
+				manifest.mu.Lock()
+				manifest.Deleted = append(manifest.Deleted, resource)
+				manifest.mu.Unlock()
+			}
+			return out, metadata, err
+		}
+	}
+	return out, metadata, err
+}
+
+// resourceIdentifier finds the ID, ARN or name of a resource in the input or output of the call
+// that created or deleted it. The SDK names them after the resource, e.g. CreateVpcOutput.Vpc.VpcId,
+// CreateClusterOutput.Cluster.Arn or DeleteRoleInput.RoleName. It returns "" for calls such as
+// CreateTags that name no resource of their own.
+func resourceIdentifier(resource string, value interface{}) string {
+	v := reflect.Indirect(reflect.ValueOf(value))
+	if v.Kind() != reflect.Struct {
+		return ""
+	}
+	if nested := reflect.Indirect(v.FieldByName(resource)); nested.Kind() == reflect.Struct {
+		v = nested
+	}
+	for _, name := range []string{resource + "Arn", resource + "Id", resource + "Name", "Arn", "Name"} {
+		if id := stringField(v.FieldByName(name)); id != "" {
+			return id
+		}
+	}
+	for i := 0; i < v.NumField(); i++ {
+		name := v.Type().Field(i).Name
+		if strings.HasSuffix(name, "Id") || strings.HasSuffix(name, "Arn") {
+			if id := stringField(v.Field(i)); id != "" {
+				return id
+			}
+		}
+	}
+	return ""
+}
+
+// stringField returns the value of a *string or string field, "" for anything else
+func stringField(v reflect.Value) string {
+	v = reflect.Indirect(v)
+	if v.Kind() != reflect.String {
+		return ""
+	}
+	return v.String()
+}
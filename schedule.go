@@ -0,0 +1,287 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	eks "github.com/aws/aws-sdk-go-v2/service/eks"
+)
+
+// pauserRole is the role EventBridge Scheduler assumes to scale node groups down and up. It may
+// only change node groups created by this tool.
+const pauserRole = "est-nodegroup-pauser"
+
+// scheduledScalingTag records on a node group with a pause schedule the sizes it is resumed to,
+// as "<min>/<desired>", so Resume Cluster can bring it back early
+const scheduledScalingTag = "ScheduledScaling"
+
+// defaultPauseHours pauses sandboxes overnight when no hours are given
+const defaultPauseHours = "19:00-08:00 UTC"
+
+var pauseHoursPattern = regexp.MustCompile(`^([01]\d|2[0-3]):([0-5]\d)-([01]\d|2[0-3]):([0-5]\d)(?:\s+(\S+))?$`)
+
+// pauseHours is when the nodes of a cluster are paused on weekdays: from Pause to Resume the next
+// morning, in Timezone. Nodes paused on Friday evening stay paused until Monday morning.
+type pauseHours struct {
+	PauseHour, PauseMinute   string
+	ResumeHour, ResumeMinute string
+	Timezone                 string
+}
+
+// parsePauseHours reads hours such as "19:00-08:00 Europe/London". Without a time zone, UTC is used.
+func parsePauseHours(value string) (pauseHours, error) {
+	m := pauseHoursPattern.FindStringSubmatch(strings.TrimSpace(value))
+	if m == nil {
+		return pauseHours{}, fmt.Errorf("%q is not <HH:MM>-<HH:MM> [time zone], e.g. %s", value, defaultPauseHours)
+	}
+	hours := pauseHours{PauseHour: m[1], PauseMinute: m[2], ResumeHour: m[3], ResumeMinute: m[4], Timezone: firstNonEmpty(m[5], "UTC")}
+	if _, err := time.LoadLocation(hours.Timezone); err != nil {
+		return pauseHours{}, fmt.Errorf("unknown time zone %q", hours.Timezone)
+	}
+	return hours, nil
+}
+
+func (h pauseHours) String() string {
+	return fmt.Sprintf("%s:%s-%s:%s %s", h.PauseHour, h.PauseMinute, h.ResumeHour, h.ResumeMinute, h.Timezone)
+}
+
+// runSchedule implements `est schedule`
+func runSchedule(ctx context.Context, args []string) error {
+	if err := requireWritable("est schedule"); err != nil {
+		return err
+	}
+	usage := validationErr("usage: est schedule --cluster <name> [--hours \"HH:MM-HH:MM [time zone]\"] [--remove] [--region <region>]")
+
+	var clusterName, region, hoursValue string
+	var remove bool
+	fs := flag.NewFlagSet("schedule", flag.ContinueOnError)
+	fs.StringVar(&clusterName, "cluster", "", "Name of the EKS cluster")
+	fs.StringVar(&hoursValue, "hours", defaultPauseHours, "When nodes are paused on weekdays, from the first time to the second")
+	fs.BoolVar(&remove, "remove", false, "Remove the pause schedules of the cluster")
+	fs.StringVar(&region, "region", firstNonEmpty(os.Getenv("EST_REGION"), os.Getenv("AWS_REGION")), "Region of the cluster")
+	if err := fs.Parse(args); err != nil || fs.NArg() > 0 || clusterName == "" {
+		return usage
+	}
+	if region == "" {
+		return validationErr("no region given: pass --region or set EST_REGION")
+	}
+	notifier.Cluster, notifier.Region = clusterName, region
+
+	if remove {
+		if err := RemovePauseSchedules(ctx, region, clusterName); err != nil {
+			return wrapErr("removing pause schedules", err)
+		}
+		successf("%s is no longer paused on a schedule", clusterName)
+		return nil
+	}
+
+	hours, err := parsePauseHours(hoursValue)
+	if err != nil {
+		return validationErr("%v", err)
+	}
+	createdByTool, err := CheckClusterTag(ctx, region, clusterName, "CreatedBy", "EKS-Sandbox-Tool")
+	if err != nil {
+		return wrapErr("checking cluster tags", err)
+	}
+	if !createdByTool {
+		return validationErr("%s was not created by this tool and is left as it is", clusterName)
+	}
+	if err := userConfig.IAM.checkPermissionsBoundary(); err != nil {
+		return err
+	}
+	if err := SchedulePauses(ctx, region, clusterName, hours); err != nil {
+		return wrapErr("scheduling pauses", err)
+	}
+	return nil
+}
+
+// askPauseHours offers to pause the nodes of the new cluster outside working hours
+func askPauseHours(ctx context.Context, a *createAnswers) error {
+	hoursPrompt := &survey.Input{
+		Message: fmt.Sprintf("Pause the nodes on weekday nights and weekends, e.g. %s (empty for never):", defaultPauseHours),
+		Default: a.PauseHours,
+	}
+	validate := func(ans interface{}) error {
+		if value := strings.TrimSpace(ans.(string)); value != "" {
+			_, err := parsePauseHours(value)
+			return err
+		}
+		return nil
+	}
+	if err := askOne("EST_PAUSE_HOURS", hoursPrompt, &a.PauseHours, survey.WithValidator(validate)); err != nil {
+		return wrapErr("reading pause hours", err)
+	}
+	a.PauseHours = strings.TrimSpace(a.PauseHours)
+	return nil
+}
+
+// managedNodegroupOnly skips questions that only apply to clusters whose nodes are a managed node group
+func managedNodegroupOnly(a *createAnswers) bool {
+	return a.compute() != computeNodegroup
+}
+
+// pauseScheduleName derives the name of a schedule of a node group, which is limited to 64
+// characters. The schedules of a cluster share pauseSchedulePrefix.
+func pauseScheduleName(clusterName, nodegroup, action string) string {
+	sum := sha256.Sum256([]byte(nodegroup))
+	return pauseSchedulePrefix(clusterName) + hex.EncodeToString(sum[:4]) + "-" + action
+}
+
+func pauseSchedulePrefix(clusterName string) string {
+	sum := sha256.Sum256([]byte(clusterName))
+	return "est-pause-" + hex.EncodeToString(sum[:6]) + "-"
+}
+
+// SchedulePauses creates two EventBridge Scheduler schedules for each node group of a cluster
+// created by the tool: one scales it to zero at the start of the pause hours on weekdays, the
+// other scales it back at their end. Each calls UpdateNodegroupConfig directly, so nothing but the
+// schedules and their role runs in the account. The sizes the node group is resumed to are the
+// ones it has now, or the ones recorded when it was paused by hand. The resume schedule does not
+// look at the pausedScalingTag, so it also brings back node groups paused by hand.
+func SchedulePauses(ctx context.Context, region, clusterName string, hours pauseHours) error {
+	cfg, err := loadAWSConfig(ctx, region)
+	if err != nil {
+		return fmt.Errorf("unable to load AWS configuration: %w", err)
+	}
+	client := eks.NewFromConfig(cfg)
+	nodegroups, err := describeNodegroups(ctx, client, clusterName)
+	if err != nil {
+		return err
+	}
+
+	roleArn, err := ensureSchedulerRole(ctx, region, schedulerRole{
+		Name:        pauserRole,
+		Description: "Pauses the node groups of sandbox clusters outside working hours",
+		PolicyName:  "est-scale-nodegroups",
+		Statement: func(partition, accountID string) map[string]interface{} {
+			return map[string]interface{}{
+				"Effect":   "Allow",
+				"Action":   "eks:UpdateNodegroupConfig",
+				"Resource": fmt.Sprintf("arn:%s:eks:*:%s:nodegroup/*", partition, accountID),
+				"Condition": map[string]interface{}{
+					"StringEquals": map[string]string{"aws:ResourceTag/CreatedBy": "EKS-Sandbox-Tool"},
+				},
+			}
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	scheduled := 0
+	for _, ng := range nodegroups {
+		name := aws.ToString(ng.NodegroupName)
+		if ng.Tags["CreatedBy"] != "EKS-Sandbox-Tool" || ng.ScalingConfig == nil {
+			warnf("Node group %s was not created by this tool and is not paused", name)
+			continue
+		}
+		minSize, desiredSize := aws.ToInt32(ng.ScalingConfig.MinSize), aws.ToInt32(ng.ScalingConfig.DesiredSize)
+		if recorded, paused := ng.Tags[pausedScalingTag]; paused {
+			if minSize, desiredSize, err = parsePausedScaling(recorded); err != nil {
+				return fmt.Errorf("node group %s: %w", name, err)
+			}
+		}
+		if desiredSize == 0 {
+			warnf("Node group %s has no nodes and is not paused", name)
+			continue
+		}
+		maxSize := aws.ToInt32(ng.ScalingConfig.MaxSize)
+
+		_, err := client.TagResource(ctx, &eks.TagResourceInput{
+			ResourceArn: ng.NodegroupArn,
+			Tags:        map[string]string{scheduledScalingTag: fmt.Sprintf("%d/%d", minSize, desiredSize)},
+		})
+		if err != nil {
+			return fmt.Errorf("unable to record the size of node group %s: %w", name, err)
+		}
+		schedules := []struct {
+			action, hour, minute string
+			minSize, desiredSize int32
+		}{
+			{"pause", hours.PauseHour, hours.PauseMinute, 0, 0},
+			{"resume", hours.ResumeHour, hours.ResumeMinute, minSize, desiredSize},
+		}
+		for _, s := range schedules {
+			input, err := json.Marshal(map[string]interface{}{
+				"ClusterName":   clusterName,
+				"NodegroupName": name,
+				"ScalingConfig": map[string]int32{"MinSize": s.minSize, "DesiredSize": s.desiredSize, "MaxSize": maxSize},
+			})
+			if err != nil {
+				return err
+			}
+			target, err := json.Marshal(map[string]string{
+				"Arn":     fmt.Sprintf("arn:%s:scheduler:::aws-sdk:eks:updateNodegroupConfig", partitionOf(roleArn)),
+				"RoleArn": roleArn,
+				"Input":   string(input),
+			})
+			if err != nil {
+				return err
+			}
+			err = putSchedule(ctx, region, s.action+" schedule of "+name, pauserRole, []string{
+				"--name", pauseScheduleName(clusterName, name, s.action),
+				"--schedule-expression", fmt.Sprintf("cron(%s %s ? * MON-FRI *)", s.minute, s.hour),
+				"--schedule-expression-timezone", hours.Timezone,
+				"--flexible-time-window", "Mode=OFF",
+				"--description", fmt.Sprintf("%s node group %s of %s", strings.ToUpper(s.action[:1])+s.action[1:], name, clusterName),
+				"--target", string(target),
+			})
+			if err != nil {
+				return err
+			}
+		}
+		scheduled++
+	}
+	if scheduled == 0 {
+		return validationErr("%s has no node group to pause; NodePools of auto mode and Karpenter are only paused with Pause Cluster", clusterName)
+	}
+	successf("The nodes of %s are paused on weekdays from %s:%s until %s:%s (%s) and over the weekend",
+		clusterName, hours.PauseHour, hours.PauseMinute, hours.ResumeHour, hours.ResumeMinute, hours.Timezone)
+	infof("The schedule also resumes node groups paused with Pause Cluster; run est schedule --cluster %s --remove to keep them paused", clusterName)
+	return nil
+}
+
+// RemovePauseSchedules deletes the pause schedules of a cluster
+func RemovePauseSchedules(ctx context.Context, region, clusterName string) error {
+	out, err := runAWSCLI(ctx, region, "scheduler", "list-schedules", "--name-prefix", pauseSchedulePrefix(clusterName))
+	if err != nil {
+		return fmt.Errorf("unable to list pause schedules: %w", err)
+	}
+	var list struct {
+		Schedules []struct {
+			Name string `json:"Name"`
+		} `json:"Schedules"`
+	}
+	if err := json.Unmarshal([]byte(out), &list); err != nil {
+		return fmt.Errorf("unable to parse pause schedules: %w", err)
+	}
+	for _, schedule := range list.Schedules {
+		_, err := runAWSCLI(ctx, region, "scheduler", "delete-schedule", "--name", schedule.Name)
+		if err != nil && !strings.Contains(err.Error(), "ResourceNotFoundException") {
+			return fmt.Errorf("unable to delete schedule %s: %w", schedule.Name, err)
+		}
+		debugf("deleted schedule %s", schedule.Name)
+	}
+	if len(list.Schedules) > 0 {
+		successf("Deleted %d pause schedule(s) of %s", len(list.Schedules), clusterName)
+	}
+	return nil
+}
+
+// CleanupPauseSchedules deletes the pause schedules of a deleted cluster. It only warns, as the
+// cluster is gone and the schedules fail harmlessly without it.
+func CleanupPauseSchedules(ctx context.Context, region, clusterName string) {
+	if err := RemovePauseSchedules(ctx, region, clusterName); err != nil {
+		warnf("Unable to delete the pause schedules of %s: %v", clusterName, err)
+	}
+}
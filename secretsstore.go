@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Versions of the Secrets Store CSI driver manifests. The AWS provider publishes its installer
+// manifest on its main branch only.
+const (
+	secretsStoreDriverVersion = "v1.4.7"
+	secretsStoreDriverBase    = "https://raw.githubusercontent.com/kubernetes-sigs/secrets-store-csi-driver/" + secretsStoreDriverVersion + "/deploy/"
+	secretsStoreAWSProvider   = "https://raw.githubusercontent.com/aws/secrets-store-csi-driver-provider-aws/main/deployment/aws-provider-installer.yaml"
+)
+
+// The demo secret is mounted by pods running as this service account, which reads it through IRSA
+const (
+	secretsDemoNamespace      = "default"
+	secretsDemoServiceAccount = "est-secrets-demo"
+	secretsDemoPolicyName     = "est-secrets-demo"
+	secretsDemoProviderClass  = "est-demo-secret"
+)
+
+// secretsDemoName returns the name of the demo secret in Secrets Manager
+func secretsDemoName(clusterName string) string {
+	return "est/" + clusterName + "/demo"
+}
+
+const secretsDemoManifest = `apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: %[1]s
+  namespace: %[2]s
+  annotations:
+    eks.amazonaws.com/role-arn: %[3]s
+---
+apiVersion: secrets-store.csi.x-k8s.io/v1
+kind: SecretProviderClass
+metadata:
+  name: %[4]s
+  namespace: %[2]s
+spec:
+  provider: aws
+  parameters:
+    objects: |
+      - objectName: "%[5]s"
+        objectType: "secretsmanager"
+`
+
+// prepareSecretsStore creates the demo secret and a role that may read only that secret
+func prepareSecretsStore(ctx context.Context, region, clusterName string) (string, error) {
+	name := secretsDemoName(clusterName)
+	args := []string{"secretsmanager", "create-secret", "--name", name,
+		"--description", "Demo secret of sandbox cluster " + clusterName,
+		"--secret-string", `{"username":"demo","password":"change-me"}`,
+		"--tags"}
+	args = append(args, cliTags()...)
+	out, err := runAWSCLI(ctx, region, args...)
+	if err != nil && strings.Contains(err.Error(), "ResourceExistsException") {
+		out, err = runAWSCLI(ctx, region, "secretsmanager", "describe-secret", "--secret-id", name)
+	}
+	if err != nil {
+		return "", fmt.Errorf("unable to create demo secret %s: %w", name, err)
+	}
+	var secret struct {
+		ARN string
+	}
+	if err := json.Unmarshal([]byte(out), &secret); err != nil {
+		return "", fmt.Errorf("unable to parse demo secret: %w", err)
+	}
+	successf("Created demo secret %s", name)
+
+	req := irsaRequest{
+		Region:         region,
+		Cluster:        clusterName,
+		Namespace:      secretsDemoNamespace,
+		ServiceAccount: secretsDemoServiceAccount,
+		RoleName:       irsaRoleName(clusterName, secretsDemoNamespace, secretsDemoServiceAccount),
+	}
+	roleArn, err := CreateIRSARole(ctx, req)
+	if err != nil {
+		return "", err
+	}
+	err = putRolePolicy(ctx, region, req.RoleName, secretsDemoPolicyName, []map[string]interface{}{{
+		"Effect":   "Allow",
+		"Action":   []string{"secretsmanager:GetSecretValue", "secretsmanager:DescribeSecret"},
+		"Resource": secret.ARN,
+	}})
+	if err != nil {
+		return "", err
+	}
+	return roleArn, nil
+}
+
+// installSecretsStore installs the driver and the AWS provider, then the service account and
+// SecretProviderClass that mount the demo secret
+func installSecretsStore(ctx context.Context, region, clusterName, roleArn string) error {
+	kube, err := newKubectlSession(ctx, region, clusterName)
+	if err != nil {
+		return err
+	}
+	defer kube.Close()
+
+	args := []string{"apply"}
+	for _, file := range []string{
+		"rbac-secretproviderclass.yaml",
+		"csidriver.yaml",
+		"secrets-store.csi.x-k8s.io_secretproviderclasses.yaml",
+		"secrets-store.csi.x-k8s.io_secretproviderclasspodstatuses.yaml",
+		"secrets-store-csi-driver.yaml",
+	} {
+		args = append(args, "-f", secretsStoreDriverBase+file)
+	}
+	args = append(args, "-f", secretsStoreAWSProvider)
+	if _, err := kube.Run(ctx, args...); err != nil {
+		return err
+	}
+	if _, err := kube.Run(ctx, "wait", "--for", "condition=established", "--timeout=2m",
+		"crd/secretproviderclasses.secrets-store.csi.x-k8s.io"); err != nil {
+		return err
+	}
+
+	manifest := fmt.Sprintf(secretsDemoManifest, secretsDemoServiceAccount, secretsDemoNamespace, roleArn,
+		secretsDemoProviderClass, secretsDemoName(clusterName))
+	if err := kube.Apply(ctx, manifest); err != nil {
+		return err
+	}
+	infof("Pods running as service account %s/%s can mount secret %s with the secrets-store.csi.k8s.io driver and secretProviderClass %s",
+		secretsDemoNamespace, secretsDemoServiceAccount, secretsDemoName(clusterName), secretsDemoProviderClass)
+	return nil
+}
+
+// cleanupSecretsStore deletes the demo secret and its role
+func cleanupSecretsStore(ctx context.Context, region, clusterName string) error {
+	roleName := irsaRoleName(clusterName, secretsDemoNamespace, secretsDemoServiceAccount)
+	found, err := deleteAddonRole(ctx, region, roleName, secretsDemoPolicyName)
+	if err != nil || !found {
+		// Without the role the driver was never installed on this cluster
+		return err
+	}
+	name := secretsDemoName(clusterName)
+	_, err = runAWSCLI(ctx, region, "secretsmanager", "delete-secret", "--secret-id", name, "--force-delete-without-recovery")
+	if err != nil && !strings.Contains(err.Error(), "ResourceNotFoundException") {
+		return fmt.Errorf("unable to delete demo secret %s: %w", name, err)
+	}
+	successf("Deleted demo secret %s", name)
+	return nil
+}
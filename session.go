@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/AlecAivazis/survey/v2"
+)
+
+// sessionProfile and sessionRegion are the AWS profile and default region switched to from the
+// interactive menu. An empty profile uses the default credential chain, an empty region each
+// prompt's own default.
+var sessionProfile, sessionRegion string
+
+// defaultProfileOption is the profile choice that leaves credentials to the default chain
+const defaultProfileOption = "(default credential chain)"
+
+// regionDefault returns the region switched to in this session, fallback when there is none
+func regionDefault(fallback string) string {
+	return firstNonEmpty(sessionRegion, fallback)
+}
+
+// runSwitchSession lets the user pick another AWS profile and region without restarting the tool
+func runSwitchSession(ctx context.Context) error {
+	options := []string{defaultProfileOption}
+	options = append(options, listAWSProfiles()...)
+	profilePrompt := &survey.Select{
+		Message:  "Select the AWS profile to use:",
+		Options:  options,
+		Default:  firstNonEmpty(sessionProfile, defaultProfileOption),
+		PageSize: 15,
+	}
+	var profile string
+	if err := askOne("EST_PROFILE", profilePrompt, &profile); err != nil {
+		return wrapErr("reading profile", err)
+	}
+	if profile == defaultProfileOption {
+		profile = ""
+	}
+	regionPrompt := &survey.Input{
+		Message: "Enter the default AWS region for this session:",
+		Default: regionDefault("eu-west-2"),
+	}
+	var region string
+	if err := askOne("EST_REGION", regionPrompt, &region, survey.WithValidator(validRegion)); err != nil {
+		return wrapErr("reading region", err)
+	}
+	if err := requireInputs(); err != nil {
+		return err
+	}
+
+	previousProfile, previousRegion := sessionProfile, sessionRegion
+	setSession(profile, region)
+	_, callerID, err := GetAWSAccountDetails(ctx, region)
+	if err != nil {
+		setSession(previousProfile, previousRegion)
+		return wrapErr("checking the credentials of profile "+firstNonEmpty(profile, defaultProfileOption), err)
+	}
+	successf("Now working in %s as %s", region, callerID)
+	return nil
+}
+
+// setSession switches the profile and region and drops the AWS configurations and caller
+// identities cached for the previous ones, so every client is rebuilt with the new credentials
+func setSession(profile, region string) {
+	awsConfigMu.Lock()
+	sessionProfile, sessionRegion = profile, region
+	clear(awsConfigs)
+	awsConfigMu.Unlock()
+
+	accountMu.Lock()
+	clear(accountLookups)
+	accountMu.Unlock()
+}
+
+// listAWSProfiles returns the profiles named in the shared config and credentials files
+func listAWSProfiles() []string {
+	home, _ := os.UserHomeDir()
+	files := []string{
+		firstNonEmpty(os.Getenv("AWS_CONFIG_FILE"), filepath.Join(home, ".aws", "config")),
+		firstNonEmpty(os.Getenv("AWS_SHARED_CREDENTIALS_FILE"), filepath.Join(home, ".aws", "credentials")),
+	}
+	seen := map[string]bool{}
+	var profiles []string
+	for _, path := range files {
+		f, err := os.Open(path)
+		if err != nil {
+			continue
+		}
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if !strings.HasPrefix(line, "[") || !strings.HasSuffix(line, "]") {
+				continue
+			}
+			name := strings.TrimSpace(strings.Trim(line, "[]"))
+			// The config file prefixes profiles and also has sso-session and services sections
+			if trimmed, ok := strings.CutPrefix(name, "profile "); ok {
+				name = strings.TrimSpace(trimmed)
+			} else if strings.Contains(name, " ") {
+				continue
+			}
+			if name != "" && !seen[name] {
+				seen[name] = true
+				profiles = append(profiles, name)
+			}
+		}
+		f.Close()
+	}
+	sort.Strings(profiles)
+	return profiles
+}
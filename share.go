@@ -0,0 +1,336 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	eks "github.com/aws/aws-sdk-go-v2/service/eks"
+	"github.com/aws/aws-sdk-go-v2/service/eks/types"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	iamtypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
+)
+
+// shareRevokerRole is the role EventBridge Scheduler assumes to delete expired access entries. It
+// may only delete access entries created by this tool.
+const shareRevokerRole = "est-access-revoker"
+
+// shareAccessPolicies maps --access to the EKS access policy granted
+var shareAccessPolicies = map[string]string{
+	"view":  "AmazonEKSViewPolicy",
+	"edit":  "AmazonEKSEditPolicy",
+	"admin": "AmazonEKSClusterAdminPolicy",
+}
+
+// shareRequest describes temporary access to a cluster for a teammate's IAM principal
+type shareRequest struct {
+	Region       string
+	Cluster      string
+	PrincipalArn string
+	Access       string
+	Namespaces   []string
+	Duration     time.Duration
+}
+
+// runShare implements `est share`
+func runShare(ctx context.Context, args []string) error {
+	if err := requireWritable("est share"); err != nil {
+		return err
+	}
+	usage := validationErr("usage: est share --cluster <name> --principal <arn> [--hours <n>] [--access view|edit|admin] [--namespace <ns>...] [--revoke] [--region <region>]")
+
+	var req shareRequest
+	var namespaces stringList
+	var hours int
+	var revoke bool
+	fs := flag.NewFlagSet("share", flag.ContinueOnError)
+	fs.StringVar(&req.Cluster, "cluster", "", "Name of the EKS cluster")
+	fs.StringVar(&req.PrincipalArn, "principal", "", "ARN of the teammate's IAM user or role")
+	fs.IntVar(&hours, "hours", 4, "Hours after which the access is revoked")
+	fs.StringVar(&req.Access, "access", "edit", "Access to grant: view, edit or admin")
+	fs.Var(&namespaces, "namespace", "Limit the access to a namespace (repeatable, default the whole cluster)")
+	fs.BoolVar(&revoke, "revoke", false, "Revoke the principal's access now")
+	fs.StringVar(&req.Region, "region", firstNonEmpty(os.Getenv("EST_REGION"), os.Getenv("AWS_REGION")), "Region of the cluster")
+	if err := fs.Parse(args); err != nil {
+		return usage
+	}
+	req.Namespaces = namespaces
+	req.Duration = time.Duration(hours) * time.Hour
+	if req.Cluster == "" || req.PrincipalArn == "" {
+		return usage
+	}
+	if req.Region == "" {
+		return validationErr("no region given: pass --region or set EST_REGION")
+	}
+	if !strings.HasPrefix(req.PrincipalArn, "arn:") {
+		return validationErr("%q is not an IAM user or role ARN", req.PrincipalArn)
+	}
+	notifier.Cluster, notifier.Region = req.Cluster, req.Region
+
+	if revoke {
+		stepf("Revoking the access of %s to %s...", req.PrincipalArn, req.Cluster)
+		if err := RevokeSharedAccess(ctx, req.Region, req.Cluster, req.PrincipalArn); err != nil {
+			return wrapErr("revoking access", err)
+		}
+		successf("%s can no longer access %s", req.PrincipalArn, req.Cluster)
+		return nil
+	}
+
+	if _, ok := shareAccessPolicies[req.Access]; !ok {
+		return validationErr("unknown access %q, expected view, edit or admin", req.Access)
+	}
+	if hours < 1 || hours > 72 {
+		return validationErr("--hours must be between 1 and 72")
+	}
+	if err := userConfig.IAM.checkPermissionsBoundary(); err != nil {
+		return err
+	}
+
+	expires := time.Now().Add(req.Duration).UTC().Truncate(time.Minute)
+	stepf("Giving %s %s access to %s until %s...", req.PrincipalArn, req.Access, req.Cluster, expires.Format(time.RFC3339))
+	if err := GrantSharedAccess(ctx, req, expires); err != nil {
+		return wrapErr("granting access", err)
+	}
+	successf("%s has %s access to %s", req.PrincipalArn, req.Access, req.Cluster)
+
+	if err := ScheduleAccessRevocation(ctx, req.Region, req.Cluster, req.PrincipalArn, expires); err != nil {
+		warnf("Unable to schedule the revocation: %v", err)
+		infof("Revoke the access yourself with: est share --cluster %s --principal %s --revoke --region %s", req.Cluster, req.PrincipalArn, req.Region)
+	} else {
+		successf("The access is revoked automatically at %s", expires.Format(time.RFC3339))
+	}
+	infof("Your teammate connects with: aws eks update-kubeconfig --name %s --region %s", req.Cluster, req.Region)
+	return nil
+}
+
+// GrantSharedAccess creates an access entry for the principal, tagged with when it expires, and
+// associates the access policy with it. An entry shared before is extended; an entry not created
+// by `est share` is left alone, as its access is meant to last.
+func GrantSharedAccess(ctx context.Context, req shareRequest, expires time.Time) error {
+	cfg, err := loadAWSConfig(ctx, req.Region)
+	if err != nil {
+		return fmt.Errorf("unable to load AWS configuration: %w", err)
+	}
+	client := eks.NewFromConfig(cfg)
+
+	expiresAt := expires.Format(time.RFC3339)
+	_, err = client.CreateAccessEntry(ctx, &eks.CreateAccessEntryInput{
+		ClusterName:  aws.String(req.Cluster),
+		PrincipalArn: aws.String(req.PrincipalArn),
+		Tags:         map[string]string{"CreatedBy": "EKS-Sandbox-Tool", "ExpiresAt": expiresAt},
+	})
+	var inUse *types.ResourceInUseException
+	if errors.As(err, &inUse) {
+		existing, err := client.DescribeAccessEntry(ctx, &eks.DescribeAccessEntryInput{
+			ClusterName:  aws.String(req.Cluster),
+			PrincipalArn: aws.String(req.PrincipalArn),
+		})
+		if err != nil {
+			return fmt.Errorf("unable to describe the access entry of %s: %w", req.PrincipalArn, err)
+		}
+		if _, shared := existing.AccessEntry.Tags["ExpiresAt"]; !shared {
+			return validationErr("%s already has a permanent access entry on %s", req.PrincipalArn, req.Cluster)
+		}
+		_, err = client.TagResource(ctx, &eks.TagResourceInput{
+			ResourceArn: existing.AccessEntry.AccessEntryArn,
+			Tags:        map[string]string{"ExpiresAt": expiresAt},
+		})
+		if err != nil {
+			return fmt.Errorf("unable to extend the access entry of %s: %w", req.PrincipalArn, err)
+		}
+		infof("%s already had temporary access, extended it", req.PrincipalArn)
+	} else if err != nil {
+		return fmt.Errorf("failed to create access entry for %s: %w", req.PrincipalArn, err)
+	}
+
+	_, callerID, err := GetAWSAccountDetails(ctx, req.Region)
+	if err != nil {
+		return err
+	}
+	partition := strings.SplitN(callerID, ":", 3)[1]
+	scope := &types.AccessScope{Type: types.AccessScopeTypeCluster}
+	if len(req.Namespaces) > 0 {
+		scope = &types.AccessScope{Type: types.AccessScopeTypeNamespace, Namespaces: req.Namespaces}
+	}
+	_, err = client.AssociateAccessPolicy(ctx, &eks.AssociateAccessPolicyInput{
+		ClusterName:  aws.String(req.Cluster),
+		PrincipalArn: aws.String(req.PrincipalArn),
+		PolicyArn:    aws.String(fmt.Sprintf("arn:%s:eks::aws:cluster-access-policy/%s", partition, shareAccessPolicies[req.Access])),
+		AccessScope:  scope,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to associate %s with %s: %w", shareAccessPolicies[req.Access], req.PrincipalArn, err)
+	}
+	return nil
+}
+
+// RevokeSharedAccess deletes the principal's access entry and its pending revocation schedule
+func RevokeSharedAccess(ctx context.Context, region, clusterName, principalArn string) error {
+	cfg, err := loadAWSConfig(ctx, region)
+	if err != nil {
+		return fmt.Errorf("unable to load AWS configuration: %w", err)
+	}
+	_, err = eks.NewFromConfig(cfg).DeleteAccessEntry(ctx, &eks.DeleteAccessEntryInput{
+		ClusterName:  aws.String(clusterName),
+		PrincipalArn: aws.String(principalArn),
+	})
+	var notFound *types.ResourceNotFoundException
+	if errors.As(err, &notFound) {
+		infof("%s has no access entry on %s", principalArn, clusterName)
+	} else if err != nil {
+		return fmt.Errorf("failed to delete the access entry of %s: %w", principalArn, err)
+	}
+
+	_, err = runAWSCLI(ctx, region, "scheduler", "delete-schedule", "--name", shareScheduleName(clusterName, principalArn))
+	if err != nil && !strings.Contains(err.Error(), "ResourceNotFoundException") {
+		warnf("Unable to delete the revocation schedule: %v", err)
+	}
+	return nil
+}
+
+// shareScheduleName derives the name of a revocation schedule, which is limited to 64 characters
+func shareScheduleName(clusterName, principalArn string) string {
+	sum := sha256.Sum256([]byte(clusterName + "|" + principalArn))
+	return "est-revoke-" + hex.EncodeToString(sum[:8])
+}
+
+// ScheduleAccessRevocation creates a one-time EventBridge Scheduler schedule that deletes the
+// access entry when it expires and then deletes itself. The tool has no Scheduler SDK client, so
+// the aws CLI is used.
+func ScheduleAccessRevocation(ctx context.Context, region, clusterName, principalArn string, expires time.Time) error {
+	roleArn, err := ensureSchedulerRole(ctx, region, schedulerRole{
+		Name:        shareRevokerRole,
+		Description: "Revokes temporary EKS access shared with est share",
+		PolicyName:  "est-delete-access-entries",
+		Statement: func(partition, accountID string) map[string]interface{} {
+			return map[string]interface{}{
+				"Effect":   "Allow",
+				"Action":   "eks:DeleteAccessEntry",
+				"Resource": fmt.Sprintf("arn:%s:eks:*:%s:access-entry/*", partition, accountID),
+				"Condition": map[string]interface{}{
+					"StringEquals": map[string]string{"aws:ResourceTag/CreatedBy": "EKS-Sandbox-Tool"},
+				},
+			}
+		},
+	})
+	if err != nil {
+		return err
+	}
+	input, err := json.Marshal(map[string]string{"ClusterName": clusterName, "PrincipalArn": principalArn})
+	if err != nil {
+		return err
+	}
+	target, err := json.Marshal(map[string]string{
+		"Arn":     "arn:aws:scheduler:::aws-sdk:eks:deleteAccessEntry",
+		"RoleArn": roleArn,
+		"Input":   string(input),
+	})
+	if err != nil {
+		return err
+	}
+	// The access was shared before when the schedule exists; its revocation moves to the new expiry
+	return putSchedule(ctx, region, "revocation schedule", shareRevokerRole, []string{
+		"--name", shareScheduleName(clusterName, principalArn),
+		"--schedule-expression", "at(" + expires.Format("2006-01-02T15:04:05") + ")",
+		"--schedule-expression-timezone", "UTC",
+		"--flexible-time-window", "Mode=OFF",
+		"--action-after-completion", "DELETE",
+		"--description", fmt.Sprintf("Revoke the access of %s to %s", principalArn, clusterName),
+		"--target", string(target),
+	})
+}
+
+// putSchedule creates an EventBridge Scheduler schedule, or updates it when it exists. A new role
+// takes a moment to become assumable by the scheduler, so the schedule is retried while it is
+// rejected for that.
+func putSchedule(ctx context.Context, region, what, roleName string, args []string) error {
+	return pollUntil(ctx, what, pollOptions{Timeout: 2 * time.Minute}, func(ctx context.Context) (bool, string, error) {
+		_, err := runAWSCLI(ctx, region, append([]string{"scheduler", "create-schedule"}, args...)...)
+		if err != nil && strings.Contains(err.Error(), "ConflictException") {
+			_, err = runAWSCLI(ctx, region, append([]string{"scheduler", "update-schedule"}, args...)...)
+		}
+		if err != nil && strings.Contains(err.Error(), "ValidationException") && strings.Contains(err.Error(), "assume") {
+			debugf("scheduler cannot assume %s yet: %v", roleName, err)
+			return false, "waiting for role " + roleName, nil
+		}
+		if err != nil {
+			return false, "", err
+		}
+		return true, "scheduled", nil
+	})
+}
+
+// schedulerRole is a role EventBridge Scheduler assumes to call an AWS API on a schedule
+type schedulerRole struct {
+	Name        string
+	Description string
+	PolicyName  string
+	// Statement returns the only statement of the role's inline policy
+	Statement func(partition, accountID string) map[string]interface{}
+}
+
+// ensureSchedulerRole returns the ARN of a scheduler role, creating it when missing. One role
+// serves every cluster of the account.
+func ensureSchedulerRole(ctx context.Context, region string, role schedulerRole) (string, error) {
+	cfg, err := loadAWSConfig(ctx, region)
+	if err != nil {
+		return "", fmt.Errorf("unable to load AWS configuration: %w", err)
+	}
+	iamClient := iam.NewFromConfig(cfg)
+
+	existing, err := iamClient.GetRole(ctx, &iam.GetRoleInput{RoleName: aws.String(role.Name)})
+	if err == nil {
+		return aws.ToString(existing.Role.Arn), nil
+	}
+	var noSuchEntity *iamtypes.NoSuchEntityException
+	if !errors.As(err, &noSuchEntity) {
+		return "", fmt.Errorf("unable to read role %s: %w", role.Name, err)
+	}
+
+	accountID, callerID, err := GetAWSAccountDetails(ctx, region)
+	if err != nil {
+		return "", err
+	}
+	partition := strings.SplitN(callerID, ":", 3)[1]
+	trustPolicy, err := json.Marshal(map[string]interface{}{
+		"Version": "2012-10-17",
+		"Statement": []map[string]interface{}{{
+			"Effect":    "Allow",
+			"Principal": map[string]string{"Service": "scheduler.amazonaws.com"},
+			"Action":    "sts:AssumeRole",
+			"Condition": map[string]interface{}{
+				"StringEquals": map[string]string{"aws:SourceAccount": accountID},
+			},
+		}},
+	})
+	if err != nil {
+		return "", err
+	}
+	createInput := &iam.CreateRoleInput{
+		RoleName:                 aws.String(role.Name),
+		AssumeRolePolicyDocument: aws.String(string(trustPolicy)),
+		Description:              aws.String(role.Description),
+		Tags:                     iamTags(),
+	}
+	if boundary := userConfig.IAM.PermissionsBoundary; boundary != "" {
+		createInput.PermissionsBoundary = aws.String(boundary)
+	}
+	created, err := iamClient.CreateRole(ctx, createInput)
+	if err != nil {
+		return "", fmt.Errorf("failed to create role %s: %w%s", role.Name, err, boundaryHint(err))
+	}
+	err = putRolePolicy(ctx, region, role.Name, role.PolicyName, []map[string]interface{}{role.Statement(partition, accountID)})
+	if err != nil {
+		return "", err
+	}
+	successf("Created role %s", role.Name)
+	return aws.ToString(created.Role.Arn), nil
+}
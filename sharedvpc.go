@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	eks "github.com/aws/aws-sdk-go-v2/service/eks"
+)
+
+// hostingVPCShared is the HostingVPC tag of the shared sandbox VPC and of the clusters placed in it
+const hostingVPCShared = "shared"
+
+// sharedVPCName is the cluster name the resources of the shared VPC are named after, as they
+// belong to no single cluster
+const sharedVPCName = "Sandbox-shared"
+
+// FindSharedVPC returns the shared sandbox VPC of a region, or "" when none has been created yet
+func FindSharedVPC(ctx context.Context, region string) (string, error) {
+	cfg, err := loadAWSConfig(ctx, region)
+	if err != nil {
+		return "", fmt.Errorf("unable to load AWS configuration: %w", err)
+	}
+	output, err := ec2.NewFromConfig(cfg).DescribeVpcs(ctx, &ec2.DescribeVpcsInput{
+		Filters: []ec2types.Filter{
+			{Name: aws.String("tag:CreatedBy"), Values: []string{"EKS-Sandbox-Tool"}},
+			{Name: aws.String("tag:HostingVPC"), Values: []string{hostingVPCShared}},
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("unable to describe VPCs: %w", err)
+	}
+	if len(output.Vpcs) == 0 {
+		return "", nil
+	}
+	if len(output.Vpcs) > 1 {
+		warnf("Found %d shared VPCs in %s, using %s", len(output.Vpcs), region, aws.ToString(output.Vpcs[0].VpcId))
+	}
+	return aws.ToString(output.Vpcs[0].VpcId), nil
+}
+
+// TagSharedVPC marks a VPC created by this tool as the shared sandbox VPC
+func TagSharedVPC(ctx context.Context, region, vpcID string) error {
+	cfg, err := loadAWSConfig(ctx, region)
+	if err != nil {
+		return fmt.Errorf("unable to load AWS configuration: %w", err)
+	}
+	_, err = ec2.NewFromConfig(cfg).CreateTags(ctx, &ec2.CreateTagsInput{
+		Resources: []string{vpcID},
+		Tags:      []ec2types.Tag{{Key: aws.String("HostingVPC"), Value: aws.String(hostingVPCShared)}},
+	})
+	if err != nil {
+		return fmt.Errorf("unable to tag VPC %s as shared: %w", vpcID, err)
+	}
+	return nil
+}
+
+// joinSharedVPC returns the network of the shared VPC for another cluster: its regional subnets
+// and the security group created with it
+func joinSharedVPC(ctx context.Context, region, vpcID string) (*clusterNetwork, error) {
+	subnets, err := DescribeVPCSubnets(ctx, region, vpcID)
+	if err != nil {
+		return nil, err
+	}
+	network := &clusterNetwork{VPCID: vpcID}
+	for _, subnet := range subnets {
+		if subnet.LocalZone || subnet.OutpostArn != "" {
+			continue
+		}
+		network.Subnets = append(network.Subnets, subnet.ID)
+		if !subnet.Public {
+			network.PrivateSubnets = append(network.PrivateSubnets, subnet.ID)
+		}
+	}
+	groups, err := ListSecurityGroups(ctx, region, vpcID, ec2types.Filter{
+		Name:   aws.String("tag:CreatedBy"),
+		Values: []string{"EKS-Sandbox-Tool"},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list security groups: %w", err)
+	}
+	network.SecurityGroups = resourceIDs(groups)
+	return network, nil
+}
+
+// SharedVPCClusters returns the clusters placed in a shared VPC. They are what keeps the VPC alive,
+// so it is only deleted once this comes back empty.
+func SharedVPCClusters(ctx context.Context, region, vpcID string) ([]string, error) {
+	cfg, err := loadAWSConfig(ctx, region)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load AWS configuration: %w", err)
+	}
+	client := eks.NewFromConfig(cfg)
+	names, err := ListEKSClusters(ctx, region)
+	if err != nil {
+		return nil, err
+	}
+	var clusters []string
+	for _, name := range names {
+		output, err := client.DescribeCluster(ctx, &eks.DescribeClusterInput{Name: aws.String(name)})
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe EKS cluster %s: %w", name, err)
+		}
+		if output.Cluster.Tags["HostingVPC"] == hostingVPCShared && output.Cluster.Tags["VpcId"] == vpcID {
+			clusters = append(clusters, name)
+		}
+	}
+	return clusters, nil
+}
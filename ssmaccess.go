@@ -0,0 +1,264 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	iamtypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
+)
+
+// ssmBastionAMI resolves to the latest Amazon Linux 2023 image, which ships with the SSM agent
+const ssmBastionAMI = "resolve:ssm:/aws/service/ami-amazon-linux-latest/al2023-ami-kernel-default-x86_64"
+
+// ssmTunnelPort is the local port the tunnel script forwards to the API server
+const ssmTunnelPort = "8443"
+
+// ssmManagedPolicy is the AWS managed policy with the permissions of the SSM agent
+const ssmManagedPolicy = "AmazonSSMManagedInstanceCore"
+
+var ssmTunnelTemplate = template.Must(template.New("tunnel").Parse(`#!/bin/sh
+# Forwards localhost:{{ .Port }} to the private API server of {{ .Cluster }} through SSM instance {{ .Instance }}.
+# Needs the aws CLI with the Session Manager plugin. Keep it running and, in another terminal:
+#   KUBECONFIG={{ .Kubeconfig }} kubectl get nodes
+exec aws ssm start-session --region {{ .Region }} --target {{ .Instance }} \
+  --document-name AWS-StartPortForwardingSessionToRemoteHost \
+  --parameters '{"host":["{{ .Host }}"],"portNumber":["443"],"localPortNumber":["{{ .Port }}"]}'
+`))
+
+// ssmBastionName returns the name of the bastion instance, role and instance profile of a cluster
+func ssmBastionName(clusterName string) string {
+	name := "EST-SSM-" + clusterName
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	return name
+}
+
+// CreateSSMBastion launches a small instance without inbound access that SSM sessions can port
+// forward through, and returns its ID
+func CreateSSMBastion(ctx context.Context, region, clusterName, subnetID, sgID string) (string, error) {
+	cfg, err := loadAWSConfig(ctx, region)
+	if err != nil {
+		return "", fmt.Errorf("unable to load AWS configuration: %w", err)
+	}
+	ec2Client := ec2.NewFromConfig(cfg)
+	name := ssmBastionName(clusterName)
+
+	if err := createSSMInstanceProfile(ctx, iam.NewFromConfig(cfg), regionPartition(region), name); err != nil {
+		return "", err
+	}
+
+	input := &ec2.RunInstancesInput{
+		ImageId:            aws.String(ssmBastionAMI),
+		InstanceType:       ec2types.InstanceTypeT3Nano,
+		MinCount:           aws.Int32(1),
+		MaxCount:           aws.Int32(1),
+		SubnetId:           aws.String(subnetID),
+		SecurityGroupIds:   []string{sgID},
+		IamInstanceProfile: &ec2types.IamInstanceProfileSpecification{Name: aws.String(name)},
+		MetadataOptions: &ec2types.InstanceMetadataOptionsRequest{
+			HttpTokens: ec2types.HttpTokensStateRequired,
+		},
+		TagSpecifications: []ec2types.TagSpecification{
+			{
+				ResourceType: ec2types.ResourceTypeInstance,
+				Tags:         ec2Tags(name),
+			},
+		},
+	}
+	// A new instance profile takes a few seconds before EC2 accepts it
+	var instanceID string
+	for attempt := 1; ; attempt++ {
+		output, err := ec2Client.RunInstances(ctx, input)
+		if err == nil {
+			instanceID = aws.ToString(output.Instances[0].InstanceId)
+			break
+		}
+		if attempt == 12 || !strings.Contains(err.Error(), "iamInstanceProfile") {
+			return "", fmt.Errorf("unable to launch SSM instance: %w", err)
+		}
+		debugf("instance profile %s not ready yet: %v", name, err)
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(5 * time.Second):
+		}
+	}
+
+	waiter := ec2.NewInstanceRunningWaiter(ec2Client)
+	if err := waiter.Wait(ctx, &ec2.DescribeInstancesInput{InstanceIds: []string{instanceID}}, 10*time.Minute); err != nil {
+		return "", fmt.Errorf("SSM instance %s did not start: %w", instanceID, err)
+	}
+	return instanceID, nil
+}
+
+// createSSMInstanceProfile creates a role with the SSM agent permissions and an instance profile for it
+func createSSMInstanceProfile(ctx context.Context, iamClient *iam.Client, partition, name string) error {
+	trustPolicy, err := assumeRolePolicy(servicePrincipal(partition, "ec2"))
+	if err != nil {
+		return err
+	}
+	createInput := &iam.CreateRoleInput{
+		RoleName:                 aws.String(name),
+		AssumeRolePolicyDocument: aws.String(trustPolicy),
+		Tags:                     iamTags(),
+	}
+	if boundary := userConfig.IAM.PermissionsBoundary; boundary != "" {
+		createInput.PermissionsBoundary = aws.String(boundary)
+	}
+	var alreadyExists *iamtypes.EntityAlreadyExistsException
+	if _, err := iamClient.CreateRole(ctx, createInput); err != nil && !errors.As(err, &alreadyExists) {
+		return fmt.Errorf("failed to create role %s: %w%s", name, err, boundaryHint(err))
+	}
+	policyArn := managedPolicyArn(partition, ssmManagedPolicy)
+	_, err = iamClient.AttachRolePolicy(ctx, &iam.AttachRolePolicyInput{
+		RoleName:  aws.String(name),
+		PolicyArn: aws.String(policyArn),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to attach policy %s to role %s: %w", policyArn, name, err)
+	}
+
+	_, err = iamClient.CreateInstanceProfile(ctx, &iam.CreateInstanceProfileInput{
+		InstanceProfileName: aws.String(name),
+		Tags:                iamTags(),
+	})
+	if err != nil {
+		if !errors.As(err, &alreadyExists) {
+			return fmt.Errorf("failed to create instance profile %s: %w", name, err)
+		}
+		return nil
+	}
+	_, err = iamClient.AddRoleToInstanceProfile(ctx, &iam.AddRoleToInstanceProfileInput{
+		InstanceProfileName: aws.String(name),
+		RoleName:            aws.String(name),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to add role %s to instance profile: %w", name, err)
+	}
+	successf("Created instance profile %s for SSM access", name)
+	return nil
+}
+
+// WriteSSMTunnel writes a kubeconfig that talks to the API server through the local end of the
+// tunnel, and the script that opens the tunnel. It returns the path of the script.
+func WriteSSMTunnel(conn clusterConnection, instanceID string) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("unable to find home directory: %w", err)
+	}
+	dir := filepath.Join(home, ".est", "tunnels", conn.Name)
+	kubeconfigPath := filepath.Join(dir, "kubeconfig")
+
+	// The API server certificate is issued for the endpoint host, not localhost
+	host := strings.TrimPrefix(conn.Endpoint, "https://")
+	conn.TLSServerName = host
+	conn.Endpoint = "https://localhost:" + ssmTunnelPort
+	if err := WriteKubeconfig(kubeconfigPath, conn); err != nil {
+		return "", err
+	}
+
+	scriptPath := filepath.Join(dir, "tunnel.sh")
+	f, err := os.OpenFile(scriptPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0700)
+	if err != nil {
+		return "", fmt.Errorf("unable to create tunnel script %s: %w", scriptPath, err)
+	}
+	defer f.Close()
+	err = ssmTunnelTemplate.Execute(f, map[string]string{
+		"Cluster":    conn.Name,
+		"Region":     conn.Region,
+		"Instance":   instanceID,
+		"Host":       host,
+		"Port":       ssmTunnelPort,
+		"Kubeconfig": kubeconfigPath,
+	})
+	if err != nil {
+		return "", fmt.Errorf("unable to write tunnel script %s: %w", scriptPath, err)
+	}
+	return scriptPath, nil
+}
+
+// DeleteSSMBastions terminates the SSM instances the tool launched in a VPC and removes their
+// instance profiles and roles. The instances have to go before their network interfaces can.
+func DeleteSSMBastions(ctx context.Context, region, vpcID string) error {
+	cfg, err := loadAWSConfig(ctx, region)
+	if err != nil {
+		return fmt.Errorf("unable to load AWS configuration: %w", err)
+	}
+	ec2Client := ec2.NewFromConfig(cfg)
+
+	output, err := ec2Client.DescribeInstances(ctx, &ec2.DescribeInstancesInput{
+		Filters: []ec2types.Filter{
+			{Name: aws.String("vpc-id"), Values: []string{vpcID}},
+			{Name: aws.String("tag:CreatedBy"), Values: []string{"EKS-Sandbox-Tool"}},
+			{Name: aws.String("instance-state-name"), Values: []string{"pending", "running", "stopping", "stopped"}},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("unable to describe instances: %w", err)
+	}
+	var instanceIDs, profiles []string
+	for _, reservation := range output.Reservations {
+		for _, instance := range reservation.Instances {
+			instanceIDs = append(instanceIDs, aws.ToString(instance.InstanceId))
+			if instance.IamInstanceProfile != nil {
+				arn := aws.ToString(instance.IamInstanceProfile.Arn)
+				profiles = append(profiles, arn[strings.LastIndex(arn, "/")+1:])
+			}
+		}
+	}
+	if len(instanceIDs) == 0 {
+		return nil
+	}
+
+	if _, err := ec2Client.TerminateInstances(ctx, &ec2.TerminateInstancesInput{InstanceIds: instanceIDs}); err != nil {
+		return fmt.Errorf("unable to terminate instances %s: %w", strings.Join(instanceIDs, ", "), err)
+	}
+	waiter := ec2.NewInstanceTerminatedWaiter(ec2Client)
+	if err := waiter.Wait(ctx, &ec2.DescribeInstancesInput{InstanceIds: instanceIDs}, 10*time.Minute); err != nil {
+		return fmt.Errorf("instances %s were not terminated: %w", strings.Join(instanceIDs, ", "), err)
+	}
+	successf("Terminated SSM instances %s", strings.Join(instanceIDs, ", "))
+
+	iamClient := iam.NewFromConfig(cfg)
+	for _, name := range profiles {
+		if err := deleteSSMInstanceProfile(ctx, iamClient, regionPartition(region), name); err != nil {
+			warnf("%v", err)
+		}
+	}
+	return nil
+}
+
+// deleteSSMInstanceProfile removes an instance profile created by createSSMInstanceProfile and its role
+func deleteSSMInstanceProfile(ctx context.Context, iamClient *iam.Client, partition, name string) error {
+	if _, err := iamClient.RemoveRoleFromInstanceProfile(ctx, &iam.RemoveRoleFromInstanceProfileInput{
+		InstanceProfileName: aws.String(name),
+		RoleName:            aws.String(name),
+	}); err != nil {
+		return fmt.Errorf("unable to remove role from instance profile %s: %w", name, err)
+	}
+	if _, err := iamClient.DeleteInstanceProfile(ctx, &iam.DeleteInstanceProfileInput{InstanceProfileName: aws.String(name)}); err != nil {
+		return fmt.Errorf("unable to delete instance profile %s: %w", name, err)
+	}
+	if _, err := iamClient.DetachRolePolicy(ctx, &iam.DetachRolePolicyInput{
+		RoleName:  aws.String(name),
+		PolicyArn: aws.String(managedPolicyArn(partition, ssmManagedPolicy)),
+	}); err != nil {
+		return fmt.Errorf("unable to detach policy from role %s: %w", name, err)
+	}
+	if _, err := iamClient.DeleteRole(ctx, &iam.DeleteRoleInput{RoleName: aws.String(name)}); err != nil {
+		return fmt.Errorf("unable to delete role %s: %w", name, err)
+	}
+	successf("Deleted instance profile and role %s", name)
+	return nil
+}
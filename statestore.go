@@ -0,0 +1,272 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// StateStore keeps the records of the sandboxes the tool created. The tool ships stores for a
+// local file, an S3 object and a DynamoDB table. An organization that keeps its records elsewhere,
+// e.g. in an internal CMDB, adds a file with an init function calling RegisterStateStore for a
+// scheme of its own and points inventory.location at it, without touching the orchestration code.
+type StateStore interface {
+	// Put records a sandbox, replacing any earlier record of the same cluster in the same region
+	Put(ctx context.Context, entry inventoryEntry) error
+	// Delete removes the record of a cluster, doing nothing when there is none
+	Delete(ctx context.Context, region, clusterName string) error
+	// List returns every recorded sandbox
+	List(ctx context.Context) ([]inventoryEntry, error)
+	// String tells where the records are kept, for messages
+	String() string
+}
+
+// stateStoreOpener opens the store at a location with its scheme removed. region is where the AWS
+// resource holding the store is, for stores kept in one.
+type stateStoreOpener func(location, region string) (StateStore, error)
+
+// stateStoreOpeners are the registered stores by location scheme
+var stateStoreOpeners = map[string]stateStoreOpener{}
+
+// RegisterStateStore makes a store available for locations of the form <scheme>://<location>
+func RegisterStateStore(scheme string, open stateStoreOpener) {
+	stateStoreOpeners[scheme] = open
+}
+
+func init() {
+	RegisterStateStore("file", openFileStateStore)
+	RegisterStateStore("s3", openS3StateStore)
+	RegisterStateStore("dynamodb", openDynamoDBStateStore)
+}
+
+// OpenStateStore opens the store at location. A location without a scheme is a local file.
+func OpenStateStore(location, region string) (StateStore, error) {
+	scheme, rest, ok := strings.Cut(location, "://")
+	if !ok {
+		scheme, rest = "file", location
+	}
+	open, ok := stateStoreOpeners[scheme]
+	if !ok {
+		return nil, fmt.Errorf("unknown state store %s:// in %q", scheme, location)
+	}
+	return open(rest, region)
+}
+
+// sandboxInventory is the document kept by the file and S3 stores
+type sandboxInventory struct {
+	Sandboxes []inventoryEntry `json:"sandboxes"`
+}
+
+// put replaces the record of the cluster of entry
+func (inv *sandboxInventory) put(entry inventoryEntry) {
+	inv.remove(entry.Region, entry.Name)
+	inv.Sandboxes = append(inv.Sandboxes, entry)
+}
+
+// remove drops the record of a cluster
+func (inv *sandboxInventory) remove(region, clusterName string) {
+	inv.Sandboxes = slices.DeleteFunc(inv.Sandboxes, func(e inventoryEntry) bool {
+		return e.Name == clusterName && e.Region == region
+	})
+}
+
+// fileStateStore keeps the records in a local JSON file
+type fileStateStore struct {
+	Path string
+}
+
+func openFileStateStore(location, _ string) (StateStore, error) {
+	if location == "" {
+		return nil, fmt.Errorf("the state store file has no path")
+	}
+	return fileStateStore{Path: location}, nil
+}
+
+func (s fileStateStore) String() string {
+	return s.Path
+}
+
+func (s fileStateStore) load() (sandboxInventory, error) {
+	var inv sandboxInventory
+	data, err := os.ReadFile(s.Path)
+	if os.IsNotExist(err) {
+		return inv, nil
+	}
+	if err != nil {
+		return inv, fmt.Errorf("unable to read %s: %w", s.Path, err)
+	}
+	if err := json.Unmarshal(data, &inv); err != nil {
+		return inv, fmt.Errorf("%s is not valid JSON: %w", s.Path, err)
+	}
+	return inv, nil
+}
+
+// update applies change to the file, which is replaced atomically
+func (s fileStateStore) update(change func(inv *sandboxInventory)) error {
+	inv, err := s.load()
+	if err != nil {
+		return err
+	}
+	change(&inv)
+	data, err := json.MarshalIndent(inv, "", "  ")
+	if err != nil {
+		return fmt.Errorf("unable to encode %s: %w", s.Path, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.Path), 0755); err != nil {
+		return fmt.Errorf("unable to create directory of %s: %w", s.Path, err)
+	}
+	tmp := s.Path + ".est-write"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("unable to write %s: %w", s.Path, err)
+	}
+	if err := os.Rename(tmp, s.Path); err != nil {
+		return fmt.Errorf("unable to write %s: %w", s.Path, err)
+	}
+	return nil
+}
+
+func (s fileStateStore) Put(ctx context.Context, entry inventoryEntry) error {
+	return s.update(func(inv *sandboxInventory) { inv.put(entry) })
+}
+
+func (s fileStateStore) Delete(ctx context.Context, region, clusterName string) error {
+	return s.update(func(inv *sandboxInventory) { inv.remove(region, clusterName) })
+}
+
+func (s fileStateStore) List(ctx context.Context) ([]inventoryEntry, error) {
+	inv, err := s.load()
+	return inv.Sandboxes, err
+}
+
+// s3StateAttempts bounds how often an S3 update is retried after losing a race to another writer
+const s3StateAttempts = 5
+
+// s3StateStore keeps the records in a JSON object shared by a team, using optimistic locking so
+// that concurrent runs do not lose each other's changes. S3 has no SDK client in this tool, so the
+// aws CLI is used.
+type s3StateStore struct {
+	Bucket string
+	Key    string
+	Region string
+}
+
+func openS3StateStore(location, region string) (StateStore, error) {
+	bucket, key, _ := strings.Cut(location, "/")
+	if bucket == "" || key == "" {
+		return nil, fmt.Errorf("an S3 state store must name an object, s3://<bucket>/<key>, got %q", "s3://"+location)
+	}
+	return s3StateStore{Bucket: bucket, Key: key, Region: region}, nil
+}
+
+func (s s3StateStore) String() string {
+	return "s3://" + s.Bucket + "/" + s.Key
+}
+
+// s3StateConflict reports that the object was changed by someone else between reading and writing it
+type s3StateConflict struct{}
+
+func (s3StateConflict) Error() string {
+	return "the state was changed by someone else"
+}
+
+// load reads the object. It also returns the version read, which save needs to detect concurrent
+// changes; a missing object is empty with version "".
+func (s s3StateStore) load(ctx context.Context) (sandboxInventory, string, error) {
+	var inv sandboxInventory
+	f, err := os.CreateTemp("", "est-state-")
+	if err != nil {
+		return inv, "", fmt.Errorf("unable to create temporary file: %w", err)
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+	out, err := runAWSCLI(ctx, s.Region, "s3api", "get-object", "--bucket", s.Bucket, "--key", s.Key, f.Name())
+	if err != nil {
+		if strings.Contains(err.Error(), "NoSuchKey") {
+			return inv, "", nil
+		}
+		return inv, "", fmt.Errorf("unable to read %s: %w", s, err)
+	}
+	var object struct {
+		ETag string
+	}
+	if err := json.Unmarshal([]byte(out), &object); err != nil {
+		return inv, "", fmt.Errorf("unable to read %s: %w", s, err)
+	}
+	data, err := os.ReadFile(f.Name())
+	if err != nil {
+		return inv, "", fmt.Errorf("unable to read %s: %w", s, err)
+	}
+	if err := json.Unmarshal(data, &inv); err != nil {
+		return inv, "", fmt.Errorf("%s is not valid JSON: %w", s, err)
+	}
+	return inv, object.ETag, nil
+}
+
+// save writes the object. The write only succeeds when the object is still at the version read,
+// or still missing when version is "", and fails with s3StateConflict otherwise.
+func (s s3StateStore) save(ctx context.Context, inv sandboxInventory, version string) error {
+	data, err := json.MarshalIndent(inv, "", "  ")
+	if err != nil {
+		return fmt.Errorf("unable to encode %s: %w", s, err)
+	}
+	f, err := os.CreateTemp("", "est-state-")
+	if err != nil {
+		return fmt.Errorf("unable to create temporary file: %w", err)
+	}
+	defer os.Remove(f.Name())
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("unable to write temporary file: %w", err)
+	}
+	args := []string{"s3api", "put-object", "--bucket", s.Bucket, "--key", s.Key, "--body", f.Name(), "--content-type", "application/json"}
+	if version == "" {
+		args = append(args, "--if-none-match", "*")
+	} else {
+		args = append(args, "--if-match", version)
+	}
+	if _, err := runAWSCLI(ctx, s.Region, args...); err != nil {
+		if strings.Contains(err.Error(), "PreconditionFailed") || strings.Contains(err.Error(), "ConditionalRequestConflict") {
+			return s3StateConflict{}
+		}
+		return fmt.Errorf("unable to write %s: %w", s, err)
+	}
+	return nil
+}
+
+// update applies change to the latest object and saves it, starting over when another writer got
+// there first
+func (s s3StateStore) update(ctx context.Context, change func(inv *sandboxInventory)) error {
+	for attempt := 1; ; attempt++ {
+		inv, version, err := s.load(ctx)
+		if err != nil {
+			return err
+		}
+		change(&inv)
+		err = s.save(ctx, inv, version)
+		if !errors.As(err, &s3StateConflict{}) || attempt == s3StateAttempts {
+			return err
+		}
+		debugf("%s changed while updating it, retrying (attempt %d)", s, attempt)
+	}
+}
+
+func (s s3StateStore) Put(ctx context.Context, entry inventoryEntry) error {
+	return s.update(ctx, func(inv *sandboxInventory) { inv.put(entry) })
+}
+
+func (s s3StateStore) Delete(ctx context.Context, region, clusterName string) error {
+	return s.update(ctx, func(inv *sandboxInventory) { inv.remove(region, clusterName) })
+}
+
+func (s s3StateStore) List(ctx context.Context) ([]inventoryEntry, error) {
+	inv, _, err := s.load(ctx)
+	return inv.Sandboxes, err
+}
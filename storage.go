@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	eks "github.com/aws/aws-sdk-go-v2/service/eks"
+	"github.com/aws/aws-sdk-go-v2/service/eks/types"
+)
+
+// EBS CSI provisioners: auto mode clusters bring their own, other clusters get it from the addon
+const (
+	ebsAutoModeProvisioner = "ebs.csi.eks.amazonaws.com"
+	ebsAddonProvisioner    = "ebs.csi.aws.com"
+	ebsAddonName           = "aws-ebs-csi-driver"
+)
+
+// gp3StorageClass is the default StorageClass given to clusters that can provision EBS volumes.
+// Volumes are encrypted and only created once a pod using them is scheduled, so they end up in
+// the pod's availability zone.
+const gp3StorageClass = `apiVersion: storage.k8s.io/v1
+kind: StorageClass
+metadata:
+  name: gp3
+  annotations:
+    storageclass.kubernetes.io/is-default-class: "true"
+  labels:
+    app.kubernetes.io/managed-by: est
+provisioner: %s
+parameters:
+  type: gp3
+  encrypted: "true"
+  csi.storage.k8s.io/fstype: ext4
+volumeBindingMode: WaitForFirstConsumer
+allowVolumeExpansion: true
+reclaimPolicy: Delete
+`
+
+// ConfigureDefaultStorageClass makes a gp3 StorageClass the cluster default, so PersistentVolumeClaims
+// without a storage class bind. Clusters without an EBS CSI driver are left alone. The older gp2
+// class EKS creates stops being the default.
+func ConfigureDefaultStorageClass(ctx context.Context, region, clusterName string, autoMode bool) error {
+	provisioner := ebsAutoModeProvisioner
+	if !autoMode {
+		installed, err := hasAddon(ctx, region, clusterName, ebsAddonName)
+		if err != nil {
+			return err
+		}
+		if !installed {
+			debugf("no EBS CSI driver on %s, not adding a default StorageClass", clusterName)
+			return nil
+		}
+		provisioner = ebsAddonProvisioner
+	}
+
+	kube, err := newKubectlSession(ctx, region, clusterName)
+	if err != nil {
+		return err
+	}
+	defer kube.Close()
+
+	if err := kube.Apply(ctx, fmt.Sprintf(gp3StorageClass, provisioner)); err != nil {
+		return err
+	}
+	_, err = kube.Run(ctx, "annotate", "storageclass", "gp2", "storageclass.kubernetes.io/is-default-class=false", "--overwrite")
+	if err != nil && !strings.Contains(err.Error(), "NotFound") {
+		return err
+	}
+	successf("gp3 is the default StorageClass")
+	return nil
+}
+
+// hasAddon reports whether an addon is installed on the cluster
+func hasAddon(ctx context.Context, region, clusterName, addonName string) (bool, error) {
+	cfg, err := loadAWSConfig(ctx, region)
+	if err != nil {
+		return false, fmt.Errorf("unable to load AWS configuration: %w", err)
+	}
+	_, err = eks.NewFromConfig(cfg).DescribeAddon(ctx, &eks.DescribeAddonInput{
+		ClusterName: aws.String(clusterName),
+		AddonName:   aws.String(addonName),
+	})
+	var notFound *types.ResourceNotFoundException
+	if errors.As(err, &notFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("unable to describe addon %s: %w", addonName, err)
+	}
+	return true, nil
+}
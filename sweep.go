@@ -0,0 +1,277 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	eks "github.com/aws/aws-sdk-go-v2/service/eks"
+)
+
+// sweepWorkers bounds how many accounts are swept at the same time
+const sweepWorkers = 4
+
+// sweepRequest describes an organization-wide sweep of expired clusters
+type sweepRequest struct {
+	OU      string
+	Role    string
+	Regions []string
+	MaxAge  time.Duration
+	Delete  bool
+}
+
+// orgAccount is an active member account of an organization
+type orgAccount struct {
+	ID   string
+	Name string
+}
+
+// runSweep implements `est sweep`
+func runSweep(ctx context.Context, args []string) error {
+	usage := validationErr("usage: est sweep --ou <ou-id> [--role <name>] [--region <region>...] [--max-age <duration>] [--delete]")
+
+	var req sweepRequest
+	var regions stringList
+	fs := flag.NewFlagSet("sweep", flag.ContinueOnError)
+	fs.StringVar(&req.OU, "ou", "", "Organizational unit whose accounts, including those of nested OUs, are swept")
+	fs.StringVar(&req.Role, "role", "OrganizationAccountAccessRole", "Name of the role assumed in every member account")
+	fs.Var(&regions, "region", "Region to sweep (repeatable, default EST_REGION or AWS_REGION)")
+	fs.DurationVar(&req.MaxAge, "max-age", 7*24*time.Hour, "Clusters created by this tool longer ago than this, or past their ExpiresAt tag, are expired")
+	fs.BoolVar(&req.Delete, "delete", false, "Delete the expired clusters and the VPCs created for them instead of only listing them")
+	if err := fs.Parse(args); err != nil {
+		return usage
+	}
+	req.Regions = regions
+	if len(req.Regions) == 0 {
+		if region := firstNonEmpty(os.Getenv("EST_REGION"), os.Getenv("AWS_REGION")); region != "" {
+			req.Regions = []string{region}
+		}
+	}
+	if req.OU == "" || len(req.Regions) == 0 {
+		return usage
+	}
+	if req.MaxAge <= 0 {
+		return validationErr("--max-age must be positive")
+	}
+	if req.Delete {
+		if err := requireWritable("est sweep --delete"); err != nil {
+			return err
+		}
+	}
+
+	_, callerID, err := GetAWSAccountDetails(ctx, req.Regions[0])
+	if err != nil {
+		return wrapErr("fetching AWS Account ID", err)
+	}
+	partition := "aws"
+	if parts := strings.SplitN(callerID, ":", 3); len(parts) == 3 {
+		partition = parts[1]
+	}
+
+	stepf("Listing the accounts of %s...", req.OU)
+	accounts, err := ListOUAccounts(ctx, req.Regions[0], req.OU)
+	if err != nil {
+		return wrapErr("listing organization accounts", err)
+	}
+	infof("Sweeping %d accounts in %s for clusters older than %s", len(accounts), strings.Join(req.Regions, ", "), req.MaxAge)
+	if !req.Delete {
+		infof("Listing only, pass --delete to delete the expired clusters")
+	}
+
+	byID := map[string]orgAccount{}
+	var ids []string
+	for _, account := range accounts {
+		byID[account.ID] = account
+		ids = append(ids, account.ID)
+	}
+	var mu sync.Mutex
+	var expired, deleted int
+	err = forEachParallel(ids, sweepWorkers, func(id string) error {
+		account := byID[id]
+		accountCtx := withAccountRole(ctx, fmt.Sprintf("arn:%s:iam::%s:role/%s", partition, account.ID, req.Role))
+		found, removed, err := sweepAccount(accountCtx, account, req)
+		mu.Lock()
+		expired, deleted = expired+found, deleted+removed
+		mu.Unlock()
+		return err
+	})
+
+	if req.Delete {
+		successf("Deleted %d of %d expired clusters", deleted, expired)
+	} else {
+		successf("Found %d expired clusters", expired)
+	}
+	if err != nil {
+		return partialErr("sweeping organization", err)
+	}
+	return nil
+}
+
+// sweepAccount lists the expired clusters of one account in every region of the sweep and deletes
+// them when asked to. It returns how many clusters were expired and how many were deleted.
+func sweepAccount(ctx context.Context, account orgAccount, req sweepRequest) (int, int, error) {
+	var expired, deleted int
+	var errs []error
+	for _, region := range req.Regions {
+		clusters, err := ListEKSClusters(ctx, region)
+		if err != nil {
+			warnf("[%s %s] unable to list clusters: %v", account.ID, region, err)
+			errs = append(errs, fmt.Errorf("account %s (%s) in %s: %w", account.ID, account.Name, region, err))
+			continue
+		}
+		for _, clusterName := range clusters {
+			age, tags, err := toolClusterAge(ctx, region, clusterName)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("account %s cluster %s: %w", account.ID, clusterName, err))
+				continue
+			}
+			if tags == nil || (age < req.MaxAge && !pastExpiry(tags)) {
+				continue
+			}
+			expired++
+			infof("[%s %s] %s (%s) is %s old", account.ID, region, clusterName, account.Name, age.Round(time.Hour))
+			if !req.Delete {
+				continue
+			}
+			if err := sweepCluster(ctx, region, clusterName, tags); err != nil {
+				errorf("[%s %s] unable to delete %s: %v", account.ID, region, clusterName, err)
+				errs = append(errs, fmt.Errorf("account %s cluster %s: %w", account.ID, clusterName, err))
+				continue
+			}
+			deleted++
+			successf("[%s %s] deleted %s", account.ID, region, clusterName)
+		}
+	}
+	if len(errs) > 0 {
+		return expired, deleted, fmt.Errorf("%d problems in account %s: %w", len(errs), account.ID, errors.Join(errs...))
+	}
+	return expired, deleted, nil
+}
+
+// pastExpiry reports whether a cluster carries an ExpiresAt tag, set when the guardrails mandate a
+// TTL, that has passed
+func pastExpiry(tags map[string]string) bool {
+	expiresAt, err := time.Parse(time.RFC3339, tags["ExpiresAt"])
+	return err == nil && time.Now().After(expiresAt)
+}
+
+// toolClusterAge returns the age and tags of a cluster created by this tool. Clusters created
+// otherwise are never swept and are returned with nil tags.
+func toolClusterAge(ctx context.Context, region, clusterName string) (time.Duration, map[string]string, error) {
+	cfg, err := loadAWSConfig(ctx, region)
+	if err != nil {
+		return 0, nil, fmt.Errorf("unable to load AWS configuration: %w", err)
+	}
+	output, err := eks.NewFromConfig(cfg).DescribeCluster(ctx, &eks.DescribeClusterInput{Name: aws.String(clusterName)})
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to describe EKS cluster %s: %w", clusterName, err)
+	}
+	cluster := output.Cluster
+	if cluster.Tags["CreatedBy"] != "EKS-Sandbox-Tool" || cluster.CreatedAt == nil {
+		return 0, nil, nil
+	}
+	return time.Since(*cluster.CreatedAt), cluster.Tags, nil
+}
+
+// sweepCluster deletes a cluster and, when it was created in an isolated VPC or was the last
+// cluster of the shared VPC, the VPC as well
+func sweepCluster(ctx context.Context, region, clusterName string, tags map[string]string) error {
+	toolVPC := (tags["HostingVPC"] == "isolated" || tags["HostingVPC"] == hostingVPCShared) && tags["VpcId"] != ""
+	// The tag can only be checked against the cluster while it exists
+	var tagErr error
+	if toolVPC {
+		tagErr = CheckClusterVPCTag(ctx, region, clusterName, tags["VpcId"])
+	}
+	if err := runHooks(ctx, hookPreDelete, region, clusterName); err != nil {
+		return err
+	}
+	if err := DeleteEKSCluster(ctx, region, clusterName); err != nil {
+		return err
+	}
+	// The inventory belongs to the caller's account, not to member accounts swept through a role
+	if accountRole(ctx) == "" {
+		forgetSandbox(ctx, region, clusterName)
+	}
+	CleanupCatalogueAddons(ctx, region, clusterName, pluginsFromTags(tags))
+	CleanupComputeRoles(ctx, region, clusterName)
+	CleanupPauseSchedules(ctx, region, clusterName)
+	DeleteEncryptionKey(ctx, region, clusterName)
+	runPostHooks(ctx, hookPostDelete, region, clusterName)
+	if !toolVPC {
+		return nil
+	}
+	if tagErr != nil {
+		return fmt.Errorf("cluster deleted but not its VPC: %w", tagErr)
+	}
+	if tags["HostingVPC"] == hostingVPCShared {
+		// The shared VPC goes with the last of its clusters
+		remaining, err := SharedVPCClusters(ctx, region, tags["VpcId"])
+		if err != nil {
+			return fmt.Errorf("cluster deleted but not its VPC: %w", err)
+		}
+		if len(remaining) > 0 {
+			infof("Shared VPC %s is kept for %d other clusters", tags["VpcId"], len(remaining))
+			return nil
+		}
+	}
+	if err := CheckVPCOwner(ctx, region, tags["VpcId"], clusterName); err != nil {
+		return fmt.Errorf("cluster deleted but not its VPC: %w", err)
+	}
+	if err := DeleteVPC(ctx, region, tags["VpcId"], clusterName); err != nil {
+		return fmt.Errorf("cluster deleted but not its VPC %s: %w", tags["VpcId"], err)
+	}
+	return nil
+}
+
+// ListOUAccounts returns the active accounts of an organizational unit and of the OUs nested in it.
+// The tool has no Organizations SDK client, so the aws CLI is used.
+func ListOUAccounts(ctx context.Context, region, ou string) ([]orgAccount, error) {
+	out, err := runAWSCLI(ctx, region, "organizations", "list-accounts-for-parent", "--parent-id", ou)
+	if err != nil {
+		return nil, err
+	}
+	var page struct {
+		Accounts []struct {
+			Id     string
+			Name   string
+			Status string
+		}
+	}
+	if err := json.Unmarshal([]byte(out), &page); err != nil {
+		return nil, fmt.Errorf("unable to parse accounts of %s: %w", ou, err)
+	}
+	var accounts []orgAccount
+	for _, account := range page.Accounts {
+		if account.Status == "ACTIVE" {
+			accounts = append(accounts, orgAccount{ID: account.Id, Name: account.Name})
+		}
+	}
+
+	out, err = runAWSCLI(ctx, region, "organizations", "list-organizational-units-for-parent", "--parent-id", ou)
+	if err != nil {
+		return nil, err
+	}
+	var children struct {
+		OrganizationalUnits []struct {
+			Id string
+		}
+	}
+	if err := json.Unmarshal([]byte(out), &children); err != nil {
+		return nil, fmt.Errorf("unable to parse organizational units of %s: %w", ou, err)
+	}
+	for _, child := range children.OrganizationalUnits {
+		nested, err := ListOUAccounts(ctx, region, child.Id)
+		if err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, nested...)
+	}
+	return accounts, nil
+}
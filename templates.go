@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// clusterTemplate is a named bundle of prompt answers that clusters can be created from,
+// so a team can share one golden sandbox definition
+type clusterTemplate struct {
+	Name    string            `json:"name"`
+	Answers map[string]string `json:"answers"`
+}
+
+var templateNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// templatesDir returns the directory templates are stored in, ~/.est/templates
+func templatesDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("unable to find home directory: %w", err)
+	}
+	return filepath.Join(home, ".est", "templates"), nil
+}
+
+func templatePath(name string) (string, error) {
+	if !templateNamePattern.MatchString(name) {
+		return "", validationErr("invalid template name %q: use letters, digits, '.', '_' and '-'", name)
+	}
+	dir, err := templatesDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name+".json"), nil
+}
+
+// saveTemplate writes a template, replacing any existing template with the same name
+func saveTemplate(t clusterTemplate) error {
+	path, err := templatePath(t.Name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("unable to create templates directory: %w", err)
+	}
+	data, err := json.MarshalIndent(t, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// loadTemplate reads the named template
+func loadTemplate(name string) (clusterTemplate, error) {
+	var t clusterTemplate
+	path, err := templatePath(name)
+	if err != nil {
+		return t, err
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return t, validationErr("template %q does not exist", name)
+	}
+	if err != nil {
+		return t, fmt.Errorf("unable to read template %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, &t); err != nil {
+		return t, validationErr("template %s is not valid JSON: %v", path, err)
+	}
+	return t, nil
+}
+
+// listTemplates returns all saved templates sorted by name
+func listTemplates() ([]clusterTemplate, error) {
+	dir, err := templatesDir()
+	if err != nil {
+		return nil, err
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	var templates []clusterTemplate
+	for _, path := range paths {
+		t, err := loadTemplate(strings.TrimSuffix(filepath.Base(path), ".json"))
+		if err != nil {
+			return nil, err
+		}
+		templates = append(templates, t)
+	}
+	return templates, nil
+}
+
+// runTemplate implements `est template save|list|apply`
+func runTemplate(ctx context.Context, timer *stepTimer, args []string) error {
+	usage := validationErr("usage: est template save <name> | list | apply <name>")
+	if len(args) == 0 {
+		return usage
+	}
+
+	switch args[0] {
+	case "save":
+		if len(args) != 2 {
+			return usage
+		}
+		if _, err := templatePath(args[1]); err != nil {
+			return err
+		}
+		if _, err := collectCreateAnswers(ctx, false); err != nil {
+			return err
+		}
+		t := clusterTemplate{Name: args[1], Answers: map[string]string{}}
+		for key, value := range givenAnswers {
+			t.Answers[key] = value
+		}
+		if err := saveTemplate(t); err != nil {
+			return fmt.Errorf("saving template: %w", err)
+		}
+		successf("Saved template %q", t.Name)
+		return nil
+
+	case "list":
+		templates, err := listTemplates()
+		if err != nil {
+			return fmt.Errorf("listing templates: %w", err)
+		}
+		if len(templates) == 0 {
+			infof("No templates saved yet. Create one with: est template save <name>")
+			return nil
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "NAME\tANSWERS")
+		for _, t := range templates {
+			var keys []string
+			for key := range t.Answers {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			var pairs []string
+			for _, key := range keys {
+				pairs = append(pairs, key+"="+t.Answers[key])
+			}
+			fmt.Fprintf(tw, "%s\t%s\n", t.Name, strings.Join(pairs, " "))
+		}
+		return tw.Flush()
+
+	case "apply":
+		if len(args) != 2 {
+			return usage
+		}
+		t, err := loadTemplate(args[1])
+		if err != nil {
+			return err
+		}
+		for key, value := range t.Answers {
+			presetAnswers[key] = value
+		}
+		stepf("Creating a cluster from template %q", t.Name)
+		if err := runCreate(ctx, timer); err != nil {
+			return err
+		}
+		return timer.PrintSummary(summaryWriter(), *outputFormat)
+
+	default:
+		return usage
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/smithy-go/middleware"
+)
+
+// batchOperationPrefixes are the operations issued in bulk during cleanups, which are the ones
+// that trip EC2 API throttling in busy accounts
+var batchOperationPrefixes = []string{"Delete", "Detach", "Disassociate", "Release", "Revoke", "Terminate"}
+
+// apiLimiter spaces out batch API calls shared by every client in the process. When AWS reports
+// throttling, all callers pause for a jittered backoff that grows while throttling continues.
+type apiLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+	streak   int
+}
+
+var batchLimiter = &apiLimiter{}
+
+// SetRate sets how many calls may start per second
+func (l *apiLimiter) SetRate(perSecond float64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.interval = time.Duration(float64(time.Second) / perSecond)
+}
+
+// Wait blocks until the caller may issue its next call
+func (l *apiLimiter) Wait(ctx context.Context) error {
+	l.mu.Lock()
+	now := time.Now()
+	start := l.next
+	if start.Before(now) {
+		start = now
+	}
+	l.next = start.Add(l.interval)
+	l.mu.Unlock()
+
+	delay := time.Until(start)
+	if delay <= 0 {
+		return nil
+	}
+	t := time.NewTimer(delay)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Done records the outcome of a call, backing off every caller when it was throttled
+func (l *apiLimiter) Done(throttled bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !throttled {
+		l.streak = 0
+		return
+	}
+	l.streak++
+	ceiling := time.Duration(1<<min(l.streak, 5)) * time.Second
+	backoff := ceiling/2 + time.Duration(rand.Int63n(int64(ceiling/2)))
+	if until := time.Now().Add(backoff); l.next.Before(until) {
+		l.next = until
+	}
+	debugf("AWS throttled batch calls, pausing them for %s", backoff.Round(time.Millisecond))
+}
+
+// addBatchRateLimit rate limits every attempt of a batch operation, after the retryer so retries are paced too
+func addBatchRateLimit(stack *middleware.Stack) error {
+	return stack.Finalize.Insert(middleware.FinalizeMiddlewareFunc("EstBatchRateLimit", limitBatchCall), "Retry", middleware.After)
+}
+
+func limitBatchCall(ctx context.Context, in middleware.FinalizeInput, next middleware.FinalizeHandler) (middleware.FinalizeOutput, middleware.Metadata, error) {
+	if !isBatchOperation(awsmiddleware.GetOperationName(ctx)) {
+		return next.HandleFinalize(ctx, in)
+	}
+	if err := batchLimiter.Wait(ctx); err != nil {
+		return middleware.FinalizeOutput{}, middleware.Metadata{}, err
+	}
+	out, metadata, err := next.HandleFinalize(ctx, in)
+	batchLimiter.Done(err != nil && retry.IsErrorThrottles(retry.DefaultThrottles).IsErrorThrottle(err) == aws.TrueTernary)
+	return out, metadata, err
+}
+
+func isBatchOperation(name string) bool {
+	for _, prefix := range batchOperationPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+var timestamps = flag.Bool("timestamps", false, "Prefix progress lines with the time and the time elapsed since the run started")
+
+var (
+	runTimeline     io.Writer = io.Discard
+	runTimelinePath string
+	runStarted      = time.Now()
+)
+
+// openTimeline starts the timeline file of this run next to its log. The timeline only holds
+// progress events, step boundaries and warnings, so it can be pasted into a bug report as is.
+// It is called with runLogMu held.
+func openTimeline(path string) (io.Closer, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create timeline file: %w", err)
+	}
+	runTimeline, runTimelinePath = f, path
+	fmt.Fprintf(f, "est run started %s, arguments %q\n", runStarted.Format(time.RFC3339), os.Args[1:])
+	return f, nil
+}
+
+// timelineEvent appends an event to the run timeline
+func timelineEvent(kind, msg string) {
+	runLogMu.Lock()
+	defer runLogMu.Unlock()
+	fmt.Fprintf(runTimeline, "%s %s %-5s %s\n", time.Now().Format("15:04:05"), elapsedLabel(), kind, msg)
+}
+
+// elapsedLabel returns the time since the run started, e.g. "+03:12"
+func elapsedLabel() string {
+	d := time.Since(runStarted).Round(time.Second)
+	return fmt.Sprintf("+%02d:%02d", int(d.Minutes()), int(d.Seconds())%60)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// stepTiming is the recorded duration of a single provisioning step
+type stepTiming struct {
+	Name     string        `json:"name"`
+	Duration time.Duration `json:"-"`
+	Seconds  float64       `json:"seconds"`
+}
+
+// stepTimer records how long each provisioning step takes so a summary can be printed at the end of a run
+type stepTimer struct {
+	started time.Time
+	steps   []stepTiming
+	// operation and plan are set by Plan for the runs that estimate their time left
+	operation string
+	plan      []string
+	history   stepHistory
+}
+
+func newStepTimer() *stepTimer {
+	return &stepTimer{started: time.Now()}
+}
+
+// Start begins timing the named step and returns a function that stops it
+func (t *stepTimer) Start(name string) func() {
+	t.printETA(name)
+	ciGroup(name)
+	timelineEvent("BEGIN", name)
+	begin := time.Now()
+	return func() {
+		ciEndGroup()
+		d := time.Since(begin)
+		timelineEvent("END", fmt.Sprintf("%s after %s", name, formatDuration(d)))
+		t.steps = append(t.steps, stepTiming{Name: name, Duration: d, Seconds: d.Seconds()})
+		if t.operation != "" {
+			recordStepDuration(t.operation, name, d)
+		}
+	}
+}
+
+// Total returns the time elapsed since the timer was created
+func (t *stepTimer) Total() time.Duration {
+	return time.Since(t.started)
+}
+
+// PrintSummary writes the recorded step durations as a table, or as a JSON document when format is "json"
+func (t *stepTimer) PrintSummary(w io.Writer, format string) error {
+	total := t.Total()
+
+	if format == "json" {
+		return json.NewEncoder(w).Encode(struct {
+			Steps        []stepTiming `json:"steps"`
+			TotalSeconds float64      `json:"totalSeconds"`
+		}{t.steps, total.Seconds()})
+	}
+
+	var parts []string
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "\nStep\tDuration")
+	for _, s := range t.steps {
+		fmt.Fprintf(tw, "%s\t%s\n", s.Name, formatDuration(s.Duration))
+		parts = append(parts, fmt.Sprintf("%s %s", s.Name, formatDuration(s.Duration)))
+	}
+	fmt.Fprintf(tw, "total\t%s\n", formatDuration(total))
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	parts = append(parts, "total "+formatDuration(total))
+	_, err := fmt.Fprintln(w, strings.Join(parts, ", "))
+	return err
+}
+
+// formatDuration rounds a duration to whole seconds, e.g. "9m12s"
+func formatDuration(d time.Duration) string {
+	return d.Round(time.Second).String()
+}
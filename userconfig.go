@@ -0,0 +1,217 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var configFile = flag.String("config", "", "Path to the est configuration file (default ~/.est/config.json)")
+
+// estConfig holds user settings read from the configuration file
+type estConfig struct {
+	Retry      retryConfig      `json:"retry"`
+	RateLimit  rateLimitConfig  `json:"rate_limit"`
+	Network    networkConfig    `json:"network"`
+	IAM        iamConfig        `json:"iam"`
+	Polling    pollConfig       `json:"polling"`
+	Kubeconfig kubeconfigConfig `json:"kubeconfig"`
+	Policy     policyConfig     `json:"policy"`
+	Naming     namingConfig     `json:"naming"`
+	Guardrails guardrailsConfig `json:"guardrails"`
+	Inventory  inventoryConfig  `json:"inventory"`
+	Registry   registryConfig   `json:"registry"`
+	Hooks      hooksConfig      `json:"hooks"`
+	// ClusterPrefix is put in front of the cluster names given at creation time; it may be empty
+	ClusterPrefix string `json:"cluster_prefix"`
+	// ConfirmDefaults overrides the default answer of yes/no prompts, keyed like templates, e.g. "auto_mode"
+	ConfirmDefaults map[string]bool `json:"confirm_defaults"`
+}
+
+// retryConfig tunes how AWS API calls are retried
+type retryConfig struct {
+	// Mode is "standard" or "adaptive". Adaptive mode also slows the client down when it is throttled.
+	Mode string `json:"mode"`
+	// MaxAttempts is the number of attempts per call, including the first one
+	MaxAttempts int `json:"max_attempts"`
+	// MaxBackoffSeconds caps the delay between two attempts
+	MaxBackoffSeconds int `json:"max_backoff_seconds"`
+}
+
+// rateLimitConfig paces the delete, detach and release calls issued in bulk during cleanups
+type rateLimitConfig struct {
+	// BatchRequestsPerSecond is the highest rate of batch calls; 0 disables the limiter
+	BatchRequestsPerSecond float64 `json:"batch_requests_per_second"`
+}
+
+// iamConfig holds organisation requirements for the IAM roles est creates
+type iamConfig struct {
+	// PermissionsBoundary is the ARN of the managed policy set as permissions boundary on every created role
+	PermissionsBoundary string `json:"permissions_boundary"`
+	// RequirePermissionsBoundary refuses to create roles when no boundary is configured
+	RequirePermissionsBoundary bool `json:"require_permissions_boundary"`
+	// ExtraPolicyArns are managed policies attached to the cluster role next to the EKS policies
+	ExtraPolicyArns []string `json:"extra_policy_arns"`
+	// InlinePolicyFile is a JSON policy document put on the cluster role as an inline policy
+	InlinePolicyFile string `json:"inline_policy_file"`
+	// ExtraTrustPrincipals are service principals the cluster role trusts next to EKS
+	ExtraTrustPrincipals []string `json:"extra_trust_principals"`
+}
+
+// checkPermissionsBoundary fails when the account requires permissions boundaries and none is configured
+func (c iamConfig) checkPermissionsBoundary() error {
+	if c.RequirePermissionsBoundary && c.PermissionsBoundary == "" {
+		return validationErr("iam.require_permissions_boundary is set but iam.permissions_boundary is empty; add the boundary policy ARN to the config file")
+	}
+	return nil
+}
+
+// inlinePolicy reads and checks the inline policy document
+func (c iamConfig) inlinePolicy() (string, error) {
+	data, err := os.ReadFile(c.InlinePolicyFile)
+	if err != nil {
+		return "", fmt.Errorf("iam.inline_policy_file: %v", err)
+	}
+	if !json.Valid(data) {
+		return "", fmt.Errorf("iam.inline_policy_file %s is not valid JSON", c.InlinePolicyFile)
+	}
+	return string(data), nil
+}
+
+// userConfig is the configuration in effect for this run
+var userConfig = defaultConfig()
+
+// defaultConfig returns the settings used when the configuration file does not set them. The retry
+// defaults allow more attempts than the SDK so throttling bursts during VPC teardown are ridden out.
+func defaultConfig() estConfig {
+	return estConfig{
+		ClusterPrefix: "Sandbox-",
+		Retry: retryConfig{
+			Mode:              "standard",
+			MaxAttempts:       10,
+			MaxBackoffSeconds: 30,
+		},
+		RateLimit: rateLimitConfig{
+			BatchRequestsPerSecond: 5,
+		},
+		Polling: pollConfig{
+			IntervalSeconds: 10,
+			ProgressSeconds: 60,
+		},
+		Kubeconfig: kubeconfigConfig{
+			ContextName: "{{ .Arn }}",
+			Auth:        "exec",
+		},
+		Naming: namingConfig{
+			VPC:               "{{ .Cluster }}-VPC-{{ .Date }}",
+			Subnet:            "{{ .Cluster }}-Subnet-{{ .Index }}",
+			PrivateSubnet:     "{{ .Cluster }}-Private-Subnet-{{ .Index }}",
+			LocalZoneSubnet:   "{{ .Cluster }}-LocalZone-Subnet-{{ .Index }}",
+			PodSubnet:         "{{ .Cluster }}-Pod-Subnet-{{ .Index }}",
+			InternetGateway:   "{{ .Cluster }}-IGW",
+			NATGateway:        "{{ .Cluster }}-NAT",
+			RouteTable:        "{{ .Cluster }}-Route-Table",
+			PrivateRouteTable: "{{ .Cluster }}-Private-Route-Table",
+			SecurityGroup:     "{{ .Cluster }}-SG",
+		},
+	}
+}
+
+// loadUserConfig reads the configuration file over the defaults. A missing default file is not an error.
+func loadUserConfig() error {
+	path := *configFile
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil
+		}
+		path = filepath.Join(home, ".est", "config.json")
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) && *configFile == "" {
+		return nil
+	}
+	if err != nil {
+		return validationErr("unable to read config file: %v", err)
+	}
+
+	cfg := defaultConfig()
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return validationErr("config file %s is not valid JSON: %v", path, err)
+	}
+	if err := cfg.validate(); err != nil {
+		return validationErr("config file %s: %v", path, err)
+	}
+	userConfig = cfg
+	debugf("loaded configuration from %s", path)
+	return nil
+}
+
+func (c estConfig) validate() error {
+	if c.Retry.Mode != "standard" && c.Retry.Mode != "adaptive" {
+		return fmt.Errorf("retry.mode must be standard or adaptive, got %q", c.Retry.Mode)
+	}
+	if c.Retry.MaxAttempts < 1 {
+		return fmt.Errorf("retry.max_attempts must be at least 1")
+	}
+	if c.Retry.MaxBackoffSeconds < 1 {
+		return fmt.Errorf("retry.max_backoff_seconds must be at least 1")
+	}
+	if c.RateLimit.BatchRequestsPerSecond < 0 {
+		return fmt.Errorf("rate_limit.batch_requests_per_second cannot be negative")
+	}
+	if p := c.ClusterPrefix; p != "" && !clusterNamePattern.MatchString(p) {
+		return fmt.Errorf("cluster_prefix must start with a letter or digit and hold only letters, digits, hyphens and underscores, got %q", p)
+	}
+	if len(c.ClusterPrefix) >= 100 {
+		return fmt.Errorf("cluster_prefix must leave room for a name within the 100 character limit of EKS")
+	}
+	if c.Polling.IntervalSeconds < 1 {
+		return fmt.Errorf("polling.interval_seconds must be at least 1")
+	}
+	if c.Polling.ProgressSeconds < 0 {
+		return fmt.Errorf("polling.progress_seconds cannot be negative")
+	}
+	if b := c.IAM.PermissionsBoundary; b != "" && !strings.HasPrefix(b, "arn:") {
+		return fmt.Errorf("iam.permissions_boundary must be a policy ARN, got %q", b)
+	}
+	for _, arn := range c.IAM.ExtraPolicyArns {
+		if !strings.HasPrefix(arn, "arn:") {
+			return fmt.Errorf("iam.extra_policy_arns must contain policy ARNs, got %q", arn)
+		}
+	}
+	for _, principal := range c.IAM.ExtraTrustPrincipals {
+		if !strings.HasSuffix(principal, ".amazonaws.com") && !strings.HasSuffix(principal, ".amazonaws.com.cn") {
+			return fmt.Errorf("iam.extra_trust_principals must contain service principals such as pods.eks.amazonaws.com, got %q", principal)
+		}
+	}
+	if c.IAM.InlinePolicyFile != "" {
+		if _, err := c.IAM.inlinePolicy(); err != nil {
+			return err
+		}
+	}
+	if err := c.Kubeconfig.validate(); err != nil {
+		return err
+	}
+	if err := c.Policy.validate(); err != nil {
+		return err
+	}
+	if err := c.Naming.validate(); err != nil {
+		return err
+	}
+	if err := c.Inventory.validate(); err != nil {
+		return err
+	}
+	if err := c.Hooks.validate(); err != nil {
+		return err
+	}
+	for key := range c.ConfirmDefaults {
+		if _, ok := confirmPrompts["EST_"+strings.ToUpper(key)]; !ok {
+			return fmt.Errorf("confirm_defaults.%s is not a yes/no prompt with a configurable default", key)
+		}
+	}
+	return c.Network.validate()
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// veleroVersion is the Velero chart installed from the catalogue, with the AWS plugin that stores
+// backups in S3
+const (
+	veleroVersion        = "8.3.0"
+	veleroChartRepo      = "https://vmware-tanzu.github.io/helm-charts"
+	veleroAWSPluginImage = "velero/velero-plugin-for-aws:v1.11.0"
+)
+
+// Velero runs as this service account, which gets the backup bucket role through IRSA
+const (
+	veleroNamespace      = "velero"
+	veleroServiceAccount = "velero"
+	veleroPolicyName     = "est-velero-backups"
+)
+
+// veleroValues configures Velero to keep backups in the sandbox bucket with the role of its
+// service account. Volumes are backed up by the node agent into the bucket rather than as EBS
+// snapshots, which would outlive the sandbox.
+const veleroValues = `initContainers:
+- name: velero-plugin-for-aws
+  image: %[1]s
+  volumeMounts:
+  - mountPath: /target
+    name: plugins
+configuration:
+  backupStorageLocation:
+  - name: default
+    provider: aws
+    bucket: %[2]s
+    config:
+      region: %[3]s
+  volumeSnapshotLocation: []
+  defaultVolumesToFsBackup: true
+snapshotsEnabled: false
+deployNodeAgent: true
+credentials:
+  useSecret: false
+serviceAccount:
+  server:
+    name: %[4]s
+    annotations:
+      eks.amazonaws.com/role-arn: %[5]s
+`
+
+// veleroBucketName returns the bucket Velero keeps the backups of a cluster in
+func veleroBucketName(clusterName, accountID, region string) string {
+	return sandboxBucketName("est-velero-", clusterName, accountID, region)
+}
+
+// prepareVelero creates the backup bucket and a role that can read and write it
+func prepareVelero(ctx context.Context, region, clusterName string) (string, error) {
+	accountID, _, err := GetAWSAccountDetails(ctx, region)
+	if err != nil {
+		return "", err
+	}
+	bucket := veleroBucketName(clusterName, accountID, region)
+	if err := createBucket(ctx, region, bucket); err != nil {
+		return "", err
+	}
+
+	req := irsaRequest{
+		Region:         region,
+		Cluster:        clusterName,
+		Namespace:      veleroNamespace,
+		ServiceAccount: veleroServiceAccount,
+		RoleName:       irsaRoleName(clusterName, veleroNamespace, veleroServiceAccount),
+	}
+	roleArn, err := CreateIRSARole(ctx, req)
+	if err != nil {
+		return "", err
+	}
+	err = putRolePolicy(ctx, region, req.RoleName, veleroPolicyName, []map[string]interface{}{
+		{
+			"Effect":   "Allow",
+			"Action":   []string{"s3:ListBucket"},
+			"Resource": "arn:aws:s3:::" + bucket,
+		},
+		{
+			"Effect":   "Allow",
+			"Action":   []string{"s3:GetObject", "s3:PutObject", "s3:DeleteObject", "s3:AbortMultipartUpload", "s3:ListMultipartUploadParts"},
+			"Resource": "arn:aws:s3:::" + bucket + "/*",
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	return roleArn, nil
+}
+
+// installVelero installs Velero with helm, storing backups in the bucket from prepareVelero
+func installVelero(ctx context.Context, region, clusterName, roleArn string) error {
+	if _, err := exec.LookPath("helm"); err != nil {
+		return fmt.Errorf("helm is not installed: %w", err)
+	}
+	accountID, _, err := GetAWSAccountDetails(ctx, region)
+	if err != nil {
+		return err
+	}
+	bucket := veleroBucketName(clusterName, accountID, region)
+	kube, err := newKubectlSession(ctx, region, clusterName)
+	if err != nil {
+		return err
+	}
+	defer kube.Close()
+
+	values := filepath.Join(kube.dir, "velero-values.yaml")
+	content := fmt.Sprintf(veleroValues, veleroAWSPluginImage, bucket, region, veleroServiceAccount, roleArn)
+	if err := os.WriteFile(values, []byte(content), 0600); err != nil {
+		return fmt.Errorf("unable to write Velero values: %w", err)
+	}
+	debugf("installing Velero chart %s with helm", veleroVersion)
+	err = kube.Helm(ctx, "upgrade", "--install", "velero", "velero", "--repo", veleroChartRepo, "--version", veleroVersion,
+		"--namespace", veleroNamespace, "--create-namespace", "-f", values)
+	if err != nil {
+		return err
+	}
+	infof("Back up a namespace with: velero backup create <name> --include-namespaces <namespace>, then restore it with: velero restore create --from-backup <name>")
+	return nil
+}
+
+// cleanupVelero deletes the Velero role and the backup bucket with the backups in it. They are
+// backups of the deleted sandbox, so there is nothing left to restore them to.
+func cleanupVelero(ctx context.Context, region, clusterName string) error {
+	roleName := irsaRoleName(clusterName, veleroNamespace, veleroServiceAccount)
+	found, err := deleteAddonRole(ctx, region, roleName, veleroPolicyName)
+	if err != nil || !found {
+		// Without the role the addon was never installed on this cluster
+		return err
+	}
+
+	accountID, _, err := GetAWSAccountDetails(ctx, region)
+	if err != nil {
+		return err
+	}
+	bucket := veleroBucketName(clusterName, accountID, region)
+	if _, err := runAWSCLI(ctx, region, "s3", "rb", "s3://"+bucket, "--force"); err != nil {
+		if strings.Contains(err.Error(), "NoSuchBucket") {
+			return nil
+		}
+		return fmt.Errorf("unable to delete bucket %s: %w", bucket, err)
+	}
+	successf("Deleted bucket %s and the backups in it", bucket)
+	return nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Lifecycle events delivered to webhooks
+const (
+	eventClusterCreated = "cluster-created"
+	eventClusterDeleted = "cluster-deleted"
+	eventFailure        = "failure"
+)
+
+// webhookEvent is the JSON payload posted to every registered webhook URL
+type webhookEvent struct {
+	Event     string            `json:"event"`
+	Cluster   string            `json:"cluster,omitempty"`
+	Region    string            `json:"region,omitempty"`
+	Timestamp time.Time         `json:"timestamp"`
+	Error     string            `json:"error,omitempty"`
+	Details   map[string]string `json:"details,omitempty"`
+}
+
+// webhookNotifier posts lifecycle events to the configured URLs. Cluster and Region are
+// filled in as the run learns them so failure events carry as much context as possible.
+type webhookNotifier struct {
+	URLs    []string
+	Cluster string
+	Region  string
+	client  *http.Client
+}
+
+// newWebhookNotifier combines URLs given on the command line with the comma separated EST_WEBHOOKS variable
+func newWebhookNotifier(urls []string) *webhookNotifier {
+	for _, u := range strings.Split(os.Getenv("EST_WEBHOOKS"), ",") {
+		if u = strings.TrimSpace(u); u != "" {
+			urls = append(urls, u)
+		}
+	}
+	return &webhookNotifier{
+		URLs:   urls,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Notify delivers an event to every webhook. Delivery problems are reported but never fail the run.
+func (n *webhookNotifier) Notify(event string, details map[string]string, eventErr error) {
+	if n == nil || len(n.URLs) == 0 {
+		return
+	}
+
+	payload := webhookEvent{
+		Event:     event,
+		Cluster:   n.Cluster,
+		Region:    n.Region,
+		Timestamp: time.Now().UTC(),
+		Details:   details,
+	}
+	if eventErr != nil {
+		payload.Error = eventErr.Error()
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		warnf("unable to encode webhook payload: %v", err)
+		return
+	}
+
+	for _, url := range n.URLs {
+		if err := n.post(url, body); err != nil {
+			warnf("webhook %s failed for event %s: %v", url, event, err)
+			continue
+		}
+		debugf("delivered %s event to webhook %s", event, url)
+	}
+}
+
+// post sends the payload to a single URL, retrying a few times on network errors and 5xx responses
+func (n *webhookNotifier) post(url string, body []byte) error {
+	var lastErr error
+	for attempt := 1; attempt <= 3; attempt++ {
+		if attempt > 1 {
+			time.Sleep(time.Duration(attempt) * time.Second)
+		}
+
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", "est-webhook")
+
+		resp, err := n.client.Do(req)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 500 {
+			lastErr = fmt.Errorf("server responded with %s", resp.Status)
+			continue
+		}
+		if resp.StatusCode >= 300 {
+			return fmt.Errorf("server responded with %s", resp.Status)
+		}
+		return nil
+	}
+	return lastErr
+}
@@ -0,0 +1,594 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"maps"
+	"os"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	eks "github.com/aws/aws-sdk-go-v2/service/eks"
+	"github.com/aws/aws-sdk-go-v2/service/eks/types"
+)
+
+// workshopEventTag names the workshop an attendee cluster or access entry was created for
+const workshopEventTag = "Workshop"
+
+// workshopHostTag names the workshop whose attendee namespaces a cluster hosts. A cluster hosts
+// one workshop at a time.
+const workshopHostTag = "WorkshopHost"
+
+// workshopEvent is the workshop the cluster being created is for, recorded in its Workshop tag;
+// empty outside of `est workshop --per-attendee`
+var workshopEvent string
+
+// workshopEventPattern matches event names, which end up in cluster names and label values
+var workshopEventPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,38}[a-z0-9])?$`)
+
+// workshopAttendee is one line of the attendee list
+type workshopAttendee struct {
+	Name string
+	// Namespace is the attendee's namespace, derived from Name
+	Namespace string
+	// PrincipalArn is the attendee's IAM user or role, optional
+	PrincipalArn string
+}
+
+// Slug is the attendee's name as it appears in their namespace, cluster and kubeconfig names
+func (a workshopAttendee) Slug() string {
+	return strings.TrimPrefix(a.Namespace, "ws-")
+}
+
+// Group is the Kubernetes group the attendee's RoleBinding grants edit rights to
+func (a workshopAttendee) Group() string {
+	return "workshop:" + a.Namespace
+}
+
+// workshopQuota is the share of the cluster each attendee namespace gets
+type workshopQuota struct {
+	CPU    string
+	Memory string
+	Pods   int
+}
+
+var workshopTemplate = template.Must(template.New("workshop").Parse(`---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: {{ .Attendee.Namespace }}
+  labels:
+    est/workshop: "{{ .Event }}"
+    owner: {{ .Attendee.Namespace }}
+---
+apiVersion: v1
+kind: ResourceQuota
+metadata:
+  name: workshop
+  namespace: {{ .Attendee.Namespace }}
+spec:
+  hard:
+    requests.cpu: "{{ .Quota.CPU }}"
+    requests.memory: {{ .Quota.Memory }}
+    limits.cpu: "{{ .Quota.CPU }}"
+    limits.memory: {{ .Quota.Memory }}
+    pods: "{{ .Quota.Pods }}"
+    services.loadbalancers: "1"
+    persistentvolumeclaims: "5"
+---
+apiVersion: v1
+kind: LimitRange
+metadata:
+  name: workshop
+  namespace: {{ .Attendee.Namespace }}
+spec:
+  limits:
+  - type: Container
+    defaultRequest:
+      cpu: 100m
+      memory: 128Mi
+    default:
+      cpu: 500m
+      memory: 512Mi
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: RoleBinding
+metadata:
+  name: workshop-edit
+  namespace: {{ .Attendee.Namespace }}
+subjects:
+- kind: Group
+  apiGroup: rbac.authorization.k8s.io
+  name: {{ .Attendee.Group }}
+roleRef:
+  kind: ClusterRole
+  apiGroup: rbac.authorization.k8s.io
+  name: edit
+`))
+
+// runWorkshop implements `est workshop`
+func runWorkshop(ctx context.Context, args []string) error {
+	if err := requireWritable("est workshop"); err != nil {
+		return err
+	}
+	if len(args) > 0 && args[0] == "teardown" {
+		return runWorkshopTeardown(ctx, args[1:])
+	}
+	usage := validationErr("usage: est workshop --attendees <file> (--cluster <name> | --per-attendee) [--event <name>] [--bundles <dir>] [--zip] [--cpu <cores>] [--memory <quantity>] [--pods <n>] [--region <region>]")
+
+	var region, clusterName, attendeesFile, event string
+	var perAttendee bool
+	var bundles workshopBundles
+	quota := workshopQuota{}
+	fs := flag.NewFlagSet("workshop", flag.ContinueOnError)
+	fs.StringVar(&clusterName, "cluster", "", "Name of the EKS cluster the attendee namespaces are created on")
+	fs.BoolVar(&perAttendee, "per-attendee", false, "Create a cluster for every attendee instead of a namespace on --cluster")
+	fs.StringVar(&attendeesFile, "attendees", "", "File with one attendee per line: <name>[,<IAM user or role ARN>]")
+	fs.StringVar(&event, "event", "", "Name of the workshop, which the teardown finds its resources by (default derived from --cluster)")
+	fs.StringVar(&bundles.Dir, "bundles", "", "Directory the attendee kubeconfig bundles are written to (default workshop-<event>)")
+	fs.BoolVar(&bundles.Zip, "zip", false, "Pack every attendee bundle into a zip file to hand out")
+	fs.StringVar(&quota.CPU, "cpu", "2", "CPU each attendee namespace may request and use")
+	fs.StringVar(&quota.Memory, "memory", "4Gi", "Memory each attendee namespace may request and use")
+	fs.IntVar(&quota.Pods, "pods", 20, "Pods each attendee namespace may run")
+	fs.StringVar(&region, "region", firstNonEmpty(os.Getenv("EST_REGION"), os.Getenv("AWS_REGION")), "Region of the cluster")
+	if err := fs.Parse(args); err != nil {
+		return usage
+	}
+	if attendeesFile == "" || (clusterName == "") == !perAttendee {
+		return usage
+	}
+	if region == "" {
+		return validationErr("no region given: pass --region or set EST_REGION")
+	}
+	if quota.Pods < 1 {
+		return validationErr("--pods must be at least 1")
+	}
+	if event == "" {
+		if perAttendee {
+			return validationErr("--per-attendee needs an --event name for the attendee clusters")
+		}
+		event = strings.Trim(invalidNamespaceChars.ReplaceAllString(strings.ToLower(clusterName), "-"), "-")
+	}
+	if !workshopEventPattern.MatchString(event) {
+		return validationErr("event name %q must be at most 40 lowercase letters, digits and hyphens", event)
+	}
+	if bundles.Dir == "" {
+		bundles.Dir = "workshop-" + event
+	}
+	notifier.Cluster, notifier.Region = clusterName, region
+
+	attendees, err := readAttendees(attendeesFile)
+	if err != nil {
+		return err
+	}
+	if perAttendee {
+		return provisionAttendeeClusters(ctx, region, event, bundles, attendees)
+	}
+
+	stepf("Creating namespaces for %d attendees...", len(attendees))
+	var manifest strings.Builder
+	for _, attendee := range attendees {
+		err := workshopTemplate.Execute(&manifest, map[string]interface{}{"Attendee": attendee, "Quota": quota, "Event": event})
+		if err != nil {
+			return fmt.Errorf("unable to render workshop manifest: %w", err)
+		}
+	}
+	if err := TagWorkshopHost(ctx, region, clusterName, event); err != nil {
+		return wrapErr("tagging cluster", err)
+	}
+	kube, err := newKubectlSession(ctx, region, clusterName)
+	if err != nil {
+		return wrapErr("connecting to cluster", err)
+	}
+	defer kube.Close()
+	if err := kube.Apply(ctx, manifest.String()); err != nil {
+		return wrapErr("creating attendee namespaces", err)
+	}
+	successf("Created %d namespaces with quotas of %s CPU, %s memory and %d pods", len(attendees), quota.CPU, quota.Memory, quota.Pods)
+
+	conn, err := WaitForClusterConnection(ctx, region, clusterName, 30*time.Minute)
+	if err != nil {
+		return wrapErr("reading cluster endpoint", err)
+	}
+	var failed []error
+	for _, attendee := range attendees {
+		if attendee.PrincipalArn == "" {
+			infof("%s has no IAM principal; map one to group %s to give them access to %s", attendee.Name, attendee.Group(), attendee.Namespace)
+			continue
+		}
+		if err := GrantWorkshopAccess(ctx, region, clusterName, event, attendee); err != nil {
+			errorf("Unable to give %s access: %v", attendee.Name, err)
+			failed = append(failed, err)
+			continue
+		}
+		conn.Namespace = attendee.Namespace
+		path, err := bundles.Write(event, conn, attendee)
+		if err != nil {
+			errorf("Unable to write the bundle of %s: %v", attendee.Name, err)
+			failed = append(failed, err)
+			continue
+		}
+		successf("%s (%s) can use namespace %s with %s", attendee.Name, attendee.PrincipalArn, attendee.Namespace, path)
+	}
+	infof("Tear the workshop down with: est workshop teardown --event %s --region %s", event, region)
+	if len(failed) > 0 {
+		return partialErr("granting attendee access", errors.Join(failed...))
+	}
+	return nil
+}
+
+// provisionAttendeeClusters creates a cluster named <event>-<attendee> for every attendee, one after
+// another, with the defaults of every create question not answered through the environment or
+// --answers. Unless told otherwise the clusters join the shared VPC, as a workshop's worth of
+// isolated VPCs would exceed the VPC quota of the region.
+func provisionAttendeeClusters(ctx context.Context, region, event string, bundles workshopBundles, attendees []workshopAttendee) error {
+	if os.Getenv("EST_CLUSTER_NAME") != "" {
+		return validationErr("EST_CLUSTER_NAME cannot be set with --per-attendee, the clusters are named after the attendees")
+	}
+	// Put the globals back once the attendees have their clusters, so later creates in this
+	// process are not tagged for the workshop or answered with its presets
+	savedDefaults, savedPool, savedEvent, savedPresets := acceptDefaults, poolName, workshopEvent, maps.Clone(presetAnswers)
+	defer func() {
+		acceptDefaults, poolName, workshopEvent, presetAnswers = savedDefaults, savedPool, savedEvent, savedPresets
+	}()
+	acceptDefaults = true
+	workshopEvent = event
+	presetAnswers[answerKey("EST_REGION")] = region
+	if _, ok := presetAnswers[answerKey("EST_VPC")]; !ok && os.Getenv("EST_VPC") == "" {
+		presetAnswers[answerKey("EST_VPC")] = hostingVPCShared
+	}
+
+	var failed []error
+	for i, attendee := range attendees {
+		stepf("Creating the cluster of %s (%d of %d)...", attendee.Name, i+1, len(attendees))
+		presetAnswers[answerKey("EST_CLUSTER_NAME")] = event + "-" + attendee.Slug()
+		answers, err := collectCreateAnswers(ctx, true)
+		if err != nil {
+			return err
+		}
+		if err := provisionCluster(ctx, newStepTimer(), answers); err != nil {
+			errorf("Unable to create the cluster of %s: %v", attendee.Name, err)
+			failed = append(failed, err)
+			continue
+		}
+		if attendee.PrincipalArn == "" {
+			infof("%s has no IAM principal; give them access to %s with est share", attendee.Name, answers.ClusterName)
+			continue
+		}
+		if err := GrantWorkshopClusterAccess(ctx, region, answers.ClusterName, event, attendee); err != nil {
+			errorf("Unable to give %s access: %v", attendee.Name, err)
+			failed = append(failed, err)
+			continue
+		}
+		conn, err := WaitForClusterConnection(ctx, region, answers.ClusterName, 30*time.Minute)
+		if err == nil {
+			var path string
+			if path, err = bundles.Write(event, conn, attendee); err == nil {
+				successf("%s (%s) is admin of %s with %s", attendee.Name, attendee.PrincipalArn, answers.ClusterName, path)
+			}
+		}
+		if err != nil {
+			errorf("Unable to write the bundle of %s: %v", attendee.Name, err)
+			failed = append(failed, err)
+		}
+	}
+	infof("Tear the workshop down with: est workshop teardown --event %s --region %s", event, region)
+	if len(failed) > 0 {
+		return partialErr("creating attendee clusters", errors.Join(failed...))
+	}
+	return nil
+}
+
+// runWorkshopTeardown implements `est workshop teardown`, which removes everything a workshop
+// created: the attendee clusters, and the attendee namespaces and access entries on the cluster
+// that hosted them
+func runWorkshopTeardown(ctx context.Context, args []string) error {
+	usage := validationErr("usage: est workshop teardown --event <name> [--region <region>]")
+
+	var region, event string
+	fs := flag.NewFlagSet("workshop teardown", flag.ContinueOnError)
+	fs.StringVar(&event, "event", "", "Name of the workshop to tear down")
+	fs.StringVar(&region, "region", firstNonEmpty(os.Getenv("EST_REGION"), os.Getenv("AWS_REGION")), "Region of the workshop")
+	if err := fs.Parse(args); err != nil || fs.NArg() > 0 {
+		return usage
+	}
+	if event == "" {
+		return usage
+	}
+	if region == "" {
+		return validationErr("no region given: pass --region or set EST_REGION")
+	}
+	notifier.Region = region
+
+	stepf("Looking for the clusters of workshop %s in %s...", event, region)
+	attendeeClusters, hosts, err := FindWorkshopClusters(ctx, region, event)
+	if err != nil {
+		return wrapErr("finding workshop clusters", err)
+	}
+	if len(attendeeClusters) == 0 && len(hosts) == 0 {
+		infof("Nothing of workshop %s found in %s", event, region)
+		return nil
+	}
+	for _, host := range hosts {
+		infof("%s hosts the attendee namespaces", host)
+	}
+	for name := range attendeeClusters {
+		infof("%s is an attendee cluster", name)
+	}
+	var confirm bool
+	confirmPrompt := &survey.Confirm{
+		Message: fmt.Sprintf("Delete %d attendee clusters and the attendee namespaces of %d clusters?", len(attendeeClusters), len(hosts)),
+	}
+	if err := askOne("EST_CONFIRM_TEARDOWN", confirmPrompt, &confirm); err != nil {
+		return wrapErr("reading confirmation", err)
+	}
+	if !confirm {
+		warnf("Workshop teardown aborted.")
+		return nil
+	}
+
+	var failed []error
+	for _, host := range hosts {
+		stepf("Removing the attendees of %s from %s...", event, host)
+		if err := TeardownWorkshopNamespaces(ctx, region, host, event); err != nil {
+			errorf("Unable to remove the attendees from %s: %v", host, err)
+			failed = append(failed, err)
+			continue
+		}
+		successf("Removed the attendee namespaces and access entries from %s", host)
+	}
+	for name, tags := range attendeeClusters {
+		stepf("Deleting attendee cluster %s...", name)
+		notifier.Cluster = name
+		if err := sweepCluster(ctx, region, name, tags); err != nil {
+			errorf("Unable to delete %s: %v", name, err)
+			failed = append(failed, err)
+			continue
+		}
+		successf("Deleted %s", name)
+	}
+	if len(failed) > 0 {
+		return partialErr("tearing down workshop "+event, errors.Join(failed...))
+	}
+	successf("Workshop %s is torn down", event)
+	return nil
+}
+
+// invalidNamespaceChars matches what cannot appear in a namespace name
+var invalidNamespaceChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// readAttendees parses the attendee list. Blank lines and lines starting with # are skipped.
+func readAttendees(path string) ([]workshopAttendee, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, validationErr("unable to read attendee list: %v", err)
+	}
+	defer f.Close()
+
+	var attendees []workshopAttendee
+	seen := map[string]string{}
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		name, arn, _ := strings.Cut(text, ",")
+		name, arn = strings.TrimSpace(name), strings.TrimSpace(arn)
+		namespace := strings.Trim(invalidNamespaceChars.ReplaceAllString(strings.ToLower(name), "-"), "-")
+		if namespace == "" {
+			return nil, validationErr("%s line %d: attendee name %q has no usable characters", path, line, name)
+		}
+		namespace = "ws-" + namespace
+		if len(namespace) > 63 {
+			namespace = strings.TrimRight(namespace[:63], "-")
+		}
+		if other, ok := seen[namespace]; ok {
+			return nil, validationErr("%s line %d: %s and %s would share namespace %s", path, line, other, name, namespace)
+		}
+		if arn != "" && !strings.HasPrefix(arn, "arn:") {
+			return nil, validationErr("%s line %d: %q is not an IAM user or role ARN", path, line, arn)
+		}
+		seen[namespace] = name
+		attendees = append(attendees, workshopAttendee{Name: name, Namespace: namespace, PrincipalArn: arn})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, validationErr("unable to read attendee list: %v", err)
+	}
+	if len(attendees) == 0 {
+		return nil, validationErr("attendee list %s is empty", path)
+	}
+	return attendees, nil
+}
+
+// GrantWorkshopAccess creates an EKS access entry that puts the attendee's IAM principal into the
+// Kubernetes group bound to their namespace. An existing entry of the principal is updated. The
+// entry is tagged with the event so that the teardown finds it.
+func GrantWorkshopAccess(ctx context.Context, region, clusterName, event string, attendee workshopAttendee) error {
+	cfg, err := loadAWSConfig(ctx, region)
+	if err != nil {
+		return fmt.Errorf("unable to load AWS configuration: %w", err)
+	}
+	client := eks.NewFromConfig(cfg)
+
+	_, err = client.CreateAccessEntry(ctx, &eks.CreateAccessEntryInput{
+		ClusterName:      aws.String(clusterName),
+		PrincipalArn:     aws.String(attendee.PrincipalArn),
+		KubernetesGroups: []string{attendee.Group()},
+		Tags:             map[string]string{"CreatedBy": "EKS-Sandbox-Tool", workshopEventTag: event},
+	})
+	var inUse *types.ResourceInUseException
+	if errors.As(err, &inUse) {
+		var output *eks.UpdateAccessEntryOutput
+		output, err = client.UpdateAccessEntry(ctx, &eks.UpdateAccessEntryInput{
+			ClusterName:      aws.String(clusterName),
+			PrincipalArn:     aws.String(attendee.PrincipalArn),
+			KubernetesGroups: []string{attendee.Group()},
+		})
+		if err == nil {
+			_, err = client.TagResource(ctx, &eks.TagResourceInput{
+				ResourceArn: output.AccessEntry.AccessEntryArn,
+				Tags:        map[string]string{workshopEventTag: event},
+			})
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create access entry for %s: %w", attendee.PrincipalArn, err)
+	}
+	return nil
+}
+
+// GrantWorkshopClusterAccess makes the attendee's IAM principal admin of their own cluster through
+// an access entry tagged with the event
+func GrantWorkshopClusterAccess(ctx context.Context, region, clusterName, event string, attendee workshopAttendee) error {
+	cfg, err := loadAWSConfig(ctx, region)
+	if err != nil {
+		return fmt.Errorf("unable to load AWS configuration: %w", err)
+	}
+	return grantClusterAdmin(ctx, eks.NewFromConfig(cfg), clusterName, attendee.PrincipalArn,
+		map[string]string{"CreatedBy": "EKS-Sandbox-Tool", workshopEventTag: event})
+}
+
+// grantClusterAdmin creates an access entry with the given tags for a principal, or keeps the one
+// it has, and associates the cluster admin access policy with it
+func grantClusterAdmin(ctx context.Context, client *eks.Client, clusterName, principalArn string, tags map[string]string) error {
+	_, err := client.CreateAccessEntry(ctx, &eks.CreateAccessEntryInput{
+		ClusterName:  aws.String(clusterName),
+		PrincipalArn: aws.String(principalArn),
+		Tags:         tags,
+	})
+	var inUse *types.ResourceInUseException
+	if err != nil && !errors.As(err, &inUse) {
+		return fmt.Errorf("failed to create access entry for %s: %w", principalArn, err)
+	}
+	partition := strings.SplitN(principalArn, ":", 3)[1]
+	_, err = client.AssociateAccessPolicy(ctx, &eks.AssociateAccessPolicyInput{
+		ClusterName:  aws.String(clusterName),
+		PrincipalArn: aws.String(principalArn),
+		PolicyArn:    aws.String(fmt.Sprintf("arn:%s:eks::aws:cluster-access-policy/%s", partition, shareAccessPolicies["admin"])),
+		AccessScope:  &types.AccessScope{Type: types.AccessScopeTypeCluster},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to associate %s with %s: %w", shareAccessPolicies["admin"], principalArn, err)
+	}
+	return nil
+}
+
+// TagWorkshopHost records on a cluster that it hosts the attendee namespaces of event
+func TagWorkshopHost(ctx context.Context, region, clusterName, event string) error {
+	cfg, err := loadAWSConfig(ctx, region)
+	if err != nil {
+		return fmt.Errorf("unable to load AWS configuration: %w", err)
+	}
+	client := eks.NewFromConfig(cfg)
+	output, err := client.DescribeCluster(ctx, &eks.DescribeClusterInput{Name: aws.String(clusterName)})
+	if err != nil {
+		return fmt.Errorf("failed to describe EKS cluster %s: %w", clusterName, err)
+	}
+	if other := output.Cluster.Tags[workshopHostTag]; other != "" && other != event {
+		return validationErr("%s already hosts workshop %s; tear it down first with est workshop teardown --event %s", clusterName, other, other)
+	}
+	_, err = client.TagResource(ctx, &eks.TagResourceInput{
+		ResourceArn: output.Cluster.Arn,
+		Tags:        map[string]string{workshopHostTag: event},
+	})
+	if err != nil {
+		return fmt.Errorf("unable to tag %s: %w", clusterName, err)
+	}
+	return nil
+}
+
+// FindWorkshopClusters returns the attendee clusters of a workshop with their tags, and the
+// clusters hosting its attendee namespaces
+func FindWorkshopClusters(ctx context.Context, region, event string) (map[string]map[string]string, []string, error) {
+	cfg, err := loadAWSConfig(ctx, region)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to load AWS configuration: %w", err)
+	}
+	client := eks.NewFromConfig(cfg)
+	names, err := ListEKSClusters(ctx, region)
+	if err != nil {
+		return nil, nil, err
+	}
+	attendeeClusters := map[string]map[string]string{}
+	var hosts []string
+	for _, name := range names {
+		output, err := client.DescribeCluster(ctx, &eks.DescribeClusterInput{Name: aws.String(name)})
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to describe EKS cluster %s: %w", name, err)
+		}
+		tags := output.Cluster.Tags
+		if tags[workshopEventTag] == event && tags["CreatedBy"] == "EKS-Sandbox-Tool" {
+			attendeeClusters[name] = tags
+		}
+		if tags[workshopHostTag] == event {
+			hosts = append(hosts, name)
+		}
+	}
+	return attendeeClusters, hosts, nil
+}
+
+// TeardownWorkshopNamespaces deletes the attendee namespaces and access entries of a workshop from
+// the cluster that hosted them, leaving the cluster itself
+func TeardownWorkshopNamespaces(ctx context.Context, region, clusterName, event string) error {
+	kube, err := newKubectlSession(ctx, region, clusterName)
+	if err != nil {
+		return fmt.Errorf("unable to connect to %s: %w", clusterName, err)
+	}
+	defer kube.Close()
+	if _, err := kube.Run(ctx, "delete", "namespace", "--selector", "est/workshop="+event, "--wait=false"); err != nil {
+		return fmt.Errorf("unable to delete the attendee namespaces: %w", err)
+	}
+
+	cfg, err := loadAWSConfig(ctx, region)
+	if err != nil {
+		return fmt.Errorf("unable to load AWS configuration: %w", err)
+	}
+	client := eks.NewFromConfig(cfg)
+	paginator := eks.NewListAccessEntriesPaginator(client, &eks.ListAccessEntriesInput{ClusterName: aws.String(clusterName)})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return fmt.Errorf("unable to list the access entries of %s: %w", clusterName, err)
+		}
+		for _, principalArn := range page.AccessEntries {
+			entry, err := client.DescribeAccessEntry(ctx, &eks.DescribeAccessEntryInput{
+				ClusterName:  aws.String(clusterName),
+				PrincipalArn: aws.String(principalArn),
+			})
+			if err != nil {
+				return fmt.Errorf("unable to describe the access entry of %s: %w", principalArn, err)
+			}
+			if entry.AccessEntry.Tags[workshopEventTag] != event {
+				continue
+			}
+			_, err = client.DeleteAccessEntry(ctx, &eks.DeleteAccessEntryInput{
+				ClusterName:  aws.String(clusterName),
+				PrincipalArn: aws.String(principalArn),
+			})
+			if err != nil {
+				return fmt.Errorf("failed to delete the access entry of %s: %w", principalArn, err)
+			}
+		}
+	}
+
+	output, err := client.DescribeCluster(ctx, &eks.DescribeClusterInput{Name: aws.String(clusterName)})
+	if err != nil {
+		return fmt.Errorf("failed to describe EKS cluster %s: %w", clusterName, err)
+	}
+	_, err = client.UntagResource(ctx, &eks.UntagResourceInput{
+		ResourceArn: output.Cluster.Arn,
+		TagKeys:     []string{workshopHostTag},
+	})
+	if err != nil {
+		return fmt.Errorf("unable to untag %s: %w", clusterName, err)
+	}
+	return nil
+}
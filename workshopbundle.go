@@ -0,0 +1,103 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// workshopBundles is where the bundles handed out to attendees are written. A bundle holds a
+// kubeconfig and a README telling the attendee how to use it.
+type workshopBundles struct {
+	Dir string
+	// Zip packs every bundle into <attendee>.zip instead of a directory
+	Zip bool
+}
+
+var workshopReadmeTemplate = template.Must(template.New("readme").Parse(`{{ .Attendee.Name }}, welcome to {{ .Event }}!
+
+The kubeconfig in this bundle signs you in to the EKS cluster {{ .Conn.Name }} in {{ .Conn.Region }}
+as {{ .Attendee.PrincipalArn }}.
+{{- if .Conn.RoleArn }}
+It assumes that role with your own AWS credentials, so they must be allowed to assume it.
+{{- else }}
+Sign in to AWS as that IAM user first, e.g. with aws configure or AWS_PROFILE.
+{{- end }}
+{{- if .Conn.Namespace }}
+You can use the namespace {{ .Conn.Namespace }}, which kubectl uses by default, and nothing else.
+{{- else }}
+You are admin of the whole cluster.
+{{- end }}
+
+You need kubectl and the aws CLI. Then run:
+
+  export KUBECONFIG=$PWD/kubeconfig
+  kubectl get pods
+`))
+
+// Write writes the bundle of an attendee and returns its path. Its kubeconfig fetches tokens with
+// the attendee's own AWS credentials rather than the organiser's profile, assuming the attendee's
+// role when their principal is one, and on a shared cluster defaults to their namespace.
+func (b workshopBundles) Write(event string, conn clusterConnection, attendee workshopAttendee) (string, error) {
+	conn.Profile = ""
+	conn.ContextName = event + "-" + attendee.Slug()
+	if strings.Contains(attendee.PrincipalArn, ":role/") {
+		conn.RoleArn = attendee.PrincipalArn
+	}
+
+	var kubeconfig, readme bytes.Buffer
+	if err := kubeconfigTemplate.Execute(&kubeconfig, conn); err != nil {
+		return "", fmt.Errorf("unable to render kubeconfig: %w", err)
+	}
+	err := workshopReadmeTemplate.Execute(&readme, map[string]interface{}{"Attendee": attendee, "Event": event, "Conn": conn})
+	if err != nil {
+		return "", fmt.Errorf("unable to render README: %w", err)
+	}
+	files := []struct {
+		name string
+		data []byte
+	}{
+		{"kubeconfig", kubeconfig.Bytes()},
+		{"README.txt", readme.Bytes()},
+	}
+
+	if err := os.MkdirAll(b.Dir, 0700); err != nil {
+		return "", fmt.Errorf("unable to create bundle directory: %w", err)
+	}
+	if !b.Zip {
+		dir := filepath.Join(b.Dir, attendee.Slug())
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return "", fmt.Errorf("unable to create bundle directory: %w", err)
+		}
+		for _, file := range files {
+			if err := os.WriteFile(filepath.Join(dir, file.name), file.data, 0600); err != nil {
+				return "", fmt.Errorf("unable to write bundle %s: %w", dir, err)
+			}
+		}
+		return dir, nil
+	}
+
+	path := filepath.Join(b.Dir, attendee.Slug()+".zip")
+	var archive bytes.Buffer
+	w := zip.NewWriter(&archive)
+	for _, file := range files {
+		f, err := w.Create(attendee.Slug() + "/" + file.name)
+		if err == nil {
+			_, err = f.Write(file.data)
+		}
+		if err != nil {
+			return "", fmt.Errorf("unable to pack bundle %s: %w", path, err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		return "", fmt.Errorf("unable to pack bundle %s: %w", path, err)
+	}
+	if err := os.WriteFile(path, archive.Bytes(), 0600); err != nil {
+		return "", fmt.Errorf("unable to write bundle %s: %w", path, err)
+	}
+	return path, nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	eks "github.com/aws/aws-sdk-go-v2/service/eks"
+	"github.com/aws/aws-sdk-go-v2/service/eks/types"
+)
+
+// runZonalShift implements `est zonal-shift`
+func runZonalShift(ctx context.Context, args []string) error {
+	if err := requireWritable("est zonal-shift"); err != nil {
+		return err
+	}
+	usage := validationErr("usage: est zonal-shift --cluster <name> (--enable | --disable) [--region <region>]")
+
+	var region, clusterName string
+	var enable, disable bool
+	fs := flag.NewFlagSet("zonal-shift", flag.ContinueOnError)
+	fs.StringVar(&clusterName, "cluster", "", "Name of the EKS cluster")
+	fs.BoolVar(&enable, "enable", false, "Enable ARC zonal shift")
+	fs.BoolVar(&disable, "disable", false, "Disable ARC zonal shift")
+	fs.StringVar(&region, "region", firstNonEmpty(os.Getenv("EST_REGION"), os.Getenv("AWS_REGION")), "Region of the cluster")
+	if err := fs.Parse(args); err != nil {
+		return usage
+	}
+	if clusterName == "" || enable == disable {
+		return usage
+	}
+	if region == "" {
+		return validationErr("no region given: pass --region or set EST_REGION")
+	}
+	notifier.Cluster, notifier.Region = clusterName, region
+
+	state := "Disabling"
+	if enable {
+		state = "Enabling"
+	}
+	stepf("%s zonal shift on %s...", state, clusterName)
+	if err := UpdateZonalShift(ctx, region, clusterName, enable); err != nil {
+		return wrapErr("updating zonal shift", err)
+	}
+	if enable {
+		successf("Zonal shift is enabled on %s", clusterName)
+		infof("Start a shift with: aws arc-zonal-shift start-zonal-shift --resource-identifier <cluster ARN> --away-from <zone ID> --expires-in 1h --comment test")
+	} else {
+		successf("Zonal shift is disabled on %s", clusterName)
+	}
+	return nil
+}
+
+// UpdateZonalShift turns ARC zonal shift on or off and waits until the cluster update completes
+func UpdateZonalShift(ctx context.Context, region, clusterName string, enabled bool) error {
+	cfg, err := loadAWSConfig(ctx, region)
+	if err != nil {
+		return fmt.Errorf("unable to load AWS configuration: %w", err)
+	}
+	client := eks.NewFromConfig(cfg)
+
+	cluster, err := client.DescribeCluster(ctx, &eks.DescribeClusterInput{Name: aws.String(clusterName)})
+	if err != nil {
+		return fmt.Errorf("failed to describe EKS cluster %s: %w", clusterName, err)
+	}
+	if current := cluster.Cluster.ZonalShiftConfig; current != nil && aws.ToBool(current.Enabled) == enabled {
+		infof("Zonal shift is already in the requested state on %s", clusterName)
+		return nil
+	}
+
+	output, err := client.UpdateClusterConfig(ctx, &eks.UpdateClusterConfigInput{
+		Name:             aws.String(clusterName),
+		ZonalShiftConfig: &types.ZonalShiftConfigRequest{Enabled: aws.Bool(enabled)},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update cluster %s: %w", clusterName, err)
+	}
+	return waitForEKSUpdate(ctx, client, clusterName, "", aws.ToString(output.Update.Id), "zonal shift update", 15*time.Minute)
+}